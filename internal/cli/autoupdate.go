@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 
 	flags "github.com/jessevdk/go-flags"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
 	"github.com/blairham/go-pre-commit/v4/internal/git"
 	"github.com/blairham/go-pre-commit/v4/internal/output"
 )
@@ -26,6 +28,8 @@ type autoupdateFlags struct {
 	Repo         []string `long:"repo" description:"Only update this repository. May be specified multiple times."`
 	Jobs         int      `short:"j" long:"jobs" default:"1" description:"Number of threads to use."`
 	DryRun       bool     `long:"dry-run" description:"Show what would be updated without writing changes."`
+	NoFreeze     bool     `long:"no-freeze" description:"Keep branch names as rev instead of pinning branch-tracking repos to a SHA."`
+	Changelog    string   `long:"changelog" description:"Write a Markdown list of the version bumps to this file."`
 }
 
 func (c *AutoupdateCommand) Run(args []string) int {
@@ -40,7 +44,7 @@ func (c *AutoupdateCommand) Run(args []string) int {
 
 	cfg, err := config.LoadConfig(opts.Config)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+		printError(err, "failed to load config: %v", err)
 		return 1
 	}
 
@@ -84,21 +88,30 @@ func (c *AutoupdateCommand) Run(args []string) int {
 		tasks = append(tasks, updateTask{repoCfg: repoCfg})
 	}
 
-	// Process updates (parallel if jobs > 1).
+	// Process updates (parallel if jobs > 1). Repos marked no-autoupdate
+	// are reported in order but never cloned.
 	results := make([]updateResult, len(tasks))
+	for i, task := range tasks {
+		if task.repoCfg.NoAutoupdate {
+			results[i] = updateResult{repo: task.repoCfg.Repo, oldRev: task.repoCfg.Rev, skipped: true}
+		}
+	}
 
 	if concurrency > 1 && len(tasks) > 1 {
 		var wg sync.WaitGroup
 		sem := make(chan struct{}, concurrency)
 
 		for i, task := range tasks {
+			if task.repoCfg.NoAutoupdate {
+				continue
+			}
 			wg.Add(1)
 			go func(idx int, t updateTask) {
 				defer wg.Done()
 				sem <- struct{}{}
 				defer func() { <-sem }()
 
-				res := processUpdate(t.repoCfg, opts.BleedingEdge, opts.Freeze)
+				res := processUpdate(t.repoCfg, opts.BleedingEdge, opts.Freeze, opts.NoFreeze, frozenRef(raw, t.repoCfg.Rev), opts.DryRun)
 
 				results[idx] = res
 			}(i, task)
@@ -106,15 +119,23 @@ func (c *AutoupdateCommand) Run(args []string) int {
 		wg.Wait()
 	} else {
 		for i, task := range tasks {
-			results[i] = processUpdate(task.repoCfg, opts.BleedingEdge, opts.Freeze)
+			if task.repoCfg.NoAutoupdate {
+				continue
+			}
+			results[i] = processUpdate(task.repoCfg, opts.BleedingEdge, opts.Freeze, opts.NoFreeze, frozenRef(raw, task.repoCfg.Rev), opts.DryRun)
 		}
 	}
 
 	// Apply results.
+	var bumped []updateResult
 	for _, res := range results {
+		if res.skipped {
+			fmt.Printf("Updating %s ... skipped (marked %q).\n", res.repo, config.NoAutoupdateMarker)
+			continue
+		}
 		if res.err != nil {
 			fmt.Printf("Updating %s ... failed\n", res.repo)
-			output.Warn("Failed to update %s: %v", res.repo, res.err)
+			output.Warn("%s", errkind.Prefix(errkind.Of(res.err), fmt.Sprintf("Failed to update %s: %v", res.repo, res.err)))
 			continue
 		}
 
@@ -124,10 +145,31 @@ func (c *AutoupdateCommand) Run(args []string) int {
 		}
 
 		fmt.Printf("Updating %s ... updating %s -> %s.\n", res.repo, res.oldRev, res.newRev)
+		if res.manifestErr != nil {
+			output.Warn("Could not check %s at %s for removed hooks: %v", res.repo, res.newRev, res.manifestErr)
+		} else if len(res.removedHooks) > 0 {
+			output.Warn("%s at %s no longer provides hook(s) used in your config: %s. Applying this update would break your config.",
+				res.repo, res.newRev, strings.Join(res.removedHooks, ", "))
+		}
 
 		// Use regex to replace rev, handling various quoting styles.
-		raw = replaceRev(raw, res.oldRev, res.newRev, res.commitHash, opts.Freeze)
+		if res.branch != "" {
+			// Branch-tracking repos are pinned to the branch tip SHA with the
+			// branch kept in a "# frozen:" comment so the next autoupdate
+			// knows which branch to follow.
+			raw = replaceRev(raw, res.oldRev, res.branch, res.newRev, true)
+		} else {
+			raw = replaceRev(raw, res.oldRev, res.newRev, res.commitHash, opts.Freeze)
+		}
 		changed = true
+		bumped = append(bumped, res)
+	}
+
+	if opts.Changelog != "" {
+		if err := os.WriteFile(opts.Changelog, []byte(formatChangelog(bumped)), 0o644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write changelog: %v\n", err)
+			return 1
+		}
 	}
 
 	if changed && !opts.DryRun {
@@ -140,19 +182,67 @@ func (c *AutoupdateCommand) Run(args []string) int {
 	return 0
 }
 
+// formatChangelog renders bumped repos as a Markdown list for a PR
+// description, linking a compare view for GitHub and GitLab repos.
+func formatChangelog(bumped []updateResult) string {
+	if len(bumped) == 0 {
+		return "No hook repositories were updated.\n"
+	}
+	var b strings.Builder
+	for _, res := range bumped {
+		fmt.Fprintf(&b, "- Bumped %s from `%s` to `%s`", res.repo, res.oldRev, res.newRev)
+		if url := compareURL(res.repo, res.oldRev, res.newRev); url != "" {
+			fmt.Fprintf(&b, " ([compare](%s))", url)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// compareURL returns the web page comparing oldRev to newRev for a repo
+// hosted on github.com or gitlab.com, or "" for any other host.
+func compareURL(repo, oldRev, newRev string) string {
+	path := strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	for _, host := range []string{"github.com", "gitlab.com"} {
+		var project string
+		for _, prefix := range []string{"https://" + host + "/", "http://" + host + "/", "git@" + host + ":", "ssh://git@" + host + "/"} {
+			if rest, ok := strings.CutPrefix(path, prefix); ok {
+				project = rest
+				break
+			}
+		}
+		if project == "" {
+			continue
+		}
+		if host == "gitlab.com" {
+			return fmt.Sprintf("https://gitlab.com/%s/-/compare/%s...%s", project, oldRev, newRev)
+		}
+		return fmt.Sprintf("https://github.com/%s/compare/%s...%s", project, oldRev, newRev)
+	}
+	return ""
+}
+
 func (c *AutoupdateCommand) Help() string {
 	return strings.TrimSpace(`
 Usage: pre-commit autoupdate [options]
 
   Auto-update pre-commit config to the latest repos' versions.
 
+  A repo entry with a "# pre-commit: no-autoupdate" comment on or above
+  one of its lines is left at its current rev and reported as skipped.
+
 Options:
 
       --bleeding-edge   Update to the bleeding edge of the default branch.
       --freeze          Store the current commit SHA alongside the tag as rev.
       --repo=REPO       Only update this repository (may be repeated).
   -j, --jobs=N          Number of threads to use (default: 1).
-      --dry-run         Show what would be updated without writing changes.
+      --dry-run         Show what would be updated without writing changes,
+                        and warn about hooks used in the config that the
+                        new version's manifest no longer provides.
+      --no-freeze       Keep branch names as rev for branch-tracking repos.
+      --changelog=FILE  Write a Markdown list of the repos bumped, with
+                        compare links for GitHub and GitLab, to FILE.
   -c, --config=FILE     Path to alternate config file.
       --color=MODE      Whether to use color (auto, always, never).
 `)
@@ -167,10 +257,20 @@ type updateResult struct {
 	oldRev     string
 	newRev     string
 	commitHash string
+	branch     string // set when the repo tracks a branch rather than tags
+	skipped    bool   // the repo is marked no-autoupdate
 	err        error
+
+	// removedHooks are the config's hook ids missing from the manifest at
+	// newRev; manifestErr is set if that manifest could not be read.
+	removedHooks []string
+	manifestErr  error
 }
 
-func processUpdate(repoCfg config.RepoConfig, bleeding, freeze bool) updateResult {
+// processUpdate resolves the new rev for a repo. frozen is the value of an
+// existing "# frozen:" comment on the rev line, if any. With checkHooks, the
+// manifest at the new rev is checked for the hook ids the config uses.
+func processUpdate(repoCfg config.RepoConfig, bleeding, freeze, noFreeze bool, frozen string, checkHooks bool) updateResult {
 	res := updateResult{
 		repo:   repoCfg.Repo,
 		oldRev: repoCfg.Rev,
@@ -190,11 +290,42 @@ func processUpdate(repoCfg config.RepoConfig, bleeding, freeze bool) updateResul
 		tmpDir2, _ := os.MkdirTemp("", "pre-commit-autoupdate-*")
 		tmpDir = tmpDir2
 		if err := git.Clone(repoCfg.Repo, tmpDir); err != nil {
-			res.err = fmt.Errorf("failed to clone: %w", err)
+			res.err = errkind.Wrap(errkind.Network, fmt.Errorf("failed to clone: %w", err))
 			return res
 		}
 	}
 
+	resolveNewRev(&res, tmpDir, repoCfg, bleeding, freeze, noFreeze, frozen)
+	if checkHooks && res.err == nil && res.newRev != res.oldRev {
+		res.removedHooks, res.manifestErr = removedHooks(tmpDir, res.newRev, repoCfg.Hooks)
+	}
+	return res
+}
+
+// resolveNewRev fills in the new rev of res from the clone in tmpDir.
+func resolveNewRev(res *updateResult, tmpDir string, repoCfg config.RepoConfig, bleeding, freeze, noFreeze bool, frozen string) {
+	// A rev (or the ref recorded in its "# frozen:" comment) naming a branch
+	// means the repo tracks that branch: follow its tip instead of tags.
+	if !bleeding {
+		for _, ref := range []string{repoCfg.Rev, frozen} {
+			if ref == "" {
+				continue
+			}
+			sha, err := git.GetRemoteBranchSHA(tmpDir, ref)
+			if err != nil {
+				continue
+			}
+			if noFreeze {
+				res.newRev = ref
+			} else {
+				res.newRev = sha
+				res.branch = ref
+			}
+			return
+		}
+	}
+
+	var err error
 	if bleeding {
 		res.newRev, err = getHEAD(tmpDir)
 	} else {
@@ -202,7 +333,7 @@ func processUpdate(repoCfg config.RepoConfig, bleeding, freeze bool) updateResul
 	}
 	if err != nil {
 		res.err = fmt.Errorf("failed to get latest version: %w", err)
-		return res
+		return
 	}
 
 	// For freeze mode, also resolve the commit hash.
@@ -212,8 +343,34 @@ func processUpdate(repoCfg config.RepoConfig, bleeding, freeze bool) updateResul
 			res.commitHash = commitHash
 		}
 	}
+}
 
-	return res
+// removedHooks returns the ids of hooks that are missing from the manifest
+// at rev in the clone at repoDir.
+func removedHooks(repoDir, rev string, hooks []config.HookConfig) ([]string, error) {
+	data, err := git.CmdOutputInDir(repoDir, "show", rev+":"+config.ManifestFile)
+	if err != nil {
+		// A branch other than the default only exists as a remote ref.
+		data, err = git.CmdOutputInDir(repoDir, "show", "origin/"+rev+":"+config.ManifestFile)
+		if err != nil {
+			return nil, fmt.Errorf("no %s at %s", config.ManifestFile, rev)
+		}
+	}
+	manifest, err := config.ParseManifest([]byte(data), config.ManifestFile+"@"+rev)
+	if err != nil {
+		return nil, err
+	}
+	provided := make(map[string]bool, len(manifest))
+	for _, h := range manifest {
+		provided[h.ID] = true
+	}
+	var removed []string
+	for _, h := range hooks {
+		if !provided[h.ID] && !slices.Contains(removed, h.ID) {
+			removed = append(removed, h.ID)
+		}
+	}
+	return removed, nil
 }
 
 // replaceRev replaces the rev value in the raw YAML, handling quoting.
@@ -242,6 +399,17 @@ func replaceRev(raw, oldRev, newRev, commitHash string, freeze bool) string {
 	)
 }
 
+// frozenRef returns the ref recorded in a "# frozen: REF" comment on the
+// line that sets rev, or "" if there is none.
+func frozenRef(raw, rev string) string {
+	re := regexp.MustCompile(`(?m)rev:\s*['"]?` + regexp.QuoteMeta(rev) + `['"]?\s*#\s*frozen:\s*(\S+)`)
+	m := re.FindStringSubmatch(raw)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
 func getLatestTag(repoDir string) (string, error) {
 	// First try git describe, which finds the most recent tag reachable from HEAD.
 	tag, err := git.GetLatestTag(repoDir)
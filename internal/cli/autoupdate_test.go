@@ -0,0 +1,210 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFrozenRef(t *testing.T) {
+	raw := "    rev: abc123  # frozen: main\n    rev: v1.0.0\n"
+	if got := frozenRef(raw, "abc123"); got != "main" {
+		t.Errorf("frozenRef(abc123) = %q, want %q", got, "main")
+	}
+	if got := frozenRef(raw, "v1.0.0"); got != "" {
+		t.Errorf("frozenRef(v1.0.0) = %q, want empty", got)
+	}
+}
+
+func TestAutoupdateCommand_BranchPinnedRepo(t *testing.T) {
+	repo, commit := initHookRepo(t)
+	newSHA := commit("second commit")
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "repos:\n-   repo: " + repo + "\n    rev: main\n    hooks:\n    -   id: lint\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &AutoupdateCommand{Meta: &Meta{}}
+	if code := cmd.Run([]string{"--config", cfgPath}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "rev: " + newSHA + "  # frozen: main"
+	if !strings.Contains(string(data), want) {
+		t.Fatalf("expected %q in config, got:\n%s", want, data)
+	}
+
+	// A later commit on the branch is picked up via the frozen comment.
+	newerSHA := commit("third commit")
+	if code := cmd.Run([]string{"--config", cfgPath}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	data, _ = os.ReadFile(cfgPath)
+	want = "rev: " + newerSHA + "  # frozen: main"
+	if !strings.Contains(string(data), want) {
+		t.Errorf("expected %q in config, got:\n%s", want, data)
+	}
+}
+
+func TestAutoupdateCommand_BranchPinnedNoFreeze(t *testing.T) {
+	repo, commit := initHookRepo(t)
+	commit("second commit")
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "repos:\n-   repo: " + repo + "\n    rev: main\n    hooks:\n    -   id: lint\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &AutoupdateCommand{Meta: &Meta{}}
+	if code := cmd.Run([]string{"--config", cfgPath, "--no-freeze"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	data, _ := os.ReadFile(cfgPath)
+	if string(data) != content {
+		t.Errorf("expected config unchanged with --no-freeze, got:\n%s", data)
+	}
+}
+
+func TestAutoupdateCommand_DryRunWarnsAboutRemovedHooks(t *testing.T) {
+	repo := t.TempDir()
+	manifest := func(ids ...string) {
+		t.Helper()
+		var b strings.Builder
+		for _, id := range ids {
+			b.WriteString("-   id: " + id + "\n    name: " + id + "\n    entry: " + id + "\n    language: system\n")
+		}
+		if err := os.WriteFile(filepath.Join(repo, ".pre-commit-hooks.yaml"), []byte(b.String()), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitIn(t, repo, "init", "-b", "main")
+	manifest("lint", "format")
+	gitIn(t, repo, "add", ".")
+	gitIn(t, repo, "commit", "-m", "v1")
+	gitIn(t, repo, "tag", "v1.0.0")
+	manifest("lint")
+	gitIn(t, repo, "add", ".")
+	gitIn(t, repo, "commit", "-m", "drop format")
+	gitIn(t, repo, "tag", "v2.0.0")
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "repos:\n-   repo: " + repo + "\n    rev: v1.0.0\n    hooks:\n    -   id: lint\n    -   id: format\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&AutoupdateCommand{Meta: &Meta{}}).Run([]string{"--config", cfgPath, "--dry-run"})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(out, "updating v1.0.0 -> v2.0.0") {
+		t.Errorf("expected the bump to be reported, got:\n%s", out)
+	}
+	want := repo + " at v2.0.0 no longer provides hook(s) used in your config: format."
+	if !strings.Contains(out, want) {
+		t.Errorf("expected %q in output, got:\n%s", want, out)
+	}
+	if data, _ := os.ReadFile(cfgPath); string(data) != content {
+		t.Errorf("--dry-run must not change the config, got:\n%s", data)
+	}
+}
+
+func TestAutoupdateCommand_Changelog(t *testing.T) {
+	one, _ := initHookRepo(t, "v1.0.0", "v1.1.0")
+	two, _ := initHookRepo(t, "v2.0.0", "v3.0.0")
+	same, _ := initHookRepo(t, "v0.1.0")
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "repos:\n"
+	for _, r := range []struct{ repo, rev string }{{one, "v1.0.0"}, {two, "v2.0.0"}, {same, "v0.1.0"}} {
+		content += "-   repo: " + r.repo + "\n    rev: " + r.rev + "\n    hooks:\n    -   id: lint\n"
+	}
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	changelog := filepath.Join(t.TempDir(), "CHANGES.md")
+
+	var code int
+	captureStdout(t, func() {
+		code = (&AutoupdateCommand{Meta: &Meta{}}).Run([]string{"--config", cfgPath, "--changelog", changelog})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	data, err := os.ReadFile(changelog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- Bumped " + one + " from `v1.0.0` to `v1.1.0`\n" +
+		"- Bumped " + two + " from `v2.0.0` to `v3.0.0`\n"
+	if string(data) != want {
+		t.Errorf("changelog = %q, want %q", data, want)
+	}
+}
+
+func TestCompareURL(t *testing.T) {
+	tests := []struct {
+		repo, want string
+	}{
+		{"https://github.com/pre-commit/pre-commit-hooks", "https://github.com/pre-commit/pre-commit-hooks/compare/v1...v2"},
+		{"https://github.com/psf/black.git", "https://github.com/psf/black/compare/v1...v2"},
+		{"git@github.com:owner/repo.git", "https://github.com/owner/repo/compare/v1...v2"},
+		{"https://gitlab.com/group/sub/project", "https://gitlab.com/group/sub/project/-/compare/v1...v2"},
+		{"https://example.com/owner/repo", ""},
+		{"/srv/hooks", ""},
+	}
+	for _, tt := range tests {
+		if got := compareURL(tt.repo, "v1", "v2"); got != tt.want {
+			t.Errorf("compareURL(%q) = %q, want %q", tt.repo, got, tt.want)
+		}
+	}
+}
+
+func TestAutoupdateCommand_SkipsNoAutoupdateRepos(t *testing.T) {
+	pinned, _ := initHookRepo(t, "v1.0.0", "v1.1.0")
+	inline, _ := initHookRepo(t, "v2.0.0", "v2.1.0")
+	free, _ := initHookRepo(t, "v3.0.0", "v3.1.0")
+
+	cfgPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "repos:\n" +
+		"# pre-commit: no-autoupdate\n" +
+		"-   repo: " + pinned + "\n    rev: v1.0.0\n    hooks:\n    -   id: lint\n" +
+		"-   repo: " + inline + "\n    rev: v2.0.0  # pre-commit: no-autoupdate\n    hooks:\n    -   id: lint\n" +
+		"-   repo: " + free + "\n    rev: v3.0.0\n    hooks:\n    -   id: lint\n"
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&AutoupdateCommand{Meta: &Meta{}}).Run([]string{"--config", cfgPath})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d\n%s", code, out)
+	}
+	for _, repo := range []string{pinned, inline} {
+		if want := "Updating " + repo + ` ... skipped (marked "pre-commit: no-autoupdate").`; !strings.Contains(out, want) {
+			t.Errorf("expected %q in output, got:\n%s", want, out)
+		}
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(content, "rev: v3.0.0", "rev: v3.1.0", 1)
+	if string(data) != want {
+		t.Errorf("config = %q, want %q", data, want)
+	}
+}
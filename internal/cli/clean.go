@@ -1,11 +1,17 @@
 package cli
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"time"
 
 	flags "github.com/jessevdk/go-flags"
+	"github.com/mattn/go-isatty"
 
 	"github.com/blairham/go-pre-commit/v4/internal/store"
 )
@@ -15,8 +21,19 @@ type CleanCommand struct {
 	Meta *Meta
 }
 
+type cleanFlags struct {
+	GlobalFlags
+	Yes bool `short:"y" long:"yes" description:"Remove the cache without asking for confirmation."`
+}
+
+// stdinIsTerminal reports whether stdin is interactive, in which case clean
+// asks before deleting a non-empty cache. Tests replace it.
+var stdinIsTerminal = func() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
+}
+
 func (c *CleanCommand) Run(args []string) int {
-	var opts GlobalFlags
+	var opts cleanFlags
 	_, err := flags.ParseArgs(&opts, args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -24,7 +41,36 @@ func (c *CleanCommand) Run(args []string) int {
 	}
 
 	s := store.New("")
-	if err := s.Clean(); err != nil {
+	if _, err := os.Stat(s.Dir()); err == nil {
+		if !opts.Yes && stdinIsTerminal() {
+			// Sizing the cache walks all of it, so only do so when asking.
+			// It is best-effort: a cache too broken to size is still one
+			// clean can remove.
+			if u, err := s.DiskUsage(nil); err != nil || u.Total > 0 {
+				summary := "pre-commit cache at " + s.Dir()
+				if err == nil {
+					repos, _ := s.ListRepos()
+					summary += fmt.Sprintf(" (%d cached repo(s), %s)", len(repos), formatSize(u.Total))
+				}
+				if !c.confirm("Remove the " + summary + "? [y/N]") {
+					fmt.Println("Aborted; the cache was left untouched.")
+					return 1
+				}
+			}
+		} else {
+			fmt.Printf("Removing the pre-commit cache at %s.\n", s.Dir())
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	removed, err := s.CleanContext(ctx, progressPrinter("Removed"))
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "Interrupted: removed %d cached repo(s); the rest of the cache is intact.\n", removed)
+		return 130
+	}
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: failed to clean: %v\n", err)
 		return 1
 	}
@@ -32,11 +78,51 @@ func (c *CleanCommand) Run(args []string) int {
 	return 0
 }
 
+// confirm asks question on the terminal and reports whether the answer was
+// yes.
+func (c *CleanCommand) confirm(question string) bool {
+	var answer string
+	if c.Meta != nil && c.Meta.UI != nil {
+		answer, _ = c.Meta.UI.Ask(question)
+	} else {
+		fmt.Print(question + " ")
+		answer, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
+// progressPrinter returns a store.ProgressFunc that reports progress on
+// stderr at most once a second, plus once at completion.
+func progressPrinter(verb string) store.ProgressFunc {
+	var last time.Time
+	return func(done, total int) {
+		if done < total && time.Since(last) < time.Second {
+			return
+		}
+		last = time.Now()
+		fmt.Fprintf(os.Stderr, "%s %d/%d cached repo(s)\n", verb, done, total)
+	}
+}
+
 func (c *CleanCommand) Help() string {
 	return strings.TrimSpace(`
-Usage: pre-commit clean
+Usage: pre-commit clean [options]
 
   Remove the pre-commit cache directory and all cached hook repositories.
+  Progress is reported as repos are removed. Interrupting with Ctrl-C stops
+  after the current repo and leaves the remaining cache usable.
+
+  When stdin is a terminal, clean asks for confirmation before removing a
+  non-empty cache. Otherwise (e.g. in CI) it prints what it is removing and
+  proceeds.
+
+Options:
+
+  -y, --yes    Remove the cache without asking for confirmation.
 `)
 }
 
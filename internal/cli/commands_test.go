@@ -18,6 +18,7 @@ func allCommands(t *testing.T) map[string]mcli.Command {
 		"autoupdate":              &AutoupdateCommand{Meta: meta},
 		"clean":                   &CleanCommand{Meta: meta},
 		"gc":                      &GCCommand{Meta: meta},
+		"doctor":                  &DoctorCommand{Meta: meta},
 		"init-templatedir":        &InitTemplateDirCommand{Meta: meta},
 		"sample-config":           &SampleConfigCommand{Meta: meta},
 		"try-repo":                &TryRepoCommand{Meta: meta},
@@ -28,6 +29,13 @@ func allCommands(t *testing.T) map[string]mcli.Command {
 		"hazmat cd":               &HazmatCdCommand{Meta: meta},
 		"hazmat ignore-exit-code": &HazmatIgnoreExitCodeCommand{Meta: meta},
 		"hazmat n1":               &HazmatN1Command{Meta: meta},
+		"store dir":               &StoreDirCommand{Meta: meta},
+		"store du":                &StoreDuCommand{Meta: meta},
+		"store list":              &StoreListCommand{Meta: meta},
+		"store export":            &StoreExportCommand{Meta: meta},
+		"store import":            &StoreImportCommand{Meta: meta},
+		"store prune-repos":       &StorePruneReposCommand{Meta: meta},
+		"store unlock":            &StoreUnlockCommand{Meta: meta},
 	}
 }
 
@@ -67,10 +75,13 @@ func TestAllCommands_HelpContainsUsage(t *testing.T) {
 func TestRun_RegistersAllExpectedCommands(t *testing.T) {
 	expectedCommands := []string{
 		"run", "install", "uninstall", "install-hooks",
-		"autoupdate", "clean", "gc", "init-templatedir",
+		"autoupdate", "clean", "gc", "doctor", "init-templatedir",
 		"sample-config", "try-repo", "validate-config",
 		"validate-manifest", "migrate-config", "hook-impl",
 		"hazmat cd", "hazmat ignore-exit-code", "hazmat n1",
+		"store dir", "store du", "store list", "store export", "store import",
+		"store prune-repos",
+		"store unlock",
 	}
 
 	cmds := allCommands(t)
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+
+	"github.com/blairham/go-pre-commit/v4/internal/hook"
+	"github.com/blairham/go-pre-commit/v4/internal/store"
+)
+
+// DoctorCommand implements the "doctor" command.
+type DoctorCommand struct {
+	Meta *Meta
+}
+
+type doctorFlags struct {
+	GlobalFlags
+	Fix bool `long:"fix" description:"Rebuild unhealthy hook environments."`
+}
+
+func (c *DoctorCommand) Run(args []string) int {
+	var opts doctorFlags
+	_, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		printError(err, "%v", err)
+		return 1
+	}
+
+	hooks, err := resolveAllHooks(opts.Config)
+	if err != nil {
+		printError(err, "%v", err)
+		return 1
+	}
+
+	for _, h := range hook.MissingEnvironments(hooks) {
+		fmt.Printf("  Not installed  %s (%s, language: %s)\n", h.ID, h.Repo, h.Language)
+	}
+	unhealthy := hook.UnhealthyEnvironments(hooks)
+	for _, f := range unhealthy {
+		fmt.Printf("  Unhealthy      %s (%s, language: %s): %v\n", f.Hook.ID, f.Hook.Repo, f.Hook.Language, f.Err)
+	}
+	if opts.Fix && len(unhealthy) > 0 {
+		summary := hook.RebuildEnvironments(context.Background(), unhealthy)
+		for _, h := range summary.Installed {
+			fmt.Printf("  Rebuilt        %s (%s, language: %s)\n", h.ID, h.Repo, h.Language)
+		}
+		for _, f := range summary.Failed {
+			fmt.Printf("  Not repaired   %s (%s, language: %s): %v\n", f.Hook.ID, f.Hook.Repo, f.Hook.Language, f.Err)
+		}
+		unhealthy = summary.Failed
+	}
+
+	// A build that runs out of space part way leaves a corrupt environment.
+	var low *store.LowSpaceError
+	if err := store.CheckFreeSpace(store.New("").Dir(), diskFree); errors.As(err, &low) {
+		fmt.Printf("  Low space      %s: %s available, %s required\n", low.Dir, formatSize(int64(low.Available)), formatSize(int64(low.Required)))
+	} else if err != nil {
+		printError(err, "%v", err)
+		return 1
+	}
+
+	if len(unhealthy) > 0 && !opts.Fix {
+		fmt.Println("Rebuild the unhealthy environments with `pre-commit doctor --fix`.")
+	}
+	if low != nil {
+		fmt.Println("Free up space, or point PRE_COMMIT_HOME at a larger disk, before installing environments.")
+	}
+	if len(unhealthy) > 0 || low != nil {
+		return 1
+	}
+	fmt.Println("All installed hook environments are healthy.")
+	return 0
+}
+
+// diskFree measures free space; tests replace it to simulate a full disk.
+var diskFree store.FreeSpaceFunc = store.FreeSpace
+
+func (c *DoctorCommand) Help() string {
+	return strings.TrimSpace(`
+Usage: pre-commit doctor [options]
+
+  Check the installed hook environments for the hooks in the config.
+
+  Each installed environment is health checked by its language, e.g. a Python
+  environment must have a working interpreter and pip. Environments that are
+  not installed yet are listed but do not fail the check. The exit code is
+  non-zero if any installed environment is unhealthy.
+
+  It also fails if the disk holding the cache has less free space than
+  environment builds require: 1 GB, or PRE_COMMIT_MIN_FREE_SPACE (e.g.
+  500M, 2G, or 0 to skip the check).
+
+  With --fix, each unhealthy environment is removed and installed again, and
+  the ones rebuilt are listed. Healthy environments are left alone. The exit
+  code is then non-zero only if an environment could not be repaired.
+
+Options:
+
+  -c, --config=FILE   Path to alternate config file.
+      --fix           Rebuild unhealthy hook environments.
+`)
+}
+
+func (c *DoctorCommand) Synopsis() string {
+	return "Check installed hook environments for problems"
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDoctorCommand_LowDiskSpace(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: noop\n        name: noop\n        entry: 'true'\n        language: system\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	free := uint64(100 << 20)
+	oldDiskFree := diskFree
+	diskFree = func(string) (uint64, error) { return free, nil }
+	t.Cleanup(func() { diskFree = oldDiskFree })
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&DoctorCommand{Meta: &Meta{}}).Run(nil)
+	})
+	if code != 1 {
+		t.Errorf("expected exit code 1 on a nearly full disk, got %d", code)
+	}
+	if !strings.Contains(out, "Low space") || !strings.Contains(out, "100.0 MB available, 1.0 GB required") {
+		t.Errorf("expected the available and required space to be reported, got:\n%s", out)
+	}
+
+	t.Setenv("PRE_COMMIT_MIN_FREE_SPACE", "50M")
+	out = captureStdout(t, func() {
+		code = (&DoctorCommand{Meta: &Meta{}}).Run(nil)
+	})
+	if code != 0 || strings.Contains(out, "Low space") {
+		t.Errorf("expected a lowered minimum to pass, got exit code %d:\n%s", code, out)
+	}
+}
+
+func TestDoctorCommand_InvalidConfig(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte("repos: not-a-list\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = (&DoctorCommand{Meta: &Meta{}}).Run(nil)
+	})
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if !strings.HasPrefix(stderr, "config-error: failed to load config") {
+		t.Errorf("expected the error to start with its category, got:\n%s", stderr)
+	}
+}
+
+func TestDoctorCommand_Fix(t *testing.T) {
+	registerLanguage(t, fakeLanguage{name: "doctortest"})
+	home := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", home)
+	t.Setenv("PRE_COMMIT_MIN_FREE_SPACE", "0")
+	dir := t.TempDir()
+	cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: tool\n        name: tool\n        entry: tool\n        language: doctortest\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	t.Chdir(dir)
+
+	captureStdout(t, func() {
+		if code := (&InstallHooksCommand{Meta: &Meta{}}).Run(nil); code != 0 {
+			t.Fatalf("install-hooks exit code %d", code)
+		}
+	})
+	markers, _ := filepath.Glob(filepath.Join(home, "*", "doctortest_env-default", "ok"))
+	if len(markers) != 1 {
+		t.Fatalf("expected one installed environment, found %v", markers)
+	}
+	os.Remove(markers[0])
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&DoctorCommand{Meta: &Meta{}}).Run(nil)
+	})
+	if code != 1 || !strings.Contains(out, "Unhealthy      tool") || !strings.Contains(out, "doctor --fix") {
+		t.Fatalf("expected the corrupt environment to be reported, got exit code %d:\n%s", code, out)
+	}
+
+	out = captureStdout(t, func() {
+		code = (&DoctorCommand{Meta: &Meta{}}).Run([]string{"--fix"})
+	})
+	if code != 0 || !strings.Contains(out, "Rebuilt        tool") {
+		t.Errorf("expected the environment to be rebuilt, got exit code %d:\n%s", code, out)
+	}
+	if _, err := os.Stat(markers[0]); err != nil {
+		t.Errorf("environment not reinstalled: %v", err)
+	}
+}
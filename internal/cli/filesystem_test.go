@@ -5,6 +5,9 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
+
+	mcli "github.com/mitchellh/cli"
 )
 
 // --- SampleConfigCommand tests ---
@@ -417,3 +420,304 @@ func TestCleanCommand_Run(t *testing.T) {
 		t.Errorf("expected 'Cleaned' in output, got %q", out)
 	}
 }
+
+// populateCache puts a fake cached repo in a fresh PRE_COMMIT_HOME.
+func populateCache(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", dir)
+	if err := os.WriteFile(filepath.Join(dir, "db.json"), []byte(`{"repos":[]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "repoabc"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "repoabc", "hook.py"), []byte("print()\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func setStdinTerminal(t *testing.T, tty bool) {
+	t.Helper()
+	old := stdinIsTerminal
+	stdinIsTerminal = func() bool { return tty }
+	t.Cleanup(func() { stdinIsTerminal = old })
+}
+
+func TestCleanCommand_PromptsOnTerminal(t *testing.T) {
+	dir := populateCache(t)
+	setStdinTerminal(t, true)
+
+	ui := mcli.NewMockUi()
+	ui.InputReader = strings.NewReader("n\n")
+	var code int
+	out := captureStdout(t, func() {
+		code = (&CleanCommand{Meta: &Meta{UI: ui}}).Run(nil)
+	})
+	if code != 1 {
+		t.Fatalf("expected exit code 1 when declined, got %d", code)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Remove the pre-commit cache at "+dir) {
+		t.Errorf("expected a confirmation prompt, got %q", ui.OutputWriter.String())
+	}
+	if !strings.Contains(out, "Aborted") {
+		t.Errorf("expected an aborted message, got %q", out)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repoabc")); err != nil {
+		t.Errorf("declining must leave the cache intact: %v", err)
+	}
+
+	ui = mcli.NewMockUi()
+	ui.InputReader = strings.NewReader("y\n")
+	if code := (&CleanCommand{Meta: &Meta{UI: ui}}).Run(nil); code != 0 {
+		t.Fatalf("expected exit code 0 when confirmed, got %d", code)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected cache to be removed, stat err = %v", err)
+	}
+}
+
+func TestCleanCommand_YesSkipsPrompt(t *testing.T) {
+	dir := populateCache(t)
+	setStdinTerminal(t, true)
+
+	// No input is available, so a prompt would read EOF and abort.
+	ui := mcli.NewMockUi()
+	var code int
+	captureStdout(t, func() {
+		code = (&CleanCommand{Meta: &Meta{UI: ui}}).Run([]string{"--yes"})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if ui.OutputWriter.String() != "" {
+		t.Errorf("--yes must not prompt, got %q", ui.OutputWriter.String())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected cache to be removed, stat err = %v", err)
+	}
+}
+
+func TestCleanCommand_NonTerminalDoesNotPrompt(t *testing.T) {
+	dir := populateCache(t)
+	setStdinTerminal(t, false)
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&CleanCommand{Meta: &Meta{UI: mcli.NewMockUi()}}).Run(nil)
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(out, "Removing the pre-commit cache at "+dir+".") {
+		t.Errorf("expected what is being removed to be printed, got %q", out)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected cache to be removed, stat err = %v", err)
+	}
+}
+
+func TestCleanCommand_CorruptDB(t *testing.T) {
+	dir := populateCache(t)
+	if err := os.WriteFile(filepath.Join(dir, "db.json"), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	setStdinTerminal(t, true)
+
+	ui := mcli.NewMockUi()
+	ui.InputReader = strings.NewReader("y\n")
+	var code int
+	captureStdout(t, func() {
+		code = (&CleanCommand{Meta: &Meta{UI: ui}}).Run(nil)
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(ui.OutputWriter.String(), "Remove the pre-commit cache at "+dir+"?") {
+		t.Errorf("expected a prompt without sizes, got %q", ui.OutputWriter.String())
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected cache to be removed, stat err = %v", err)
+	}
+}
+
+// --- InstallCommand tests ---
+
+func TestInstallCommand_IfNeeded(t *testing.T) {
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte("repos: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	hookFile := filepath.Join(dir, ".git", "hooks", "pre-commit")
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&InstallCommand{Meta: &Meta{}}).Run([]string{"--if-needed"})
+	})
+	if code != 0 || !strings.Contains(out, "pre-commit installed at") {
+		t.Fatalf("first install: exit code %d, output:\n%s", code, out)
+	}
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(hookFile, old, old); err != nil {
+		t.Fatal(err)
+	}
+	out = captureStdout(t, func() {
+		code = (&InstallCommand{Meta: &Meta{}}).Run([]string{"--if-needed"})
+	})
+	if code != 0 || !strings.Contains(out, "already up to date") {
+		t.Errorf("second install: exit code %d, output:\n%s", code, out)
+	}
+	info, err := os.Stat(hookFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("expected the hook script to be left untouched, mtime changed to %v", info.ModTime())
+	}
+
+	// A stale script is still rewritten.
+	if err := os.WriteFile(hookFile, []byte("#!/usr/bin/env bash\n# File generated by pre-commit: https://pre-commit.com\n# old\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	out = captureStdout(t, func() {
+		code = (&InstallCommand{Meta: &Meta{}}).Run([]string{"--if-needed"})
+	})
+	if code != 0 || !strings.Contains(out, "pre-commit installed at") {
+		t.Errorf("stale install: exit code %d, output:\n%s", code, out)
+	}
+}
+
+func TestInstallCommand_MultipleHookTypes(t *testing.T) {
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte("repos: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	hooksDir := filepath.Join(dir, ".git", "hooks")
+	foreign := "#!/bin/sh\necho mine\n"
+	if err := os.MkdirAll(hooksDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hooksDir, "pre-push"), []byte(foreign), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	install := func(args ...string) string {
+		t.Helper()
+		var code int
+		out := captureStdout(t, func() {
+			code = (&InstallCommand{Meta: &Meta{}}).Run(args)
+		})
+		if code != 0 {
+			t.Fatalf("install %v: exit code %d, output:\n%s", args, code, out)
+		}
+		return out
+	}
+	readHook := func(name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(hooksDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	out := install("--hook-type", "pre-commit", "--hook-type", "pre-push", "--hook-type", "pre-commit")
+	if n := strings.Count(out, "pre-commit installed at"); n != 2 {
+		t.Errorf("expected 2 scripts installed, got %d:\n%s", n, out)
+	}
+	for _, ht := range []string{"pre-commit", "pre-push"} {
+		if got := readHook(ht); !strings.Contains(got, "--hook-type="+ht+")") {
+			t.Errorf("%s script does not dispatch %s:\n%s", ht, ht, got)
+		}
+	}
+	if got := readHook("pre-push.legacy"); got != foreign {
+		t.Errorf("pre-push.legacy = %q, want the original hook", got)
+	}
+
+	// Reinstalling replaces our scripts rather than backing them up.
+	want := readHook("pre-push")
+	install("-t", "pre-commit", "-t", "pre-push")
+	if got := readHook("pre-push"); got != want {
+		t.Errorf("reinstalled pre-push = %q, want %q", got, want)
+	}
+	if got := readHook("pre-push.legacy"); got != foreign {
+		t.Errorf("reinstall clobbered pre-push.legacy with %q", got)
+	}
+
+	// --overwrite replaces every type and drops its backup.
+	install("-t", "pre-commit", "-t", "pre-push", "--overwrite")
+	if _, err := os.Stat(filepath.Join(hooksDir, "pre-push.legacy")); !os.IsNotExist(err) {
+		t.Errorf("expected --overwrite to remove pre-push.legacy, stat err = %v", err)
+	}
+	if got := readHook("pre-push"); got != want {
+		t.Errorf("overwritten pre-push = %q, want %q", got, want)
+	}
+}
+
+func TestGCCommand_DryRun(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", home)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte("repos:\n-   repo: https://example.com/used\n    rev: v1\n    hooks:\n    -   id: lint\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	db := `{"repos": [` +
+		`{"repo": "https://example.com/used", "rev": "v1", "path": "` + filepath.Join(home, "used") + `"},` +
+		`{"repo": "https://example.com/old", "rev": "v0", "path": "` + filepath.Join(home, "old") + `"}]}`
+	if err := os.WriteFile(filepath.Join(home, "db.json"), []byte(db), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for name, size := range map[string]int{"used": 10, "old": 2048} {
+		if err := os.MkdirAll(filepath.Join(home, name, "py_env-default"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(home, name, "py_env-default", "blob"), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&GCCommand{Meta: &Meta{}}).Run([]string{"--dry-run"})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d\n%s", code, out)
+	}
+	want := "Would remove " + filepath.Join(home, "old") + " (https://example.com/old@v0, 2.0 KB)\n" +
+		"Would reclaim 2.0 KB from 1 unused repo(s).\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+	if _, err := os.Stat(filepath.Join(home, "old")); err != nil {
+		t.Errorf("expected --dry-run to leave the unused repo in place: %v", err)
+	}
+}
+
+func TestGCCommand_VacuumRejectsDryRun(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", home)
+	db := `{"repos": [` +
+		`{"repo": "https://example.com/gone", "rev": "v0", "path": "` + filepath.Join(home, "gone") + `"}]}`
+	if err := os.WriteFile(filepath.Join(home, "db.json"), []byte(db), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = (&GCCommand{Meta: &Meta{}}).Run([]string{"--vacuum", "--dry-run"})
+	})
+	if code != 1 || !strings.Contains(stderr, "--dry-run can't be used with --vacuum") {
+		t.Errorf("expected --vacuum --dry-run to be rejected, got exit code %d:\n%s", code, stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, "db.json")); string(data) != db {
+		t.Errorf("store database was rewritten:\n%s", data)
+	}
+}
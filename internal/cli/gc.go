@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
 
 	flags "github.com/jessevdk/go-flags"
@@ -16,16 +19,37 @@ type GCCommand struct {
 	Meta *Meta
 }
 
+type gcFlags struct {
+	GlobalFlags
+	Vacuum bool `long:"vacuum" description:"Compact the store database instead of removing unused repos."`
+	DryRun bool `long:"dry-run" description:"List the unused repos and the space removing them would free, without removing them."`
+}
+
 func (c *GCCommand) Run(args []string) int {
-	var opts GlobalFlags
+	var opts gcFlags
 	_, err := flags.ParseArgs(&opts, args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printError(err, "%v", err)
+		return 1
+	}
+
+	if opts.Vacuum && opts.DryRun {
+		printError(nil, "--dry-run can't be used with --vacuum")
 		return 1
 	}
 
 	s := store.New("")
 
+	if opts.Vacuum {
+		removed, err := s.Vacuum()
+		if err != nil {
+			printError(err, "failed to vacuum store database: %v", err)
+			return 1
+		}
+		fmt.Printf("Vacuumed store database: removed %d redundant entries.\n", removed)
+		return 0
+	}
+
 	// Gather used repos from all known config files.
 	usedRepos := make(map[string]bool)
 
@@ -52,31 +76,85 @@ func (c *GCCommand) Run(args []string) int {
 
 	for _, cfgPath := range configPaths {
 		if cfg, err := config.LoadConfig(cfgPath); err == nil {
-			for _, repo := range cfg.Repos {
-				if !repo.IsLocal() && !repo.IsMeta() {
-					usedRepos[repo.Repo+"@"+repo.Rev] = true
-				}
-			}
+			markUsedRepos(usedRepos, cfg)
 		}
 	}
 
-	if err := s.GC(usedRepos); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to run GC: %v\n", err)
+	if opts.DryRun {
+		return gcDryRun(s, usedRepos)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	removed, err := s.GCContext(ctx, usedRepos, progressPrinter("Removed"))
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "Interrupted: removed %d unused repo(s) before stopping.\n", removed)
+		return 130
+	}
+	if err != nil {
+		printError(err, "failed to run GC: %v", err)
 		return 1
 	}
 	fmt.Println("Garbage collection complete.")
 	return 0
 }
 
+// gcDryRun prints each repo gc would remove, with the environments in it,
+// and the space that would free.
+func gcDryRun(s *store.Store, usedRepos map[string]bool) int {
+	unused, err := s.UnusedRepoInfo(usedRepos)
+	if err != nil {
+		printError(err, "failed to list unused repos: %v", err)
+		return 1
+	}
+	var total int64
+	counted := make(map[string]bool)
+	for _, r := range unused {
+		fmt.Printf("Would remove %s (%s@%s, %s)\n", r.Path, r.Repo, r.Rev, formatSize(r.Size))
+		if !counted[r.Path] {
+			counted[r.Path] = true
+			total += r.Size
+		}
+	}
+	fmt.Printf("Would reclaim %s from %d unused repo(s).\n", formatSize(total), len(unused))
+	return 0
+}
+
+// markUsedRepos records the repo@rev keys of the store entries cfg uses.
+func markUsedRepos(used map[string]bool, cfg *config.Config) {
+	for _, repo := range cfg.Repos {
+		switch {
+		case repo.IsLocal():
+			for _, hc := range repo.Hooks {
+				used[store.LocalRepo+"@"+store.LocalRev(hc.AdditionalDependencies)] = true
+			}
+		case !repo.IsMeta():
+			used[repo.Repo+"@"+repo.Rev] = true
+		}
+	}
+}
+
 func (c *GCCommand) Help() string {
 	return strings.TrimSpace(`
 Usage: pre-commit gc [options]
 
   Clean unused cached repos. Repos that are no longer referenced by any
-  config file will be removed from the cache.
+  config file will be removed from the cache. Interrupting with Ctrl-C
+  stops after the current repo.
+
+  With --dry-run, each repo that would be removed is listed with its size,
+  including the hook environments installed in it, followed by the total
+  space that would be reclaimed. Nothing is removed.
+
+  With --vacuum, only the store database is compacted: duplicate entries and
+  entries for missing clones or configs are dropped and the rest sorted.
+  Nothing on disk is removed. It can't be combined with --dry-run.
 
 Options:
 
+      --dry-run       List what would be removed and its size instead.
+      --vacuum        Compact the store database instead of removing repos.
   -c, --config=FILE   Path to alternate config file.
       --color=MODE    Whether to use color (auto, always, never).
 `)
@@ -1,11 +1,47 @@
 package cli
 
 import (
+	"errors"
+	"io"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
+	"github.com/blairham/go-pre-commit/v4/internal/hook"
 )
 
+// captureStdout redirects os.Stdout while fn runs and returns what was written.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stdout, fn)
+}
+
+// captureStderr redirects os.Stderr while fn runs and returns what was written.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stderr, fn)
+}
+
+func captureFile(t *testing.T, f **os.File, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := *f
+	*f = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	*f = old
+	return <-done
+}
+
 // --- versionString tests ---
 
 func TestVersionString_Basic(t *testing.T) {
@@ -264,3 +300,51 @@ func TestIsLocalPath_File(t *testing.T) {
 		t.Error("expected false for regular file")
 	}
 }
+
+// --- printInstallSummary tests ---
+
+func TestPrintInstallSummary(t *testing.T) {
+	summary := hook.InstallSummary{
+		Installed: []*hook.Hook{
+			{ID: "black", Repo: "https://github.com/psf/black"},
+			{ID: "eslint", Repo: "https://github.com/pre-commit/mirrors-eslint"},
+		},
+		Failed: []hook.InstallFailure{
+			{Hook: &hook.Hook{ID: "rubocop", Repo: "https://github.com/rubocop/rubocop"}, Err: errors.New("ruby not found")},
+		},
+	}
+	out := captureStdout(t, func() { printInstallSummary(summary) })
+
+	for _, want := range []string{
+		"Installed  https://github.com/psf/black (black)",
+		"Installed  https://github.com/pre-commit/mirrors-eslint (eslint)",
+		"Failed     https://github.com/rubocop/rubocop (rubocop): ruby not found",
+		"2 installed, 1 failed.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in summary:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintVerifyResults(t *testing.T) {
+	results := []hook.VerifyResult{
+		{Hook: &hook.Hook{ID: "black", Repo: "https://github.com/psf/black"}},
+		{Hook: &hook.Hook{ID: "eslint", Repo: "https://github.com/pre-commit/mirrors-eslint"}, Err: errkind.Wrap(errkind.Env, errors.New("eslint: not found"))},
+	}
+	var failed int
+	out := captureStdout(t, func() { failed = printVerifyResults(results) })
+
+	if failed != 1 {
+		t.Errorf("printVerifyResults() = %d, want 1", failed)
+	}
+	for _, want := range []string{
+		"Passed     https://github.com/psf/black (black)",
+		"Failed     https://github.com/pre-commit/mirrors-eslint (eslint): env-error: eslint: not found",
+		"1 passed, 1 failed.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in results:\n%s", want, out)
+		}
+	}
+}
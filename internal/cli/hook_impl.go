@@ -26,7 +26,7 @@ type hookImplFlags struct {
 	HookType            string `long:"hook-type" required:"true" description:"The hook type being run."`
 	HookDir             string `long:"hook-dir" description:"The hook directory."`
 	SkipOnMissingConfig bool   `long:"skip-on-missing-config" description:"Skip if config file is missing."`
-	Color               string `long:"color" default:"auto" description:"Whether to use color in output."`
+	Color               string `long:"color" description:"Whether to use color in output. Defaults to $PRE_COMMIT_COLOR or auto."`
 }
 
 func (c *HookImplCommand) Run(args []string) int {
@@ -39,12 +39,41 @@ func (c *HookImplCommand) Run(args []string) int {
 	var opts hookImplFlags
 	remaining, err := flags.NewParser(&opts, flags.HelpFlag|flags.PassDoubleDash).ParseArgs(args)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		printError(err, "%v", err)
 		return 1
 	}
 
 	output.SetColorModeFromString(opts.Color)
 
+	// PRE_COMMIT_SKIP_<HOOK_TYPE> turns a single installed hook type into a
+	// no-op without uninstalling it.
+	if stageSkipped(opts.HookType) {
+		return 0
+	}
+
+	// Guard against a hook re-triggering the same git hook in the same repo
+	// (e.g. a pre-commit hook that runs `git commit`), which would otherwise
+	// recurse forever. The marker is inherited by everything the hooks spawn.
+	root, _ := git.GetRoot()
+	key := opts.HookType + ":" + root
+	active := os.Getenv(hookImplActiveEnv)
+	if hookImplActive(active, key) {
+		printError(nil, "recursive %s hook invocation detected in %s.", opts.HookType, root)
+		fmt.Fprintf(os.Stderr, "A hook is running git in a way that triggers %s again; aborting to avoid an infinite loop.\n", opts.HookType)
+		return 1
+	}
+	if active != "" {
+		key = active + "\n" + key
+	}
+	os.Setenv(hookImplActiveEnv, key)
+	defer func() {
+		if active == "" {
+			os.Unsetenv(hookImplActiveEnv)
+		} else {
+			os.Setenv(hookImplActiveEnv, active)
+		}
+	}()
+
 	// Check if config exists when --skip-on-missing-config is set.
 	if opts.SkipOnMissingConfig {
 		if _, err := os.Stat(opts.Config); os.IsNotExist(err) {
@@ -71,6 +100,9 @@ func (c *HookImplCommand) Run(args []string) int {
 	if opts.Config != "" {
 		runArgs = append(runArgs, "--config", opts.Config)
 	}
+	if opts.Color != "" {
+		runArgs = append(runArgs, "--color", opts.Color)
+	}
 
 	// Add stage.
 	runArgs = append(runArgs, "--hook-stage", opts.HookType)
@@ -82,23 +114,19 @@ func (c *HookImplCommand) Run(args []string) int {
 
 	case "pre-push":
 		// Args: <remote-name> <remote-url>
+		var remoteName string
 		if len(remaining) >= 2 {
+			remoteName = remaining[0]
 			runArgs = append(runArgs, "--remote-name", remaining[0])
 			runArgs = append(runArgs, "--remote-url", remaining[1])
 		}
 		// Read stdin for refs (pre-push receives ref info on stdin).
-		for _, line := range readPrePushStdin() {
-			parts := strings.Fields(line)
-			if len(parts) >= 4 {
-				localRef := parts[1]
-				remoteRef := parts[3]
-				z40 := "0000000000000000000000000000000000000000"
-				if localRef != z40 && remoteRef != z40 {
-					runArgs = append(runArgs, "--from-ref", remoteRef, "--to-ref", localRef)
-				}
-				runArgs = append(runArgs, "--local-branch", parts[0], "--remote-branch", parts[2])
-			}
+		pushArgs, ok := prePushArgs(readPrePushStdin(), remoteName)
+		if !ok {
+			// No commits are being pushed.
+			return 0
 		}
+		runArgs = append(runArgs, pushArgs...)
 
 	case "commit-msg":
 		if len(remaining) >= 1 {
@@ -150,7 +178,7 @@ func (c *HookImplCommand) Run(args []string) int {
 	}
 
 	// Execute the run command directly.
-	runCmd := &RunCommand{Meta: c.Meta}
+	runCmd := &RunCommand{Meta: c.Meta, fromHook: true}
 	return runCmd.Run(runArgs)
 }
 
@@ -158,7 +186,10 @@ func (c *HookImplCommand) Help() string {
 	return strings.TrimSpace(`
 Usage: pre-commit hook-impl [options] [-- args...]
 
-  Implementation of git hooks (internal use only).
+  Implementation of git hooks (internal use only). Setting
+  PRE_COMMIT_SKIP_<HOOK_TYPE> (e.g. PRE_COMMIT_SKIP_POST_CHECKOUT=1) makes
+  that hook type exit 0 without running anything; 0 and false leave it
+  enabled.
 
 Options:
 
@@ -174,6 +205,39 @@ func (c *HookImplCommand) Synopsis() string {
 	return "Implementation of git hooks (internal use only)"
 }
 
+// stageSkipEnv returns the variable that disables hookType, e.g.
+// PRE_COMMIT_SKIP_POST_CHECKOUT for post-checkout.
+func stageSkipEnv(hookType string) string {
+	return "PRE_COMMIT_SKIP_" + strings.ToUpper(strings.ReplaceAll(hookType, "-", "_"))
+}
+
+// stageSkipped reports whether stageSkipEnv(hookType) is set to anything
+// but "", "0" or "false".
+func stageSkipped(hookType string) bool {
+	switch strings.ToLower(strings.TrimSpace(os.Getenv(stageSkipEnv(hookType)))) {
+	case "", "0", "false":
+		return false
+	}
+	return true
+}
+
+// hookImplActiveEnv lists the "<hook type>:<repo root>" pairs currently
+// running hook-impl in this process tree, one per line.
+const hookImplActiveEnv = "PRE_COMMIT_HOOK_IMPL_ACTIVE"
+
+// hookImplActive reports whether key is already listed in active.
+func hookImplActive(active, key string) bool {
+	if active == "" {
+		return false
+	}
+	for _, k := range strings.Split(active, "\n") {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
 // readPrePushStdin reads ref info from stdin for pre-push hooks.
 func readPrePushStdin() []string {
 	info, _ := os.Stdin.Stat()
@@ -192,6 +256,53 @@ func readPrePushStdin() []string {
 	return lines
 }
 
+// zeroSHA is the all-zeros object name git uses for missing refs.
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// prePushArgs turns the "<local ref> <local sha> <remote ref> <remote sha>"
+// lines git passes to pre-push into run arguments selecting the files being
+// pushed. Like Python pre-commit, the first line pushing commits wins. For an
+// existing remote branch the range is remote sha..local sha; for a new branch
+// it starts at the merge-base with what the remote already has, falling back
+// to all files when the branch shares no history with the remote. It returns
+// false when no commits are being pushed (e.g. only deletions).
+func prePushArgs(lines []string, remoteName string) ([]string, bool) {
+	for _, line := range lines {
+		parts := strings.Fields(line)
+		if len(parts) < 4 {
+			continue
+		}
+		localBranch, localSHA, remoteBranch, remoteSHA := parts[0], parts[1], parts[2], parts[3]
+		if localSHA == zeroSHA {
+			continue
+		}
+		branchArgs := []string{"--local-branch", localBranch, "--remote-branch", remoteBranch}
+
+		if remoteSHA != zeroSHA {
+			if _, err := git.ResolveCommit(remoteSHA); err == nil {
+				return append([]string{"--from-ref", remoteSHA, "--to-ref", localSHA}, branchArgs...), true
+			}
+		}
+
+		// New branch (or unknown remote sha): find the commits the remote
+		// doesn't have yet and start from the parent of the oldest one.
+		remotes := "--remotes"
+		if remoteName != "" {
+			remotes += "=" + remoteName
+		}
+		out, err := git.CmdOutput("rev-list", localSHA, "--topo-order", "--reverse", "--not", remotes)
+		if err != nil || out == "" {
+			continue
+		}
+		first := strings.Fields(out)[0]
+		if base, err := git.ResolveCommit(first + "^"); err == nil {
+			return append([]string{"--from-ref", base, "--to-ref", localSHA}, branchArgs...), true
+		}
+		return append([]string{"--all-files"}, branchArgs...), true
+	}
+	return nil, false
+}
+
 // runLegacyHook runs the legacy hook script if it exists.
 func runLegacyHook(hookType, hookDir string, args []string) error {
 	legacyPath := filepath.Join(legacyHookDir(hookDir), hookType+".legacy")
@@ -1,7 +1,11 @@
 package cli
 
 import (
+	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -24,3 +28,244 @@ func TestLegacyHookDir(t *testing.T) {
 		t.Errorf("legacyHookDir precedence = %q, want %q", got, want)
 	}
 }
+
+// gitOut runs a git command in dir and returns its trimmed output.
+func gitOut(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git %s failed: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commitFile writes name in dir and commits it, returning the new HEAD.
+func commitFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", name)
+	gitIn(t, dir, "commit", "-m", "add "+name)
+	return gitOut(t, dir, "rev-parse", "HEAD")
+}
+
+func TestPrePushArgs(t *testing.T) {
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-b", "main")
+	root := commitFile(t, dir, "a.txt")
+	base := commitFile(t, dir, "b.txt")
+	gitIn(t, dir, "update-ref", "refs/remotes/origin/main", base)
+	gitIn(t, dir, "checkout", "-b", "feature")
+	local := commitFile(t, dir, "c.txt")
+	t.Chdir(dir)
+
+	tests := []struct {
+		name   string
+		lines  []string
+		want   []string
+		wantOK bool
+	}{
+		{
+			name:   "existing branch",
+			lines:  []string{"refs/heads/feature " + local + " refs/heads/feature " + root},
+			want:   []string{"--from-ref", root, "--to-ref", local},
+			wantOK: true,
+		},
+		{
+			name:   "new branch uses merge-base with remote",
+			lines:  []string{"refs/heads/feature " + local + " refs/heads/feature " + zeroSHA},
+			want:   []string{"--from-ref", base, "--to-ref", local},
+			wantOK: true,
+		},
+		{
+			name:   "deletion only",
+			lines:  []string{"(delete) " + zeroSHA + " refs/heads/old " + base},
+			wantOK: false,
+		},
+		{
+			name:   "already on remote",
+			lines:  []string{"refs/heads/main " + base + " refs/heads/other " + zeroSHA},
+			wantOK: false,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := prePushArgs(tc.lines, "origin")
+			if ok != tc.wantOK {
+				t.Fatalf("ok = %v, want %v", ok, tc.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !slices.Equal(got[:len(tc.want)], tc.want) {
+				t.Errorf("args = %v, want prefix %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPrePushArgsNoSharedHistory(t *testing.T) {
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-b", "main")
+	local := commitFile(t, dir, "a.txt")
+	t.Chdir(dir)
+
+	got, ok := prePushArgs([]string{"refs/heads/main " + local + " refs/heads/main " + zeroSHA}, "origin")
+	if !ok || got[0] != "--all-files" {
+		t.Errorf("args = %v, ok = %v, want --all-files", got, ok)
+	}
+}
+
+func TestHookImplCommand_PrePushStdin(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "files.log")
+	cfg := `repos:
+-   repo: local
+    hooks:
+    -   id: record
+        name: record
+        entry: sh -c 'printf "%s\n" "$@" >> ` + logPath + `' --
+        language: system
+        stages: [pre-push]
+`
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", ".pre-commit-config.yaml")
+	gitIn(t, dir, "commit", "-m", "config")
+	base := commitFile(t, dir, "pushed-before.txt")
+	gitIn(t, dir, "update-ref", "refs/remotes/origin/main", base)
+	commitFile(t, dir, "new-one.txt")
+	local := commitFile(t, dir, "new-two.txt")
+	// A staged-but-uncommitted file must not be checked.
+	if err := os.WriteFile(filepath.Join(dir, "staged.txt"), []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", "staged.txt")
+	t.Chdir(dir)
+
+	stdin := filepath.Join(t.TempDir(), "stdin")
+	line := "refs/heads/main " + local + " refs/heads/main " + base + "\n"
+	if err := os.WriteFile(stdin, []byte(line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	oldStdin := os.Stdin
+	os.Stdin = f
+	defer func() { os.Stdin = oldStdin }()
+
+	cmd := &HookImplCommand{Meta: &Meta{}}
+	code := cmd.Run([]string{"--hook-type", "pre-push", "--hook-dir", filepath.Join(dir, ".git", "hooks"), "--", "origin", "https://example.com/repo.git"})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Fields(string(data))
+	slices.Sort(got)
+	if want := []string{"new-one.txt", "new-two.txt"}; !slices.Equal(got, want) {
+		t.Errorf("hook ran on %v, want %v", got, want)
+	}
+}
+
+func TestHookImplActive(t *testing.T) {
+	active := "pre-commit:/repo/a\ncommit-msg:/repo/b"
+	if !hookImplActive(active, "commit-msg:/repo/b") {
+		t.Error("expected commit-msg:/repo/b to be active")
+	}
+	if hookImplActive(active, "pre-commit:/repo/b") {
+		t.Error("same hook type in another repo must not be treated as recursive")
+	}
+	if hookImplActive("", "pre-commit:/repo/a") {
+		t.Error("nothing is active when the variable is empty")
+	}
+}
+
+func TestHookImplCommand_RecursiveInvocation(t *testing.T) {
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-b", "main")
+	t.Chdir(dir)
+	root := gitOut(t, dir, "rev-parse", "--show-toplevel")
+
+	// Simulate a hook that ran `git commit`, re-entering pre-commit while the
+	// outer invocation is still active.
+	t.Setenv(hookImplActiveEnv, "pre-commit:"+root)
+
+	r, w, _ := os.Pipe()
+	oldStderr := os.Stderr
+	os.Stderr = w
+	code := (&HookImplCommand{Meta: &Meta{}}).Run([]string{"--hook-type", "pre-commit", "--skip-on-missing-config"})
+	w.Close()
+	os.Stderr = oldStderr
+	buf := make([]byte, 4096)
+	n, _ := r.Read(buf)
+
+	if code != 1 {
+		t.Fatalf("expected exit code 1 for recursive invocation, got %d", code)
+	}
+	if !strings.Contains(string(buf[:n]), "recursive pre-commit hook invocation detected") {
+		t.Errorf("expected recursion message, got %q", buf[:n])
+	}
+
+	// A different hook type in the same repo is still allowed.
+	if code := (&HookImplCommand{Meta: &Meta{}}).Run([]string{"--hook-type", "commit-msg", "--skip-on-missing-config"}); code != 0 {
+		t.Errorf("expected commit-msg to run (and skip on missing config), got exit code %d", code)
+	}
+	if got := os.Getenv(hookImplActiveEnv); got != "pre-commit:"+root {
+		t.Errorf("marker not restored after hook-impl, got %q", got)
+	}
+}
+
+func TestHookImplCommand_StageSkipEnv(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "ran.log")
+	cfg := "repos:\n-   repo: local\n    hooks:\n" +
+		"    -   id: record\n        name: record\n        entry: sh -c 'echo $0 >> " + logPath + "'\n        language: system\n" +
+		"        always_run: true\n        pass_filenames: false\n        stages: [post-checkout, post-merge]\n"
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", ".pre-commit-config.yaml")
+	gitIn(t, dir, "commit", "-m", "config")
+	head := gitOut(t, dir, "rev-parse", "HEAD")
+	t.Chdir(dir)
+	t.Setenv("PRE_COMMIT_SKIP_POST_CHECKOUT", "1")
+	t.Setenv("PRE_COMMIT_SKIP_POST_MERGE", "false")
+
+	if code := (&HookImplCommand{Meta: &Meta{}}).Run([]string{"--hook-type", "post-checkout", "--", head, head, "1"}); code != 0 {
+		t.Fatalf("post-checkout: expected exit code 0, got %d", code)
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Fatalf("post-checkout hook ran despite PRE_COMMIT_SKIP_POST_CHECKOUT")
+	}
+
+	if code := (&HookImplCommand{Meta: &Meta{}}).Run([]string{"--hook-type", "post-merge", "--", "0"}); code != 0 {
+		t.Fatalf("post-merge: expected exit code 0, got %d", code)
+	}
+	if _, err := os.Stat(logPath); err != nil {
+		t.Errorf("post-merge hook did not run with PRE_COMMIT_SKIP_POST_MERGE=false: %v", err)
+	}
+}
+
+func TestStageSkipped(t *testing.T) {
+	for v, want := range map[string]bool{"": false, "0": false, "false": false, "FALSE": false, "1": true, "true": true, "yes": true} {
+		t.Setenv("PRE_COMMIT_SKIP_POST_CHECKOUT", v)
+		if got := stageSkipped("post-checkout"); got != want {
+			t.Errorf("PRE_COMMIT_SKIP_POST_CHECKOUT=%q: stageSkipped = %v, want %v", v, got, want)
+		}
+	}
+}
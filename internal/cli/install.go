@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
 	flags "github.com/jessevdk/go-flags"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
 	"github.com/blairham/go-pre-commit/v4/internal/git"
 	"github.com/blairham/go-pre-commit/v4/internal/hook"
 	"github.com/blairham/go-pre-commit/v4/internal/output"
@@ -64,6 +66,7 @@ type installFlags struct {
 	AllowMissing bool     `long:"allow-missing-config" description:"Allow the hook installation to succeed when no config is found."`
 	Overwrite    bool     `short:"f" long:"overwrite" description:"Overwrite existing hooks."`
 	InstallHooks bool     `long:"install-hooks" description:"Install hook environments for all hooks in the config."`
+	IfNeeded     bool     `long:"if-needed" description:"Only write hook scripts whose content would change."`
 }
 
 func (c *InstallCommand) Run(args []string) int {
@@ -90,13 +93,19 @@ func (c *InstallCommand) Run(args []string) int {
 		}
 	}
 
-	// Validate hook types.
+	// Validate hook types, installing each only once however often it is
+	// given.
+	var seen []string
 	for _, ht := range typesToInstall {
 		if _, ok := hookTypes[ht]; !ok {
 			fmt.Fprintf(os.Stderr, "Error: unknown hook type: %s. Choose from: %s\n", ht, strings.Join(sortedHookTypes(), ", "))
 			return 1
 		}
+		if !slices.Contains(seen, ht) {
+			seen = append(seen, ht)
+		}
 	}
+	typesToInstall = seen
 
 	hooksDir, err := resolveHooksDir("")
 	if err != nil {
@@ -120,27 +129,39 @@ func (c *InstallCommand) Run(args []string) int {
 	// Install each hook type.
 	for _, hookType := range typesToInstall {
 		hookFile := filepath.Join(hooksDir, hookType)
+		installID := "pre-commit-" + hookType
+		content := fmt.Sprintf(hookTemplate, installID, opts.Config, hookType)
+
+		// With --if-needed, leave an identical script (and its mtime) alone.
+		if opts.IfNeeded {
+			if existing, err := os.ReadFile(hookFile); err == nil && string(existing) == content {
+				fmt.Printf("pre-commit already up to date at %s\n", hookFile)
+				continue
+			}
+		}
 
-		// Check for existing hook.
-		if !opts.Overwrite {
-			if info, err := os.Stat(hookFile); err == nil && info.Size() > 0 {
-				content, err := os.ReadFile(hookFile)
-				if err == nil && !isPreCommitHook(string(content)) {
-					// Back up the legacy hook.
-					legacyFile := hookFile + ".legacy"
-					if err := os.Rename(hookFile, legacyFile); err != nil {
-						fmt.Fprintf(os.Stderr, "Error: existing hook %s found and cannot be backed up: %v\nuse --overwrite to replace it\n", hookFile, err)
-						return 1
-					}
-					output.Info("Moving existing hook %s to %s", hookFile, legacyFile)
+		// Check for existing hook. A script we generated is simply replaced;
+		// with --overwrite, so is anything else, along with a backup of it
+		// left by an earlier install.
+		legacyFile := hookFile + ".legacy"
+		if opts.Overwrite {
+			if err := os.Remove(legacyFile); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Error: failed to remove %s: %v\n", legacyFile, err)
+				return 1
+			}
+		} else if info, err := os.Stat(hookFile); err == nil && info.Size() > 0 {
+			content, err := os.ReadFile(hookFile)
+			if err == nil && !isPreCommitHook(string(content)) {
+				// Back up the legacy hook.
+				if err := os.Rename(hookFile, legacyFile); err != nil {
+					fmt.Fprintf(os.Stderr, "Error: existing hook %s found and cannot be backed up: %v\nuse --overwrite to replace it\n", hookFile, err)
+					return 1
 				}
+				output.Info("Moving existing hook %s to %s", hookFile, legacyFile)
 			}
 		}
 
 		// Write the hook script.
-		installID := "pre-commit-" + hookType
-		content := fmt.Sprintf(hookTemplate, installID, opts.Config, hookType)
-
 		if err := os.WriteFile(hookFile, []byte(content), 0o755); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: failed to write hook: %v\n", err)
 			return 1
@@ -152,7 +173,7 @@ func (c *InstallCommand) Run(args []string) int {
 	// Install hook environments if requested.
 	if opts.InstallHooks {
 		if err := installAllHookEnvironments(opts.Config); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			printError(err, "%v", err)
 			return 1
 		}
 	}
@@ -165,15 +186,19 @@ func (c *InstallCommand) Help() string {
 Usage: pre-commit install [options]
 
   Install the pre-commit script into .git/hooks/.
-  By default installs a pre-commit hook. Use -t multiple times to install
-  several hook types.
+  By default installs the config's default_install_hook_types, or a
+  pre-commit hook. Use -t multiple times to install several hook types in
+  one go; scripts from an earlier install are replaced in place.
 
 Options:
 
   -t, --hook-type=TYPE         The hook type(s) to install.
       --allow-missing-config   Allow installation when no config is found.
-  -f, --overwrite              Overwrite existing hooks.
+  -f, --overwrite              Overwrite existing hooks of every type
+                               installed, dropping any .legacy backups.
       --install-hooks          Install hook environments for all hooks.
+      --if-needed              Only write hook scripts that are missing or out
+                               of date; up-to-date ones are left untouched.
   -c, --config=FILE            Path to alternate config file.
       --color=MODE             Whether to use color (auto, always, never).
 `)
@@ -279,19 +304,77 @@ type InstallHooksCommand struct {
 	Meta *Meta
 }
 
+type installHooksFlags struct {
+	GlobalFlags
+	KeepGoing bool `long:"keep-going" description:"Attempt every environment and summarize successes and failures."`
+	Verify    bool `long:"verify" description:"After installing, launch each hook's entry to check that it starts."`
+}
+
 func (c *InstallHooksCommand) Run(args []string) int {
-	var opts GlobalFlags
+	var opts installHooksFlags
 	_, err := flags.ParseArgs(&opts, args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		return 1
 	}
 
-	if err := installAllHookEnvironments(opts.Config); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	hooks, err := resolveAllHooks(opts.Config)
+	if err != nil {
+		printError(err, "%v", err)
 		return 1
 	}
-	return 0
+
+	exit := 0
+	if opts.KeepGoing {
+		summary := hook.InstallEnvironmentsSummary(context.Background(), hooks)
+		printInstallSummary(summary)
+		if len(summary.Failed) > 0 {
+			exit = 1
+		}
+	} else if err := hook.InstallEnvironments(context.Background(), hooks); err != nil {
+		printError(err, "%v", err)
+		return 1
+	}
+
+	if opts.Verify {
+		results := hook.VerifyHooks(context.Background(), hooks)
+		if printVerifyResults(results) > 0 {
+			exit = 1
+		}
+	}
+	return exit
+}
+
+// printVerifyResults lists which hooks' entries launched and which did not,
+// with their errors, and returns the number of failures.
+func printVerifyResults(results []hook.VerifyResult) int {
+	fmt.Println()
+	fmt.Println("Hook verification:")
+	failed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			failed++
+			fmt.Printf("  Failed     %s (%s): %s\n", r.Hook.Repo, r.Hook.ID, errkind.Prefix(errkind.Of(r.Err), r.Err.Error()))
+			continue
+		}
+		fmt.Printf("  Passed     %s (%s)\n", r.Hook.Repo, r.Hook.ID)
+	}
+	fmt.Printf("%d passed, %d failed.\n", len(results)-failed, failed)
+	return failed
+}
+
+// printInstallSummary lists the environments installed and the ones that
+// failed, with their errors.
+func printInstallSummary(summary hook.InstallSummary) {
+	fmt.Println()
+	fmt.Println("Environment install summary:")
+	for _, h := range summary.Installed {
+		fmt.Printf("  Installed  %s (%s)\n", h.Repo, h.ID)
+	}
+	for _, f := range summary.Failed {
+		fmt.Printf("  Failed     %s (%s): %v\n", f.Hook.Repo, f.Hook.ID, f.Err)
+	}
+	fmt.Printf("%d installed, %d failed.\n", len(summary.Installed), len(summary.Failed))
 }
 
 func (c *InstallHooksCommand) Help() string {
@@ -300,8 +383,18 @@ Usage: pre-commit install-hooks [options]
 
   Install hook environments for all hooks in the config.
 
+  With --keep-going, every environment is attempted even if some fail, and a
+  summary of successes and failures is printed at the end. The exit code is
+  non-zero if any environment failed.
+
+  With --verify, each hook's entry is then launched with --version and no
+  files, from an empty directory, to check that its tool actually starts.
+  Only a failure to launch counts; the tool's own exit status is ignored.
+
 Options:
 
+      --keep-going    Attempt every environment and summarize the results.
+      --verify        Launch each hook's entry to check that it starts.
   -c, --config=FILE   Path to alternate config file.
       --color=MODE    Whether to use color (auto, always, never).
 `)
@@ -312,9 +405,19 @@ func (c *InstallHooksCommand) Synopsis() string {
 }
 
 func installAllHookEnvironments(cfgPath string) error {
+	hooks, err := resolveAllHooks(cfgPath)
+	if err != nil {
+		return err
+	}
+	return hook.InstallEnvironments(context.Background(), hooks)
+}
+
+// resolveAllHooks loads the config and resolves every hook in it, cloning
+// repos as needed.
+func resolveAllHooks(cfgPath string) ([]*hook.Hook, error) {
 	cfg, err := config.LoadConfig(cfgPath)
 	if err != nil {
-		return fmt.Errorf("failed to load config: %w", err)
+		return nil, fmt.Errorf("failed to load config: %w", err)
 	}
 
 	s := store.New("")
@@ -322,10 +425,9 @@ func installAllHookEnvironments(cfgPath string) error {
 	resolver := repository.NewResolver(s, cfg)
 	hooks, err := resolver.ResolveAll(context.Background(), cfg)
 	if err != nil {
-		return fmt.Errorf("failed to resolve hooks: %w", err)
+		return nil, fmt.Errorf("failed to resolve hooks: %w", err)
 	}
-
-	return hook.InstallEnvironments(context.Background(), hooks)
+	return hooks, nil
 }
 
 func resolveHooksDir(hookDir string) (string, error) {
@@ -2,7 +2,12 @@
 package cli
 
 import (
+	"fmt"
+	"os"
+
 	mcli "github.com/mitchellh/cli"
+
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
 )
 
 // Meta contains shared state for all commands.
@@ -12,6 +17,17 @@ type Meta struct {
 
 // GlobalFlags are flags available to all commands.
 type GlobalFlags struct {
-	Color  string `long:"color" default:"auto" description:"Whether to use color in output. Options: auto, always, never."`
+	Color  string `long:"color" description:"Whether to use color in output. Options: auto, always, never. Defaults to $PRE_COMMIT_COLOR or auto."`
 	Config string `long:"config" short:"c" default:".pre-commit-config.yaml" description:"Path to alternate config file."`
 }
+
+// printError prints an error line to stderr. It starts with the errkind
+// category of err, e.g. "config-error: ", so wrappers can classify it, or
+// with "Error: " if err has none.
+func printError(err error, format string, args ...any) {
+	kind := errkind.Of(err)
+	if kind == "" {
+		kind = "Error"
+	}
+	fmt.Fprintln(os.Stderr, errkind.Prefix(kind, fmt.Sprintf(format, args...)))
+}
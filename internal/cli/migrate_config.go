@@ -6,6 +6,8 @@ import (
 	"strings"
 
 	flags "github.com/jessevdk/go-flags"
+
+	"github.com/blairham/go-pre-commit/v4/internal/config"
 )
 
 // MigrateConfigCommand implements the "migrate-config" command.
@@ -30,6 +32,12 @@ func (c *MigrateConfigCommand) Run(args []string) int {
 	raw := string(content)
 	migrated := false
 
+	// Wrap an old-style top-level list of repos in a repos: key.
+	if config.IsListConfig(content) {
+		raw = config.MigrateListConfig(raw)
+		migrated = true
+	}
+
 	// Replace old-style sha: with rev:
 	if strings.Contains(raw, "\n    sha:") || strings.Contains(raw, "\n  sha:") {
 		raw = strings.ReplaceAll(raw, "\n    sha:", "\n    rev:")
@@ -59,6 +59,7 @@ func Run(args []string, build BuildInfo) int {
 			"autoupdate":        func() (mcli.Command, error) { return &AutoupdateCommand{Meta: meta}, nil },
 			"clean":             func() (mcli.Command, error) { return &CleanCommand{Meta: meta}, nil },
 			"gc":                func() (mcli.Command, error) { return &GCCommand{Meta: meta}, nil },
+			"doctor":            func() (mcli.Command, error) { return &DoctorCommand{Meta: meta}, nil },
 			"init-templatedir":  func() (mcli.Command, error) { return &InitTemplateDirCommand{Meta: meta}, nil },
 			"sample-config":     func() (mcli.Command, error) { return &SampleConfigCommand{Meta: meta}, nil },
 			"try-repo":          func() (mcli.Command, error) { return &TryRepoCommand{Meta: meta}, nil },
@@ -75,6 +76,27 @@ func Run(args []string, build BuildInfo) int {
 			"hazmat n1": func() (mcli.Command, error) {
 				return &HazmatN1Command{Meta: meta}, nil
 			},
+			"store dir": func() (mcli.Command, error) {
+				return &StoreDirCommand{Meta: meta}, nil
+			},
+			"store du": func() (mcli.Command, error) {
+				return &StoreDuCommand{Meta: meta}, nil
+			},
+			"store list": func() (mcli.Command, error) {
+				return &StoreListCommand{Meta: meta}, nil
+			},
+			"store export": func() (mcli.Command, error) {
+				return &StoreExportCommand{Meta: meta}, nil
+			},
+			"store import": func() (mcli.Command, error) {
+				return &StoreImportCommand{Meta: meta}, nil
+			},
+			"store prune-repos": func() (mcli.Command, error) {
+				return &StorePruneReposCommand{Meta: meta}, nil
+			},
+			"store unlock": func() (mcli.Command, error) {
+				return &StoreUnlockCommand{Meta: meta}, nil
+			},
 		},
 		HiddenCommands: []string{
 			"hook-impl",
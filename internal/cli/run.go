@@ -2,17 +2,28 @@ package cli
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
 	flags "github.com/jessevdk/go-flags"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
 	"github.com/blairham/go-pre-commit/v4/internal/git"
 	"github.com/blairham/go-pre-commit/v4/internal/hook"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
 	"github.com/blairham/go-pre-commit/v4/internal/output"
+	"github.com/blairham/go-pre-commit/v4/internal/pcre"
 	"github.com/blairham/go-pre-commit/v4/internal/repository"
 	"github.com/blairham/go-pre-commit/v4/internal/staged"
 	"github.com/blairham/go-pre-commit/v4/internal/store"
@@ -21,16 +32,30 @@ import (
 // RunCommand implements the "run" command.
 type RunCommand struct {
 	Meta *Meta
+
+	// fromHook is set when a git hook invokes run through hook-impl.
+	fromHook bool
 }
 
 type runFlags struct {
 	GlobalFlags
 	AllFiles        bool     `short:"a" long:"all-files" description:"Run on all files in the repo."`
-	Files           []string `long:"files" description:"Specific filenames to run hooks on."`
+	Files           []string `long:"files" description:"Specific filenames (or globs) to run hooks on."`
+	Include         string   `long:"include" description:"Only run hooks on selected files whose path matches REGEX."`
+	Exclude         string   `long:"exclude" description:"Don't run hooks on selected files whose path matches REGEX."`
+	FilesFrom       string   `long:"files-from" description:"Read filenames to run hooks on from FILE (- for stdin)."`
+	FilesSeparator  string   `long:"files-separator" choice:"newline" choice:"nul" default:"newline" description:"Separator between filenames read by --files-from."`
+	Null            bool     `short:"z" long:"null" description:"Shorthand for --files-separator nul."`
 	ShowDiffOnFail  bool     `long:"show-diff-on-failure" description:"When hooks fail, show the diff of changes."`
+	PatchFile       string   `long:"patch-file" description:"When hooks fail, write the diff of changes to this file."`
+	AnnotationsFile string   `long:"annotations-file" description:"Write file/line annotations parsed from failing hooks' output to this file as JSON."`
+	JUnitXML        string   `long:"junit-xml" description:"Write hook results to this file as JUnit XML."`
+	OutputFormat    string   `long:"output-format" choice:"text" choice:"json" default:"text" description:"Format of the hook results: text, or json written to stdout."`
 	HookStage       string   `long:"hook-stage" description:"The stage during which the hook is fired."`
 	FromRef         string   `long:"from-ref" description:"Ref to check revision changes."`
 	ToRef           string   `long:"to-ref" description:"Ref to check revision changes."`
+	Since           string   `long:"since" description:"Run on files changed since REF (same as --from-ref REF --to-ref HEAD)."`
+	ChangedWithin   string   `long:"changed-within" description:"Only run on files modified on disk within DURATION (e.g. 5m)."`
 	Source          string   `short:"s" long:"source" description:"(DEPRECATED: use --from-ref) Ref to check revision changes."`
 	Origin          string   `short:"o" long:"origin" description:"(DEPRECATED: use --to-ref) Ref to check revision changes."`
 	CommitMsgFn     string   `long:"commit-msg-filename" description:"Filename to check when running during commit-msg."`
@@ -46,9 +71,21 @@ type runFlags struct {
 	PreRebaseUp     string   `long:"pre-rebase-upstream" description:"Upstream from which the series was forked."`
 	PreRebaseBranch string   `long:"pre-rebase-branch" description:"Branch being rebased."`
 	Verbose         bool     `short:"v" long:"verbose" description:"Produce hook output regardless of success."`
+	Quiet           bool     `short:"q" long:"quiet" description:"Show only failed hooks and a one-line summary."`
+	ShowFullCmd     bool     `long:"show-full-command" description:"With --verbose, list every filename in the echoed hook command."`
+	MaxOutputLines  int      `long:"max-output-lines" description:"Show at most the last N lines of each hook's output."`
+	StreamOutput    bool     `long:"stream-output" description:"Show each hook's output live as it runs; implies serial execution."`
+	DedupOutput     bool     `long:"dedup-output" description:"Print output shared by several failed hooks only once."`
+	CI              bool     `long:"ci" description:"Also skip the hooks listed under the config's ci.skip."`
 	FailFast        bool     `long:"fail-fast" description:"Stop running hooks after the first failure."`
-	NoInstall       bool     `long:"no-install" description:"Skip automatic installation of hook environments."`
+	NoInstall       bool     `long:"no-install" description:"Fail instead of installing missing hook environments."`
 	Jobs            int      `short:"j" long:"jobs" description:"Number of jobs to run in parallel."`
+	Parallel        bool     `long:"parallel" description:"Run separate hooks concurrently, up to --jobs at a time."`
+	CacheTypes      bool     `long:"cache-types" description:"Reuse file type classifications from earlier runs."`
+	DetectNoopChurn bool     `long:"detect-noop-churn" description:"Hint when a hook only changes whitespace or line endings."`
+	HookArgs        string   `long:"hook-args" description:"Extra arguments to append to the selected hook's args."`
+	DumpEnv         bool     `long:"dump-env" description:"Print the environment the selected hook would run with, without running it."`
+	OnlyChanged     bool     `long:"only-changed-hooks" description:"Run only hooks added or changed in the config since --from-ref, on all files."`
 }
 
 func (c *RunCommand) Run(args []string) int {
@@ -74,6 +111,18 @@ func (c *RunCommand) Run(args []string) int {
 	if opts.Origin != "" && opts.ToRef == "" {
 		opts.ToRef = opts.Origin
 	}
+	refsGiven := opts.FromRef != "" || opts.ToRef != ""
+
+	// CI systems describe the diff range with the same variables pre-commit
+	// exports to hooks; use them when no file selection was given. A git
+	// hook ignores them: a commit made inside a pre-push hook or a CI job
+	// still checks its staged files.
+	if !c.fromHook && opts.FromRef == "" && opts.ToRef == "" && !opts.AllFiles && len(opts.Files) == 0 && opts.FilesFrom == "" && opts.Since == "" {
+		from, to := os.Getenv("PRE_COMMIT_FROM_REF"), os.Getenv("PRE_COMMIT_TO_REF")
+		if from != "" && to != "" {
+			opts.FromRef, opts.ToRef = from, to
+		}
+	}
 
 	// At most one positional arg (hook-id).
 	if len(remaining) > 1 {
@@ -83,11 +132,94 @@ func (c *RunCommand) Run(args []string) int {
 
 	output.SetColorModeFromString(opts.Color)
 
+	// With --output-format=json stdout carries only the results; everything
+	// else that would be printed there goes to stderr.
+	jsonOut := os.Stdout
+	if opts.OutputFormat == "json" {
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = jsonOut }()
+	}
+
 	// --files and --all-files are mutually exclusive.
 	if opts.AllFiles && len(opts.Files) > 0 {
 		fmt.Fprintf(os.Stderr, "Error: --all-files and --files are mutually exclusive\n")
 		return 1
 	}
+	if opts.Since != "" && (opts.AllFiles || len(opts.Files) > 0 || opts.FromRef != "" || opts.ToRef != "") {
+		fmt.Fprintf(os.Stderr, "Error: --since is mutually exclusive with --all-files, --files, --from-ref and --to-ref\n")
+		return 1
+	}
+	if opts.FilesFrom != "" && (opts.AllFiles || len(opts.Files) > 0 || opts.Since != "") {
+		fmt.Fprintf(os.Stderr, "Error: --files-from is mutually exclusive with --all-files, --files and --since\n")
+		return 1
+	}
+	// With --all-files, hooks aren't checked for modifying files at all.
+	if opts.DetectNoopChurn && opts.AllFiles {
+		fmt.Fprintf(os.Stderr, "Error: --detect-noop-churn can't be used with --all-files\n")
+		return 1
+	}
+	for _, f := range []struct{ name, pattern string }{{"--include", opts.Include}, {"--exclude", opts.Exclude}} {
+		if f.pattern == "" {
+			continue
+		}
+		if _, err := pcre.Compile(f.pattern); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: invalid %s pattern %q: %v\n", f.name, f.pattern, err)
+			return 1
+		}
+	}
+	stage := config.HookTypePreCommit
+	if opts.HookStage != "" {
+		if stage, err = config.ParseStage(opts.HookStage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --hook-stage: %v\n", err)
+			return 1
+		}
+	}
+	// Message hooks check the commit message file, as they would in a real
+	// commit, rather than the staged files.
+	msgStage := stage == config.HookTypeCommitMsg || stage == config.HookTypePrepareCommitMsg
+	if msgStage {
+		if opts.CommitMsgFn == "" {
+			fmt.Fprintf(os.Stderr, "Error: --hook-stage %s requires --commit-msg-filename\n", stage)
+			return 1
+		}
+		if info, err := os.Stat(opts.CommitMsgFn); err != nil || info.IsDir() {
+			fmt.Fprintf(os.Stderr, "Error: --commit-msg-filename %s is not a file\n", opts.CommitMsgFn)
+			return 1
+		}
+		if opts.AllFiles || len(opts.Files) > 0 || opts.FilesFrom != "" || opts.Since != "" || refsGiven || opts.ChangedWithin != "" {
+			fmt.Fprintf(os.Stderr, "Error: --hook-stage %s runs on the commit message file and can't be used with "+
+				"--all-files, --files, --files-from, --since, --from-ref/--to-ref or --changed-within\n", stage)
+			return 1
+		}
+	}
+	if opts.Quiet && (opts.Verbose || opts.StreamOutput) {
+		fmt.Fprintf(os.Stderr, "Error: --quiet is mutually exclusive with --verbose and --stream-output\n")
+		return 1
+	}
+	if opts.Quiet {
+		output.SetQuiet(true)
+		defer output.SetQuiet(false)
+	}
+	if opts.OnlyChanged {
+		if opts.FromRef == "" && opts.Since == "" {
+			fmt.Fprintf(os.Stderr, "Error: --only-changed-hooks requires --from-ref or --since\n")
+			return 1
+		}
+		if len(opts.Files) > 0 || opts.FilesFrom != "" {
+			fmt.Fprintf(os.Stderr, "Error: --only-changed-hooks is mutually exclusive with --files and --files-from\n")
+			return 1
+		}
+	}
+
+	var changedWithin time.Duration
+	if opts.ChangedWithin != "" {
+		d, err := time.ParseDuration(opts.ChangedWithin)
+		if err != nil || d <= 0 {
+			fmt.Fprintf(os.Stderr, "Error: --changed-within: invalid duration %q (e.g. 90s, 5m, 1h)\n", opts.ChangedWithin)
+			return 1
+		}
+		changedWithin = d
+	}
 
 	// Load config.
 	cfg, err := config.LoadConfig(opts.Config)
@@ -95,10 +227,16 @@ func (c *RunCommand) Run(args []string) int {
 		if os.Getenv("PRE_COMMIT_ALLOW_NO_CONFIG") != "" {
 			return 0
 		}
-		fmt.Fprintf(os.Stderr, "Error: failed to load config: %v\n", err)
+		printError(err, "failed to load config: %v", err)
 		return 1
 	}
 
+	// With --ci, skip what pre-commit.ci is told to skip.
+	var ciSkip []string
+	if opts.CI {
+		ciSkip, _ = cfg.CISkip() // checked by LoadConfig
+	}
+
 	// Propagate fail_fast from CLI or config.
 	if opts.FailFast {
 		cfg.FailFast = true
@@ -111,32 +249,90 @@ func (c *RunCommand) Run(args []string) int {
 		return 1
 	}
 
+	// --since REF is shorthand for --from-ref REF --to-ref HEAD.
+	if opts.Since != "" {
+		if _, err := git.ResolveCommit(opts.Since); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --since: ref %q does not exist\n", opts.Since)
+			return 1
+		}
+		opts.FromRef, opts.ToRef = opts.Since, "HEAD"
+	}
+
+	// --only-changed-hooks runs the hooks a config change touched against
+	// every file, rather than every hook against the changed files.
+	var changedHooks map[config.HookRef]bool
+	if opts.OnlyChanged {
+		changedHooks, err = changedConfigHooks(root, opts.Config, opts.FromRef, opts.ToRef)
+		if err != nil {
+			printError(err, "--only-changed-hooks: %v", err)
+			return 1
+		}
+		opts.AllFiles = true
+	}
+
 	// Set PRE_COMMIT=1.
 	os.Setenv("PRE_COMMIT", "1")
 	defer os.Unsetenv("PRE_COMMIT")
 
+	// SIGINT/SIGTERM cancel ctx; hook processes are signalled in turn.
+	ctx, stop := interruptContext()
+	defer stop()
+
 	// Initialize the store.
 	s := store.New("")
 
 	// Resolve hooks.
 	resolver := repository.NewResolver(s, cfg)
-	hooks, err := resolver.ResolveAll(context.Background(), cfg)
+	hooks, err := resolver.ResolveAll(ctx, cfg)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to resolve hooks: %v\n", err)
+		printError(err, "failed to resolve hooks: %v", err)
 		return 1
 	}
+	if changedHooks != nil {
+		hooks = slices.DeleteFunc(hooks, func(h *hook.Hook) bool {
+			return !changedHooks[config.HookRef{Repo: h.Repo, ID: h.ID}]
+		})
+		if len(hooks) == 0 {
+			output.Info("No hooks changed in the config since %s.", opts.FromRef)
+			return 0
+		}
+	}
 
 	// Determine files.
 	var filenames []string
 	noStash := os.Getenv("PRE_COMMIT_NO_STASH") != ""
-	if opts.AllFiles {
+	if msgStage {
+		filenames = []string{opts.CommitMsgFn}
+	} else if opts.AllFiles {
 		filenames, err = git.GetAllFiles()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: failed to get all files: %v\n", err)
 			return 1
 		}
 	} else if len(opts.Files) > 0 {
-		filenames = opts.Files
+		filenames, err = expandFileGlobs(opts.Files)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to expand --files: %v\n", err)
+			return 1
+		}
+		if f := outsideRoot(root, filenames); f != "" {
+			fmt.Fprintf(os.Stderr, "Error: --files path %q is outside the repository at %s\n", f, root)
+			return 1
+		}
+	} else if opts.FilesFrom != "" {
+		sep := byte('\n')
+		if opts.Null || opts.FilesSeparator == "nul" {
+			sep = 0
+		}
+		filenames, err = readFilesFrom(opts.FilesFrom, sep)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to read --files-from: %v\n", err)
+			return 1
+		}
+		if f := outsideRoot(root, filenames); f != "" {
+			fmt.Fprintf(os.Stderr, "Error: --files-from path %q is outside the repository at %s\n", f, root)
+			return 1
+		}
 	} else if opts.FromRef != "" && opts.ToRef != "" {
 		filenames, err = git.GetChangedFiles(opts.FromRef, opts.ToRef)
 		if err != nil {
@@ -151,10 +347,10 @@ func (c *RunCommand) Run(args []string) int {
 		}
 	}
 
-	// Determine stage.
-	stage := config.Stage(opts.HookStage)
-	if stage == "" {
-		stage = config.HookTypePreCommit
+	// --changed-within narrows whichever file set was selected above to
+	// files recently modified on disk.
+	if opts.ChangedWithin != "" {
+		filenames = modifiedWithin(root, filenames, time.Now().Add(-changedWithin))
 	}
 
 	// Filter to a single hook if specified.
@@ -163,40 +359,26 @@ func (c *RunCommand) Run(args []string) int {
 		hookID = remaining[0]
 	}
 
-	// Determine if we need to stash.
-	needsStash := !opts.AllFiles && len(opts.Files) == 0 && opts.FromRef == "" && opts.ToRef == "" && !noStash
-	var stashMgr *staged.Manager
-	if needsStash {
-		hasUnstaged, _ := git.HasUnstagedChanges(root)
-		if hasUnstaged {
-			stashMgr = staged.NewManager(root)
-			stashed, err := stashMgr.StashUnstaged()
-			if !stashed || err != nil {
-				output.Warn("Failed to stash unstaged changes: %v", err)
-				stashMgr = nil
-			}
-		}
-	}
-
-	// Install environments (unless --no-install).
-	if !opts.NoInstall {
-		if err := hook.InstallEnvironments(context.Background(), hooks); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: failed to install environments: %v\n", err)
-			return 1
-		}
-	}
-
-	// Run hooks.
-	runner := hook.NewRunner(cfg, hooks, root)
-	result := runner.Run(context.Background(), hook.RunOptions{
+	runOpts := hook.RunOptions{
 		HookID:                     hookID,
 		HookStage:                  stage,
+		AnyStage:                   hookID != "" && opts.HookStage == "",
 		Files:                      filenames,
 		AllFiles:                   opts.AllFiles,
+		Include:                    opts.Include,
+		Exclude:                    opts.Exclude,
 		Verbose:                    opts.Verbose,
+		ShowFullCommand:            opts.ShowFullCmd,
+		MaxOutputLines:             opts.MaxOutputLines,
+		StreamOutput:               opts.StreamOutput,
+		Quiet:                      opts.Quiet,
+		DedupOutput:                opts.DedupOutput,
+		SkipList:                   ciSkip,
 		ShowDiff:                   opts.ShowDiffOnFail,
 		Color:                      opts.Color,
 		Jobs:                       opts.Jobs,
+		Parallel:                   opts.Parallel,
+		CacheTypes:                 opts.CacheTypes,
 		FromRef:                    opts.FromRef,
 		ToRef:                      opts.ToRef,
 		CommitMsgFilename:          opts.CommitMsgFn,
@@ -211,20 +393,129 @@ func (c *RunCommand) Run(args []string) int {
 		RewriteCommand:             opts.RewriteCmd,
 		PreRebaseUpstream:          opts.PreRebaseUp,
 		PreRebaseBranch:            opts.PreRebaseBranch,
-	})
+		DetectNoopChurn:            opts.DetectNoopChurn,
+		CollectAnnotations:         opts.AnnotationsFile != "",
+		CollectReports:             opts.OutputFormat == "json" || opts.JUnitXML != "",
+		DumpEnv:                    opts.DumpEnv,
+		HookArgs:                   languages.ParseEntry(opts.HookArgs),
+	}
 
-	// Restore stash.
-	if stashMgr != nil {
-		if err := stashMgr.Restore(); err != nil {
-			output.Warn("Failed to restore unstaged changes: %v", err)
+	// With --no-install, environments must already be provisioned.
+	if opts.NoInstall {
+		if missing := hook.MissingEnvironments(hook.SelectHooks(hooks, runOpts)); len(missing) > 0 {
+			fmt.Fprintln(os.Stderr, errkind.Prefix(errkind.Env, "--no-install was given but these hook environments are not installed:"))
+			for _, h := range missing {
+				fmt.Fprintf(os.Stderr, "  %s (%s, language: %s)\n", h.ID, h.Repo, h.Language)
+			}
+			fmt.Fprintln(os.Stderr, "Run `pre-commit install-hooks` to install them.")
+			return 1
 		}
 	}
 
+	// Determine if we need to stash.
+	needsStash := !opts.AllFiles && len(opts.Files) == 0 && opts.FilesFrom == "" && opts.FromRef == "" && opts.ToRef == "" && !noStash && !opts.DumpEnv
+	var stashMgr *staged.Manager
+	restoreStash := func() {
+		if stashMgr != nil {
+			if err := stashMgr.Restore(); err != nil {
+				output.Warn("Failed to restore unstaged changes: %v", err)
+			}
+		}
+	}
+	if needsStash {
+		hasUnstaged, _ := git.HasUnstagedChanges(root)
+		if hasUnstaged {
+			stashMgr = staged.NewManager(root)
+			stashed, err := stashMgr.StashUnstaged()
+			if !stashed || err != nil {
+				output.Warn("Failed to stash unstaged changes: %v", err)
+				stashMgr = nil
+			}
+		}
+	}
+
+	// Install environments (unless --no-install). --dump-env only inspects
+	// the environment, so it never installs.
+	if !opts.NoInstall && !opts.DumpEnv {
+		summary := hook.InstallEnvironmentsSummary(ctx, hooks)
+		var failed []hook.InstallFailure
+		runOpts.MissingRuntimes, failed = summary.MissingRuntimes()
+		if len(failed) > 0 {
+			err := failed[0].Err
+			restoreStash()
+			printError(err, "failed to install environments: %v", err)
+			return 1
+		}
+		runOpts.BuiltEnvironments = make(map[string]bool, len(summary.Installed))
+		for _, h := range summary.Installed {
+			runOpts.BuiltEnvironments[h.InstallKey()] = true
+		}
+	}
+
+	// With --show-diff-on-failure or --patch-file, the diff is only shown or
+	// written if hooks changed it, so note what it was beforehand.
+	var diffBefore string
+	if opts.ShowDiffOnFail || opts.PatchFile != "" {
+		diffBefore, _ = hook.WorkingTreeDiff()
+	}
+
+	// Run hooks.
+	runner := hook.NewRunner(cfg, hooks, root)
+	result := runner.Run(ctx, runOpts)
+
+	// Take the hooks' diff before restoring unstaged changes, which would
+	// otherwise show up in it.
 	hasFailures := result.Failed > 0 || result.Errors > 0
+	var hookDiff string
+	if (opts.ShowDiffOnFail || opts.PatchFile != "") && hasFailures {
+		var err error
+		if hookDiff, err = hook.ChangedDiff(diffBefore); err != nil {
+			output.Warn("Failed to compute diff: %v", err)
+		}
+	}
+
+	restoreStash()
+
+	if opts.OutputFormat == "json" {
+		reports := result.Hooks
+		if reports == nil {
+			reports = []hook.HookReport{}
+		}
+		enc := json.NewEncoder(jsonOut)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to write JSON results: %v\n", err)
+			return 1
+		}
+	}
+
+	if cause := context.Cause(ctx); cause != nil {
+		fmt.Fprintf(os.Stderr, "Interrupted: %v; hooks were stopped and unstaged changes restored.\n", cause)
+		return 130
+	}
+
+	if opts.Quiet {
+		fmt.Fprintln(os.Stderr, runSummary(result))
+	}
 
 	// Show diff on failure if requested.
-	if opts.ShowDiffOnFail && hasFailures {
-		hook.ShowDiffOnFailure(opts.AllFiles)
+	if opts.ShowDiffOnFail {
+		hook.ShowDiffOnFailure(hookDiff, opts.AllFiles)
+	}
+	if opts.PatchFile != "" && hookDiff != "" {
+		if err := hook.WritePatchFile(opts.PatchFile, hookDiff); err != nil {
+			output.Warn("Failed to write patch file: %v", err)
+		}
+	}
+	if opts.AnnotationsFile != "" {
+		if err := hook.WriteAnnotationsFile(opts.AnnotationsFile, result.Annotations); err != nil {
+			output.Warn("Failed to write annotations file: %v", err)
+		}
+	}
+	if opts.JUnitXML != "" {
+		if err := hook.WriteJUnitFile(opts.JUnitXML, result.Hooks); err != nil {
+			output.Warn("Failed to write JUnit XML: %v", err)
+		}
 	}
 
 	if hasFailures {
@@ -234,6 +525,215 @@ func (c *RunCommand) Run(args []string) int {
 	return 0
 }
 
+// runSummary returns the one-line tally of a run that --quiet prints.
+func runSummary(result hook.RunResult) string {
+	summary := fmt.Sprintf("%d passed, %d failed, %d skipped", result.Passed, result.Failed, result.Skipped)
+	if result.Errors > 0 {
+		summary += fmt.Sprintf(", %d errored", result.Errors)
+	}
+	return summary
+}
+
+// interruptContext returns a context that is cancelled, with a
+// languages.Interrupted cause naming the signal, on SIGINT or SIGTERM.
+func interruptContext() (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigs:
+			cancel(&languages.Interrupted{Signal: sig})
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(sigs)
+		cancel(nil)
+	}
+}
+
+// outsideRoot returns the first of files, relative to the working directory
+// unless absolute, that resolves outside root, or "" if they all stay
+// inside. Symlinked parent directories are resolved, but not the files
+// themselves, since a tracked symlink may legitimately point anywhere.
+func outsideRoot(root string, files []string) string {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		realRoot = root
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = root
+	}
+	for _, f := range files {
+		p := f
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(cwd, p)
+		}
+		p = filepath.Clean(p)
+		if dir, err := filepath.EvalSymlinks(filepath.Dir(p)); err == nil {
+			p = filepath.Join(dir, filepath.Base(p))
+		}
+		rel, err := filepath.Rel(realRoot, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return f
+		}
+	}
+	return ""
+}
+
+// modifiedWithin returns the files, relative to root unless absolute, whose
+// mtime is at or after since. Files that no longer exist are dropped.
+func modifiedWithin(root string, files []string, since time.Time) []string {
+	var recent []string
+	for _, f := range files {
+		p := f
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(root, p)
+		}
+		if fi, err := os.Stat(p); err == nil && !fi.ModTime().Before(since) {
+			recent = append(recent, f)
+		}
+	}
+	return recent
+}
+
+// expandFileGlobs expands --files arguments that contain glob
+// metacharacters and don't name an existing path against the tracked files.
+// "**" matches any number of directories. Literal paths are kept as-is.
+func expandFileGlobs(args []string) ([]string, error) {
+	var tracked []string
+	var result []string
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, "*?[") {
+			result = append(result, arg)
+			continue
+		}
+		if _, err := os.Lstat(arg); err == nil {
+			result = append(result, arg)
+			continue
+		}
+		if tracked == nil {
+			var err error
+			if tracked, err = git.GetAllFiles(); err != nil {
+				return nil, err
+			}
+		}
+		matched := false
+		for _, f := range tracked {
+			if matchGlob(arg, f) {
+				result = append(result, f)
+				matched = true
+			}
+		}
+		if !matched {
+			output.Warn("--files pattern %q matched no tracked files", arg)
+		}
+	}
+	return result, nil
+}
+
+// matchGlob reports whether name matches a slash-separated glob pattern in
+// which a "**" segment matches zero or more path segments.
+func matchGlob(pattern, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// readFilesFrom reads a list of filenames separated by sep from path, or
+// from stdin when path is "-". Empty entries are ignored. With a newline
+// separator a trailing carriage return is stripped from each name; use a NUL
+// separator (as produced by git ls-files -z) for names containing newlines.
+func readFilesFrom(path string, sep byte) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return splitFileList(data, sep), nil
+}
+
+func splitFileList(data []byte, sep byte) []string {
+	var files []string
+	for _, name := range strings.Split(string(data), string(sep)) {
+		if sep == '\n' {
+			name = strings.TrimSuffix(name, "\r")
+		}
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files
+}
+
+// changedConfigHooks diffs the config at cfgPath between fromRef and toRef
+// (HEAD if empty) and returns the hook entries added or changed in between.
+// A config that did not exist at fromRef counts as entirely new.
+func changedConfigHooks(root, cfgPath, fromRef, toRef string) (map[config.HookRef]bool, error) {
+	if toRef == "" {
+		toRef = "HEAD"
+	}
+	for _, ref := range []string{fromRef, toRef} {
+		if _, err := git.ResolveCommit(ref); err != nil {
+			return nil, fmt.Errorf("ref %q does not exist", ref)
+		}
+	}
+
+	// "./path" makes git resolve a relative path against the working
+	// directory instead of the repository root.
+	gitPath := "./" + filepath.ToSlash(cfgPath)
+	if filepath.IsAbs(cfgPath) {
+		rel, err := filepath.Rel(root, cfgPath)
+		if err != nil {
+			return nil, err
+		}
+		gitPath = filepath.ToSlash(rel)
+	}
+
+	data, err := git.ShowFile(toRef, gitPath)
+	if err != nil {
+		return nil, errkind.Wrap(errkind.Config, fmt.Errorf("no config %s at %s", cfgPath, toRef))
+	}
+	cur, err := config.ParseConfig([]byte(data))
+	if err != nil {
+		return nil, errkind.Wrap(errkind.Config, fmt.Errorf("config at %s: %w", toRef, err))
+	}
+
+	var base *config.Config
+	if data, err := git.ShowFile(fromRef, gitPath); err == nil {
+		if base, err = config.ParseConfig([]byte(data)); err != nil {
+			return nil, errkind.Wrap(errkind.Config, fmt.Errorf("config at %s: %w", fromRef, err))
+		}
+	}
+	return config.ChangedHooks(base, cur), nil
+}
+
 func (c *RunCommand) Help() string {
 	return strings.TrimSpace(`
 Usage: pre-commit run [options] [hook-id]
@@ -241,18 +741,97 @@ Usage: pre-commit run [options] [hook-id]
   Run hooks. If hook-id is given, only that hook is run, otherwise all hooks
   are run. If no files are specified, all staged files are used.
 
+  A hook selected by hook-id runs regardless of its stages (including
+  stages: [manual]) unless --hook-stage is given explicitly.
+
 Options:
 
   -a, --all-files              Run on all files in the repo.
-      --files=FILE             Specific filenames to run hooks on.
-      --show-diff-on-failure   When hooks fail, show the diff of changes.
-      --hook-stage=STAGE       The stage during which the hook is fired.
+      --files=FILE             Specific filenames (or globs) to run hooks on.
+      --files-from=FILE        Read filenames from FILE (- for stdin).
+      --files-separator=SEP    Separator for --files-from: newline (default) or nul.
+  -z, --null                   Shorthand for --files-separator nul.
+      --include=REGEX          Only pass selected files whose path matches
+                               REGEX to hooks, on top of their own files.
+      --exclude=REGEX          Never pass selected files whose path matches
+                               REGEX to hooks, on top of their own exclude.
+      --show-diff-on-failure   When hooks fail after modifying files, show the
+                               diff of their changes.
+      --patch-file=FILE        When hooks fail, write the diff of changes to FILE
+                               (never colored, suitable for git apply).
+      --annotations-file=FILE  Write file:line:col findings parsed from failing
+                               hooks' output to FILE as JSON. A hook's
+                               annotation_regex overrides the default parser.
+      --junit-xml=FILE         Write a JUnit XML report to FILE: one testcase
+                               per hook, with a failure holding the output of
+                               each failed hook.
+      --output-format=FORMAT   text (default), or json: print an array of hook
+                               results (id, name, status, exit_code,
+                               duration_ms, files, output) to stdout, with
+                               all other output on stderr.
+      --hook-stage=STAGE       The stage during which the hook is fired: a git
+                               hook type such as pre-push or post-checkout, or
+                               manual (default: pre-commit). Hooks without
+                               stages run at every stage but manual.
+      --commit-msg-filename=FILE
+                               Commit message file for the commit-msg and
+                               prepare-commit-msg stages (required there);
+                               hooks are run on it instead of staged files,
+                               so no other file selection may be given.
       --from-ref=REF           Ref to check revision changes.
       --to-ref=REF             Ref to check revision changes.
-  -v, --verbose                Produce hook output regardless of success.
+      --since=REF              Run on files changed since REF up to HEAD.
+                               Without any of these, PRE_COMMIT_FROM_REF and
+                               PRE_COMMIT_TO_REF (when both set) give the range,
+                               except when run from a git hook.
+      --changed-within=DURATION
+                               Only run on files modified on disk within
+                               DURATION (e.g. 5m), intersected with the files
+                               otherwise selected (staged, --all-files, ...).
+      --only-changed-hooks     Run only the hooks added or changed in the config
+                               between --from-ref (or --since) and --to-ref
+                               (default HEAD), against all files.
+  -q, --quiet                  Show only hooks that failed, with their output,
+                               then a one-line summary of the run such as
+                               "5 passed, 1 failed, 2 skipped".
+  -v, --verbose                Produce hook output regardless of success, and
+                               echo each hook's command. File lists too long
+                               for the terminal are shown as <N files>. Before
+                               each hook runs, print its language, version and
+                               environment, and whether that was just built.
+      --show-full-command      With --verbose, list every filename in the
+                               echoed command.
+      --max-output-lines=N     Show only the last N lines of each hook's output,
+                               after a "... (truncated, M more lines)" marker.
+                               A hook's log_file still receives everything.
+      --stream-output          Show each hook's output live as it runs, with its
+                               status line printed once it finishes. Hooks and
+                               their batches run one at a time.
+      --dedup-output           When several hooks fail with the same output
+                               (ignoring colors and surrounding whitespace),
+                               print it once under the first of them with an
+                               "(also failed: ...)" note. Output is shown
+                               once all hooks have run. Ignored with
+                               --stream-output.
+      --ci                     Also skip the hooks listed in the config's ci.skip,
+                               as pre-commit.ci does.
       --fail-fast              Stop running hooks after the first failure.
-      --no-install             Skip automatic installation of hook environments.
+      --no-install             Fail instead of installing missing hook environments.
   -j, --jobs=N                 Number of jobs to run in parallel.
+      --parallel               Run separate hooks concurrently, up to --jobs at
+                               a time (default: number of CPUs). Output is
+                               shown per hook in config order; hooks sharing
+                               an environment or matching the same files
+                               still run one at a time, in config order, and a
+                               fail_fast failure drops hooks not yet started.
+      --cache-types            Cache file type classifications under
+                               PRE_COMMIT_HOME, and reuse them for files whose
+                               mtime and size are unchanged.
+      --detect-noop-churn      Hint when a hook only changes whitespace or line
+                               endings. Not available with --all-files.
+      --hook-args=ARGS         Extra arguments to append to the selected hook's args.
+      --dump-env               Print the environment the selected hook would run
+                               with, without installing or running it.
   -c, --config=FILE            Path to alternate config file.
       --color=MODE             Whether to use color (auto, always, never).
 `)
@@ -0,0 +1,82 @@
+//go:build !windows
+
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
+)
+
+func TestRunCommand_InterruptStopsHookProcessGroup(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	oldGrace := languages.InterruptGrace
+	languages.InterruptGrace = 200 * time.Millisecond
+	t.Cleanup(func() { languages.InterruptGrace = oldGrace })
+
+	dir := t.TempDir()
+	tick := filepath.Join(t.TempDir(), "tick")
+	started := filepath.Join(t.TempDir(), "started")
+	// The backgrounded loop outlives the shell unless the whole group is
+	// signalled; it also ignores SIGINT so only the SIGKILL stops it.
+	entry := "sh -c '(trap \"\" INT; while :; do echo tick >> " + tick + "; sleep 0.05; done) & echo ok > " + started + "; sleep 30' --"
+	cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: slow\n        name: slow\n        entry: \"" + strings.ReplaceAll(entry, `"`, `\"`) + "\"\n        language: system\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("two\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", "a.txt")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("three\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	go func() {
+		for range 200 {
+			if _, err := os.Stat(started); err == nil {
+				syscall.Kill(os.Getpid(), syscall.SIGINT)
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}()
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = (&RunCommand{Meta: &Meta{}}).Run(nil)
+	})
+	if code != 130 {
+		t.Fatalf("expected exit code 130, got %d\n%s", code, stderr)
+	}
+	if !strings.Contains(stderr, "Interrupted: interrupted by interrupt") {
+		t.Errorf("expected an interrupted message, got:\n%s", stderr)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "three\n" {
+		t.Errorf("unstaged changes not restored, got %q", data)
+	}
+
+	before, _ := os.ReadFile(tick)
+	time.Sleep(300 * time.Millisecond)
+	after, _ := os.ReadFile(tick)
+	if len(after) != len(before) {
+		t.Errorf("background hook process still running after interrupt")
+	}
+}
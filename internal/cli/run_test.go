@@ -0,0 +1,1357 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blairham/go-pre-commit/v4/internal/hook"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
+)
+
+// gitIn runs a git command in dir with a fixed identity.
+func gitIn(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=Test",
+		"GIT_AUTHOR_EMAIL=test@test.com",
+		"GIT_COMMITTER_NAME=Test",
+		"GIT_COMMITTER_EMAIL=test@test.com",
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %s failed: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+
+// initHookRepo creates a hooks repo on branch main providing a "lint" hook,
+// with one tagged commit per tag or a single untagged commit if there are
+// none. It returns the repo's path along with a function that adds a commit
+// and returns the new HEAD SHA.
+func initHookRepo(t *testing.T, tags ...string) (string, func(msg string) string) {
+	t.Helper()
+	dir := t.TempDir()
+	commit := func(msg string) string {
+		t.Helper()
+		manifest := "-   id: lint\n    name: lint\n    entry: lint\n    language: system\n# " + msg + "\n"
+		if err := os.WriteFile(filepath.Join(dir, ".pre-commit-hooks.yaml"), []byte(manifest), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		gitIn(t, dir, "add", ".")
+		gitIn(t, dir, "commit", "-m", msg)
+		return gitOut(t, dir, "rev-parse", "HEAD")
+	}
+
+	gitIn(t, dir, "init", "-b", "main")
+	if len(tags) == 0 {
+		commit("initial commit")
+	}
+	for _, tag := range tags {
+		commit(tag)
+		gitIn(t, dir, "tag", tag)
+	}
+	return dir, commit
+}
+
+// fakeLanguage stands in for a real language. Installing creates the
+// environment with an "ok" marker, which HealthCheck requires; hooks fail
+// unless their environment was installed and, when record is set, write the
+// files they were given there.
+type fakeLanguage struct {
+	name   string
+	record string
+}
+
+func (l fakeLanguage) Name() string { return l.name }
+
+func (l fakeLanguage) EnvironmentDir() string { return l.name + "_env" }
+
+func (l fakeLanguage) GetDefaultVersion() string { return "default" }
+
+func (l fakeLanguage) HealthCheck(prefix, version string) error {
+	if _, err := os.Stat(filepath.Join(prefix, l.EnvironmentDir()+"-"+version, "ok")); err != nil {
+		return errors.New("ok marker missing")
+	}
+	return nil
+}
+
+func (l fakeLanguage) InstallEnvironment(prefix, version string, _ []string) error {
+	envDir := filepath.Join(prefix, l.EnvironmentDir()+"-"+version)
+	if err := os.MkdirAll(envDir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(envDir, "ok"), nil, 0o644)
+}
+
+func (l fakeLanguage) Run(_ context.Context, prefix, _, _ string, _, fileArgs []string, version string) (int, []byte, error) {
+	if _, err := os.Stat(filepath.Join(prefix, l.EnvironmentDir()+"-"+version)); err != nil {
+		return 1, []byte("environment not installed\n"), nil
+	}
+	if l.record == "" {
+		return 0, nil, nil
+	}
+	return 0, nil, os.WriteFile(l.record, []byte(strings.Join(fileArgs, "\n")), 0o644)
+}
+
+// registerLanguage registers lang for the duration of the test.
+func registerLanguage(t *testing.T, lang languages.Language) {
+	t.Helper()
+	languages.Register(lang.Name(), lang)
+	t.Cleanup(func() { languages.Unregister(lang.Name()) })
+}
+
+func TestRunCommand_Since(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "files.log")
+
+	config := `repos:
+-   repo: local
+    hooks:
+    -   id: record
+        name: record
+        entry: sh -c 'printf "%s\n" "$@" >> ` + logPath + `' --
+        language: system
+`
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gitIn(t, dir, "init", "-b", "main")
+	write(".pre-commit-config.yaml", config)
+	write("base.txt", "base\n")
+	write("changed.txt", "before\n")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "base")
+
+	gitIn(t, dir, "checkout", "-b", "feature")
+	write("changed.txt", "after\n")
+	write("added.txt", "new\n")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "feature")
+
+	// Commits on main after the branch point are excluded (merge-base).
+	gitIn(t, dir, "checkout", "main")
+	write("main-only.txt", "main\n")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "main")
+	gitIn(t, dir, "checkout", "feature")
+
+	t.Chdir(dir)
+	cmd := &RunCommand{Meta: &Meta{}}
+	if code := cmd.Run([]string{"--since", "main"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Fields(string(data))
+	slices.Sort(got)
+	want := []string{"added.txt", "changed.txt"}
+	if !slices.Equal(got, want) {
+		t.Errorf("hook ran on %v, want %v", got, want)
+	}
+}
+
+func TestRunCommand_ChangedWithin(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "files.log")
+
+	config := `repos:
+-   repo: local
+    hooks:
+    -   id: record
+        name: record
+        entry: sh -c 'printf "%s\n" "$@" >> ` + logPath + `' --
+        language: system
+        files: \.txt$
+`
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	age := func(name string) {
+		t.Helper()
+		old := time.Now().Add(-time.Hour)
+		if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranOn := func(args ...string) []string {
+		t.Helper()
+		os.Remove(logPath)
+		if code := (&RunCommand{Meta: &Meta{}}).Run(args); code != 0 {
+			t.Fatalf("run %v: expected exit code 0, got %d", args, code)
+		}
+		data, _ := os.ReadFile(logPath)
+		got := strings.Fields(string(data))
+		slices.Sort(got)
+		return got
+	}
+
+	gitIn(t, dir, "init", "-b", "main")
+	write(".pre-commit-config.yaml", config)
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		write(name, name+"\n")
+	}
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	age("a.txt")
+	age("b.txt")
+	t.Chdir(dir)
+
+	if got, want := ranOn("--all-files", "--changed-within", "5m"), []string{"c.txt"}; !slices.Equal(got, want) {
+		t.Errorf("with --all-files hook ran on %v, want %v", got, want)
+	}
+
+	// Combined with the staged selection, only recently modified staged
+	// files are checked.
+	write("a.txt", "a2\n")
+	write("b.txt", "b2\n")
+	gitIn(t, dir, "add", "a.txt", "b.txt")
+	age("b.txt")
+	if got, want := ranOn("--changed-within", "5m"), []string{"a.txt"}; !slices.Equal(got, want) {
+		t.Errorf("with staged files hook ran on %v, want %v", got, want)
+	}
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--all-files", "--changed-within", "soon"})
+	})
+	if code != 1 || !strings.Contains(stderr, `invalid duration "soon"`) {
+		t.Errorf("expected an invalid duration error, got %d:\n%s", code, stderr)
+	}
+}
+
+func TestRunCommand_RefsFromEnvironment(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "files.log")
+	cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: record\n        name: record\n        entry: sh -c 'printf \"%s\\n\" \"$@\" >> " + logPath + "' --\n        language: system\n        files: \\.txt$\n"
+	write := func(name string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ranOn := func(args ...string) []string {
+		t.Helper()
+		os.Remove(logPath)
+		if code := (&RunCommand{Meta: &Meta{}}).Run(args); code != 0 {
+			t.Fatalf("run %v: expected exit code 0, got %d", args, code)
+		}
+		data, _ := os.ReadFile(logPath)
+		got := strings.Fields(string(data))
+		slices.Sort(got)
+		return got
+	}
+
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	write("a.txt")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	base := gitOut(t, dir, "rev-parse", "HEAD")
+	write("b.txt")
+	write("c.txt")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "more")
+	t.Chdir(dir)
+
+	want := ranOn("--from-ref", base, "--to-ref", "HEAD")
+	if !slices.Equal(want, []string{"b.txt", "c.txt"}) {
+		t.Fatalf("with flags hook ran on %v", want)
+	}
+	t.Setenv("PRE_COMMIT_FROM_REF", base)
+	t.Setenv("PRE_COMMIT_TO_REF", "HEAD")
+	if got := ranOn(); !slices.Equal(got, want) {
+		t.Errorf("with env hook ran on %v, want %v", got, want)
+	}
+
+	// Explicit flags win over the environment.
+	if got := ranOn("--from-ref", "HEAD", "--to-ref", "HEAD"); len(got) != 0 {
+		t.Errorf("with empty flag range hook ran on %v", got)
+	}
+	if got, want := ranOn("--all-files"), []string{"a.txt", "b.txt", "c.txt"}; !slices.Equal(got, want) {
+		t.Errorf("with --all-files hook ran on %v, want %v", got, want)
+	}
+
+	// A commit made while the variables are set still checks staged files.
+	t.Setenv("PRE_COMMIT_FROM_REF", base)
+	t.Setenv("PRE_COMMIT_TO_REF", "HEAD")
+	write("d.txt")
+	gitIn(t, dir, "add", "d.txt")
+	os.Remove(logPath)
+	if code := (&HookImplCommand{Meta: &Meta{}}).Run([]string{"--hook-type", "pre-commit"}); code != 0 {
+		t.Fatalf("hook-impl: expected exit code 0, got %d", code)
+	}
+	data, _ := os.ReadFile(logPath)
+	if got := strings.Fields(string(data)); !slices.Equal(got, []string{"d.txt"}) {
+		t.Errorf("from the pre-commit hook ran on %v, want [d.txt]", got)
+	}
+}
+
+func TestRunCommand_CISkip(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "ran.log")
+	cfg := "ci:\n    skip: [slow]\nrepos:\n-   repo: local\n    hooks:\n" +
+		"    -   id: slow\n        name: slow\n        entry: sh -c 'echo slow >> " + logPath + "'\n        language: system\n        always_run: true\n" +
+		"    -   id: fast\n        name: fast\n        entry: sh -c 'echo fast >> " + logPath + "'\n        language: system\n        always_run: true\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+
+	ran := func(args ...string) []string {
+		t.Helper()
+		os.Remove(logPath)
+		if code := (&RunCommand{Meta: &Meta{}}).Run(args); code != 0 {
+			t.Fatalf("run %v: expected exit code 0, got %d", args, code)
+		}
+		data, _ := os.ReadFile(logPath)
+		return strings.Fields(string(data))
+	}
+	if got, want := ran("--all-files"), []string{"slow", "fast"}; !slices.Equal(got, want) {
+		t.Errorf("without --ci ran %v, want %v", got, want)
+	}
+	if got, want := ran("--all-files", "--ci"), []string{"fast"}; !slices.Equal(got, want) {
+		t.Errorf("with --ci ran %v, want %v", got, want)
+	}
+}
+
+func TestRunCommand_OutputFormatJSON(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	cfg := "repos:\n-   repo: local\n    hooks:\n" +
+		"    -   id: ok\n        name: ok\n        entry: sh -c 'echo fine' --\n        language: system\n" +
+		"    -   id: bad\n        name: bad\n        entry: sh -c 'echo broken; exit 3' --\n        language: system\n" +
+		"    -   id: none\n        name: none\n        entry: 'true'\n        language: system\n        files: \\.py$\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+
+	var code int
+	var out string
+	stderr := captureStderr(t, func() {
+		out = captureStdout(t, func() {
+			code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--all-files", "--output-format", "json"})
+		})
+	})
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	var reports []hook.HookReport
+	if err := json.Unmarshal([]byte(out), &reports); err != nil {
+		t.Fatalf("stdout is not a JSON array: %v\n%s", err, out)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("got %d reports, want 3: %+v", len(reports), reports)
+	}
+	want := []struct {
+		id, status string
+		exitCode   int
+		output     string
+	}{
+		{"ok", "passed", 0, "fine\n"},
+		{"bad", "failed", 3, "broken\n"},
+		{"none", "skipped", 0, ""},
+	}
+	for i, w := range want {
+		r := reports[i]
+		if r.ID != w.id || r.Status != w.status || r.ExitCode != w.exitCode || r.Output != w.output {
+			t.Errorf("report %d = %+v, want %+v", i, r, w)
+		}
+	}
+	if got := reports[0].Files; !slices.Contains(got, "a.txt") {
+		t.Errorf("ok files = %v, want a.txt included", got)
+	}
+	if reports[2].Files == nil {
+		t.Error("skipped hook files should be an empty array, not null")
+	}
+	if !strings.Contains(stderr, "bad") {
+		t.Errorf("expected the text results on stderr, got:\n%s", stderr)
+	}
+}
+
+func TestOutsideRootFromSubdirectory(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "src", "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(filepath.Join(root, "src", "pkg"))
+
+	for f, want := range map[string]string{
+		"main.go":              "",
+		"../../README.md":      "",
+		"../../../outside.txt": "../../../outside.txt",
+	} {
+		if got := outsideRoot(root, []string{f}); got != want {
+			t.Errorf("outsideRoot(%q) = %q, want %q", f, got, want)
+		}
+	}
+}
+
+func TestRunCommand_FilesOutsideRepoRejected(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := filepath.Join(t.TempDir(), "a", "b", "repo")
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	logPath := filepath.Join(t.TempDir(), "files.log")
+	cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: record\n        name: record\n        entry: sh -c 'printf \"%s\\n\" \"$@\" >> " + logPath + "' --\n        language: system\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+
+	for _, args := range [][]string{
+		{"--files", "../../etc/foo"},
+		{"--files", "src/../../outside.txt"},
+		{"--files", "src/main.go", "--files", filepath.Join(t.TempDir(), "elsewhere.txt")},
+	} {
+		var code int
+		stderr := captureStderr(t, func() {
+			code = (&RunCommand{Meta: &Meta{}}).Run(args)
+		})
+		if code != 1 || !strings.Contains(stderr, "is outside the repository") {
+			t.Errorf("run %v: expected rejection, got exit code %d, stderr:\n%s", args, code, stderr)
+		}
+	}
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Error("hook ran despite a path outside the repository")
+	}
+
+	for _, f := range []string{"src/main.go", "./src/../src/main.go", filepath.Join(dir, "src", "main.go")} {
+		if code := (&RunCommand{Meta: &Meta{}}).Run([]string{"--files", f}); code != 0 {
+			t.Errorf("run --files %s: expected exit code 0, got %d", f, code)
+		}
+	}
+}
+
+func TestRunCommand_SinceUnknownRef(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-b", "main")
+	cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: noop\n        name: noop\n        entry: 'true'\n        language: system\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+
+	t.Chdir(dir)
+	cmd := &RunCommand{Meta: &Meta{}}
+	if code := cmd.Run([]string{"--since", "does-not-exist"}); code != 1 {
+		t.Errorf("expected exit code 1 for unknown ref, got %d", code)
+	}
+}
+
+func TestRunCommand_SinceMutuallyExclusive(t *testing.T) {
+	cmd := &RunCommand{Meta: &Meta{}}
+	for _, args := range [][]string{
+		{"--since", "main", "--all-files"},
+		{"--since", "main", "--files", "a.txt"},
+		{"--since", "main", "--from-ref", "HEAD~1"},
+	} {
+		if code := cmd.Run(args); code != 1 {
+			t.Errorf("Run(%v) = %d, want 1", args, code)
+		}
+	}
+}
+
+func TestRunCommand_DetectNoopChurnWithAllFiles(t *testing.T) {
+	var code int
+	stderr := captureStderr(t, func() {
+		code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--detect-noop-churn", "--all-files"})
+	})
+	if code != 1 || !strings.Contains(stderr, "--detect-noop-churn can't be used with --all-files") {
+		t.Errorf("Run = %d, stderr:\n%s", code, stderr)
+	}
+}
+
+func TestRunCommand_FilesFromNul(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "files.log")
+
+	config := `repos:
+-   repo: local
+    hooks:
+    -   id: record
+        name: record
+        entry: sh -c 'printf "%s\0" "$@" >> ` + logPath + `' --
+        language: system
+`
+	names := []string{"plain.txt", "new\nline.txt"}
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range append(names, "other.txt") {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+
+	list := filepath.Join(t.TempDir(), "list")
+	if err := os.WriteFile(list, []byte(strings.Join(names, "\x00")+"\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(dir)
+	cmd := &RunCommand{Meta: &Meta{}}
+	if code := cmd.Run([]string{"--files-from", list, "-z"}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Split(strings.TrimSuffix(string(data), "\x00"), "\x00")
+	slices.Sort(got)
+	want := slices.Clone(names)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("hook ran on %q, want %q", got, want)
+	}
+}
+
+func TestRunCommand_FilesFromMutuallyExclusive(t *testing.T) {
+	cmd := &RunCommand{Meta: &Meta{}}
+	for _, args := range [][]string{
+		{"--files-from", "-", "--all-files"},
+		{"--files-from", "-", "--files", "a.txt"},
+		{"--files-from", "-", "--since", "main"},
+	} {
+		if code := cmd.Run(args); code != 1 {
+			t.Errorf("Run(%v) = %d, want 1", args, code)
+		}
+	}
+}
+
+func TestSplitFileList(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		sep  byte
+		want []string
+	}{
+		{"newline", "a.txt\nb.txt\n", '\n', []string{"a.txt", "b.txt"}},
+		{"crlf", "a.txt\r\nb.txt", '\n', []string{"a.txt", "b.txt"}},
+		{"blank lines", "\na.txt\n\n", '\n', []string{"a.txt"}},
+		{"nul", "a.txt\x00new\nline.txt\x00", 0, []string{"a.txt", "new\nline.txt"}},
+		{"empty", "", 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitFileList([]byte(tt.data), tt.sep); !slices.Equal(got, tt.want) {
+				t.Errorf("splitFileList(%q) = %q, want %q", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunCommand_AnnotationsFile(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	cfg := `repos:
+-   repo: local
+    hooks:
+    -   id: lint
+        name: lint
+        entry: 'sh -c ''echo "$1:2:3: bad style"; exit 1'' --'
+        language: system
+    -   id: custom
+        name: custom
+        entry: sh -c 'echo "WARN $1@9 shadowed"; exit 1' --
+        language: system
+        annotation_regex: '^WARN (?<file>\S+)@(?<line>\d+) (?<message>.+)$'
+`
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.py"), []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+
+	annotations := filepath.Join(t.TempDir(), "annotations.json")
+	t.Chdir(dir)
+	cmd := &RunCommand{Meta: &Meta{}}
+	if code := cmd.Run([]string{"--files", "a.py", "--annotations-file", annotations}); code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+
+	data, err := os.ReadFile(annotations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"hook": "lint"`, `"file": "a.py"`, `"line": 2`, `"column": 3`, `"message": "bad style"`,
+		`"hook": "custom"`, `"line": 9`, `"message": "shadowed"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("annotations file missing %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestRunCommand_DumpEnv(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+	cfg := `repos:
+-   repo: local
+    hooks:
+    -   id: py
+        name: py
+        entry: touch ` + marker + `
+        language: python
+    -   id: other
+        name: other
+        entry: 'true'
+        language: system
+`
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&RunCommand{Meta: &Meta{}}).Run([]string{"py", "--dump-env", "--all-files"})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d\n%s", code, out)
+	}
+	for _, want := range []string{"\nVIRTUAL_ENV=", "\nPRE_COMMIT=1\n", "py_env-default/bin:"} {
+		if !strings.Contains("\n"+out, want) {
+			t.Errorf("dumped env missing %q:\n%s", want, out)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("--dump-env ran the hook")
+	}
+
+	if code := (&RunCommand{Meta: &Meta{}}).Run([]string{"--dump-env", "--all-files"}); code != 1 {
+		t.Errorf("--dump-env with two hooks selected: exit code %d, want 1", code)
+	}
+}
+
+func TestRunCommand_OnlyChangedHooks(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	markers := t.TempDir()
+	localHook := func(id string) string {
+		return "    -   id: " + id + "\n        name: " + id + "\n        entry: touch " + filepath.Join(markers, id) +
+			"\n        language: system\n        pass_filenames: false\n"
+	}
+	writeConfig := func(cfg string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gitIn(t, dir, "init", "-b", "main")
+	writeConfig("repos:\n-   repo: local\n    hooks:\n" + localHook("existing"))
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	gitIn(t, dir, "checkout", "-b", "feature")
+	writeConfig("repos:\n-   repo: local\n    hooks:\n" + localHook("existing") + localHook("added"))
+	gitIn(t, dir, "commit", "-am", "add hook")
+	t.Chdir(dir)
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--only-changed-hooks", "--from-ref", "main"})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d\n%s", code, out)
+	}
+	if _, err := os.Stat(filepath.Join(markers, "added")); err != nil {
+		t.Errorf("added hook did not run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(markers, "existing")); err == nil {
+		t.Error("unchanged hook ran")
+	}
+
+	if code := (&RunCommand{Meta: &Meta{}}).Run([]string{"--only-changed-hooks"}); code != 1 {
+		t.Errorf("--only-changed-hooks without --from-ref: exit code %d, want 1", code)
+	}
+}
+
+func TestRunCommand_ManualHookByID(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+	cfg := `repos:
+-   repo: local
+    hooks:
+    -   id: my-manual-hook
+        name: manual
+        entry: touch ` + marker + `
+        language: system
+        always_run: true
+        pass_filenames: false
+        stages: [manual]
+`
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+
+	cmd := &RunCommand{Meta: &Meta{}}
+
+	// Without an id, manual hooks stay out of the default stage.
+	if code := cmd.Run(nil); code != 0 {
+		t.Fatalf("run: expected exit code 0, got %d", code)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("manual hook ran without being selected")
+	}
+
+	// An explicit --hook-stage still filters by stage.
+	if code := cmd.Run([]string{"--hook-stage", "pre-commit", "my-manual-hook"}); code != 1 {
+		t.Errorf("run --hook-stage pre-commit: expected exit code 1, got %d", code)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("manual hook ran despite explicit --hook-stage pre-commit")
+	}
+
+	if code := cmd.Run([]string{"my-manual-hook"}); code != 0 {
+		t.Fatalf("run my-manual-hook: expected exit code 0, got %d", code)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("expected manual hook to run when selected by id")
+	}
+}
+
+func TestRunCommand_NoInstallMissingEnvironment(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+
+	hookRepo := t.TempDir()
+	manifest := "-   id: py-lint\n    name: py lint\n    entry: py-lint\n    language: python\n"
+	if err := os.WriteFile(filepath.Join(hookRepo, ".pre-commit-hooks.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, hookRepo, "init", "-b", "main")
+	gitIn(t, hookRepo, "add", ".")
+	gitIn(t, hookRepo, "commit", "-m", "hooks")
+	rev := gitOut(t, hookRepo, "rev-parse", "HEAD")
+
+	dir := t.TempDir()
+	cfg := "repos:\n-   repo: " + hookRepo + "\n    rev: " + rev + "\n    hooks:\n    -   id: py-lint\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--no-install", "--all-files"})
+	})
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if !strings.Contains(stderr, "not installed") || !strings.Contains(stderr, "py-lint ("+hookRepo+", language: python)") {
+		t.Errorf("expected missing environment to be named, got:\n%s", stderr)
+	}
+	if !strings.Contains("\n"+stderr, "\nenv-error: --no-install") {
+		t.Errorf("expected an env-error prefix, got:\n%s", stderr)
+	}
+}
+
+func TestRunCommand_ErrorCategories(t *testing.T) {
+	tests := []struct {
+		name, config, want string
+	}{
+		{
+			name:   "invalid config",
+			config: "repos: not-a-list\n",
+			want:   "\nconfig-error: failed to load config",
+		},
+		{
+			name:   "unknown meta hook",
+			config: "repos:\n-   repo: meta\n    hooks:\n    -   id: no-such-meta-hook\n",
+			want:   "\nconfig-error: failed to resolve hooks",
+		},
+		{
+			name:   "unreachable repo",
+			config: "repos:\n-   repo: file:///nonexistent/hooks.git\n    rev: v1.0.0\n    hooks:\n    -   id: x\n",
+			want:   "\nnetwork-error: failed to resolve hooks",
+		},
+		{
+			name:   "hook cannot execute",
+			config: "repos:\n-   repo: local\n    hooks:\n    -   id: missing\n        name: missing\n        entry: pre-commit-no-such-binary\n        language: system\n        always_run: true\n",
+			want:   "hook-error: hook execution error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			gitIn(t, dir, "init", "-b", "main")
+			gitIn(t, dir, "add", ".")
+			gitIn(t, dir, "commit", "-m", "init")
+			t.Chdir(dir)
+
+			var code int
+			stderr := captureStderr(t, func() {
+				code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--all-files"})
+			})
+			if code != 1 {
+				t.Errorf("expected exit code 1, got %d", code)
+			}
+			// A leading newline in want means it must start a line.
+			if !strings.Contains("\n"+stderr, tt.want) {
+				t.Errorf("expected %q in stderr, got:\n%s", tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestInstallHooksCommand_Verify(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	cfg := `repos:
+-   repo: local
+    hooks:
+    -   id: works
+        name: works
+        entry: 'true'
+        language: system
+    -   id: broken
+        name: broken
+        entry: pre-commit-no-such-binary
+        language: system
+`
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&InstallHooksCommand{Meta: &Meta{}}).Run([]string{"--verify"})
+	})
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	for _, want := range []string{"Passed     local (works)", "Failed     local (broken): env-error:", "1 passed, 1 failed."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"*.py", "a.py", true},
+		{"*.py", "pkg/a.py", false},
+		{"**/foo", "foo", true},
+		{"**/foo", "a/b/foo", true},
+		{"**/foo", "a/foobar", false},
+		{"src/**/*.py", "src/a.py", true},
+		{"src/**/*.py", "src/x/y/a.py", true},
+		{"src/**/*.py", "lib/a.py", false},
+	}
+	for _, tc := range tests {
+		if got := matchGlob(tc.pattern, tc.name); got != tc.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestExpandFileGlobs(t *testing.T) {
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-b", "main")
+	for _, name := range []string{"top.py", "pkg/mod.py", "pkg/deep/foo", "foo", "notes.txt"} {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitIn(t, dir, "add", ".")
+	t.Chdir(dir)
+
+	tests := []struct {
+		arg  string
+		want []string
+	}{
+		{"*.py", []string{"top.py"}},
+		{"**/foo", []string{"foo", "pkg/deep/foo"}},
+		{"**/*.py", []string{"pkg/mod.py", "top.py"}},
+		{"notes.txt", []string{"notes.txt"}},
+		{"missing.txt", []string{"missing.txt"}},
+	}
+	for _, tc := range tests {
+		got, err := expandFileGlobs([]string{tc.arg})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("expandFileGlobs(%q) = %v, want %v", tc.arg, got, tc.want)
+		}
+	}
+}
+
+func TestExpandFileGlobsLiteralPathWithMetachars(t *testing.T) {
+	dir := t.TempDir()
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, "[weird].py"), []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	got, err := expandFileGlobs([]string{"[weird].py"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{"[weird].py"}) {
+		t.Errorf("expandFileGlobs = %v, want the literal path", got)
+	}
+}
+
+func TestRunCommand_Quiet(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	writeCfg := func(extra string) {
+		cfg := "repos:\n-   repo: local\n    hooks:\n" +
+			"    -   id: ok\n        name: ok-hook\n        entry: sh -c 'echo fine' --\n        language: system\n" +
+			"    -   id: none\n        name: none-hook\n        entry: 'true'\n        language: system\n        files: \\.py$\n" + extra
+		if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCfg("    -   id: bad\n        name: bad-hook\n        entry: sh -c 'echo broken; exit 3' --\n        language: system\n")
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+
+	var code int
+	stderr := captureStderr(t, func() {
+		captureStdout(t, func() {
+			code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--all-files", "--quiet"})
+		})
+	})
+	if code != 1 {
+		t.Errorf("expected exit code 1, got %d", code)
+	}
+	if strings.Contains(stderr, "ok-hook") || strings.Contains(stderr, "none-hook") || strings.Contains(stderr, "fine") {
+		t.Errorf("passed or skipped hooks shown under --quiet:\n%s", stderr)
+	}
+	if !strings.Contains(stderr, "bad-hook") || !strings.Contains(stderr, "broken") {
+		t.Errorf("failed hook and its output missing:\n%s", stderr)
+	}
+	if !strings.HasSuffix(stderr, "1 passed, 1 failed, 1 skipped\n") {
+		t.Errorf("expected a final summary line, got:\n%s", stderr)
+	}
+
+	writeCfg("")
+	stderr = captureStderr(t, func() {
+		captureStdout(t, func() {
+			code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--all-files", "-q"})
+		})
+	})
+	if code != 0 {
+		t.Errorf("expected exit code 0, got %d", code)
+	}
+	if stderr != "1 passed, 0 failed, 1 skipped\n" {
+		t.Errorf("expected only the summary when all pass, got:\n%q", stderr)
+	}
+}
+
+func TestRunCommand_HookStages(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "ran.log")
+	hookDef := func(id, stages string) string {
+		def := "    -   id: " + id + "\n        name: " + id + "\n        entry: sh -c 'echo " + id + " >> " + logPath + "' --\n" +
+			"        language: system\n        always_run: true\n        pass_filenames: false\n"
+		if stages != "" {
+			def += "        stages: " + stages + "\n"
+		}
+		return def
+	}
+	cfg := "repos:\n-   repo: local\n    hooks:\n" +
+		hookDef("every", "") +
+		hookDef("manual-only", "[manual]") +
+		hookDef("checkout", "[post-checkout]") +
+		hookDef("merge-rewrite", "[post-merge, post-rewrite]") +
+		hookDef("prepare", "[prepare-commit-msg]")
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+	if err := os.WriteFile(filepath.Join(dir, "MSG"), []byte("message\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"every"}},
+		{[]string{"--hook-stage", "manual"}, []string{"manual-only"}},
+		{[]string{"--hook-stage", "post-checkout"}, []string{"every", "checkout"}},
+		{[]string{"--hook-stage", "post-merge"}, []string{"every", "merge-rewrite"}},
+		{[]string{"--hook-stage", "post-rewrite"}, []string{"every", "merge-rewrite"}},
+		{[]string{"--hook-stage", "prepare-commit-msg", "--commit-msg-filename", "MSG"}, []string{"every", "prepare"}},
+	} {
+		os.Remove(logPath)
+		var code int
+		captureStderr(t, func() {
+			captureStdout(t, func() {
+				code = (&RunCommand{Meta: &Meta{}}).Run(tc.args)
+			})
+		})
+		if code != 0 {
+			t.Errorf("run %v: exit code %d", tc.args, code)
+		}
+		data, _ := os.ReadFile(logPath)
+		if got := strings.Fields(string(data)); !slices.Equal(got, tc.want) {
+			t.Errorf("run %v ran %v, want %v", tc.args, got, tc.want)
+		}
+	}
+
+	var code int
+	stderr := captureStderr(t, func() {
+		code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--hook-stage", "post-chekout"})
+	})
+	if code != 1 || !strings.Contains(stderr, `--hook-stage: unknown stage "post-chekout"`) {
+		t.Errorf("expected an unknown stage error, got exit code %d:\n%s", code, stderr)
+	}
+}
+
+func TestRunCommand_ShowDiffOnFailure(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	writeCfg := func(entry string) {
+		cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: fix\n        name: fix\n        entry: \"" + entry + "\"\n" +
+			"        language: system\n        always_run: true\n        pass_filenames: false\n"
+		if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeCfg(`sh -c 'echo fixed >> a.txt; printf \"\\0new\" >> img.bin; exit 1' --`)
+	for name, content := range map[string]string{"a.txt": "a\n", "b.txt": "b\n", "img.bin": "\x00old"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	// An unstaged edit of the user's own must not show up as a hook change.
+	if err := os.WriteFile(filepath.Join(dir, "b.txt"), []byte("user edit\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+	patch := filepath.Join(t.TempDir(), "fix.patch")
+
+	run := func(args ...string) (int, string) {
+		t.Helper()
+		var code int
+		stderr := captureStderr(t, func() {
+			captureStdout(t, func() {
+				code = (&RunCommand{Meta: &Meta{}}).Run(append([]string{"--show-diff-on-failure", "--patch-file", patch}, args...))
+			})
+		})
+		return code, stderr
+	}
+
+	code, stderr := run()
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d\n%s", code, stderr)
+	}
+	if !strings.Contains(stderr, "+fixed") || !strings.Contains(stderr, "Binary files a/img.bin and b/img.bin differ") {
+		t.Errorf("expected the hook's changes in the diff, got:\n%s", stderr)
+	}
+	if strings.Contains(stderr, "user edit") {
+		t.Errorf("unstaged user changes shown as hook changes:\n%s", stderr)
+	}
+	data, err := os.ReadFile(patch)
+	if err != nil || !strings.Contains(string(data), "+fixed") || strings.Contains(string(data), "user edit") {
+		t.Errorf("expected only the hook's changes in the patch file, got %v:\n%s", err, data)
+	}
+	os.Remove(patch)
+
+	// A failing hook that changes nothing shows no diff, even with other
+	// changes in the working tree.
+	writeCfg("false")
+	code, stderr = run("--all-files")
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d\n%s", code, stderr)
+	}
+	if strings.Contains(stderr, "diff --git") {
+		t.Errorf("diff shown although no hook modified files:\n%s", stderr)
+	}
+	if _, err := os.Stat(patch); !os.IsNotExist(err) {
+		t.Errorf("patch file written although no hook modified files (stat err = %v)", err)
+	}
+}
+
+func TestRunCommand_MissingLanguageRuntime(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	t.Setenv("PRE_COMMIT_MIN_FREE_SPACE", "0")
+	dir := t.TempDir()
+	cfg := "repos:\n-   repo: local\n    hooks:\n" +
+		"    -   id: go-tool\n        name: go-tool\n        entry: go-tool\n        language: golang\n        additional_dependencies: [example.com/tool@v1]\n" +
+		"    -   id: image\n        name: image\n        entry: example/lint\n        language: docker_image\n" +
+		"    -   id: ok\n        name: ok\n        entry: sh -c 'echo ran > ok.out' --\n        language: system\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	t.Chdir(dir)
+
+	// Leave only what the test itself needs on PATH, so neither go nor
+	// docker can be found.
+	bin := t.TempDir()
+	for _, tool := range []string{"git", "sh"} {
+		path, err := exec.LookPath(tool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(path, filepath.Join(bin, tool)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+
+	var code int
+	stderr := captureStderr(t, func() {
+		captureStdout(t, func() {
+			code = (&RunCommand{Meta: &Meta{}}).Run([]string{"--all-files"})
+		})
+	})
+	if code != 1 {
+		t.Fatalf("expected exit code 1, got %d\n%s", code, stderr)
+	}
+	for _, want := range []string{
+		"env-error: golang hooks need go, which was not found on PATH",
+		"https://go.dev/doc/install",
+		"env-error: docker_image hooks need docker",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected %q in output, got:\n%s", want, stderr)
+		}
+	}
+	if strings.Contains(stderr, "failed to install environments") {
+		t.Errorf("missing runtime aborted the whole run:\n%s", stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ok.out")); err != nil {
+		t.Errorf("unrelated hook did not run: %v\n%s", err, stderr)
+	}
+}
+
+func TestRunCommand_IncludeExcludeFlags(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: record\n        name: record\n" +
+		"        entry: sh -c 'echo \"$@\" >> args.log' --\n        language: system\n        files: \\.(py|txt)$\n"
+	for name, content := range map[string]string{".pre-commit-config.yaml": cfg, "a.py": "a\n", "b.py": "b\n", "gen_c.py": "c\n", "d.txt": "d\n", "e.md": "e\n"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	t.Chdir(dir)
+
+	run := func(args ...string) (int, string) {
+		t.Helper()
+		os.Remove(filepath.Join(dir, "args.log"))
+		var code int
+		stderr := captureStderr(t, func() {
+			captureStdout(t, func() {
+				code = (&RunCommand{Meta: &Meta{}}).Run(args)
+			})
+		})
+		return code, stderr
+	}
+	passed := func() []string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, "args.log"))
+		if err != nil {
+			return nil
+		}
+		files := strings.Fields(string(data))
+		slices.Sort(files)
+		return files
+	}
+
+	if code, stderr := run("--all-files", "--include", `\.py$`, "--exclude", `^gen_`); code != 0 {
+		t.Fatalf("expected exit code 0, got %d\n%s", code, stderr)
+	}
+	if got, want := passed(), []string{"a.py", "b.py"}; !slices.Equal(got, want) {
+		t.Errorf("hook got %v, want %v", got, want)
+	}
+
+	// Staged files are narrowed the same way.
+	if code, stderr := run("--exclude", `^(a|d)\.`); code != 0 {
+		t.Fatalf("expected exit code 0, got %d\n%s", code, stderr)
+	}
+	if got, want := passed(), []string{"b.py", "gen_c.py"}; !slices.Equal(got, want) {
+		t.Errorf("hook got %v, want %v", got, want)
+	}
+
+	code, stderr := run("--all-files", "--exclude", "(unclosed")
+	if code != 1 || !strings.Contains(stderr, "invalid --exclude pattern") {
+		t.Errorf("expected an invalid pattern error, got exit code %d:\n%s", code, stderr)
+	}
+	if got := passed(); got != nil {
+		t.Errorf("expected no hook to run with an invalid pattern, got %v", got)
+	}
+}
+
+func TestRunCommand_CommitMsgStage(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "args.log")
+	cfg := "repos:\n-   repo: local\n    hooks:\n" +
+		"    -   id: msg-lint\n        name: msg-lint\n        entry: sh -c 'echo \"$@\" >> " + logPath + "; grep -q ^feat \"$1\"' --\n" +
+		"        language: system\n        stages: [commit-msg]\n"
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "staged.txt"), []byte("x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "init", "-b", "main")
+	gitIn(t, dir, "add", ".")
+	msgFile := filepath.Join(t.TempDir(), "COMMIT_EDITMSG")
+	t.Chdir(dir)
+
+	run := func(args ...string) (int, string) {
+		t.Helper()
+		os.Remove(logPath)
+		var code int
+		stderr := captureStderr(t, func() {
+			captureStdout(t, func() {
+				code = (&RunCommand{Meta: &Meta{}}).Run(append([]string{"--hook-stage", "commit-msg"}, args...))
+			})
+		})
+		return code, stderr
+	}
+
+	for _, tc := range []struct {
+		msg  string
+		code int
+	}{{"feat: add thing\n", 0}, {"wip\n", 1}} {
+		if err := os.WriteFile(msgFile, []byte(tc.msg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if code, stderr := run("--commit-msg-filename", msgFile); code != tc.code {
+			t.Errorf("message %q: exit code %d, want %d\n%s", tc.msg, code, tc.code, stderr)
+		}
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("message %q: hook did not run: %v", tc.msg, err)
+		}
+		if got := strings.TrimSpace(string(data)); got != msgFile {
+			t.Errorf("message %q: hook got %q, want %q", tc.msg, got, msgFile)
+		}
+	}
+
+	if code, stderr := run("--commit-msg-filename", filepath.Join(dir, "missing")); code != 1 || !strings.Contains(stderr, "is not a file") {
+		t.Errorf("expected a missing file error, got exit code %d:\n%s", code, stderr)
+	}
+	if code, stderr := run(); code != 1 || !strings.Contains(stderr, "requires --commit-msg-filename") {
+		t.Errorf("expected a missing flag error, got exit code %d:\n%s", code, stderr)
+	}
+	for _, sel := range [][]string{
+		{"--all-files"},
+		{"--files", "staged.txt"},
+		{"--files-from", msgFile},
+		{"--since", "HEAD"},
+		{"--from-ref", "HEAD", "--to-ref", "HEAD"},
+		{"--changed-within", "1h"},
+	} {
+		code, stderr := run(append([]string{"--commit-msg-filename", msgFile}, sel...)...)
+		if code != 1 || !strings.Contains(stderr, "runs on the commit message file") {
+			t.Errorf("%v: expected the file selection to be rejected, got exit code %d:\n%s", sel, code, stderr)
+		}
+		if _, err := os.Stat(logPath); err == nil {
+			t.Errorf("%v: hook ran", sel)
+		}
+	}
+
+	// CI's range variables are not a file selection the user gave.
+	t.Setenv("PRE_COMMIT_FROM_REF", "HEAD")
+	t.Setenv("PRE_COMMIT_TO_REF", "HEAD")
+	if code, stderr := run("--commit-msg-filename", msgFile); code != 1 || strings.Contains(stderr, "runs on the commit message file") {
+		t.Errorf("expected the hook to run on the message file, got exit code %d:\n%s", code, stderr)
+	} else if _, err := os.Stat(logPath); err != nil {
+		t.Errorf("hook did not run with PRE_COMMIT_FROM_REF/PRE_COMMIT_TO_REF set: %v", err)
+	}
+}
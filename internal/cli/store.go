@@ -0,0 +1,439 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+
+	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
+	"github.com/blairham/go-pre-commit/v4/internal/store"
+)
+
+type storeFlags struct {
+	GlobalFlags
+	JSON bool `long:"json" description:"Print output as JSON."`
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) int {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// formatSize renders a byte count for humans, e.g. "12.3 MB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// StoreDirCommand implements "store dir" - prints the resolved cache location.
+type StoreDirCommand struct {
+	Meta *Meta
+}
+
+func (c *StoreDirCommand) Run(args []string) int {
+	var opts storeFlags
+	if _, err := flags.ParseArgs(&opts, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	dir := store.New("").Dir()
+	if opts.JSON {
+		return printJSON(map[string]string{"dir": dir})
+	}
+	fmt.Println(dir)
+	return 0
+}
+
+func (c *StoreDirCommand) Help() string {
+	return strings.TrimSpace(`
+Usage: pre-commit store dir [options]
+
+  Print the resolved pre-commit cache directory (PRE_COMMIT_HOME).
+
+Options:
+
+      --json   Print output as JSON.
+`)
+}
+
+func (c *StoreDirCommand) Synopsis() string {
+	return "Print the pre-commit cache directory"
+}
+
+// StoreDuCommand implements "store du" - reports cache disk usage by category.
+type StoreDuCommand struct {
+	Meta *Meta
+}
+
+func (c *StoreDuCommand) Run(args []string) int {
+	var opts storeFlags
+	if _, err := flags.ParseArgs(&opts, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	u, err := store.New("").DiskUsage(languages.EnvironmentDirs())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to compute disk usage: %v\n", err)
+		return 1
+	}
+	if opts.JSON {
+		return printJSON(u)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "repos\t%s\t\n", formatSize(u.Repos))
+	fmt.Fprintf(w, "environments\t%s\t\n", formatSize(u.Environments))
+	fmt.Fprintf(w, "caches\t%s\t\n", formatSize(u.Caches))
+	fmt.Fprintf(w, "other\t%s\t\n", formatSize(u.Other))
+	fmt.Fprintf(w, "total\t%s\t\n", formatSize(u.Total))
+	w.Flush()
+	return 0
+}
+
+func (c *StoreDuCommand) Help() string {
+	return strings.TrimSpace(`
+Usage: pre-commit store du [options]
+
+  Show the pre-commit cache's disk usage, broken down into repo clones,
+  installed environments, shared caches and other files.
+
+Options:
+
+      --json   Print output as JSON.
+`)
+}
+
+func (c *StoreDuCommand) Synopsis() string {
+	return "Show pre-commit cache disk usage"
+}
+
+// StoreListCommand implements "store list" - lists cached repos.
+type StoreListCommand struct {
+	Meta *Meta
+}
+
+func (c *StoreListCommand) Run(args []string) int {
+	var opts storeFlags
+	if _, err := flags.ParseArgs(&opts, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	repos, err := store.New("").ListRepoInfo()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to list cached repos: %v\n", err)
+		return 1
+	}
+	if opts.JSON {
+		if repos == nil {
+			repos = []store.RepoInfo{}
+		}
+		return printJSON(repos)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REPO\tREV\tSIZE")
+	for _, r := range repos {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", r.Repo, r.Rev, formatSize(r.Size))
+	}
+	w.Flush()
+	return 0
+}
+
+func (c *StoreListCommand) Help() string {
+	return strings.TrimSpace(`
+Usage: pre-commit store list [options]
+
+  List cached hook repositories with their revs and sizes on disk.
+
+Options:
+
+      --json   Print output as JSON.
+`)
+}
+
+func (c *StoreListCommand) Synopsis() string {
+	return "List cached hook repositories"
+}
+
+// StoreExportCommand implements "store export" - packages the cache into an archive.
+type StoreExportCommand struct {
+	Meta *Meta
+}
+
+func (c *StoreExportCommand) Run(args []string) int {
+	var opts GlobalFlags
+	rest, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(rest) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: usage: store export <archive>\n")
+		return 1
+	}
+
+	s := store.New("")
+	if err := s.Export(rest[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to export store: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Exported %s to %s.\n", s.Dir(), rest[0])
+	return 0
+}
+
+func (c *StoreExportCommand) Help() string {
+	return strings.TrimSpace(`
+Usage: pre-commit store export <archive>
+
+  Package the cached hook repositories and their environments into a
+  gzipped tarball that can be imported on another machine.
+`)
+}
+
+func (c *StoreExportCommand) Synopsis() string {
+	return "Export the pre-commit cache to an archive"
+}
+
+// StoreImportCommand implements "store import" - seeds the cache from an archive.
+type StoreImportCommand struct {
+	Meta *Meta
+}
+
+func (c *StoreImportCommand) Run(args []string) int {
+	var opts GlobalFlags
+	rest, err := flags.ParseArgs(&opts, args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(rest) != 1 {
+		fmt.Fprintf(os.Stderr, "Error: usage: store import <archive>\n")
+		return 1
+	}
+
+	s := store.New("")
+	if err := s.Import(rest[0]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to import store: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Imported %s into %s.\n", rest[0], s.Dir())
+	return 0
+}
+
+func (c *StoreImportCommand) Help() string {
+	return strings.TrimSpace(`
+Usage: pre-commit store import <archive>
+
+  Unpack an archive created by "store export" into the pre-commit cache.
+  Environments that embed the exporting machine's cache path are removed
+  so they are rebuilt on next use.
+`)
+}
+
+func (c *StoreImportCommand) Synopsis() string {
+	return "Import the pre-commit cache from an archive"
+}
+
+// StorePruneReposCommand implements "store prune-repos" - removes cached
+// repos not referenced by any config under a set of directories.
+type StorePruneReposCommand struct {
+	Meta *Meta
+}
+
+type storePruneReposFlags struct {
+	GlobalFlags
+	ConfigRoots []string `long:"config-root" description:"Directory to scan for config files (repeatable)."`
+}
+
+func (c *StorePruneReposCommand) Run(args []string) int {
+	var opts storePruneReposFlags
+	if _, err := flags.ParseArgs(&opts, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if len(opts.ConfigRoots) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: at least one --config-root is required\n")
+		return 1
+	}
+
+	var configPaths []string
+	for _, root := range opts.ConfigRoots {
+		if strings.TrimSpace(root) == "" {
+			fmt.Fprintf(os.Stderr, "Error: --config-root must not be empty\n")
+			return 1
+		}
+		paths, err := findConfigs(root)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to scan %s: %v\n", root, err)
+			return 1
+		}
+		configPaths = append(configPaths, paths...)
+	}
+
+	// Unlike gc, a config that fails to load aborts the prune: skipping it
+	// would remove the repos it still needs.
+	used := make(map[string]bool)
+	for _, path := range configPaths {
+		cfg, err := config.LoadConfig(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		markUsedRepos(used, cfg)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	removed, err := store.New("").GCContext(ctx, used, progressPrinter("Removed"))
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "Interrupted: removed %d unreferenced repo(s) before stopping.\n", removed)
+		return 130
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to prune repos: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Removed %d unreferenced repo(s); %d config(s) scanned.\n", removed, len(configPaths))
+	return 0
+}
+
+// findConfigs returns every config file under root, skipping .git
+// directories.
+func findConfigs(root string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() && d.Name() == config.ConfigFile {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func (c *StorePruneReposCommand) Help() string {
+	return strings.TrimSpace(`
+Usage: pre-commit store prune-repos --config-root=DIR [--config-root=DIR...]
+
+  Remove cached hook repositories, and their environments, that no
+  .pre-commit-config.yaml under any of the given directories refers to.
+  Unlike gc, which only knows the configs it has seen used, this suits
+  shared runners that check out many projects. Any config that fails to
+  load aborts the prune.
+
+Options:
+
+      --config-root=DIR   Directory to scan for config files (repeatable).
+`)
+}
+
+func (c *StorePruneReposCommand) Synopsis() string {
+	return "Remove cached repos unused by configs under given directories"
+}
+
+// StoreUnlockCommand implements "store unlock" - shows and removes the store
+// lock.
+type StoreUnlockCommand struct {
+	Meta *Meta
+}
+
+type storeUnlockFlags struct {
+	GlobalFlags
+	Force bool `long:"force" description:"Remove the lock even if a process holds it."`
+}
+
+func (c *StoreUnlockCommand) Run(args []string) int {
+	var opts storeUnlockFlags
+	if _, err := flags.ParseArgs(&opts, args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	s := store.New("")
+	if opts.Force {
+		holder, err := s.ForceUnlock()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to remove store lock: %v\n", err)
+			return 1
+		}
+		if holder != nil {
+			fmt.Printf("Removed store lock held by pid %d.\n", holder.PID)
+		} else {
+			fmt.Println("Removed store lock.")
+		}
+		return 0
+	}
+
+	held, holder, err := s.LockStatus()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to check store lock: %v\n", err)
+		return 1
+	}
+	if !held {
+		fmt.Println("Store lock is not held.")
+		return 0
+	}
+	if holder == nil {
+		fmt.Println("Store lock is held by an unknown process.")
+	} else {
+		stale := ""
+		if holder.Stale() {
+			stale = " (stale)"
+		}
+		fmt.Printf("Store lock is held by pid %d since %s%s.\n", holder.PID, holder.Started.Format(time.RFC3339), stale)
+	}
+	fmt.Fprintf(os.Stderr, "Error: store lock is held; re-run with --force to remove it\n")
+	return 1
+}
+
+func (c *StoreUnlockCommand) Help() string {
+	return strings.TrimSpace(`
+Usage: pre-commit store unlock [options]
+
+  Show which process holds the pre-commit cache lock. Waiting processes
+  reclaim a lock on their own once its holder has exited or held it for
+  over an hour; --force removes it immediately, for a holder that is hung.
+
+Options:
+
+      --force   Remove the lock even if a process holds it.
+`)
+}
+
+func (c *StoreUnlockCommand) Synopsis() string {
+	return "Show or forcibly remove the pre-commit cache lock"
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blairham/go-pre-commit/v4/internal/store"
+)
+
+// seedStore writes a store database with the given repos under dir, each
+// with a small file so it has a non-zero size.
+func seedStore(t *testing.T, dir string, repos ...store.RepoEntry) {
+	t.Helper()
+	for i := range repos {
+		repos[i].Path = filepath.Join(dir, repos[i].Path)
+		if err := os.MkdirAll(repos[i].Path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(repos[i].Path, "hook.py"), []byte("print('hi')\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := json.Marshal(map[string]any{"repos": repos})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "db.json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStoreDirCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", dir)
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&StoreDirCommand{Meta: &Meta{}}).Run(nil)
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if got := strings.TrimSpace(out); got != dir {
+		t.Errorf("store dir = %q, want %q", got, dir)
+	}
+
+	out = captureStdout(t, func() {
+		code = (&StoreDirCommand{Meta: &Meta{}}).Run([]string{"--json"})
+	})
+	var got map[string]string
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if got["dir"] != dir {
+		t.Errorf("store dir --json = %v, want dir %q", got, dir)
+	}
+}
+
+func TestStoreListCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", dir)
+	seedStore(t, dir,
+		store.RepoEntry{Repo: "https://github.com/example/one", Rev: "v1.0.0", Path: "repoone"},
+		store.RepoEntry{Repo: "https://github.com/example/two", Rev: "abc123", Path: "repotwo"},
+	)
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&StoreListCommand{Meta: &Meta{}}).Run(nil)
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	for _, want := range []string{"https://github.com/example/one", "v1.0.0", "https://github.com/example/two", "abc123"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+
+	out = captureStdout(t, func() {
+		code = (&StoreListCommand{Meta: &Meta{}}).Run([]string{"--json"})
+	})
+	var repos []store.RepoInfo
+	if err := json.Unmarshal([]byte(out), &repos); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("expected 2 repos, got %d", len(repos))
+	}
+	if repos[0].Repo != "https://github.com/example/one" || repos[0].Size == 0 {
+		t.Errorf("unexpected first repo: %+v", repos[0])
+	}
+}
+
+func TestStoreDuCommandJSON(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", dir)
+	seedStore(t, dir, store.RepoEntry{Repo: "https://github.com/example/one", Rev: "v1", Path: "repoone"})
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&StoreDuCommand{Meta: &Meta{}}).Run([]string{"--json"})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	var u store.Usage
+	if err := json.Unmarshal([]byte(out), &u); err != nil {
+		t.Fatalf("invalid JSON %q: %v", out, err)
+	}
+	if u.Repos == 0 || u.Total < u.Repos {
+		t.Errorf("unexpected usage: %+v", u)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		0:               "0 B",
+		1023:            "1023 B",
+		1024:            "1.0 KB",
+		1536:            "1.5 KB",
+		5 * 1024 * 1024: "5.0 MB",
+	}
+	for n, want := range tests {
+		if got := formatSize(n); got != want {
+			t.Errorf("formatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestStorePruneReposCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", dir)
+	seedStore(t, dir,
+		store.RepoEntry{Repo: "https://github.com/example/one", Rev: "v1.0.0", Path: "repoone"},
+		store.RepoEntry{Repo: "https://github.com/example/two", Rev: "v2.0.0", Path: "repotwo"},
+		store.RepoEntry{Repo: "https://github.com/example/old", Rev: "v0.1.0", Path: "repoold"},
+	)
+
+	root := t.TempDir()
+	for name, repo := range map[string]string{"alpha": "one", "nested/beta": "two"} {
+		projDir := filepath.Join(root, name)
+		if err := os.MkdirAll(projDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		rev := map[string]string{"one": "v1.0.0", "two": "v2.0.0"}[repo]
+		cfg := "repos:\n-   repo: https://github.com/example/" + repo + "\n    rev: " + rev + "\n    hooks:\n    -   id: lint\n"
+		if err := os.WriteFile(filepath.Join(projDir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&StorePruneReposCommand{Meta: &Meta{}}).Run([]string{"--config-root", root})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if !strings.Contains(out, "Removed 1 unreferenced repo(s); 2 config(s) scanned.") {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	for name, want := range map[string]bool{"repoone": true, "repotwo": true, "repoold": false} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s exists = %v, want %v", name, exists, want)
+		}
+	}
+	repos, err := store.New("").ListRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Errorf("store db has %d repos, want 2: %+v", len(repos), repos)
+	}
+
+	if code := (&StorePruneReposCommand{Meta: &Meta{}}).Run(nil); code != 1 {
+		t.Errorf("expected exit code 1 without --config-root, got %d", code)
+	}
+	stderr := captureStderr(t, func() {
+		code = (&StorePruneReposCommand{Meta: &Meta{}}).Run([]string{"--config-root", ""})
+	})
+	if code != 1 || !strings.Contains(stderr, "--config-root must not be empty") {
+		t.Errorf("expected an empty --config-root to be rejected, got exit code %d:\n%s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "repoone")); err != nil {
+		t.Errorf("empty --config-root removed a used repo: %v", err)
+	}
+}
+
+func TestStoreUnlockCommand(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", dir)
+	if err := os.WriteFile(filepath.Join(dir, ".lock"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	holder := `{"pid": 42, "started": "2026-01-02T03:04:05Z"}`
+	if err := os.WriteFile(filepath.Join(dir, ".lock.holder"), []byte(holder), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	out := captureStdout(t, func() {
+		code = (&StoreUnlockCommand{Meta: &Meta{}}).Run(nil)
+	})
+	if code != 0 || !strings.Contains(out, "Store lock is not held.") {
+		t.Errorf("code = %d, output:\n%s", code, out)
+	}
+
+	out = captureStdout(t, func() {
+		code = (&StoreUnlockCommand{Meta: &Meta{}}).Run([]string{"--force"})
+	})
+	if code != 0 || !strings.Contains(out, "Removed store lock held by pid 42.") {
+		t.Errorf("code = %d, output:\n%s", code, out)
+	}
+	for _, name := range []string{".lock", ".lock.holder"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s not removed: %v", name, err)
+		}
+	}
+}
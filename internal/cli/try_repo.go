@@ -1,11 +1,9 @@
 package cli
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -103,25 +101,20 @@ func (c *TryRepoCommand) Run(args []string) int {
 		},
 	}
 
-	// If it's a local path, create a shadow clone with uncommitted changes.
+	// If it's a local path, snapshot it including uncommitted changes.
 	var hooks []*hook.Hook
-	var cleanupDir string
 	if isLocalPath(repoURL) {
-		var repoDir string
-		repoDir, cleanupDir, err = shadowCloneLocal(repoURL, tryRef)
+		repoDir, cleanup, err := snapshotLocalRepo(repoURL, opts.Ref)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: failed to prepare local repo: %v\n", err)
 			return 1
 		}
-		if cleanupDir != "" {
-			defer os.RemoveAll(cleanupDir)
-		}
+		defer cleanup()
 
 		manifestPath := filepath.Join(repoDir, config.ManifestFile)
-		var manifest []config.ManifestHook
-		manifest, err = config.LoadManifest(manifestPath)
+		manifest, err := config.LoadManifest(manifestPath)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: failed to load manifest from %s: %v\n", repoDir, err)
+			printError(err, "failed to load manifest from %s: %v", repoDir, err)
 			return 1
 		}
 		for i := range manifest {
@@ -134,11 +127,18 @@ func (c *TryRepoCommand) Run(args []string) int {
 		resolver := repository.NewResolver(s, tryConfig)
 		hooks, err = resolver.ResolveAll(context.Background(), tryConfig)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error: failed to resolve hooks: %v\n", err)
+			printError(err, "failed to resolve hooks: %v", err)
 			return 1
 		}
 	}
 
+	missingRuntimes, failed := hook.InstallEnvironmentsSummary(context.Background(), hooks).MissingRuntimes()
+	if len(failed) > 0 {
+		err := failed[0].Err
+		printError(err, "failed to install environments: %v", err)
+		return 1
+	}
+
 	// Determine files.
 	var filenames []string
 	if opts.AllFiles {
@@ -163,9 +163,12 @@ func (c *TryRepoCommand) Run(args []string) int {
 		}
 	}
 
-	stage := config.Stage(opts.HookStage)
-	if stage == "" {
-		stage = config.HookTypePreCommit
+	stage := config.HookTypePreCommit
+	if opts.HookStage != "" {
+		if stage, err = config.ParseStage(opts.HookStage); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --hook-stage: %v\n", err)
+			return 1
+		}
 	}
 
 	// Build a minimal config for the runner.
@@ -174,6 +177,11 @@ func (c *TryRepoCommand) Run(args []string) int {
 		runCfg.FailFast = true
 	}
 
+	var diffBefore string
+	if opts.ShowDiffOnFail {
+		diffBefore, _ = hook.WorkingTreeDiff()
+	}
+
 	root, _ := git.GetRoot()
 	runner := hook.NewRunner(runCfg, hooks, root)
 	result := runner.Run(context.Background(), hook.RunOptions{
@@ -199,12 +207,17 @@ func (c *TryRepoCommand) Run(args []string) int {
 		RewriteCommand:             opts.RewriteCmd,
 		PreRebaseUpstream:          opts.PreRebaseUp,
 		PreRebaseBranch:            opts.PreRebaseBranch,
+		MissingRuntimes:            missingRuntimes,
 	})
 
 	hasFailures := result.Failed > 0 || result.Errors > 0
 
 	if opts.ShowDiffOnFail && hasFailures {
-		hook.ShowDiffOnFailure(opts.AllFiles)
+		diff, err := hook.ChangedDiff(diffBefore)
+		if err != nil {
+			output.Warn("Failed to compute diff: %v", err)
+		}
+		hook.ShowDiffOnFailure(diff, opts.AllFiles)
 	}
 
 	if hasFailures {
@@ -219,8 +232,12 @@ func (c *TryRepoCommand) Help() string {
 Usage: pre-commit try-repo [options] REPO [hook-id]
 
   Try the hooks in a repository, useful for developing new hooks.
-  If REPO is a local path, the hooks are run from the local directory
-  (including uncommitted changes via a shadow clone).
+  REPO may be a local directory, which needs no remote. If it is a git repo,
+  its working tree state is tried without --ref, including uncommitted and
+  untracked changes, via a temporary worktree snapshot; with --ref, the
+  committed state at that ref is used. A directory that is not a git repo
+  is tried as is, and --ref is ignored. Hook environments are built in the
+  snapshot, never in the directory itself.
 
 Options:
 
@@ -248,93 +265,118 @@ func isLocalPath(path string) bool {
 	return err == nil && info.IsDir()
 }
 
-// shadowCloneLocal creates a shadow clone of a local repository so that
-// uncommitted changes (staged and unstaged) are included when testing hooks.
-// This matches Python pre-commit's try-repo behavior for local repos.
-// Returns (repoDir, cleanupDir, error). cleanupDir should be removed when done.
-func shadowCloneLocal(localPath, ref string) (string, string, error) {
-	// Resolve to absolute path.
+// snapshotLocalRepo returns a temporary directory holding the state of a
+// local hooks repo to try, so hook environments are built there rather
+// than in the developer's checkout. Without an explicit ref, uncommitted
+// changes (staged, unstaged and untracked files) are included by checking
+// out a `git stash create` snapshot into a temporary worktree, matching
+// Python pre-commit's try-repo behavior for local repos. With a ref, the
+// committed state at that ref is checked out instead. A directory that is
+// not a git repo is copied as is, and ref is ignored with a warning. The
+// returned cleanup function removes the temporary directory and must
+// always be called.
+func snapshotLocalRepo(localPath, ref string) (string, func(), error) {
+	noop := func() {}
+
 	absPath, err := filepath.Abs(localPath)
 	if err != nil {
-		return "", "", err
+		return "", noop, err
 	}
 
-	// Check if it's a git repo.
-	if !git.IsInsideWorkTreeInDir(absPath) {
-		// Not a git repo, just use directly.
-		return absPath, "", nil
+	tmpDir, err := os.MkdirTemp("", "pre-commit-try-repo-*")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create temp dir: %w", err)
 	}
+	worktree := filepath.Join(tmpDir, "repo")
 
-	// Check for any staged or unstaged changes.
-	hasStagedChanges, _ := git.HasStagedChanges(absPath)
-	hasUnstagedChanges, _ := git.HasUnstagedChanges(absPath)
-
-	if !hasStagedChanges && !hasUnstagedChanges {
-		// No local changes, use directly.
-		return absPath, "", nil
+	if !git.IsInsideWorkTreeInDir(absPath) {
+		if ref != "" {
+			output.Warn("--ref is ignored: %s is not a git repository", localPath)
+		}
+		cleanup := func() { os.RemoveAll(tmpDir) }
+		if err := copyTree(absPath, worktree); err != nil {
+			cleanup()
+			return "", noop, fmt.Errorf("failed to copy %s: %w", localPath, err)
+		}
+		return worktree, cleanup, nil
 	}
 
-	// Create a shadow clone to capture uncommitted changes.
-	tmpDir, err := os.MkdirTemp("", "pre-commit-try-repo-*")
-	if err != nil {
-		return "", "", fmt.Errorf("failed to create temp dir: %w", err)
+	snapshot := ref
+	var untracked []string
+	if ref == "" {
+		out, _ := git.CmdOutputInDir(absPath, "ls-files", "--others", "--exclude-standard", "-z")
+		untracked = splitNullTerminated(out)
+		snapshot, err = git.StashCreate(absPath)
+		if err != nil {
+			os.RemoveAll(tmpDir)
+			return "", noop, fmt.Errorf("failed to snapshot uncommitted changes: %w", err)
+		}
+		if snapshot == "" {
+			snapshot = "HEAD"
+		}
 	}
 
-	// Clone the local repo.
-	if err := git.Clone(absPath, tmpDir, "--no-checkout"); err != nil {
+	cleanup := func() {
+		_ = git.WorktreeRemove(absPath, worktree)
 		os.RemoveAll(tmpDir)
-		return "", "", fmt.Errorf("failed to clone local repo: %w", err)
+		_, _ = git.CmdOutputInDir(absPath, "worktree", "prune")
 	}
 
-	// Checkout the target ref.
-	if err := git.Checkout(tmpDir, ref); err != nil {
-		os.RemoveAll(tmpDir)
-		return "", "", fmt.Errorf("failed to checkout %s: %w", ref, err)
+	if err := git.WorktreeAdd(absPath, worktree, snapshot); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to check out %s: %w", snapshot, err)
 	}
-
-	// Apply staged changes.
-	if hasStagedChanges {
-		diffCmd := exec.Command("git", "diff", "--staged", "--binary")
-		diffCmd.Dir = absPath
-		patch, err := diffCmd.Output()
-		if err == nil && len(patch) > 0 {
-			applyCmd := exec.Command("git", "apply", "--allow-empty")
-			applyCmd.Dir = tmpDir
-			applyCmd.Stdin = bytes.NewReader(patch)
-			_ = applyCmd.Run()
+	for _, f := range untracked {
+		if err := copyFileMode(filepath.Join(absPath, f), filepath.Join(worktree, f)); err != nil {
+			cleanup()
+			return "", noop, fmt.Errorf("failed to copy untracked file %s: %w", f, err)
 		}
 	}
+	return worktree, cleanup, nil
+}
 
-	// Apply unstaged changes.
-	if hasUnstagedChanges {
-		diffCmd := exec.Command("git", "diff", "--binary")
-		diffCmd.Dir = absPath
-		patch, err := diffCmd.Output()
-		if err == nil && len(patch) > 0 {
-			applyCmd := exec.Command("git", "apply", "--allow-empty")
-			applyCmd.Dir = tmpDir
-			applyCmd.Stdin = bytes.NewReader(patch)
-			_ = applyCmd.Run()
+// copyTree copies the directory src to dst, keeping file permissions and
+// symlinks.
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
-	}
-
-	// Also copy untracked files.
-	untrackedCmd := exec.Command("git", "ls-files", "--others", "--exclude-standard", "-z")
-	untrackedCmd.Dir = absPath
-	untrackedOut, err := untrackedCmd.Output()
-	if err == nil && len(untrackedOut) > 0 {
-		for _, f := range splitNullTerminated(string(untrackedOut)) {
-			src := filepath.Join(absPath, f)
-			dst := filepath.Join(tmpDir, f)
-			os.MkdirAll(filepath.Dir(dst), 0o755)
-			data, err := os.ReadFile(src)
-			if err == nil {
-				os.WriteFile(dst, data, 0o644)
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		switch {
+		case d.IsDir():
+			return os.MkdirAll(target, 0o755)
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
 			}
+			return os.Symlink(link, target)
+		default:
+			return copyFileMode(path, target)
 		}
-	}
+	})
+}
 
-	return tmpDir, tmpDir, nil
+// copyFileMode copies src to dst, keeping src's permissions so untracked
+// hook scripts stay executable.
+func copyFileMode(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(dst, data, info.Mode().Perm())
 }
 
 func splitNullTerminated(s string) []string {
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// initLocalHooksRepo creates a committed hooks repo whose single hook writes
+// "committed" to marker.
+func initLocalHooksRepo(t *testing.T, marker string) string {
+	t.Helper()
+	dir := t.TempDir()
+	manifest := `-   id: mark
+    name: mark
+    entry: sh -c 'echo committed > ` + marker + `' --
+    language: system
+    always_run: true
+    pass_filenames: false
+`
+	gitIn(t, dir, "init", "-b", "main")
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-hooks.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	gitIn(t, dir, "add", ".")
+	gitIn(t, dir, "commit", "-m", "init")
+	return dir
+}
+
+// tryRepoIn runs try-repo against hooksRepo from a fresh target repo.
+func tryRepoIn(t *testing.T, hooksRepo string, args ...string) int {
+	t.Helper()
+	target := t.TempDir()
+	gitIn(t, target, "init", "-b", "main")
+	t.Chdir(target)
+	cmd := &TryRepoCommand{Meta: &Meta{}}
+	return cmd.Run(append(args, hooksRepo))
+}
+
+func readMarker(t *testing.T, marker string) string {
+	t.Helper()
+	data, err := os.ReadFile(marker)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestTryRepo_LocalUncommittedChanges(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	marker := filepath.Join(t.TempDir(), "marker")
+	hooksRepo := initLocalHooksRepo(t, marker)
+
+	// Point the hook at an untracked, executable script without committing.
+	script := "#!/bin/sh\necho uncommitted > " + marker + "\n"
+	if err := os.WriteFile(filepath.Join(hooksRepo, "mark.sh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	manifest := `-   id: mark
+    name: mark
+    entry: mark.sh
+    language: script
+    always_run: true
+    pass_filenames: false
+`
+	if err := os.WriteFile(filepath.Join(hooksRepo, ".pre-commit-hooks.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := tryRepoIn(t, hooksRepo, "--all-files"); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if got := readMarker(t, marker); got != "uncommitted" {
+		t.Errorf("hook wrote %q, want the uncommitted change to take effect", got)
+	}
+
+	out := gitOut(t, hooksRepo, "worktree", "list")
+	if n := len(strings.Split(strings.TrimSpace(out), "\n")); n != 1 {
+		t.Errorf("temporary worktree not cleaned up:\n%s", out)
+	}
+	if out := gitOut(t, hooksRepo, "stash", "list"); out != "" {
+		t.Errorf("stash list modified: %q", out)
+	}
+}
+
+func TestTryRepo_LocalExplicitRefUsesCommittedState(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	marker := filepath.Join(t.TempDir(), "marker")
+	hooksRepo := initLocalHooksRepo(t, marker)
+
+	manifest := `-   id: mark
+    name: mark
+    entry: sh -c 'echo uncommitted > ` + marker + `' --
+    language: system
+    always_run: true
+    pass_filenames: false
+`
+	if err := os.WriteFile(filepath.Join(hooksRepo, ".pre-commit-hooks.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := tryRepoIn(t, hooksRepo, "--all-files", "--ref", "HEAD"); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if got := readMarker(t, marker); got != "committed" {
+		t.Errorf("hook wrote %q, want the committed state with --ref", got)
+	}
+}
+
+func TestTryRepo_LocalDirectoryWithoutGit(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	t.Setenv("PRE_COMMIT_MIN_FREE_SPACE", "0")
+	record := filepath.Join(t.TempDir(), "files")
+	registerLanguage(t, fakeLanguage{name: "trytest", record: record})
+
+	hooksDir := t.TempDir()
+	manifest := "-   id: check\n    name: check\n    entry: check\n    language: trytest\n" +
+		"-   id: other\n    name: other\n    entry: 'false'\n    language: system\n"
+	if err := os.WriteFile(filepath.Join(hooksDir, ".pre-commit-hooks.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := t.TempDir()
+	gitIn(t, target, "init", "-b", "main")
+	for _, name := range []string{"staged.txt", "unstaged.txt"} {
+		if err := os.WriteFile(filepath.Join(target, name), []byte(name+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gitIn(t, target, "add", "staged.txt")
+	t.Chdir(target)
+
+	var code int
+	var stdout string
+	stderr := captureStderr(t, func() {
+		stdout = captureStdout(t, func() {
+			code = (&TryRepoCommand{Meta: &Meta{}}).Run([]string{"--ref", "v1", hooksDir, "check"})
+		})
+	})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d\n%s", code, stderr)
+	}
+	if !strings.Contains(stdout, "--ref is ignored") {
+		t.Errorf("expected a warning that --ref is ignored, got:\n%s", stdout)
+	}
+	if got := readMarker(t, record); got != "staged.txt" {
+		t.Errorf("hook ran on %q, want only the staged file", got)
+	}
+	if envs, _ := filepath.Glob(filepath.Join(hooksDir, "trytest_env-*")); len(envs) > 0 {
+		t.Errorf("environment built inside the hook directory: %v", envs)
+	}
+}
@@ -8,6 +8,8 @@ import (
 	flags "github.com/jessevdk/go-flags"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
 )
 
 // ValidateConfigCommand implements the "validate-config" command.
@@ -32,14 +34,24 @@ func (c *ValidateConfigCommand) Run(args []string) int {
 	for _, filename := range filenames {
 		cfg, err := config.LoadConfig(filename)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, errkind.Prefix(errkind.Config, err.Error()))
 			allValid = false
 			continue
 		}
 		if err := cfg.Validate(); err != nil {
-			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, errkind.Prefix(errkind.Config, err.Error()))
 			allValid = false
 		}
+		if err := checkHookLanguages(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", filename, errkind.Prefix(errkind.Config, err.Error()))
+			allValid = false
+		}
+		for _, repo := range cfg.Repos {
+			if !repo.IsLocal() && !repo.IsMeta() && repo.Rev != "" {
+				config.WarnMutableRev(repo.Repo, repo.Rev)
+			}
+		}
+		warnExcludeEverything(filename, cfg)
 	}
 
 	if !allValid {
@@ -50,12 +62,50 @@ func (c *ValidateConfigCommand) Run(args []string) int {
 	return 0
 }
 
+// checkHookLanguages reports the first hook whose language is not one
+// pre-commit supports.
+func checkHookLanguages(cfg *config.Config) error {
+	for i, repo := range cfg.Repos {
+		for j, h := range repo.Hooks {
+			if h.Language == "" {
+				continue
+			}
+			if _, err := languages.Get(h.Language); err != nil {
+				return fmt.Errorf("repos[%d].hooks[%d]%s: unsupported language %q for hook %q",
+					i, j, cfg.HookLocation(i, j, "language"), h.Language, h.ID)
+			}
+		}
+	}
+	return nil
+}
+
+// warnExcludeEverything warns about hooks whose exclude matches every path,
+// since without always_run such a hook never runs.
+func warnExcludeEverything(filename string, cfg *config.Config) {
+	for _, repo := range cfg.Repos {
+		for _, h := range repo.Hooks {
+			if h.Exclude == "" || (h.AlwaysRun != nil && *h.AlwaysRun) || !config.MatchesEverything(h.Exclude) {
+				continue
+			}
+			fmt.Fprintf(os.Stderr,
+				"%s: WARNING: The 'exclude' of hook %q (%q) matches every file, so the hook never runs.\n",
+				filename, h.ID, h.Exclude,
+			)
+		}
+	}
+}
+
 func (c *ValidateConfigCommand) Help() string {
 	return strings.TrimSpace(`
 Usage: pre-commit validate-config [options] [filenames...]
 
   Validate .pre-commit-config.yaml files. If no filenames are given,
-  validates the default config.
+  validates the default config. A warning is printed for repos whose rev
+  is HEAD or a branch (main, master, refs/heads/...), and for hooks whose
+  exclude matches every file (e.g. '.*') without always_run, since they
+  never run. Local hooks must set id, name, entry
+  and language, and every language must be supported; errors give the
+  line and column of the offending hook.
 
 Options:
 
@@ -73,8 +123,14 @@ type ValidateManifestCommand struct {
 	Meta *Meta
 }
 
+type validateManifestFlags struct {
+	GlobalFlags
+	Fix  bool `long:"fix" description:"Rewrite manifests to correct trivially-fixable issues."`
+	Sort bool `long:"sort" description:"With --fix, sort hooks by id."`
+}
+
 func (c *ValidateManifestCommand) Run(args []string) int {
-	var opts GlobalFlags
+	var opts validateManifestFlags
 	remaining, err := flags.ParseArgs(&opts, args)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -88,6 +144,18 @@ func (c *ValidateManifestCommand) Run(args []string) int {
 
 	allValid := true
 	for _, filename := range filenames {
+		if opts.Fix {
+			fixed, err := config.FixManifest(filename, opts.Sort)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+				allValid = false
+				continue
+			}
+			if fixed {
+				fmt.Printf("Fixed %s.\n", filename)
+			}
+			continue
+		}
 		_, err := config.LoadManifest(filename)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
@@ -109,10 +177,17 @@ Usage: pre-commit validate-manifest [options] [filenames...]
 
   Validate .pre-commit-hooks.yaml manifest files.
 
+  With --fix, trivially-fixable issues are corrected in place: missing
+  stages are filled in, legacy stage names are migrated and the
+  pass_filenames/always_run defaults are written explicitly. Comments are
+  preserved. Problems that cannot be fixed automatically are still errors.
+
 Options:
 
   -c, --config=FILE   Path to alternate config file.
       --color=MODE    Whether to use color (auto, always, never).
+      --fix           Rewrite manifests to correct trivially-fixable issues.
+      --sort          With --fix, sort hooks by id.
 `)
 }
 
@@ -0,0 +1,204 @@
+package cli
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigCommand_MutableRevWarning(t *testing.T) {
+	tests := []struct {
+		rev  string
+		warn bool
+	}{
+		{"0123456789abcdef0123456789abcdef01234567", false},
+		{"v1.2.3", false},
+		{"main", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rev, func(t *testing.T) {
+			cfgPath := filepath.Join(t.TempDir(), ".pre-commit-config.yaml")
+			cfg := "repos:\n-   repo: https://github.com/example/hooks\n    rev: " + tt.rev + "\n    hooks:\n    -   id: lint\n"
+			if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var code int
+			stderr := captureStderr(t, func() {
+				captureStdout(t, func() {
+					code = (&ValidateConfigCommand{Meta: &Meta{}}).Run([]string{cfgPath})
+				})
+			})
+			if code != 0 {
+				t.Fatalf("expected a warning, not a failure; exit code %d, stderr:\n%s", code, stderr)
+			}
+			warned := strings.Contains(stderr, "mutable reference")
+			if warned != tt.warn {
+				t.Errorf("warned = %v, want %v; stderr:\n%s", warned, tt.warn, stderr)
+			}
+			if tt.warn && !strings.Contains(stderr, "autoupdate --freeze") {
+				t.Errorf("expected warning to suggest autoupdate --freeze, got:\n%s", stderr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigCommand_ExcludeEverythingWarning(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude string
+		extra   string
+		warn    bool
+	}{
+		{"everything", ".*", "", true},
+		{"normal", "^vendor/", "", false},
+		{"always_run", ".*", "        always_run: true\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgPath := filepath.Join(t.TempDir(), ".pre-commit-config.yaml")
+			cfg := "repos:\n-   repo: local\n    hooks:\n    -   id: lint\n        name: lint\n        entry: lint\n        language: system\n        exclude: '" + tt.exclude + "'\n" + tt.extra
+			if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var code int
+			stderr := captureStderr(t, func() {
+				captureStdout(t, func() {
+					code = (&ValidateConfigCommand{Meta: &Meta{}}).Run([]string{cfgPath})
+				})
+			})
+			if code != 0 {
+				t.Fatalf("expected a warning, not a failure; exit code %d, stderr:\n%s", code, stderr)
+			}
+			warned := strings.Contains(stderr, `hook "lint"`) && strings.Contains(stderr, "never runs")
+			if warned != tt.warn {
+				t.Errorf("warned = %v, want %v; stderr:\n%s", warned, tt.warn, stderr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigCommand_AcceptsCIBlock(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), ".pre-commit-config.yaml")
+	cfg := `ci:
+    autofix_prs: true
+    autoupdate_schedule: weekly
+    skip: [lint]
+    submodules: false
+repos:
+-   repo: local
+    hooks:
+    -   id: lint
+        name: lint
+        entry: lint
+        language: system
+`
+	if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	stderr := captureStderr(t, func() {
+		captureStdout(t, func() {
+			code = (&ValidateConfigCommand{Meta: &Meta{}}).Run([]string{cfgPath})
+		})
+	})
+	if code != 0 || stderr != "" {
+		t.Errorf("expected the ci block to validate cleanly, got exit code %d, stderr:\n%s", code, stderr)
+	}
+}
+
+func TestValidateConfigCommand_LocalHookFields(t *testing.T) {
+	tests := []struct {
+		name string
+		hook string
+		want string
+	}{
+		{
+			name: "missing entry",
+			hook: "    -   id: lint\n        name: lint\n        language: system\n",
+			want: `repos[0].hooks[1] (line 8, column 9): 'entry' is required for local hook "lint"`,
+		},
+		{
+			name: "missing language",
+			hook: "    -   id: lint\n        name: lint\n        entry: lint\n",
+			want: `repos[0].hooks[1] (line 8, column 9): 'language' is required for local hook "lint"`,
+		},
+		{
+			name: "unsupported language",
+			hook: "    -   id: lint\n        name: lint\n        entry: lint\n        language: cobol\n",
+			want: `repos[0].hooks[1] (line 11, column 19): unsupported language "cobol" for hook "lint"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgPath := filepath.Join(t.TempDir(), ".pre-commit-config.yaml")
+			cfg := "repos:\n-   repo: local\n    hooks:\n" +
+				"    -   id: ok\n        name: ok\n        entry: ok\n        language: system\n" + tt.hook
+			if err := os.WriteFile(cfgPath, []byte(cfg), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var code int
+			stderr := captureStderr(t, func() {
+				captureStdout(t, func() {
+					code = (&ValidateConfigCommand{Meta: &Meta{}}).Run([]string{cfgPath})
+				})
+			})
+			if code != 1 {
+				t.Errorf("expected exit code 1, got %d", code)
+			}
+			if !strings.Contains(stderr, tt.want) {
+				t.Errorf("expected %q in stderr, got:\n%s", tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestValidateConfigCommand_OldStyleListConfig(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), ".pre-commit-config.yaml")
+	old := "# hooks\n-   repo: https://github.com/example/hooks\n    sha: v1.0.0\n    hooks:\n    -   id: lint\n"
+	if err := os.WriteFile(cfgPath, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	validate := func() (int, string) {
+		t.Helper()
+		var code int
+		stderr := captureStderr(t, func() {
+			captureStdout(t, func() {
+				code = (&ValidateConfigCommand{Meta: &Meta{}}).Run([]string{cfgPath})
+			})
+		})
+		return code, stderr
+	}
+
+	code, stderr := validate()
+	if code != 1 || !strings.Contains(stderr, "this looks like an old-style config") || !strings.Contains(stderr, "run `pre-commit migrate-config`") {
+		t.Fatalf("expected a migrate-config suggestion, got exit code %d:\n%s", code, stderr)
+	}
+	if strings.Contains(stderr, "cannot unmarshal") {
+		t.Errorf("expected the suggestion instead of the type error, got:\n%s", stderr)
+	}
+
+	// Following the suggestion fixes the config.
+	var migrateCode int
+	captureStdout(t, func() {
+		migrateCode = (&MigrateConfigCommand{Meta: &Meta{}}).Run([]string{"--config", cfgPath})
+	})
+	if migrateCode != 0 {
+		t.Fatalf("migrate-config: exit code %d", migrateCode)
+	}
+	data, err := os.ReadFile(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# hooks\nrepos:\n-   repo: https://github.com/example/hooks\n    rev: v1.0.0\n    hooks:\n    -   id: lint\n"
+	if string(data) != want {
+		t.Errorf("migrated config = %q, want %q", data, want)
+	}
+	if code, stderr := validate(); code != 0 {
+		t.Errorf("expected the migrated config to be valid, got exit code %d:\n%s", code, stderr)
+	}
+}
@@ -4,10 +4,15 @@ package config
 import (
 	"fmt"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp/syntax"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
 	"github.com/blairham/go-pre-commit/v4/internal/pcre"
 )
 
@@ -86,6 +91,24 @@ type Config struct {
 	FailFast                bool              `yaml:"fail_fast,omitempty"`
 	MinimumPreCommitVersion string            `yaml:"minimum_pre_commit_version,omitempty"`
 	CIConfig                map[string]any    `yaml:"ci,omitempty"`
+
+	// FilesCaseInsensitive makes the top-level files/exclude patterns, and
+	// by default every hook's, match regardless of case. It is off by
+	// default for parity with Python pre-commit.
+	FilesCaseInsensitive bool `yaml:"files_case_insensitive,omitempty"`
+
+	// PreRun and PostRun are commands run before the first hook and after
+	// the last one. A failing PreRun fails the run; PostRun always runs.
+	PreRun  string `yaml:"pre_run,omitempty"`
+	PostRun string `yaml:"post_run,omitempty"`
+
+	// Dir is the directory containing the config file. Relative paths in
+	// the config (such as file: dependencies) are resolved against it.
+	Dir string `yaml:"-"`
+
+	// hookNodes holds each hook's YAML mapping, by repo and hook index,
+	// when the config was parsed from a file, so errors can name a line.
+	hookNodes [][]*yaml.Node
 }
 
 // RepoConfig represents a single repo entry in the config.
@@ -93,6 +116,10 @@ type RepoConfig struct {
 	Repo  string       `yaml:"repo"`
 	Rev   string       `yaml:"rev,omitempty"`
 	Hooks []HookConfig `yaml:"hooks"`
+
+	// NoAutoupdate is set when the entry is marked with a
+	// "# pre-commit: no-autoupdate" comment, pinning it for autoupdate.
+	NoAutoupdate bool `yaml:"-"`
 }
 
 // IsLocal returns true if this is a local repo config.
@@ -128,6 +155,13 @@ type HookConfig struct {
 	FailFast               *bool    `yaml:"fail_fast,omitempty"`
 	Description            string   `yaml:"description,omitempty"`
 	LogFile                string   `yaml:"log_file,omitempty"`
+	FilesCaseInsensitive   *bool    `yaml:"files_case_insensitive,omitempty"`
+	AnnotationRegex        string   `yaml:"annotation_regex,omitempty"`
+	TrackedOnly            *bool    `yaml:"tracked_only,omitempty"`
+	WorkingDirectory       string   `yaml:"working_directory,omitempty"`
+	Branches               []string `yaml:"branches,omitempty"`
+	CondaEnvFile           string   `yaml:"conda_env_file,omitempty"`
+	Concurrency            int      `yaml:"concurrency,omitempty"`
 }
 
 // ManifestHook represents a hook entry in .pre-commit-hooks.yaml.
@@ -152,6 +186,7 @@ type ManifestHook struct {
 	RequireSerial           bool     `yaml:"require_serial,omitempty"`
 	Description             string   `yaml:"description,omitempty"`
 	MinimumPreCommitVersion string   `yaml:"minimum_pre_commit_version,omitempty"`
+	CondaEnvFile            string   `yaml:"conda_env_file,omitempty"`
 }
 
 // DefaultPassFilenames returns the pass_filenames value, defaulting to true.
@@ -163,7 +198,16 @@ func (h *ManifestHook) DefaultPassFilenames() bool {
 }
 
 // LoadConfig reads and parses a .pre-commit-config.yaml file.
+// Errors are classified as errkind.Config.
 func LoadConfig(path string) (*Config, error) {
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return nil, errkind.Wrap(errkind.Config, err)
+	}
+	return cfg, nil
+}
+
+func loadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
@@ -171,8 +215,17 @@ func LoadConfig(path string) (*Config, error) {
 
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		if IsListConfig(data) {
+			return nil, fmt.Errorf("failed to parse config file %s: this looks like an old-style config "+
+				"(a list of repos rather than a map with a 'repos' key); run `pre-commit migrate-config` to update it", path)
+		}
 		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
 	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err == nil {
+		cfg.hookNodes = hookNodes(&doc)
+		cfg.markNoAutoupdate(&doc)
+	}
 
 	if err := cfg.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid config %s: %w", path, err)
@@ -189,16 +242,13 @@ func LoadConfig(path string) (*Config, error) {
 		}
 	}
 
+	if abs, err := filepath.Abs(path); err == nil {
+		cfg.Dir = filepath.Dir(abs)
+	}
+
 	// Apply defaults.
 	cfg.ApplyDefaults()
 
-	// Warn about mutable revs.
-	for _, repo := range cfg.Repos {
-		if !repo.IsLocal() && !repo.IsMeta() && repo.Rev != "" {
-			WarnMutableRev(repo.Repo, repo.Rev)
-		}
-	}
-
 	return &cfg, nil
 }
 
@@ -226,19 +276,21 @@ func (c *Config) ApplyDefaults() {
 	}
 }
 
+// legacyStages maps legacy stage names to their current equivalents.
+var legacyStages = map[Stage]Stage{
+	"commit":       HookTypePreCommit,
+	"merge-commit": HookTypePreMergeCommit,
+	"push":         HookTypePrePush,
+}
+
 // migrateLegacyStages maps legacy stage names to their current equivalents.
 func migrateLegacyStages(stages []Stage) []Stage {
 	if len(stages) == 0 {
 		return stages
 	}
-	legacyMap := map[Stage]Stage{
-		"commit":       HookTypePreCommit,
-		"merge-commit": HookTypePreMergeCommit,
-		"push":         HookTypePrePush,
-	}
 	result := make([]Stage, len(stages))
 	for i, s := range stages {
-		if mapped, ok := legacyMap[s]; ok {
+		if mapped, ok := legacyStages[s]; ok {
 			result[i] = mapped
 		} else {
 			result[i] = s
@@ -247,6 +299,23 @@ func migrateLegacyStages(stages []Stage) []Stage {
 	return result
 }
 
+// ParseStage returns the stage named s, accepting legacy names such as
+// "commit", or an error listing the valid stages.
+func ParseStage(s string) (Stage, error) {
+	stage := Stage(s)
+	if mapped, ok := legacyStages[stage]; ok {
+		return mapped, nil
+	}
+	if !slices.Contains(AllStages(), stage) {
+		names := make([]string, 0, len(AllStages()))
+		for _, s := range AllStages() {
+			names = append(names, string(s))
+		}
+		return "", fmt.Errorf("unknown stage %q (expected one of: %s)", s, strings.Join(names, ", "))
+	}
+	return stage, nil
+}
+
 // CheckMinimumVersion checks if the current version meets the minimum requirement.
 func CheckMinimumVersion(minVersion string) bool {
 	cParts := splitVersionParts(Version)
@@ -304,28 +373,90 @@ func splitDot(s string) []string {
 
 // WarnMutableRev warns if a rev looks like a branch name rather than a tag/SHA.
 func WarnMutableRev(repo, rev string) {
-	// Check for common mutable rev patterns.
-	mutablePrefixes := []string{"master", "main", "develop", "HEAD"}
-	for _, prefix := range mutablePrefixes {
-		if rev == prefix {
-			fmt.Fprintf(os.Stderr,
-				"WARNING: The 'rev' field of repo %q appears to be a mutable reference (%q).\n"+
-					"Mutable references are never updated after first install and are not "+
-					"supported. Use `pre-commit autoupdate` to update to a pinned revision.\n",
-				repo, rev,
-			)
-			return
-		}
+	if !IsMutableRev(rev) {
+		return
+	}
+	fmt.Fprintf(os.Stderr,
+		"WARNING: The 'rev' field of repo %q appears to be a mutable reference (%q).\n"+
+			"Mutable references are never updated after first install and are not "+
+			"supported. Use `pre-commit autoupdate --freeze` to pin it to a commit.\n",
+		repo, rev,
+	)
+}
+
+// IsMutableRev reports whether rev is HEAD or names a branch: a
+// refs/heads/ ref or a common default branch name such as main. Anything
+// else may be a tag or an abbreviated SHA, so it is not reported.
+func IsMutableRev(rev string) bool {
+	if rev == "HEAD" || strings.HasPrefix(rev, "refs/heads/") {
+		return true
 	}
+	switch strings.ToLower(rev) {
+	case "main", "master", "develop", "development", "dev", "trunk":
+		return true
+	}
+	return false
+}
+
+// MatchesEverything reports whether pattern matches every path, like `.*`,
+// `.+` or `^.*$`. Patterns Go's regexp syntax can't parse are assumed not to.
+func MatchesEverything(pattern string) bool {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return false
+	}
+	re = re.Simplify()
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	for len(subs) > 0 && (subs[0].Op == syntax.OpBeginLine || subs[0].Op == syntax.OpBeginText) {
+		subs = subs[1:]
+	}
+	for len(subs) > 0 && (subs[len(subs)-1].Op == syntax.OpEndLine || subs[len(subs)-1].Op == syntax.OpEndText) {
+		subs = subs[:len(subs)-1]
+	}
+	if len(subs) != 1 {
+		return false
+	}
+	rep := subs[0]
+	for rep.Op == syntax.OpCapture {
+		rep = rep.Sub[0]
+	}
+	if rep.Op != syntax.OpStar && rep.Op != syntax.OpPlus {
+		return false
+	}
+	return rep.Sub[0].Op == syntax.OpAnyChar || rep.Sub[0].Op == syntax.OpAnyCharNotNL
 }
 
 // LoadManifest reads and parses a .pre-commit-hooks.yaml file.
+// Errors are classified as errkind.Config.
 func LoadManifest(path string) ([]ManifestHook, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read manifest file %s: %w", path, err)
+		return nil, errkind.Wrap(errkind.Config, fmt.Errorf("failed to read manifest file %s: %w", path, err))
+	}
+
+	hooks, err := parseManifest(data, path)
+	if err != nil {
+		return nil, errkind.Wrap(errkind.Config, err)
+	}
+	return hooks, nil
+}
+
+// ParseManifest parses and validates manifest contents, e.g. as read from a
+// git object rather than a file. source names them in errors. Errors are
+// classified as errkind.Config.
+func ParseManifest(data []byte, source string) ([]ManifestHook, error) {
+	hooks, err := parseManifest(data, source)
+	if err != nil {
+		return nil, errkind.Wrap(errkind.Config, err)
 	}
+	return hooks, nil
+}
 
+// parseManifest parses and validates manifest contents read from path.
+func parseManifest(data []byte, path string) ([]ManifestHook, error) {
 	var hooks []ManifestHook
 	if err := yaml.Unmarshal(data, &hooks); err != nil {
 		return nil, fmt.Errorf("failed to parse manifest file %s: %w", path, err)
@@ -365,24 +496,39 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("repos[%d]: 'hooks' is required for repo %q", i, repo.Repo)
 		}
 		for j, hook := range repo.Hooks {
+			at := c.HookLocation(i, j, "")
 			if hook.ID == "" {
-				return fmt.Errorf("repos[%d].hooks[%d]: 'id' is required", i, j)
+				return fmt.Errorf("repos[%d].hooks[%d]%s: 'id' is required", i, j, at)
 			}
 			// Local hooks require additional fields.
 			if repo.IsLocal() {
 				if hook.Name == "" {
-					return fmt.Errorf("repos[%d].hooks[%d]: 'name' is required for local hook %q", i, j, hook.ID)
+					return fmt.Errorf("repos[%d].hooks[%d]%s: 'name' is required for local hook %q", i, j, at, hook.ID)
 				}
 				if hook.Entry == "" {
-					return fmt.Errorf("repos[%d].hooks[%d]: 'entry' is required for local hook %q", i, j, hook.ID)
+					return fmt.Errorf("repos[%d].hooks[%d]%s: 'entry' is required for local hook %q", i, j, at, hook.ID)
 				}
 				if hook.Language == "" {
-					return fmt.Errorf("repos[%d].hooks[%d]: 'language' is required for local hook %q", i, j, hook.ID)
+					return fmt.Errorf("repos[%d].hooks[%d]%s: 'language' is required for local hook %q", i, j, at, hook.ID)
 				}
 			}
 		}
 	}
 
+	// pre_run/post_run must be real commands when present.
+	if c.PreRun != "" && strings.TrimSpace(c.PreRun) == "" {
+		return fmt.Errorf("'pre_run' must not be blank")
+	}
+	if c.PostRun != "" && strings.TrimSpace(c.PostRun) == "" {
+		return fmt.Errorf("'post_run' must not be blank")
+	}
+
+	for _, stage := range c.DefaultStages {
+		if _, err := ParseStage(string(stage)); err != nil {
+			return fmt.Errorf("'default_stages': %w", err)
+		}
+	}
+
 	// Validate regex patterns.
 	if c.Files != "" {
 		if _, err := pcre.Compile(c.Files); err != nil {
@@ -395,6 +541,12 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	// The ci block belongs to pre-commit.ci and is otherwise ignored, but
+	// run --ci reads its skip list.
+	if _, err := c.CISkip(); err != nil {
+		return err
+	}
+
 	// Validate hook-level regex patterns.
 	for i, repo := range c.Repos {
 		for j, hook := range repo.Hooks {
@@ -408,12 +560,52 @@ func (c *Config) Validate() error {
 					return fmt.Errorf("repos[%d].hooks[%d] (%s): invalid 'exclude' pattern: %w", i, j, hook.ID, err)
 				}
 			}
+			if hook.AnnotationRegex != "" {
+				if _, err := pcre.Compile(hook.AnnotationRegex); err != nil {
+					return fmt.Errorf("repos[%d].hooks[%d] (%s): invalid 'annotation_regex' pattern: %w", i, j, hook.ID, err)
+				}
+			}
+			for _, stage := range hook.Stages {
+				if _, err := ParseStage(string(stage)); err != nil {
+					return fmt.Errorf("repos[%d].hooks[%d] (%s): %w", i, j, hook.ID, err)
+				}
+			}
+			if hook.Concurrency < 0 {
+				return fmt.Errorf("repos[%d].hooks[%d] (%s): 'concurrency' must not be negative", i, j, hook.ID)
+			}
+			for _, pattern := range hook.Branches {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("repos[%d].hooks[%d] (%s): invalid 'branches' pattern %q: %w", i, j, hook.ID, pattern, err)
+				}
+			}
 		}
 	}
 
 	return nil
 }
 
+// CISkip returns the hook ids listed under the ci block's skip key, which
+// pre-commit.ci skips and run --ci skips locally too.
+func (c *Config) CISkip() ([]string, error) {
+	raw, ok := c.CIConfig["skip"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]any)
+	if !ok {
+		return nil, fmt.Errorf("'ci.skip' must be a list of hook ids")
+	}
+	ids := make([]string, 0, len(list))
+	for _, v := range list {
+		id, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("'ci.skip' must be a list of hook ids, got %v", v)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 // ValidateConfigFile validates a .pre-commit-config.yaml file.
 func ValidateConfigFile(path string) error {
 	_, err := LoadConfig(path)
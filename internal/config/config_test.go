@@ -1,8 +1,10 @@
 package config
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -43,6 +45,39 @@ func TestLoadConfig_Valid(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_PerHookVerbose(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := `repos:
+-   repo: local
+    hooks:
+    -   id: summary
+        name: summary
+        entry: echo
+        language: system
+        verbose: true
+    -   id: quiet
+        name: quiet
+        entry: echo
+        language: system
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hooks := cfg.Repos[0].Hooks
+	if hooks[0].Verbose == nil || !*hooks[0].Verbose {
+		t.Errorf("expected verbose: true on first hook, got %v", hooks[0].Verbose)
+	}
+	if hooks[1].Verbose != nil {
+		t.Errorf("expected verbose unset on second hook, got %v", *hooks[1].Verbose)
+	}
+}
+
 func TestLoadConfig_MissingFile(t *testing.T) {
 	_, err := LoadConfig("/nonexistent/path/config.yaml")
 	if err == nil {
@@ -149,6 +184,25 @@ func TestValidate_MetaRepoNoRevRequired(t *testing.T) {
 	}
 }
 
+func TestValidate_CISkip(t *testing.T) {
+	newConfig := func(ci map[string]any) *Config {
+		return &Config{
+			Repos:    []RepoConfig{{Repo: "meta", Hooks: []HookConfig{{ID: "check-hooks-apply"}}}},
+			CIConfig: ci,
+		}
+	}
+	cfg := newConfig(map[string]any{"autofix_prs": true, "skip": []any{"lint", "mypy"}})
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("ci block should validate: %v", err)
+	}
+	if got, _ := cfg.CISkip(); !slices.Equal(got, []string{"lint", "mypy"}) {
+		t.Errorf("CISkip() = %v, want [lint mypy]", got)
+	}
+	if err := newConfig(map[string]any{"skip": "lint"}).Validate(); err == nil {
+		t.Error("expected a non-list ci.skip to be rejected")
+	}
+}
+
 func TestValidate_LocalHookRequiresFields(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -275,6 +329,56 @@ func TestValidate_InvalidRegex_HookLevel(t *testing.T) {
 	}
 }
 
+func TestValidate_InvalidBranchesPattern(t *testing.T) {
+	cfg := &Config{
+		Repos: []RepoConfig{{
+			Repo:  "local",
+			Hooks: []HookConfig{{ID: "test", Name: "test", Entry: "true", Language: "system", Branches: []string{"main", "release/["}}},
+		}},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), `invalid 'branches' pattern "release/["`) {
+		t.Errorf("expected invalid branches pattern error, got: %v", err)
+	}
+}
+
+func TestValidate_UnknownStage(t *testing.T) {
+	cfg := &Config{
+		Repos: []RepoConfig{{
+			Repo:  "local",
+			Hooks: []HookConfig{{ID: "test", Name: "test", Entry: "true", Language: "system", Stages: []Stage{HookTypePostCheckout, "post-chekout"}}},
+		}},
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), `unknown stage "post-chekout"`) || !strings.Contains(err.Error(), "manual") {
+		t.Errorf("expected unknown stage error listing valid stages, got: %v", err)
+	}
+
+	cfg.Repos[0].Hooks[0].Stages = []Stage{"commit", StageManual}
+	cfg.DefaultStages = []Stage{"pre-comit"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "'default_stages'") {
+		t.Errorf("expected default_stages error, got: %v", err)
+	}
+}
+
+func TestParseStage(t *testing.T) {
+	for in, want := range map[string]Stage{
+		"manual":             StageManual,
+		"post-checkout":      HookTypePostCheckout,
+		"post-merge":         HookTypePostMerge,
+		"post-rewrite":       HookTypePostRewrite,
+		"prepare-commit-msg": HookTypePrepareCommitMsg,
+		"commit":             HookTypePreCommit,
+	} {
+		if got, err := ParseStage(in); err != nil || got != want {
+			t.Errorf("ParseStage(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseStage("pre-comit"); err == nil {
+		t.Error("ParseStage(\"pre-comit\") succeeded")
+	}
+}
+
 func TestValidate_ValidRegex(t *testing.T) {
 	cfg := &Config{
 		Files:   `\.go$`,
@@ -628,8 +732,81 @@ func TestDefaultConfig(t *testing.T) {
 	}
 }
 
+// --- IsMutableRev tests ---
+
+func TestIsMutableRev(t *testing.T) {
+	tests := []struct {
+		rev  string
+		want bool
+	}{
+		{"0123456789abcdef0123456789abcdef01234567", false}, // full SHA
+		{"v1.2.3", false}, // tag
+		{"v4", false},     // tag
+		{"23.1.0", false}, // dotted tag
+		{"release-1.0", false},
+		{"stable", false},  // undotted tag
+		{"abc1234", false}, // abbreviated SHA
+		{"main", true},
+		{"master", true},
+		{"HEAD", true},
+		{"refs/heads/feature", true},
+	}
+	for _, tt := range tests {
+		if got := IsMutableRev(tt.rev); got != tt.want {
+			t.Errorf("IsMutableRev(%q) = %v, want %v", tt.rev, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesEverything(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{".*", true},
+		{".+", true},
+		{"^.*$", true},
+		{"(?s).*", true},
+		{"(.*)", true},
+		{"^vendor/", false},
+		{`\.txt$`, false},
+		{"^(?!src/).*", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := MatchesEverything(tt.pattern); got != tt.want {
+			t.Errorf("MatchesEverything(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
+
 // --- WarnMutableRev tests ---
 
+func TestLoadConfig_NoMutableRevWarning(t *testing.T) {
+	// Only validate-config warns; loading for a run stays quiet.
+	path := filepath.Join(t.TempDir(), ".pre-commit-config.yaml")
+	cfg := "repos:\n-   repo: https://github.com/example/repo\n    rev: main\n    hooks:\n    -   id: lint\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	oldStderr := os.Stderr
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = w
+	_, loadErr := LoadConfig(path)
+	w.Close()
+	os.Stderr = oldStderr
+	data, _ := io.ReadAll(r)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	if len(data) != 0 {
+		t.Errorf("LoadConfig wrote to stderr: %q", data)
+	}
+}
+
 func TestWarnMutableRev(t *testing.T) {
 	// WarnMutableRev writes to stderr for mutable refs.
 	// We capture stderr to verify the warning is emitted.
@@ -847,3 +1024,48 @@ func TestValidate_MissingHookID(t *testing.T) {
 		t.Errorf("unexpected error: %v", err)
 	}
 }
+
+// --- Validate: pre_run/post_run ---
+
+func TestValidate_BlankPreRun(t *testing.T) {
+	cfg := &Config{
+		Repos: []RepoConfig{{
+			Repo:  "local",
+			Hooks: []HookConfig{{ID: "x", Name: "x", Entry: "true", Language: "system"}},
+		}},
+		PreRun: "   ",
+	}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "'pre_run' must not be blank") {
+		t.Fatalf("expected blank pre_run error, got %v", err)
+	}
+
+	cfg.PreRun = "make generate"
+	cfg.PostRun = "make clean"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadConfig_NoAutoupdateMarker(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".pre-commit-config.yaml")
+	content := "repos:\n" +
+		"# pre-commit: no-autoupdate\n" +
+		"-   repo: https://example.com/head\n    rev: v1\n    hooks:\n    -   id: a\n" +
+		"-   repo: https://example.com/line  # pre-commit: no-autoupdate\n    rev: v1\n    hooks:\n    -   id: a\n" +
+		"-   repo: https://example.com/hook\n    rev: v1\n    hooks:\n    -   id: a  # pre-commit: no-autoupdate\n" +
+		"-   repo: https://example.com/other\n    rev: v1  # pre-commit: autoupdate\n    hooks:\n    -   id: a\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{true, true, false, false}
+	for i, repo := range cfg.Repos {
+		if repo.NoAutoupdate != want[i] {
+			t.Errorf("%s: NoAutoupdate = %v, want %v", repo.Repo, repo.NoAutoupdate, want[i])
+		}
+	}
+}
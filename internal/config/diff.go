@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// HookRef identifies a hook entry in a config by its repo and id.
+type HookRef struct {
+	Repo string
+	ID   string
+}
+
+// ParseConfig parses config YAML without validating it or applying
+// defaults, e.g. to inspect a config as it was at an older commit.
+func ParseConfig(data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// ChangedHooks returns the hook entries in cur that are new or differ from
+// base: added hooks, hooks whose config changed, and every hook of a repo
+// whose rev changed. A nil base means every hook in cur is new.
+func ChangedHooks(base, cur *Config) map[HookRef]bool {
+	type entry struct {
+		rev  string
+		hook HookConfig
+	}
+	old := make(map[HookRef]entry)
+	if base != nil {
+		for _, repo := range base.Repos {
+			for _, h := range repo.Hooks {
+				ref := HookRef{Repo: repo.Repo, ID: h.ID}
+				if _, ok := old[ref]; !ok {
+					old[ref] = entry{rev: repo.Rev, hook: h}
+				}
+			}
+		}
+	}
+
+	changed := make(map[HookRef]bool)
+	for _, repo := range cur.Repos {
+		for _, h := range repo.Hooks {
+			ref := HookRef{Repo: repo.Repo, ID: h.ID}
+			e, ok := old[ref]
+			if !ok || e.rev != repo.Rev || !reflect.DeepEqual(e.hook, h) {
+				changed[ref] = true
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,62 @@
+package config
+
+import "testing"
+
+func TestChangedHooks(t *testing.T) {
+	base, err := ParseConfig([]byte(`repos:
+-   repo: https://github.com/example/hooks
+    rev: v1.0.0
+    hooks:
+    -   id: same
+    -   id: edited
+        args: [--old]
+-   repo: https://github.com/example/bumped
+    rev: v1.0.0
+    hooks:
+    -   id: bumped
+-   repo: local
+    hooks:
+    -   id: removed
+        name: removed
+        entry: 'true'
+        language: system
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := ParseConfig([]byte(`repos:
+-   repo: https://github.com/example/hooks
+    rev: v1.0.0
+    hooks:
+    -   id: same
+    -   id: edited
+        args: [--new]
+    -   id: added
+-   repo: https://github.com/example/bumped
+    rev: v2.0.0
+    hooks:
+    -   id: bumped
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := ChangedHooks(base, cur)
+	want := []HookRef{
+		{Repo: "https://github.com/example/hooks", ID: "edited"},
+		{Repo: "https://github.com/example/hooks", ID: "added"},
+		{Repo: "https://github.com/example/bumped", ID: "bumped"},
+	}
+	if len(changed) != len(want) {
+		t.Errorf("ChangedHooks() = %v, want %v", changed, want)
+	}
+	for _, ref := range want {
+		if !changed[ref] {
+			t.Errorf("expected %v to be changed", ref)
+		}
+	}
+
+	if all := ChangedHooks(nil, cur); len(all) != 4 {
+		t.Errorf("ChangedHooks(nil) = %v, want every hook", all)
+	}
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FixManifest rewrites the manifest at path, correcting trivially-fixable
+// issues: missing stages are filled with all stages, legacy stage names are
+// migrated, and pass_filenames/always_run defaults are written explicitly.
+// When sortHooks is set, hooks are ordered by id. Comments are preserved.
+// It reports whether the file changed; problems that cannot be fixed
+// automatically are returned as errors and the file is left untouched.
+func FixManifest(path string, sortHooks bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, fmt.Errorf("failed to read manifest file %s: %w", path, err)
+	}
+
+	fixed, err := fixManifestData(data, sortHooks)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse manifest file %s: %w", path, err)
+	}
+	if _, err := parseManifest(fixed, path); err != nil {
+		return false, err
+	}
+	if bytes.Equal(fixed, data) {
+		return false, nil
+	}
+	if err := os.WriteFile(path, fixed, 0o644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func fixManifestData(data []byte, sortHooks bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("manifest must be a list of hooks")
+	}
+
+	seq := doc.Content[0]
+	changed := false
+	for _, hook := range seq.Content {
+		if hook.Kind != yaml.MappingNode {
+			continue
+		}
+		if fixManifestHook(hook) {
+			changed = true
+		}
+	}
+
+	if sortHooks && len(seq.Content) > 0 {
+		// Keep a leading file comment at the top rather than moving it with
+		// the hook it happens to be attached to.
+		header := seq.Content[0].HeadComment
+		seq.Content[0].HeadComment = ""
+		sorted := sort.SliceIsSorted(seq.Content, func(i, j int) bool {
+			return mappingValue(seq.Content[i], "id") < mappingValue(seq.Content[j], "id")
+		})
+		if !sorted {
+			sort.SliceStable(seq.Content, func(i, j int) bool {
+				return mappingValue(seq.Content[i], "id") < mappingValue(seq.Content[j], "id")
+			})
+			changed = true
+		}
+		seq.Content[0].HeadComment = header
+	}
+
+	if !changed {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return reindentItems(buf.Bytes(), sequenceIndent(data)), nil
+}
+
+// reindentItems widens the "- " list item prefix yaml.v3 always emits for a
+// top-level sequence to width columns, shifting item bodies to match.
+func reindentItems(data []byte, width int) []byte {
+	if width <= 2 {
+		return data
+	}
+	pad := strings.Repeat(" ", width-2)
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "- "):
+			lines[i] = "-" + pad + line[1:]
+		case strings.HasPrefix(line, "  "):
+			lines[i] = pad + line
+		}
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// fixManifestHook applies the fixes to a single hook mapping and reports
+// whether anything changed.
+func fixManifestHook(hook *yaml.Node) bool {
+	changed := false
+
+	if stages := mappingNode(hook, "stages"); stages != nil && stages.Kind == yaml.SequenceNode {
+		legacy := make([]Stage, len(stages.Content))
+		for i, n := range stages.Content {
+			legacy[i] = Stage(n.Value)
+		}
+		for i, s := range migrateLegacyStages(legacy) {
+			if stages.Content[i].Value != string(s) {
+				stages.Content[i].Value = string(s)
+				changed = true
+			}
+		}
+	} else if stages == nil {
+		all := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+		for _, s := range AllStages() {
+			all.Content = append(all.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(s)})
+		}
+		appendMapping(hook, "stages", all)
+		changed = true
+	}
+
+	for _, d := range []struct{ key, value string }{
+		{"pass_filenames", "true"},
+		{"always_run", "false"},
+	} {
+		if mappingNode(hook, d.key) == nil {
+			appendMapping(hook, d.key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: d.value})
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// mappingNode returns the value node for key in a mapping, or nil.
+func mappingNode(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func mappingValue(m *yaml.Node, key string) string {
+	if n := mappingNode(m, key); n != nil {
+		return n.Value
+	}
+	return ""
+}
+
+func appendMapping(m *yaml.Node, key string, value *yaml.Node) {
+	m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// sequenceIndent guesses the indentation used for list items in data, so
+// both "- id:" and "-   id:" styles survive a rewrite.
+func sequenceIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "-") {
+			continue
+		}
+		rest := strings.TrimPrefix(line, "-")
+		n := len(rest) - len(strings.TrimLeft(rest, " "))
+		if n > 0 && strings.TrimSpace(rest) != "" {
+			return n + 1
+		}
+	}
+	return 2
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixManifest_Golden(t *testing.T) {
+	input := `# Hooks provided by this repo.
+-   id: zeta
+    name: Zeta
+    entry: zeta
+    language: system
+    stages: [commit, push]  # legacy names
+-   id: alpha
+    name: Alpha
+    entry: alpha
+    language: python
+    pass_filenames: false
+`
+	want := `# Hooks provided by this repo.
+-   id: alpha
+    name: Alpha
+    entry: alpha
+    language: python
+    pass_filenames: false
+    stages: [pre-commit, pre-merge-commit, pre-push, pre-rebase, commit-msg, prepare-commit-msg, post-checkout, post-commit, post-merge, post-rewrite, manual]
+    always_run: false
+-   id: zeta
+    name: Zeta
+    entry: zeta
+    language: system
+    stages: [pre-commit, pre-push] # legacy names
+    pass_filenames: true
+    always_run: false
+`
+	path := filepath.Join(t.TempDir(), "hooks.yaml")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := FixManifest(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("expected manifest to be changed")
+	}
+	got, _ := os.ReadFile(path)
+	if string(got) != want {
+		t.Errorf("fixed manifest mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Fixing again is a no-op.
+	changed, err = FixManifest(path, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("expected second fix to leave the manifest unchanged")
+	}
+}
+
+func TestFixManifest_UnfixableError(t *testing.T) {
+	input := "- id: test\n  name: Test\n  language: system\n"
+	path := filepath.Join(t.TempDir(), "hooks.yaml")
+	if err := os.WriteFile(path, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := FixManifest(path, false)
+	if err == nil || !strings.Contains(err.Error(), "missing required 'entry' field") {
+		t.Fatalf("expected missing entry error, got %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	if string(got) != input {
+		t.Errorf("expected manifest to be left untouched, got:\n%s", got)
+	}
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsListConfig reports whether data is a config in the old format, where
+// the document is the list of repos itself instead of a map holding it
+// under 'repos'.
+func IsListConfig(data []byte) bool {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	return doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 && doc.Content[0].Kind == yaml.SequenceNode
+}
+
+// MigrateListConfig turns an old-style config, a bare list of repos, into
+// a map with the list under repos:, keeping any leading comments above
+// the key. The list is only indented if it doesn't parse as-is under the
+// key, as with a flow-style list.
+func MigrateListConfig(raw string) string {
+	lines := strings.SplitAfter(raw, "\n")
+	i := 0
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "---") && strings.TrimSpace(line) != "" {
+			break
+		}
+	}
+	header, rest := strings.Join(lines[:i], ""), lines[i:]
+
+	trial := header + "repos:\n" + strings.Join(rest, "")
+	var probe struct {
+		Repos []yaml.Node `yaml:"repos"`
+	}
+	if yaml.Unmarshal([]byte(trial), &probe) == nil {
+		return trial
+	}
+	for j, line := range rest {
+		if strings.TrimSpace(line) != "" {
+			rest[j] = "    " + line
+		}
+	}
+	return header + "repos:\n" + strings.Join(rest, "")
+}
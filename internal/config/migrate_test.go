@@ -0,0 +1,37 @@
+package config
+
+import "testing"
+
+func TestMigrateListConfig(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			"block list",
+			"---\n# header\n\n-   repo: local\n    hooks: []\n",
+			"---\n# header\n\nrepos:\n-   repo: local\n    hooks: []\n",
+		},
+		{
+			"flow list",
+			"[{repo: local, hooks: []}]\n",
+			"repos:\n    [{repo: local, hooks: []}]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !IsListConfig([]byte(tt.in)) {
+				t.Fatalf("IsListConfig(%q) = false", tt.in)
+			}
+			got := MigrateListConfig(tt.in)
+			if got != tt.want {
+				t.Errorf("MigrateListConfig = %q, want %q", got, tt.want)
+			}
+			if IsListConfig([]byte(got)) {
+				t.Errorf("migrated config is still a list: %q", got)
+			}
+		})
+	}
+	if IsListConfig([]byte("repos: []\n")) {
+		t.Error("IsListConfig reported a map config as a list")
+	}
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NoAutoupdateMarker is the comment that keeps autoupdate from touching a
+// repo entry, written on or just above any of the entry's own lines.
+const NoAutoupdateMarker = "pre-commit: no-autoupdate"
+
+// repoNodes returns the node of each entry of repos in a parsed config
+// document.
+func repoNodes(doc *yaml.Node) []*yaml.Node {
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	repos := mappingNode(doc.Content[0], "repos")
+	if repos == nil || repos.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return repos.Content
+}
+
+// hookNodes returns the mapping node of each hook in a parsed config
+// document, indexed by repo and hook. Entries that are not mappings are nil.
+func hookNodes(doc *yaml.Node) [][]*yaml.Node {
+	repos := repoNodes(doc)
+	if repos == nil {
+		return nil
+	}
+	nodes := make([][]*yaml.Node, len(repos))
+	for i, repo := range repos {
+		if repo.Kind != yaml.MappingNode {
+			continue
+		}
+		hooks := mappingNode(repo, "hooks")
+		if hooks == nil || hooks.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, h := range hooks.Content {
+			if h.Kind != yaml.MappingNode {
+				h = nil
+			}
+			nodes[i] = append(nodes[i], h)
+		}
+	}
+	return nodes
+}
+
+// markNoAutoupdate sets NoAutoupdate on each repo whose entry carries
+// NoAutoupdateMarker. Only comments on the entry itself and its keys and
+// scalar values count, not those inside its hooks.
+func (c *Config) markNoAutoupdate(doc *yaml.Node) {
+	for i, repo := range repoNodes(doc) {
+		if i >= len(c.Repos) || repo.Kind != yaml.MappingNode {
+			continue
+		}
+		marked := hasMarker(repo)
+		for _, n := range repo.Content {
+			if n.Kind == yaml.ScalarNode && hasMarker(n) {
+				marked = true
+			}
+		}
+		c.Repos[i].NoAutoupdate = marked
+	}
+}
+
+// hasMarker reports whether one of n's comment lines is NoAutoupdateMarker.
+func hasMarker(n *yaml.Node) bool {
+	for _, comment := range []string{n.HeadComment, n.LineComment, n.FootComment} {
+		for line := range strings.SplitSeq(comment, "\n") {
+			if strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#")) == NoAutoupdateMarker {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HookLocation returns " (line L, column C)" for the given field of
+// repos[repo].hooks[hook], or for the hook itself when field is empty or
+// absent, for appending to error messages. It is empty for configs not
+// loaded from a file.
+func (c *Config) HookLocation(repo, hook int, field string) string {
+	if repo >= len(c.hookNodes) || hook >= len(c.hookNodes[repo]) {
+		return ""
+	}
+	node := c.hookNodes[repo][hook]
+	if node == nil {
+		return ""
+	}
+	if field != "" {
+		if v := mappingNode(node, field); v != nil {
+			node = v
+		}
+	}
+	return fmt.Sprintf(" (line %d, column %d)", node.Line, node.Column)
+}
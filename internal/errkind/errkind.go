@@ -0,0 +1,58 @@
+// Package errkind classifies errors into broad categories. The CLI prefixes
+// error messages with their category so that tools wrapping pre-commit can
+// tell failures apart without parsing the rest of the message.
+package errkind
+
+import "errors"
+
+// Kind is an error category. Its value is the prefix printed before the
+// error message.
+type Kind string
+
+const (
+	// Config covers invalid or unreadable configs and manifests, and hooks
+	// or revisions they reference that do not exist.
+	Config Kind = "config-error"
+	// Network covers failures to fetch hook repositories.
+	Network Kind = "network-error"
+	// Env covers failures to build or find hook environments.
+	Env Kind = "env-error"
+	// Hook covers hooks that could not be executed.
+	Hook Kind = "hook-error"
+)
+
+// Error is an error tagged with a Kind.
+type Error struct {
+	Kind Kind
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Wrap tags err with kind. It returns nil if err is nil.
+func Wrap(kind Kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// Of returns the Kind of the outermost Error in err's chain, or "" if err
+// has not been classified.
+func Of(err error) Kind {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Kind
+	}
+	return ""
+}
+
+// Prefix returns msg prefixed with kind, e.g. "config-error: msg", or msg
+// unchanged if kind is empty.
+func Prefix(kind Kind, msg string) string {
+	if kind == "" {
+		return msg
+	}
+	return string(kind) + ": " + msg
+}
@@ -0,0 +1,40 @@
+package errkind
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWrapNil(t *testing.T) {
+	if err := Wrap(Config, nil); err != nil {
+		t.Errorf("Wrap(nil) = %v, want nil", err)
+	}
+}
+
+func TestOf(t *testing.T) {
+	base := errors.New("boom")
+	wrapped := fmt.Errorf("resolving repo: %w", Wrap(Network, base))
+
+	if got := Of(wrapped); got != Network {
+		t.Errorf("Of() = %q, want %q", got, Network)
+	}
+	if !errors.Is(wrapped, base) {
+		t.Error("Wrap must preserve the underlying error")
+	}
+	if wrapped.Error() != "resolving repo: boom" {
+		t.Errorf("Error() = %q, the kind must not change the message", wrapped.Error())
+	}
+	if got := Of(base); got != "" {
+		t.Errorf("Of(unclassified) = %q, want empty", got)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	if got := Prefix(Env, "no env"); got != "env-error: no env" {
+		t.Errorf("Prefix(Env) = %q", got)
+	}
+	if got := Prefix("", "plain"); got != "plain" {
+		t.Errorf("Prefix(\"\") = %q", got)
+	}
+}
@@ -172,6 +172,53 @@ func GetAllFiles() ([]string, error) {
 	return result, nil
 }
 
+// BinaryAttributes reports, for each path whose .gitattributes settle the
+// question, whether git treats it as binary. The binary macro and an unset
+// text attribute mean binary; a set text attribute means text; otherwise an
+// explicit diff attribute decides. Paths without such hints are omitted.
+func BinaryAttributes(paths []string) (map[string]bool, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	cmd := exec.Command("git", "check-attr", "-z", "--stdin", "binary", "text", "diff")
+	cmd.Env = NoGitEnv()
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git check-attr failed: %w\nstderr: %s", err, stderr.String())
+	}
+
+	// Output is <path> NUL <attribute> NUL <value> NUL for each pair.
+	attrs := make(map[string]map[string]string)
+	fields := strings.Split(stdout.String(), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		path, attr, value := fields[i], fields[i+1], fields[i+2]
+		if attrs[path] == nil {
+			attrs[path] = make(map[string]string)
+		}
+		attrs[path][attr] = value
+	}
+
+	result := make(map[string]bool)
+	for path, a := range attrs {
+		switch {
+		case a["binary"] == "set":
+			result[path] = true
+		case a["text"] == "set":
+			result[path] = false
+		case a["text"] == "unset":
+			result[path] = true
+		case a["diff"] == "unset":
+			result[path] = true
+		case a["diff"] == "set":
+			result[path] = false
+		}
+	}
+	return result, nil
+}
+
 // GetChangedFiles returns files changed between two refs.
 func GetChangedFiles(fromRef, toRef string) ([]string, error) {
 	out, err := CmdOutput("diff", "--name-only", "--diff-filter=ACMRT", "--no-ext-diff", "-z", fromRef+"..."+toRef)
@@ -191,6 +238,11 @@ func GetChangedFiles(fromRef, toRef string) ([]string, error) {
 	return result, nil
 }
 
+// ShowFile returns the contents of the repo-relative path at ref.
+func ShowFile(ref, path string) (string, error) {
+	return CmdOutput("show", ref+":"+path)
+}
+
 // GetDefaultBranch returns the default branch name.
 func GetDefaultBranch(remote string) (string, error) {
 	out, err := CmdOutput("symbolic-ref", fmt.Sprintf("refs/remotes/%s/HEAD", remote))
@@ -202,6 +254,25 @@ func GetDefaultBranch(remote string) (string, error) {
 	return strings.TrimPrefix(out, prefix), nil
 }
 
+// CurrentBranch returns the short name of the branch checked out in dir, or
+// "" when HEAD is detached.
+func CurrentBranch(dir string) (string, error) {
+	cmd := exec.Command("git", "symbolic-ref", "--short", "-q", "HEAD")
+	cmd.Dir = dir
+	cmd.Env = NoGitEnv()
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// symbolic-ref -q exits 1 without output when HEAD is not a symref.
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 && stderr.Len() == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("git symbolic-ref HEAD (in %s) failed: %w\nstderr: %s", dir, err, stderr.String())
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
 // Clone clones a repository.
 func Clone(url, dest string, args ...string) error {
 	cmdArgs := []string{"clone"}
@@ -271,6 +342,19 @@ func GetTagSHA(dir, tag string) (string, error) {
 	return CmdOutputInDir(dir, "rev-parse", tag)
 }
 
+// GetRemoteBranchSHA returns the commit SHA that origin/<branch> points to
+// in a cloned repository. It fails if branch is not a remote branch (e.g. a
+// tag or SHA).
+func GetRemoteBranchSHA(dir, branch string) (string, error) {
+	return CmdOutputInDir(dir, "rev-parse", "--verify", "--quiet", "refs/remotes/origin/"+branch+"^{commit}")
+}
+
+// ResolveCommit returns the commit SHA that ref points to in the current
+// repository. It fails if ref does not name a commit.
+func ResolveCommit(ref string) (string, error) {
+	return CmdOutput("rev-parse", "--verify", "--quiet", ref+"^{commit}")
+}
+
 // Diff runs git diff and returns the output.
 func Diff(args ...string) (string, error) {
 	cmdArgs := append([]string{"diff"}, args...)
@@ -311,6 +395,26 @@ func StashPop(dir string) error {
 	return RunInDir(dir, "stash", "pop")
 }
 
+// StashCreate records the staged and unstaged changes to tracked files in dir
+// as a commit without touching the working tree or the stash list, and
+// returns its SHA. It returns "" when there are no such changes.
+func StashCreate(dir string) (string, error) {
+	return CmdOutputInDir(dir, "stash", "create")
+}
+
+// WorktreeAdd checks out ref into a new detached worktree of dir at dest.
+func WorktreeAdd(dir, dest, ref string) error {
+	_, err := CmdOutputInDir(dir, "worktree", "add", "--detach", dest, ref)
+	return err
+}
+
+// WorktreeRemove deletes the worktree of dir at dest, including any
+// untracked files in it.
+func WorktreeRemove(dir, dest string) error {
+	_, err := CmdOutputInDir(dir, "worktree", "remove", "--force", dest)
+	return err
+}
+
 // CheckoutIndex checks out the index to a temp directory.
 func CheckoutIndex(dir, dest string) error {
 	cmd := exec.Command("git", "checkout-index", "-a", "--prefix="+dest+"/")
@@ -385,6 +489,28 @@ func IntentToAddFiles() ([]string, error) {
 	return result, nil
 }
 
+// TrackedFiles returns the set of files git tracks. Intent-to-add entries
+// (git add -N) are listed by ls-files but have no content in the index yet,
+// so they are not considered tracked.
+func TrackedFiles() (map[string]bool, error) {
+	all, err := GetAllFiles()
+	if err != nil {
+		return nil, err
+	}
+	intentToAdd, err := IntentToAddFiles()
+	if err != nil {
+		return nil, err
+	}
+	tracked := make(map[string]bool, len(all))
+	for _, f := range all {
+		tracked[f] = true
+	}
+	for _, f := range intentToAdd {
+		delete(tracked, f)
+	}
+	return tracked, nil
+}
+
 // GetConflictedFiles returns files with merge conflicts.
 func GetConflictedFiles() ([]string, error) {
 	out, err := CmdOutput("diff", "--name-only", "--diff-filter=U", "-z")
@@ -333,6 +333,24 @@ func TestGetAllFiles(t *testing.T) {
 	}
 }
 
+func TestTrackedFiles(t *testing.T) {
+	dir := initTestRepo(t)
+	os.WriteFile(filepath.Join(dir, "staged.txt"), []byte("x\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "intent.txt"), []byte("x\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "untracked.txt"), []byte("x\n"), 0o644)
+	exec.Command("git", "-C", dir, "add", "staged.txt").Run()
+	exec.Command("git", "-C", dir, "add", "-N", "intent.txt").Run()
+	t.Chdir(dir)
+
+	tracked, err := TrackedFiles()
+	if err != nil {
+		t.Fatalf("TrackedFiles failed: %v", err)
+	}
+	if len(tracked) != 2 || !tracked["README.md"] || !tracked["staged.txt"] {
+		t.Errorf("expected README.md and staged.txt, got %v", tracked)
+	}
+}
+
 // --- GetStagedFiles tests ---
 
 func TestGetStagedFiles_None(t *testing.T) {
@@ -532,6 +550,40 @@ func TestGetTagSHA(t *testing.T) {
 	}
 }
 
+// --- GetRemoteBranchSHA tests ---
+
+func TestGetRemoteBranchSHA(t *testing.T) {
+	src := initTestRepo(t)
+	headSHA, _ := GetHeadSHA(src)
+	branch, err := CmdOutputInDir(src, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("git", "tag", "v1.0.0")
+	cmd.Dir = src
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(t.TempDir(), "clone")
+	if err := Clone(src, dest); err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+
+	sha, err := GetRemoteBranchSHA(dest, branch)
+	if err != nil {
+		t.Fatalf("GetRemoteBranchSHA failed: %v", err)
+	}
+	if sha != headSHA {
+		t.Errorf("expected %q, got %q", headSHA, sha)
+	}
+
+	if _, err := GetRemoteBranchSHA(dest, "v1.0.0"); err == nil {
+		t.Error("expected error for a tag, got nil")
+	}
+}
+
 // --- WriteTree tests ---
 
 func TestWriteTree(t *testing.T) {
@@ -637,3 +689,34 @@ func TestCheckoutIndex(t *testing.T) {
 		t.Errorf("unexpected content: %q", string(content))
 	}
 }
+
+// --- BinaryAttributes tests ---
+
+func TestBinaryAttributes(t *testing.T) {
+	dir := initTestRepo(t)
+	attrs := "generated text\n*.dat binary\nblob -text\nnodiff -diff\nplain.txt diff\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte(attrs), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	got, err := BinaryAttributes([]string{"generated", "x.dat", "blob", "nodiff", "plain.txt", "README.md"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]bool{
+		"generated": false,
+		"x.dat":     true,
+		"blob":      true,
+		"nodiff":    true,
+		"plain.txt": false,
+	}
+	if len(got) != len(want) {
+		t.Errorf("BinaryAttributes() = %v, want %v", got, want)
+	}
+	for path, binary := range want {
+		if b, ok := got[path]; !ok || b != binary {
+			t.Errorf("BinaryAttributes()[%q] = %v, %v; want %v", path, b, ok, binary)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package hook
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blairham/go-pre-commit/v4/internal/pcre"
+)
+
+// DefaultAnnotationRegex matches the "file:line[:col]: message" lines most
+// linters print. A hook's annotation_regex overrides it and must use the same
+// named groups: file, line and message are required, col is optional.
+const DefaultAnnotationRegex = `^(?<file>[^:\s][^:]*):(?<line>\d+):(?:(?<col>\d+):)?\s*(?<message>.+)$`
+
+// Annotation is a single file/line finding parsed from a failing hook's
+// output, normalized so CI systems can turn it into an inline comment.
+type Annotation struct {
+	Hook    string `json:"hook"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column,omitempty"`
+	Message string `json:"message"`
+}
+
+// ansiEscapeRe matches terminal color sequences that linters may emit.
+var ansiEscapeRe = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+
+// ParseAnnotations extracts annotations from a hook's output using pattern,
+// or DefaultAnnotationRegex when pattern is empty. Lines that do not match
+// are ignored.
+func ParseAnnotations(hookID, pattern string, out []byte) ([]Annotation, error) {
+	if pattern == "" {
+		pattern = DefaultAnnotationRegex
+	}
+	re, err := pcre.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid annotation_regex for hook %q: %w", hookID, err)
+	}
+
+	var annotations []Annotation
+	for _, line := range strings.Split(ansiEscapeRe.ReplaceAllString(string(out), ""), "\n") {
+		line = strings.TrimRight(line, "\r")
+		m, err := re.FindStringMatch(line)
+		if err != nil || m == nil {
+			continue
+		}
+		group := func(name string) string {
+			if g := m.GroupByName(name); g != nil {
+				return strings.TrimSpace(g.String())
+			}
+			return ""
+		}
+		file := group("file")
+		lineNo, err := strconv.Atoi(group("line"))
+		if file == "" || err != nil {
+			continue
+		}
+		col, _ := strconv.Atoi(group("col"))
+		annotations = append(annotations, Annotation{
+			Hook:    hookID,
+			File:    filepath.ToSlash(filepath.Clean(file)),
+			Line:    lineNo,
+			Column:  col,
+			Message: group("message"),
+		})
+	}
+	return annotations, nil
+}
+
+// WriteAnnotationsFile writes annotations to path as a JSON array. An empty
+// run still writes "[]" so consumers can rely on the file existing.
+func WriteAnnotationsFile(path string, annotations []Annotation) error {
+	if annotations == nil {
+		annotations = []Annotation{}
+	}
+	data, err := json.MarshalIndent(annotations, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
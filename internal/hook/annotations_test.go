@@ -0,0 +1,77 @@
+package hook
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseAnnotations_Default(t *testing.T) {
+	out := []byte("src/app.py:12:5: E501 line too long (99 > 79 characters)\n" +
+		"./src/util.py:3: error: Name \"x\" is not defined  [name-defined]\r\n" +
+		"\x1b[1mlib/a.go\x1b[0m:7:2: unused variable\n" +
+		"Found 3 errors in 3 files\n" +
+		"\n")
+
+	got, err := ParseAnnotations("lint", "", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Annotation{
+		{Hook: "lint", File: "src/app.py", Line: 12, Column: 5, Message: "E501 line too long (99 > 79 characters)"},
+		{Hook: "lint", File: "src/util.py", Line: 3, Message: `error: Name "x" is not defined  [name-defined]`},
+		{Hook: "lint", File: "lib/a.go", Line: 7, Column: 2, Message: "unused variable"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAnnotations() =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestParseAnnotations_CustomRegex(t *testing.T) {
+	out := []byte("WARN [src/main.rs] line 4: prefer let over var\nok\n")
+	pattern := `^WARN \[(?<file>[^\]]+)\] line (?<line>\d+): (?<message>.+)$`
+
+	got, err := ParseAnnotations("custom", pattern, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Annotation{{Hook: "custom", File: "src/main.rs", Line: 4, Message: "prefer let over var"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseAnnotations() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseAnnotations_InvalidRegex(t *testing.T) {
+	if _, err := ParseAnnotations("bad", "(unclosed", []byte("a:1: b\n")); err == nil {
+		t.Error("ParseAnnotations with invalid regex = nil error, want error")
+	}
+}
+
+func TestWriteAnnotationsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "annotations.json")
+	if err := WriteAnnotationsFile(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[]\n" {
+		t.Errorf("empty annotations file = %q, want %q", data, "[]\n")
+	}
+
+	want := []Annotation{{Hook: "lint", File: "a.py", Line: 1, Column: 2, Message: "bad"}}
+	if err := WriteAnnotationsFile(path, want); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(path)
+	var got []Annotation
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round-tripped annotations = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package hook
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/blairham/go-pre-commit/v4/internal/output"
+)
+
+// hookLog is a hook's buffered terminal output for --dedup-output. The
+// output of a failed hook is held apart from the rest so that identical
+// blocks from several hooks can be printed once.
+type hookLog struct {
+	bytes.Buffer
+	failure *heldFailure
+}
+
+type heldFailure struct {
+	at       int // offset in the buffer the block belongs at
+	hookID   string
+	exitCode int
+	output   []byte
+	verbose  bool
+}
+
+func (l *hookLog) holdFailure(hookID string, exitCode int, out []byte, verbose bool) {
+	l.failure = &heldFailure{at: l.Len(), hookID: hookID, exitCode: exitCode, output: out, verbose: verbose}
+}
+
+// outputHash returns the hash identical failure outputs share. Color codes,
+// trailing whitespace and surrounding blank lines are ignored.
+func outputHash(out []byte) [sha256.Size]byte {
+	lines := strings.Split(ansiEscapeRe.ReplaceAllString(string(out), ""), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return sha256.Sum256([]byte(strings.Trim(strings.Join(lines, "\n"), "\n")))
+}
+
+// writeDeduped writes logs to w in order. The first hook with a given
+// failure output shows it, noting which later hooks failed with the same
+// output; those hooks only refer back to it.
+func writeDeduped(w io.Writer, logs []*hookLog) {
+	groups := make(map[[sha256.Size]byte][]string)
+	for _, l := range logs {
+		if f := l.failure; f != nil {
+			sum := outputHash(f.output)
+			groups[sum] = append(groups[sum], f.hookID)
+		}
+	}
+
+	for _, l := range logs {
+		f := l.failure
+		if f == nil {
+			w.Write(l.Bytes())
+			continue
+		}
+		w.Write(l.Bytes()[:f.at])
+		group := groups[outputHash(f.output)]
+		if group[0] == f.hookID {
+			output.FprintHookOutput(w, f.output, f.hookID, f.exitCode, f.verbose)
+			if len(group) > 1 {
+				fmt.Fprintf(w, "(also failed: %s)\n", strings.Join(group[1:], ", "))
+			}
+		} else {
+			output.FprintHookOutput(w, nil, f.hookID, f.exitCode, true)
+			fmt.Fprintf(w, "(same output as %s, shown above)\n", group[0])
+		}
+		w.Write(l.Bytes()[f.at:])
+	}
+}
@@ -3,9 +3,11 @@ package hook
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
 	"github.com/blairham/go-pre-commit/v4/internal/pcre"
 )
 
@@ -33,6 +35,12 @@ type Hook struct {
 	Description             string
 	MinimumPreCommitVersion string
 	LogFile                 string
+	AnnotationRegex         string
+	TrackedOnly             bool
+	WorkingDirectory        string   // relative to the repo root
+	Branches                []string // glob patterns; empty runs on any branch
+	CondaEnvFile            string   // relative to the repo; "" means environment.yml
+	Concurrency             int      // max simultaneous invocations; 0 is unlimited
 
 	// Repo information.
 	Repo    string
@@ -41,9 +49,26 @@ type Hook struct {
 }
 
 // InstallKey returns a unique key for deduplication of hook environments.
+// It is also written to the install state file, so additional_dependencies
+// are sorted to make reordering them in the config not force a rebuild.
+// For languages that build from files in the repo, their fingerprint
+// follows on a line of its own.
 func (h *Hook) InstallKey() string {
-	deps := strings.Join(h.AdditionalDependencies, ",")
-	return fmt.Sprintf("%s:%s:%s:%s", h.RepoDir, h.Language, h.LanguageVersion, deps)
+	sorted := slices.Clone(h.AdditionalDependencies)
+	if h.Language == "node" {
+		for i, dep := range sorted {
+			sorted[i] = languages.NodeDependencyKey(dep)
+		}
+	}
+	slices.Sort(sorted)
+	deps := strings.Join(sorted, ",")
+	key := fmt.Sprintf("%s:%s:%s:%s", h.RepoDir, h.Language, h.LanguageVersion, deps)
+	if lang, err := languages.Get(h.Language); err == nil {
+		if fp := languages.EnvironmentFingerprint(lang, h.RepoDir, h.CondaEnvFile); fp != "" {
+			key += "\n" + fp
+		}
+	}
+	return key
 }
 
 // MatchesFiles returns true if the given filename matches this hook's file filters.
@@ -103,6 +128,7 @@ func MergeManifest(manifest *config.ManifestHook, hookCfg *config.HookConfig, re
 		RequireSerial:           manifest.RequireSerial,
 		Description:             manifest.Description,
 		MinimumPreCommitVersion: manifest.MinimumPreCommitVersion,
+		CondaEnvFile:            manifest.CondaEnvFile,
 		Repo:                    repoCfg.Repo,
 		Rev:                     repoCfg.Rev,
 	}
@@ -145,6 +171,9 @@ func MergeManifest(manifest *config.ManifestHook, hookCfg *config.HookConfig, re
 	}
 	if len(hookCfg.AdditionalDependencies) > 0 {
 		h.AdditionalDependencies = hookCfg.AdditionalDependencies
+		if h.Language == "node" && globalCfg != nil {
+			h.AdditionalDependencies = languages.ResolveNodeDependencies(h.AdditionalDependencies, globalCfg.Dir)
+		}
 	}
 	if hookCfg.AlwaysRun != nil {
 		h.AlwaysRun = *hookCfg.AlwaysRun
@@ -164,10 +193,30 @@ func MergeManifest(manifest *config.ManifestHook, hookCfg *config.HookConfig, re
 	if hookCfg.LogFile != "" {
 		h.LogFile = hookCfg.LogFile
 	}
+	if hookCfg.AnnotationRegex != "" {
+		h.AnnotationRegex = hookCfg.AnnotationRegex
+	}
+	if hookCfg.TrackedOnly != nil {
+		h.TrackedOnly = *hookCfg.TrackedOnly
+	}
+	if hookCfg.Concurrency > 0 {
+		h.Concurrency = hookCfg.Concurrency
+	}
+	if hookCfg.WorkingDirectory != "" {
+		h.WorkingDirectory = hookCfg.WorkingDirectory
+	}
+	if len(hookCfg.Branches) > 0 {
+		h.Branches = hookCfg.Branches
+	}
+	if hookCfg.CondaEnvFile != "" {
+		h.CondaEnvFile = hookCfg.CondaEnvFile
+	}
 
-	// Apply global config defaults.
+	// Apply global config defaults. A language_version from the manifest
+	// or the hook's config wins over default_language_version; an explicit
+	// "default" does not.
 	if globalCfg != nil {
-		if h.LanguageVersion == "" {
+		if h.LanguageVersion == "" || h.LanguageVersion == "default" {
 			if v, ok := globalCfg.DefaultLanguageVersion[h.Language]; ok {
 				h.LanguageVersion = v
 			}
@@ -181,9 +230,23 @@ func MergeManifest(manifest *config.ManifestHook, hookCfg *config.HookConfig, re
 		h.LanguageVersion = "default"
 	}
 
+	ci := filesCaseInsensitive(hookCfg, globalCfg)
+	h.Files = pcre.CaseInsensitive(h.Files, ci)
+	h.Exclude = pcre.CaseInsensitive(h.Exclude, ci)
+
 	return h
 }
 
+// filesCaseInsensitive reports whether a hook's files/exclude patterns should
+// ignore case: the hook's files_case_insensitive if set, otherwise the
+// top-level default.
+func filesCaseInsensitive(hookCfg *config.HookConfig, globalCfg *config.Config) bool {
+	if hookCfg.FilesCaseInsensitive != nil {
+		return *hookCfg.FilesCaseInsensitive
+	}
+	return globalCfg != nil && globalCfg.FilesCaseInsensitive
+}
+
 // FromLocalConfig creates a Hook from a local hook config (repo: local).
 func FromLocalConfig(hookCfg *config.HookConfig, globalCfg *config.Config) *Hook {
 	h := &Hook{
@@ -217,6 +280,9 @@ func FromLocalConfig(hookCfg *config.HookConfig, globalCfg *config.Config) *Hook
 	}
 	if len(hookCfg.AdditionalDependencies) > 0 {
 		h.AdditionalDependencies = hookCfg.AdditionalDependencies
+		if h.Language == "node" && globalCfg != nil {
+			h.AdditionalDependencies = languages.ResolveNodeDependencies(h.AdditionalDependencies, globalCfg.Dir)
+		}
 	}
 	if hookCfg.AlwaysRun != nil {
 		h.AlwaysRun = *hookCfg.AlwaysRun
@@ -241,6 +307,24 @@ func FromLocalConfig(hookCfg *config.HookConfig, globalCfg *config.Config) *Hook
 	if hookCfg.LogFile != "" {
 		h.LogFile = hookCfg.LogFile
 	}
+	if hookCfg.AnnotationRegex != "" {
+		h.AnnotationRegex = hookCfg.AnnotationRegex
+	}
+	if hookCfg.TrackedOnly != nil {
+		h.TrackedOnly = *hookCfg.TrackedOnly
+	}
+	if hookCfg.Concurrency > 0 {
+		h.Concurrency = hookCfg.Concurrency
+	}
+	if hookCfg.WorkingDirectory != "" {
+		h.WorkingDirectory = hookCfg.WorkingDirectory
+	}
+	if len(hookCfg.Branches) > 0 {
+		h.Branches = hookCfg.Branches
+	}
+	if hookCfg.CondaEnvFile != "" {
+		h.CondaEnvFile = hookCfg.CondaEnvFile
+	}
 	if hookCfg.Description != "" {
 		h.Description = hookCfg.Description
 	}
@@ -266,6 +350,10 @@ func FromLocalConfig(hookCfg *config.HookConfig, globalCfg *config.Config) *Hook
 		h.LanguageVersion = "default"
 	}
 
+	ci := filesCaseInsensitive(hookCfg, globalCfg)
+	h.Files = pcre.CaseInsensitive(h.Files, ci)
+	h.Exclude = pcre.CaseInsensitive(h.Exclude, ci)
+
 	return h
 }
 
@@ -292,6 +380,7 @@ func FromManifestHook(manifest *config.ManifestHook) *Hook {
 		RequireSerial:           manifest.RequireSerial,
 		Description:             manifest.Description,
 		MinimumPreCommitVersion: manifest.MinimumPreCommitVersion,
+		CondaEnvFile:            manifest.CondaEnvFile,
 	}
 
 	if len(h.Types) == 0 && len(h.TypesOr) == 0 {
@@ -2,6 +2,7 @@ package hook
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -202,8 +203,8 @@ func TestInstallKey(t *testing.T) {
 		if !strings.Contains(key, "3.11") {
 			t.Error("InstallKey missing LanguageVersion")
 		}
-		if !strings.Contains(key, "foo,bar") {
-			t.Error("InstallKey missing AdditionalDependencies")
+		if !strings.Contains(key, "bar,foo") {
+			t.Error("InstallKey missing AdditionalDependencies in sorted order")
 		}
 	})
 
@@ -225,6 +226,40 @@ func TestInstallKey(t *testing.T) {
 		}
 	})
 
+	t.Run("dependency order does not matter", func(t *testing.T) {
+		h1 := &Hook{
+			RepoDir:                "/tmp/repo",
+			Language:               "python",
+			AdditionalDependencies: []string{"requests==2.31", "black", "attrs"},
+		}
+		h2 := &Hook{
+			RepoDir:                "/tmp/repo",
+			Language:               "python",
+			AdditionalDependencies: []string{"attrs", "requests==2.31", "black"},
+		}
+		if h1.InstallKey() != h2.InstallKey() {
+			t.Errorf("expected same InstallKey, got %q vs %q", h1.InstallKey(), h2.InstallKey())
+		}
+		if h1.AdditionalDependencies[0] != "requests==2.31" {
+			t.Error("InstallKey must not reorder the hook's dependencies")
+		}
+	})
+
+	t.Run("node tarball contents are part of the key", func(t *testing.T) {
+		tgz := filepath.Join(t.TempDir(), "tool-1.0.0.tgz")
+		os.WriteFile(tgz, []byte("v1"), 0o644)
+		h := &Hook{
+			RepoDir:                "/tmp/repo",
+			Language:               "node",
+			AdditionalDependencies: []string{"file:" + tgz, "git+https://github.com/org/tool#v2"},
+		}
+		before := h.InstallKey()
+		os.WriteFile(tgz, []byte("v2"), 0o644)
+		if after := h.InstallKey(); after == before {
+			t.Errorf("expected InstallKey to change with tarball contents, got %q", after)
+		}
+	})
+
 	t.Run("same fields produce same key", func(t *testing.T) {
 		h1 := &Hook{
 			RepoDir:         "/tmp/repo",
@@ -492,6 +527,20 @@ func TestMergeManifest(t *testing.T) {
 		}
 	})
 
+	t.Run("manifest language_version wins over default_language_version", func(t *testing.T) {
+		repoCfg := &config.RepoConfig{Repo: "https://github.com/example/repo", Rev: "v1.0.0"}
+		globalCfg := &config.Config{
+			DefaultLanguageVersion: map[string]string{"python": "3.10"},
+		}
+		for version, want := range map[string]string{"3.11": "3.11", "default": "3.10"} {
+			manifest := &config.ManifestHook{ID: "my-hook", Name: "Hook", Entry: "entry", Language: "python", LanguageVersion: version}
+			h := MergeManifest(manifest, &config.HookConfig{ID: "my-hook"}, repoCfg, globalCfg)
+			if h.LanguageVersion != want {
+				t.Errorf("manifest %q: LanguageVersion = %q, want %q", version, h.LanguageVersion, want)
+			}
+		}
+	})
+
 	t.Run("global config default_stages applied when hook has none", func(t *testing.T) {
 		manifest := &config.ManifestHook{
 			ID:       "my-hook",
@@ -846,7 +895,7 @@ func TestFilterFiles_SkipsNonExistentFiles(t *testing.T) {
 		Types: []string{"file"},
 	}
 
-	result := filterFiles([]string{realFile, ghostFile}, h)
+	result := filterFiles("", []string{realFile, ghostFile}, h, nil)
 
 	if len(result) != 1 {
 		t.Fatalf("expected 1 file, got %d: %v", len(result), result)
@@ -855,3 +904,71 @@ func TestFilterFiles_SkipsNonExistentFiles(t *testing.T) {
 		t.Errorf("expected %q, got %q", realFile, result[0])
 	}
 }
+
+func TestNodeFileDepsResolvedAgainstConfigDir(t *testing.T) {
+	deps := []string{"file:./tools/foo-1.0.tgz", "file:/abs/bar.tgz", "git+https://github.com/org/tool#v2", "eslint@8"}
+	want := []string{"file:/work/project/tools/foo-1.0.tgz", "file:/abs/bar.tgz", "git+https://github.com/org/tool#v2", "eslint@8"}
+	cfg := &config.Config{Dir: "/work/project"}
+
+	tests := []struct {
+		name string
+		hook func() *Hook
+	}{
+		{"remote", func() *Hook {
+			manifest := &config.ManifestHook{ID: "lint", Name: "lint", Entry: "lint", Language: "node"}
+			hookCfg := &config.HookConfig{ID: "lint", AdditionalDependencies: deps}
+			repoCfg := &config.RepoConfig{Repo: "https://example.com/r", Rev: "v1"}
+			return MergeManifest(manifest, hookCfg, repoCfg, cfg)
+		}},
+		{"local", func() *Hook {
+			hookCfg := &config.HookConfig{ID: "lint", Name: "lint", Entry: "lint", Language: "node", AdditionalDependencies: deps}
+			return FromLocalConfig(hookCfg, cfg)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := tt.hook()
+			if strings.Join(h.AdditionalDependencies, " ") != strings.Join(want, " ") {
+				t.Errorf("AdditionalDependencies = %v, want %v", h.AdditionalDependencies, want)
+			}
+		})
+	}
+}
+
+func TestFilesCaseInsensitive(t *testing.T) {
+	boolPtr := func(b bool) *bool { return &b }
+	manifest := &config.ManifestHook{ID: "lint", Name: "lint", Entry: "lint", Language: "system", Files: `\.py$`, Exclude: `^vendor/`}
+	repoCfg := &config.RepoConfig{Repo: "https://example.com/r", Rev: "v1"}
+
+	tests := []struct {
+		name      string
+		hookCI    *bool
+		globalCI  bool
+		wantMatch bool
+	}{
+		{"default is case-sensitive", nil, false, false},
+		{"hook flag", boolPtr(true), false, true},
+		{"top-level default", nil, true, true},
+		{"hook overrides top-level", boolPtr(false), true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hookCfg := &config.HookConfig{ID: "lint", Language: "system", Entry: "lint", Files: `\.py$`, Exclude: `^vendor/`, FilesCaseInsensitive: tt.hookCI}
+			globalCfg := &config.Config{FilesCaseInsensitive: tt.globalCI}
+			for _, h := range []*Hook{
+				MergeManifest(manifest, &config.HookConfig{ID: "lint", FilesCaseInsensitive: tt.hookCI}, repoCfg, globalCfg),
+				FromLocalConfig(hookCfg, globalCfg),
+			} {
+				if got := h.MatchesFiles("SETUP.PY"); got != tt.wantMatch {
+					t.Errorf("%s: MatchesFiles(SETUP.PY) = %v, want %v", h.Repo, got, tt.wantMatch)
+				}
+				if !h.MatchesFiles("setup.py") {
+					t.Errorf("%s: MatchesFiles(setup.py) = false, want true", h.Repo)
+				}
+				if got := h.MatchesFiles("Vendor/x.py"); got != !tt.wantMatch {
+					t.Errorf("%s: MatchesFiles(Vendor/x.py) = %v, want %v", h.Repo, got, !tt.wantMatch)
+				}
+			}
+		})
+	}
+}
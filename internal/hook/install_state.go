@@ -0,0 +1,320 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
+)
+
+// installStateFile is the filename used to track installed environment state.
+// This matches Python pre-commit's install_state tracking to avoid unnecessary
+// reinstalls and to detect when dependencies have changed. Its content is the
+// hook's InstallKey.
+const installStateFile = "install_state_v2"
+
+// installStateMigration upgrades an older install state file to the current
+// format. migrate receives the old file's contents and the hook whose
+// environment it describes, and returns the equivalent current state.
+type installStateMigration struct {
+	file    string
+	migrate func(data []byte, h *Hook) (string, error)
+}
+
+// installStateMigrations lists older state formats, newest first. When a
+// format changes, add the previous one here with a migration to the current
+// format rather than forcing environments to be rebuilt.
+var installStateMigrations = []installStateMigration{
+	{file: ".install_state_v1", migrate: migrateInstallStateV1},
+}
+
+// migrateInstallStateV1 converts Python pre-commit's .install_state_v1, a
+// JSON object recording additional_dependencies. The repo, language and
+// version are implied by where the environment lives, so only the
+// dependencies are taken from the file.
+func migrateInstallStateV1(data []byte, h *Hook) (string, error) {
+	var v1 struct {
+		AdditionalDependencies []string `json:"additional_dependencies"`
+	}
+	if err := json.Unmarshal(data, &v1); err != nil {
+		return "", err
+	}
+	old := *h
+	old.AdditionalDependencies = v1.AdditionalDependencies
+	return old.InstallKey(), nil
+}
+
+// envStatePath returns the environment directory h installs into, where its
+// install state is recorded. Like the directory the language builds, it is
+// named after the hook's resolved language_version, so a hook inheriting
+// default_language_version and one pinning the same version explicitly
+// share a single environment.
+func envStatePath(lang languages.Language, h *Hook) string {
+	return languages.EnvironmentPath(lang, h.RepoDir, h.LanguageVersion)
+}
+
+// readInstallState returns the install state recorded in envPath for h,
+// upgrading an older state file to the current format in place. It reports
+// false when no usable state exists.
+func readInstallState(envPath string, h *Hook) (string, bool) {
+	if data, err := os.ReadFile(filepath.Join(envPath, installStateFile)); err == nil {
+		return string(data), true
+	}
+	if state, ok := migrateInstallState(envPath, envPath, h); ok {
+		return state, true
+	}
+	return readLegacyInstallState(envPath, h)
+}
+
+// migrateInstallState upgrades the first older state file found in dir to
+// the current format, writing it to envPath. It reports false when there is
+// none or it can't be read.
+func migrateInstallState(dir, envPath string, h *Hook) (string, bool) {
+	for _, m := range installStateMigrations {
+		oldFile := filepath.Join(dir, m.file)
+		data, err := os.ReadFile(oldFile)
+		if err != nil {
+			continue
+		}
+		state, err := m.migrate(data, h)
+		if err != nil {
+			return "", false
+		}
+		if err := writeInstallState(envPath, state); err != nil {
+			return "", false
+		}
+		os.Remove(oldFile)
+		return state, true
+	}
+	return "", false
+}
+
+// readLegacyInstallState moves state recorded by older releases, which kept
+// it in an unversioned directory beside the environment (e.g. py_env for
+// py_env-default), into envPath. Only an environment that exists is
+// adopted, and only with state describing h's version, so state left for
+// another language_version is kept for that one.
+func readLegacyInstallState(envPath string, h *Hook) (string, bool) {
+	lang, err := languages.Get(h.Language)
+	if err != nil || lang.EnvironmentDir() == "" || h.RepoDir == "" {
+		return "", false
+	}
+	if fi, err := os.Stat(envPath); err != nil || !fi.IsDir() {
+		return "", false
+	}
+	legacyDir := filepath.Join(h.RepoDir, lang.EnvironmentDir())
+	if legacyDir == envPath {
+		return "", false
+	}
+	state, ok := "", false
+	if data, err := os.ReadFile(filepath.Join(legacyDir, installStateFile)); err == nil {
+		if string(data) != h.InstallKey() {
+			return "", false
+		}
+		if err := writeInstallState(envPath, string(data)); err != nil {
+			return "", false
+		}
+		os.Remove(filepath.Join(legacyDir, installStateFile))
+		state, ok = string(data), true
+	} else {
+		state, ok = migrateInstallState(legacyDir, envPath, h)
+	}
+	if ok {
+		os.Remove(legacyDir) // only once it is empty
+	}
+	return state, ok
+}
+
+// writeInstallState atomically writes state to envPath's install state file,
+// so an interrupted write never leaves a partial state behind.
+func writeInstallState(envPath, state string) error {
+	if err := os.MkdirAll(envPath, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(envPath, installStateFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.WriteString(state); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(envPath, installStateFile))
+}
+
+// explainInstallState describes why h's environment at envPath is reused or
+// (re)built, given the install state read from it, for debug logging. When
+// there is no state it looks for an environment of the same repo and
+// language built for another language_version, so a version that resolved
+// differently shows up as such rather than as a first install.
+func explainInstallState(lang languages.Language, envPath string, h *Hook, state string, ok bool) string {
+	key := h.InstallKey()
+	if ok && state == key {
+		return fmt.Sprintf("reusing env %s (install state matches)", envPath)
+	}
+	if !ok {
+		siblings, _ := filepath.Glob(filepath.Join(h.RepoDir, lang.EnvironmentDir()+"-*"))
+		for _, dir := range siblings {
+			if data, err := os.ReadFile(filepath.Join(dir, installStateFile)); err == nil && dir != envPath {
+				state, ok = string(data), true
+				break
+			}
+		}
+	}
+	prefix := h.RepoDir + ":" + h.Language + ":"
+	rest, found := strings.CutPrefix(state, prefix)
+	if !ok || !found {
+		return fmt.Sprintf("installing env %s: no environment installed yet", envPath)
+	}
+	rest, oldFingerprint, _ := strings.Cut(rest, "\n")
+	oldVersion, oldDeps, _ := strings.Cut(rest, ":")
+	newRest, newFingerprint, _ := strings.Cut(strings.TrimPrefix(key, prefix), "\n")
+	_, newDeps, _ := strings.Cut(newRest, ":")
+
+	var reasons []string
+	if oldVersion != h.LanguageVersion {
+		reasons = append(reasons, fmt.Sprintf("version %s→%s", oldVersion, h.LanguageVersion))
+	}
+	added, removed := diffDeps(oldDeps, newDeps)
+	if len(added) > 0 || len(removed) > 0 {
+		var changes []string
+		if len(added) > 0 {
+			changes = append(changes, "added "+strings.Join(added, ", "))
+		}
+		if len(removed) > 0 {
+			changes = append(changes, "removed "+strings.Join(removed, ", "))
+		}
+		reasons = append(reasons, "deps changed: "+strings.Join(changes, "; "))
+	}
+	if oldFingerprint != newFingerprint {
+		reasons = append(reasons, fmt.Sprintf("environment files changed: %s→%s", shortFingerprint(oldFingerprint), shortFingerprint(newFingerprint)))
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, "install state changed")
+	}
+	return fmt.Sprintf("rebuilding env %s: %s", envPath, strings.Join(reasons, "; "))
+}
+
+// shortFingerprint abbreviates a fingerprint's digest for display.
+func shortFingerprint(fp string) string {
+	if fp == "" {
+		return "none"
+	}
+	if name, digest, ok := strings.Cut(fp, "="); ok && len(digest) > 12 {
+		return name + "=" + digest[:12]
+	}
+	return fp
+}
+
+// diffDeps compares two comma-joined dependency lists as recorded in an
+// InstallKey.
+func diffDeps(oldDeps, newDeps string) (added, removed []string) {
+	split := func(deps string) []string {
+		if deps == "" {
+			return nil
+		}
+		return strings.Split(deps, ",")
+	}
+	oldList, newList := split(oldDeps), split(newDeps)
+	for _, d := range newList {
+		if !slices.Contains(oldList, d) {
+			added = append(added, d)
+		}
+	}
+	for _, d := range oldList {
+		if !slices.Contains(newList, d) {
+			removed = append(removed, d)
+		}
+	}
+	return added, removed
+}
+
+// MissingEnvironments returns the hooks whose environment is not installed
+// with their current dependencies. Hooks that need no environment are never
+// reported.
+func MissingEnvironments(hooks []*Hook) []*Hook {
+	seen := make(map[string]bool)
+	var missing []*Hook
+	for _, h := range hooks {
+		key := h.InstallKey()
+		if seen[key] || h.RepoDir == "" {
+			continue
+		}
+		seen[key] = true
+
+		lang, err := languages.Get(h.Language)
+		if err != nil || lang.EnvironmentDir() == "" {
+			continue
+		}
+		envPath := envStatePath(lang, h)
+		if state, ok := readInstallState(envPath, h); !ok || state != key {
+			missing = append(missing, h)
+		}
+	}
+	return missing
+}
+
+// UnhealthyEnvironments runs the language health check for every installed
+// hook environment and returns the ones that fail. Environments that are not
+// installed are skipped; see MissingEnvironments.
+func UnhealthyEnvironments(hooks []*Hook) []InstallFailure {
+	seen := make(map[string]bool)
+	var unhealthy []InstallFailure
+	for _, h := range hooks {
+		key := h.InstallKey()
+		if seen[key] || h.RepoDir == "" {
+			continue
+		}
+		seen[key] = true
+
+		lang, err := languages.Get(h.Language)
+		if err != nil || lang.EnvironmentDir() == "" {
+			continue
+		}
+		envPath := envStatePath(lang, h)
+		if state, ok := readInstallState(envPath, h); !ok || state != key {
+			continue
+		}
+		if err := lang.HealthCheck(h.RepoDir, h.LanguageVersion); err != nil {
+			unhealthy = append(unhealthy, InstallFailure{Hook: h, Err: err})
+		}
+	}
+	return unhealthy
+}
+
+// RebuildEnvironments removes the environments of the given unhealthy hooks
+// and installs them again from scratch. A rebuilt environment that still
+// fails its health check is reported as failed.
+func RebuildEnvironments(ctx context.Context, unhealthy []InstallFailure) InstallSummary {
+	var hooks []*Hook
+	for _, f := range unhealthy {
+		lang, err := languages.Get(f.Hook.Language)
+		if err != nil {
+			continue
+		}
+		os.RemoveAll(envStatePath(lang, f.Hook))
+		hooks = append(hooks, f.Hook)
+	}
+
+	summary := InstallEnvironmentsSummary(ctx, hooks)
+	still := UnhealthyEnvironments(summary.Installed)
+	summary.Installed = slices.DeleteFunc(summary.Installed, func(h *Hook) bool {
+		return slices.ContainsFunc(still, func(f InstallFailure) bool { return f.Hook == h })
+	})
+	summary.Failed = append(summary.Failed, still...)
+	return summary
+}
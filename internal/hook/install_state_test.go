@@ -0,0 +1,389 @@
+package hook
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
+	"github.com/blairham/go-pre-commit/v4/internal/store"
+)
+
+func TestInstallEnvironments_MigratesV1State(t *testing.T) {
+	repoDir := t.TempDir()
+	statePath := filepath.Join(repoDir, "py_env")
+	envPath := filepath.Join(repoDir, "py_env-default")
+	os.MkdirAll(statePath, 0o755)
+	os.MkdirAll(filepath.Join(envPath, "bin"), 0o755)
+	interpreter := filepath.Join(envPath, "bin", "python")
+	os.WriteFile(interpreter, []byte("#!/bin/sh\n"), 0o755)
+	os.WriteFile(filepath.Join(statePath, ".install_state_v1"), []byte(`{"additional_dependencies": ["b==1", "a==1"]}`), 0o644)
+
+	h := &Hook{
+		ID: "lint", Language: "python", LanguageVersion: "default", RepoDir: repoDir,
+		AdditionalDependencies: []string{"a==1", "b==1"},
+	}
+	if err := InstallEnvironments(context.Background(), []*Hook{h}); err != nil {
+		t.Fatalf("expected v1 environment to be reused, got %v", err)
+	}
+
+	if _, err := os.Stat(interpreter); err != nil {
+		t.Errorf("interpreter was rebuilt or removed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(envPath, installStateFile))
+	if err != nil {
+		t.Fatalf("expected migrated state file: %v", err)
+	}
+	if string(data) != h.InstallKey() {
+		t.Errorf("migrated state = %q, want %q", data, h.InstallKey())
+	}
+	if _, err := os.Stat(filepath.Join(statePath, ".install_state_v1")); !os.IsNotExist(err) {
+		t.Error("expected v1 state file to be removed after migration")
+	}
+}
+
+func TestMissingEnvironments_AdoptsUnversionedState(t *testing.T) {
+	repoDir := t.TempDir()
+	h := &Hook{ID: "lint", Language: "python", LanguageVersion: "default", RepoDir: repoDir}
+	other := &Hook{ID: "lint", Language: "python", LanguageVersion: "3.12", RepoDir: repoDir}
+	envPath := filepath.Join(repoDir, "py_env-default")
+	os.MkdirAll(filepath.Join(envPath, "bin"), 0o755)
+	os.MkdirAll(filepath.Join(repoDir, "py_env-3.12", "bin"), 0o755)
+	if err := writeInstallState(filepath.Join(repoDir, "py_env"), h.InstallKey()); err != nil {
+		t.Fatal(err)
+	}
+
+	// State recorded before environments were versioned is adopted by the
+	// environment it describes, and only that one.
+	if missing := MissingEnvironments([]*Hook{h, other}); len(missing) != 1 || missing[0] != other {
+		t.Fatalf("MissingEnvironments() = %v, want only the 3.12 environment", missing)
+	}
+	if data, err := os.ReadFile(filepath.Join(envPath, installStateFile)); err != nil || string(data) != h.InstallKey() {
+		t.Errorf("state not moved into %s: %q, %v", envPath, data, err)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "py_env")); !os.IsNotExist(err) {
+		t.Error("expected the emptied unversioned state directory to be removed")
+	}
+}
+
+func TestReadInstallState(t *testing.T) {
+	h := &Hook{ID: "lint", Language: "python", RepoDir: "/repo", AdditionalDependencies: []string{"a==1"}}
+
+	t.Run("current state", func(t *testing.T) {
+		envPath := t.TempDir()
+		os.WriteFile(filepath.Join(envPath, installStateFile), []byte("state"), 0o644)
+		if got, ok := readInstallState(envPath, h); !ok || got != "state" {
+			t.Errorf("readInstallState() = %q, %v", got, ok)
+		}
+	})
+
+	t.Run("v1 with different deps migrates to a mismatching state", func(t *testing.T) {
+		envPath := t.TempDir()
+		os.WriteFile(filepath.Join(envPath, ".install_state_v1"), []byte(`{"additional_dependencies": ["a==2"]}`), 0o644)
+		got, ok := readInstallState(envPath, h)
+		if !ok {
+			t.Fatal("expected v1 state to be migrated")
+		}
+		if got == h.InstallKey() {
+			t.Error("expected migrated state to reflect the old dependencies")
+		}
+	})
+
+	t.Run("corrupt v1", func(t *testing.T) {
+		envPath := t.TempDir()
+		os.WriteFile(filepath.Join(envPath, ".install_state_v1"), []byte("not json"), 0o644)
+		if _, ok := readInstallState(envPath, h); ok {
+			t.Error("expected corrupt v1 state to be ignored")
+		}
+		if _, err := os.Stat(filepath.Join(envPath, installStateFile)); !os.IsNotExist(err) {
+			t.Error("no state file should be written for corrupt v1 state")
+		}
+	})
+
+	t.Run("no state", func(t *testing.T) {
+		if _, ok := readInstallState(t.TempDir(), h); ok {
+			t.Error("expected no state")
+		}
+	})
+}
+
+func TestWriteInstallStateLeavesNoTempFiles(t *testing.T) {
+	envPath := filepath.Join(t.TempDir(), "env")
+	if err := writeInstallState(envPath, "key"); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(envPath)
+	if len(entries) != 1 || entries[0].Name() != installStateFile {
+		t.Errorf("unexpected files in env dir: %v", entries)
+	}
+}
+
+func TestMissingEnvironments(t *testing.T) {
+	installed := &Hook{ID: "installed", Language: "python", LanguageVersion: "default", RepoDir: t.TempDir()}
+	if err := writeInstallState(filepath.Join(installed.RepoDir, "py_env-default"), installed.InstallKey()); err != nil {
+		t.Fatal(err)
+	}
+	stale := &Hook{ID: "stale", Language: "python", LanguageVersion: "default", RepoDir: t.TempDir()}
+	if err := writeInstallState(filepath.Join(stale.RepoDir, "py_env-default"), "old-key"); err != nil {
+		t.Fatal(err)
+	}
+	absent := &Hook{ID: "absent", Language: "node", LanguageVersion: "default", RepoDir: t.TempDir()}
+	system := &Hook{ID: "system", Language: "system", RepoDir: t.TempDir()}
+	local := &Hook{ID: "local", Language: "python", Repo: "local"}
+
+	missing := MissingEnvironments([]*Hook{installed, stale, absent, system, local})
+	var ids []string
+	for _, h := range missing {
+		ids = append(ids, h.ID)
+	}
+	if len(ids) != 2 || ids[0] != "stale" || ids[1] != "absent" {
+		t.Errorf("MissingEnvironments() = %v, want [stale absent]", ids)
+	}
+}
+
+func TestUnhealthyEnvironments(t *testing.T) {
+	newPythonHook := func(id string, bins ...string) *Hook {
+		h := &Hook{ID: id, Language: "python", LanguageVersion: "default", RepoDir: t.TempDir()}
+		binDir := filepath.Join(h.RepoDir, "py_env-default", "bin")
+		os.MkdirAll(binDir, 0o755)
+		for _, name := range bins {
+			os.WriteFile(filepath.Join(binDir, name), []byte("#!/bin/sh\nexit 0\n"), 0o755)
+		}
+		os.WriteFile(filepath.Join(h.RepoDir, "py_env-default", "pyvenv.cfg"), []byte("home = "+t.TempDir()+"\n"), 0o644)
+		if err := writeInstallState(filepath.Join(h.RepoDir, "py_env-default"), h.InstallKey()); err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	healthy := newPythonHook("healthy", "python", "pip")
+	noPip := newPythonHook("no-pip", "python")
+	notInstalled := &Hook{ID: "not-installed", Language: "python", LanguageVersion: "default", RepoDir: t.TempDir()}
+
+	unhealthy := UnhealthyEnvironments([]*Hook{healthy, noPip, notInstalled})
+	if len(unhealthy) != 1 || unhealthy[0].Hook != noPip {
+		t.Fatalf("UnhealthyEnvironments() = %v, want only no-pip", unhealthy)
+	}
+	if !strings.Contains(unhealthy[0].Err.Error(), "pip missing or broken") {
+		t.Errorf("error = %v, want pip missing or broken", unhealthy[0].Err)
+	}
+}
+
+func TestExplainInstallState(t *testing.T) {
+	lang, err := languages.Get("python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoDir := t.TempDir()
+	old := &Hook{ID: "lint", Language: "python", LanguageVersion: "default", RepoDir: repoDir, AdditionalDependencies: []string{"flake8"}}
+	oldEnv := filepath.Join(repoDir, "py_env-default")
+	if err := writeInstallState(oldEnv, old.InstallKey()); err != nil {
+		t.Fatal(err)
+	}
+
+	state, ok := readInstallState(oldEnv, old)
+	if got, want := explainInstallState(lang, oldEnv, old, state, ok), "reusing env "+oldEnv+" (install state matches)"; got != want {
+		t.Errorf("matching state: got %q, want %q", got, want)
+	}
+
+	// Pinning a new version builds a fresh env dir; the explanation points
+	// at the version rather than claiming nothing was installed.
+	bumped := *old
+	bumped.LanguageVersion = "3.12.1"
+	bumped.AdditionalDependencies = []string{"flake8", "pytest"}
+	newEnv := filepath.Join(repoDir, "py_env-3.12.1")
+	state, ok = readInstallState(newEnv, &bumped)
+	want := "rebuilding env " + newEnv + ": version default→3.12.1; deps changed: added pytest"
+	if got := explainInstallState(lang, newEnv, &bumped, state, ok); got != want {
+		t.Errorf("version change: got %q, want %q", got, want)
+	}
+
+	fresh := &Hook{ID: "lint", Language: "python", LanguageVersion: "default", RepoDir: t.TempDir()}
+	freshEnv := filepath.Join(fresh.RepoDir, "py_env-default")
+	if got := explainInstallState(lang, freshEnv, fresh, "", false); !strings.HasPrefix(got, "installing env ") {
+		t.Errorf("no state: got %q", got)
+	}
+}
+
+func TestInstallEnvironments_RefusesOnLowDiskSpace(t *testing.T) {
+	old := diskFree
+	diskFree = func(string) (uint64, error) { return 1 << 20, nil }
+	t.Cleanup(func() { diskFree = old })
+	t.Setenv("PRE_COMMIT_MIN_FREE_SPACE", "1G")
+
+	h := &Hook{ID: "lint", Language: "python", LanguageVersion: "default", RepoDir: t.TempDir()}
+	err := InstallEnvironments(context.Background(), []*Hook{h})
+	var low *store.LowSpaceError
+	if !errors.As(err, &low) || errkind.Of(err) != errkind.Env {
+		t.Fatalf("expected an env-kind low space error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(h.RepoDir, "py_env-default")); !os.IsNotExist(err) {
+		t.Error("expected no environment to be started")
+	}
+}
+
+func TestInstallEnvironments_WarnsOnLowDiskSpace(t *testing.T) {
+	old := diskFree
+	diskFree = func(string) (uint64, error) { return 1 << 20, nil }
+	t.Cleanup(func() { diskFree = old })
+	registerLanguage(t, fakeLanguage{name: "installtest"})
+
+	// Without an explicit minimum, low space doesn't stop a build.
+	h := &Hook{ID: "lint", Language: "installtest", LanguageVersion: "default", RepoDir: t.TempDir()}
+	if err := InstallEnvironments(context.Background(), []*Hook{h}); err != nil {
+		t.Fatalf("expected the build to go ahead, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(h.RepoDir, "installtest_env-default", installStateFile)); err != nil {
+		t.Errorf("expected the environment to be installed: %v", err)
+	}
+}
+
+func TestMissingEnvironments_CondaEnvironmentYmlChanged(t *testing.T) {
+	repoDir := t.TempDir()
+	yml := filepath.Join(repoDir, "environment.yml")
+	if err := os.WriteFile(yml, []byte("channels: [conda-forge]\ndependencies: [python=3.11]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := &Hook{ID: "lint", Language: "conda", LanguageVersion: "default", RepoDir: repoDir}
+	envPath := filepath.Join(repoDir, "conda_env-default")
+	if err := writeInstallState(envPath, h.InstallKey()); err != nil {
+		t.Fatal(err)
+	}
+	if missing := MissingEnvironments([]*Hook{h}); len(missing) != 0 {
+		t.Fatalf("expected the conda env to be reused, got %d missing", len(missing))
+	}
+
+	if err := os.WriteFile(yml, []byte("channels: [conda-forge]\ndependencies: [python=3.12]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if missing := MissingEnvironments([]*Hook{h}); len(missing) != 1 {
+		t.Fatalf("expected editing environment.yml to invalidate the env, got %d missing", len(missing))
+	}
+	lang, _ := languages.Get("conda")
+	state, ok := readInstallState(envPath, h)
+	if got := explainInstallState(lang, envPath, h, state, ok); !strings.Contains(got, "environment files changed: environment.yml=") {
+		t.Errorf("explanation = %q, want it to name environment.yml", got)
+	}
+}
+
+func TestInstallEnvironments_RebuildsCondaWhenEnvironmentYmlChanges(t *testing.T) {
+	bin, logPath := t.TempDir(), filepath.Join(t.TempDir(), "conda.log")
+	conda := "#!/bin/sh\necho \"$*\" >> " + logPath + "\n" +
+		"if [ \"$1 $2\" = \"env create\" ]; then mkdir -p \"$6/conda-meta\"; fi\n"
+	if err := os.WriteFile(filepath.Join(bin, "conda"), []byte(conda), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	repoDir := t.TempDir()
+	yml := filepath.Join(repoDir, "environment.yml")
+	creates := func(content string, deps ...string) int {
+		t.Helper()
+		if err := os.WriteFile(yml, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		h := &Hook{ID: "lint", Language: "conda", LanguageVersion: "default", RepoDir: repoDir, AdditionalDependencies: deps}
+		if err := InstallEnvironments(context.Background(), []*Hook{h}); err != nil {
+			t.Fatal(err)
+		}
+		data, _ := os.ReadFile(logPath)
+		return strings.Count(string(data), "env create")
+	}
+
+	if n := creates("dependencies: [python=3.11]\n"); n != 1 {
+		t.Fatalf("env creates = %d after the first install, want 1", n)
+	}
+	if n := creates("dependencies: [python=3.11]\n"); n != 1 {
+		t.Fatalf("env creates = %d after an unchanged rerun, want 1", n)
+	}
+	if n := creates("dependencies: [python=3.12]\n"); n != 2 {
+		t.Fatalf("env creates = %d after editing environment.yml, want 2", n)
+	}
+	if n := creates("dependencies: [python=3.12]\n", "flake8"); n != 3 {
+		t.Fatalf("env creates = %d after adding a dependency, want 3", n)
+	}
+}
+
+func TestInstallEnvironments_ReinstallsWhenDepsChange(t *testing.T) {
+	for _, name := range []string{"python", "node", "ruby"} {
+		t.Run(name, func(t *testing.T) {
+			var installs int
+			registerLanguage(t, fakeLanguage{name: name, installs: &installs})
+
+			repoDir := t.TempDir()
+			envDeps := filepath.Join(repoDir, name+"_env-default", "deps")
+			install := func(deps ...string) {
+				t.Helper()
+				h := &Hook{ID: "lint", Language: name, LanguageVersion: "default", RepoDir: repoDir, AdditionalDependencies: deps}
+				if err := InstallEnvironments(context.Background(), []*Hook{h}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			install("old-dep")
+			install("old-dep")
+			if installs != 1 {
+				t.Fatalf("installs = %d after an unchanged rerun, want 1", installs)
+			}
+
+			install("new-dep")
+			if installs != 2 {
+				t.Fatalf("installs = %d after changing deps, want 2", installs)
+			}
+			if data, _ := os.ReadFile(envDeps); string(data) != "new-dep\n" {
+				t.Errorf("env deps = %q, want only new-dep", data)
+			}
+
+			// An install that never wrote its state is rebuilt from scratch.
+			os.Remove(filepath.Join(repoDir, name+"_env-default", installStateFile))
+			install("new-dep")
+			if installs != 3 {
+				t.Fatalf("installs = %d after losing the install state, want 3", installs)
+			}
+			if data, _ := os.ReadFile(envDeps); string(data) != "new-dep\n" {
+				t.Errorf("env deps = %q, want a clean reinstall", data)
+			}
+		})
+	}
+}
+
+func TestRebuildEnvironments(t *testing.T) {
+	registerLanguage(t, fakeLanguage{name: "installtest"})
+
+	var hooks []*Hook
+	for _, id := range []string{"healthy", "corrupt", "unfixable"} {
+		repoDir := t.TempDir()
+		if id == "unfixable" {
+			os.WriteFile(filepath.Join(repoDir, "unfixable"), nil, 0o644)
+		}
+		hooks = append(hooks, &Hook{ID: id, Repo: "https://example.com/" + id, Language: "installtest", LanguageVersion: "default", RepoDir: repoDir})
+	}
+	if err := InstallEnvironments(context.Background(), hooks); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(hooks[1].RepoDir, "installtest_env-default", "ok"))
+	healthyState := filepath.Join(hooks[0].RepoDir, "installtest_env-default", installStateFile)
+	before, _ := os.Stat(healthyState)
+
+	unhealthy := UnhealthyEnvironments(hooks)
+	if len(unhealthy) != 2 {
+		t.Fatalf("UnhealthyEnvironments() = %+v, want corrupt and unfixable", unhealthy)
+	}
+	summary := RebuildEnvironments(context.Background(), unhealthy)
+
+	if len(summary.Installed) != 1 || summary.Installed[0].ID != "corrupt" {
+		t.Errorf("Installed = %+v, want only corrupt", summary.Installed)
+	}
+	if len(summary.Failed) != 1 || summary.Failed[0].Hook.ID != "unfixable" {
+		t.Errorf("Failed = %+v, want only unfixable", summary.Failed)
+	}
+	if after, err := os.Stat(healthyState); err != nil || !after.ModTime().Equal(before.ModTime()) {
+		t.Error("healthy environment was touched")
+	}
+	if got := UnhealthyEnvironments(hooks[:2]); len(got) != 0 {
+		t.Errorf("still unhealthy after rebuild: %+v", got)
+	}
+}
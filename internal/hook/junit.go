@@ -0,0 +1,72 @@
+package hook
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// junitSeconds renders a duration in milliseconds as JUnit's seconds.
+func junitSeconds(ms int64) string {
+	return fmt.Sprintf("%.3f", float64(ms)/1000)
+}
+
+// WriteJUnitFile writes reports to path as a JUnit XML testsuite with one
+// testcase per hook, named by hook id. Failed hooks carry their output in a
+// failure element, hooks that could not run an error element.
+func WriteJUnitFile(path string, reports []HookReport) error {
+	suite := junitTestSuite{Name: "pre-commit", Tests: len(reports)}
+	var total int64
+	for _, r := range reports {
+		tc := junitTestCase{Name: r.ID, ClassName: "pre-commit", Time: junitSeconds(r.DurationMs)}
+		switch r.Status {
+		case "failed":
+			tc.Failure = &junitMessage{Message: fmt.Sprintf("%s failed with exit code %d", r.ID, r.ExitCode), Body: r.Output}
+			suite.Failures++
+		case "error":
+			tc.Error = &junitMessage{Message: fmt.Sprintf("%s could not be run", r.ID), Body: r.Output}
+			suite.Errors++
+		case "skipped":
+			tc.Skipped = &junitMessage{Message: r.Output}
+			suite.Skipped++
+		}
+		total += r.DurationMs
+		suite.Cases = append(suite.Cases, tc)
+	}
+	suite.Time = junitSeconds(total)
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append([]byte(xml.Header), append(data, '\n')...), 0o644)
+}
@@ -0,0 +1,57 @@
+package hook
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnitFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.xml")
+	reports := []HookReport{
+		{ID: "fmt", Name: "Format", Status: "passed", DurationMs: 1500, Files: []string{"a.go"}},
+		{ID: "lint", Name: "Lint", Status: "failed", ExitCode: 2, DurationMs: 250, Files: []string{"a.go"}, Output: "a.go:1: bad <thing>\n"},
+		{ID: "docs", Name: "Docs", Status: "skipped", Files: []string{}},
+	}
+	if err := WriteJUnitFile(path, reports); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "<?xml") {
+		t.Errorf("missing XML header:\n%s", data)
+	}
+
+	var got junitTestSuites
+	if err := xml.Unmarshal(data, &got); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, data)
+	}
+	if len(got.Suites) != 1 {
+		t.Fatalf("got %d testsuites, want 1", len(got.Suites))
+	}
+	suite := got.Suites[0]
+	if suite.Tests != 3 || suite.Failures != 1 || suite.Skipped != 1 || suite.Time != "1.750" {
+		t.Errorf("suite = %+v", suite)
+	}
+	if len(suite.Cases) != 3 {
+		t.Fatalf("got %d testcases, want 3", len(suite.Cases))
+	}
+	for i, id := range []string{"fmt", "lint", "docs"} {
+		if suite.Cases[i].Name != id {
+			t.Errorf("testcase %d name = %q, want %q", i, suite.Cases[i].Name, id)
+		}
+	}
+	if c := suite.Cases[0]; c.Failure != nil || c.Time != "1.500" {
+		t.Errorf("passed testcase = %+v", c)
+	}
+	if c := suite.Cases[1]; c.Failure == nil || c.Failure.Body != "a.go:1: bad <thing>\n" || !strings.Contains(c.Failure.Message, "exit code 2") {
+		t.Errorf("failed testcase = %+v", c)
+	}
+	if c := suite.Cases[2]; c.Skipped == nil || c.Failure != nil {
+		t.Errorf("skipped testcase = %+v", c)
+	}
+}
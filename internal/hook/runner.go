@@ -1,22 +1,32 @@
 package hook
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/dlclark/regexp2"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
+	"github.com/blairham/go-pre-commit/v4/internal/git"
 	"github.com/blairham/go-pre-commit/v4/internal/identify"
 	"github.com/blairham/go-pre-commit/v4/internal/languages"
 	"github.com/blairham/go-pre-commit/v4/internal/output"
 	"github.com/blairham/go-pre-commit/v4/internal/pcre"
+	"github.com/blairham/go-pre-commit/v4/internal/store"
 	"github.com/blairham/go-pre-commit/v4/internal/xargs"
 )
 
@@ -37,6 +47,86 @@ type RunOptions struct {
 	SkipList  []string
 	Jobs      int
 
+	// Parallel runs separate hooks concurrently, up to Jobs at a time, with
+	// each hook's output shown in config order. Hooks matching the same
+	// files still run one after another. Jobs also still bounds the
+	// file batches run in parallel within each hook.
+	Parallel bool
+
+	// Output receives hook status lines and output; nil means os.Stderr.
+	Output io.Writer
+
+	// CacheTypes reuses the file type classifications of earlier runs,
+	// kept under the store directory and keyed by path, mtime and size.
+	CacheTypes bool
+
+	// Quiet shows only hooks that failed or errored, with their output;
+	// passed and skipped hooks print nothing.
+	Quiet bool
+
+	// DedupOutput prints the output shared by several failed hooks once,
+	// under the first of them, with the others referring back to it. All
+	// hook output is then shown when the run finishes. Ignored with
+	// StreamOutput.
+	DedupOutput bool
+
+	// DetectNoopChurn reports when a hook's modifications are limited to
+	// whitespace or line endings, which usually points at a line-ending or
+	// editorconfig mismatch rather than a real fix. It has no effect with
+	// AllFiles, where modifications aren't detected.
+	DetectNoopChurn bool
+
+	// ShowFullCommand prints every filename in the --verbose command echo
+	// instead of summarizing long file lists as "<N files>".
+	ShowFullCommand bool
+
+	// Include and Exclude are regexes that further narrow Files, on top of
+	// the config's top-level and each hook's own files/exclude: a file is
+	// only passed to a hook if every pattern lets it through. Callers
+	// validate them; an invalid one is ignored.
+	Include string
+	Exclude string
+
+	// MissingRuntimes holds, keyed by InstallKey, the error of each hook
+	// whose environment could not be installed because its language runtime
+	// is missing (see InstallSummary.MissingRuntimes). Those hooks are
+	// reported as errors without being run.
+	MissingRuntimes map[string]error
+
+	// BuiltEnvironments holds the InstallKey of each hook whose environment
+	// was built for this run rather than reused; --verbose reports which.
+	BuiltEnvironments map[string]bool
+
+	// MaxOutputLines, if positive, caps the output shown for each hook to
+	// its last MaxOutputLines lines.
+	MaxOutputLines int
+
+	// StreamOutput copies each hook's output to the terminal as it is
+	// produced, with the status line printed once the hook finishes, instead
+	// of showing the buffered output afterwards. Hooks run one at a time.
+	StreamOutput bool
+
+	// CollectAnnotations parses the output of failing hooks into
+	// RunResult.Annotations (see ParseAnnotations).
+	CollectAnnotations bool
+
+	// CollectReports records a HookReport for each selected hook in
+	// RunResult.Hooks.
+	CollectReports bool
+
+	// AnyStage runs the hook selected by HookID even if none of its stages
+	// match HookStage. It is set when a hook is requested by id without an
+	// explicit --hook-stage, since the user clearly asked for that hook.
+	AnyStage bool
+
+	// DumpEnv prints the environment the selected hook would run with
+	// instead of running it. Exactly one hook must be selected.
+	DumpEnv bool
+
+	// HookArgs are appended after the configured args of the selected hook
+	// and before filenames. Exactly one hook must be selected.
+	HookArgs []string
+
 	// Environment variables to pass to hooks.
 	CommitMsgFilename          string
 	PrepareCommitMessageSource string
@@ -58,6 +148,29 @@ type RunResult struct {
 	Failed  int
 	Skipped int
 	Errors  int
+
+	// Annotations holds the findings parsed from failing hooks' output
+	// when RunOptions.CollectAnnotations is set.
+	Annotations []Annotation
+
+	// Hooks holds one report per selected hook, in run order, when
+	// RunOptions.CollectReports is set.
+	Hooks []HookReport
+}
+
+// HookReport is the machine-readable outcome of a single hook.
+type HookReport struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"` // passed, failed, skipped or error
+	// ExitCode is the hook's exit code; 0 if it was skipped and -1 if it
+	// could not be run.
+	ExitCode   int      `json:"exit_code"`
+	DurationMs int64    `json:"duration_ms"`
+	Files      []string `json:"files"`
+	// Output is the hook's combined stdout and stderr, or the error that
+	// kept it from running.
+	Output string `json:"output"`
 }
 
 // Runner executes hooks.
@@ -77,9 +190,7 @@ func NewRunner(cfg *config.Config, hooks []*Hook, root string) *Runner {
 }
 
 // Run executes all hooks and returns the result.
-func (r *Runner) Run(ctx context.Context, opts RunOptions) RunResult {
-	result := RunResult{}
-
+func (r *Runner) Run(ctx context.Context, opts RunOptions) (result RunResult) {
 	// Set PRE_COMMIT=1 environment variable.
 	os.Setenv("PRE_COMMIT", "1")
 	defer os.Unsetenv("PRE_COMMIT")
@@ -99,152 +210,683 @@ func (r *Runner) Run(ctx context.Context, opts RunOptions) RunResult {
 		skipSet[id] = true
 	}
 
-	// Apply top-level files/exclude filters from config.
 	files := opts.Files
+
+	// Apply top-level files/exclude filters from config.
 	if r.cfg.Files != "" || r.cfg.Exclude != "" {
-		files = filterByIncludeExclude(files, r.cfg.Files, r.cfg.Exclude)
+		ci := r.cfg.FilesCaseInsensitive
+		files = filterByIncludeExclude(r.root, files, pcre.CaseInsensitive(r.cfg.Files, ci), pcre.CaseInsensitive(r.cfg.Exclude, ci))
+	}
+	if opts.Include != "" || opts.Exclude != "" {
+		files = filterByIncludeExclude(r.root, files, opts.Include, opts.Exclude)
 	}
 
-	// Filter hooks by stage and ID.
-	var hooksToRun []*Hook
-	for _, h := range r.hooks {
-		if opts.HookID != "" && h.ID != opts.HookID && h.Alias != opts.HookID {
-			continue
+	hooksToRun := SelectHooks(r.hooks, opts)
+
+	if len(hooksToRun) == 0 && opts.HookID != "" {
+		output.ErrorContext(ctx, "No hook with id %q found", opts.HookID)
+		result.Errors++
+		return result
+	}
+
+	if len(opts.HookArgs) > 0 && len(hooksToRun) != 1 {
+		output.ErrorContext(ctx, "--hook-args requires exactly one hook to be selected, got %d", len(hooksToRun))
+		result.Errors++
+		return result
+	}
+
+	if opts.DumpEnv {
+		if len(hooksToRun) != 1 {
+			output.ErrorContext(ctx, "--dump-env requires exactly one hook to be selected, got %d", len(hooksToRun))
+			result.Errors++
+			return result
 		}
-		if opts.HookStage != "" && !h.MatchesStage(opts.HookStage) {
-			continue
+		if err := dumpHookEnv(ctx, hooksToRun[0], r.root); err != nil {
+			output.ErrorContext(ctx, "%v", err)
+			result.Errors++
 		}
-		hooksToRun = append(hooksToRun, h)
+		return result
 	}
 
-	if len(hooksToRun) == 0 && opts.HookID != "" {
-		output.Error("No hook with id %q found", opts.HookID)
+	// .gitattributes text/binary hints override content sniffing when
+	// classifying files. Outside a git repo there are none.
+	binaryAttrs, _ := git.BinaryAttributes(files)
+	types := &fileTypes{binaryAttrs: binaryAttrs}
+	if opts.CacheTypes {
+		types.cache = loadTypeCache(typeCachePath(r.root))
+		defer func() {
+			if err := types.cache.save(); err != nil {
+				output.WarnContext(ctx, "failed to save the file type cache: %v", err)
+			}
+		}()
+	}
+
+	if opts.StreamOutput {
+		opts.DedupOutput = false // streamed output can't be held back
+	}
+	st := &runState{opts: opts, files: files, skipSet: skipSet, types: types, limits: invocationLimits(hooksToRun)}
+
+	// post_run always runs once the hook sequence has started, like a
+	// finally block, so teardown happens even when hooks fail.
+	if r.cfg.PostRun != "" {
+		defer func() {
+			if !r.runGlobalCommand(ctx, st.stderr(), "post_run", r.cfg.PostRun) {
+				result.Errors++
+			}
+		}()
+	}
+	if r.cfg.PreRun != "" && !r.runGlobalCommand(ctx, st.stderr(), "pre_run", r.cfg.PreRun) {
 		result.Errors++
 		return result
 	}
 
+	if opts.DedupOutput {
+		defer func() { writeDeduped(st.stderr(), st.logs) }()
+	}
+	if opts.Parallel && !opts.StreamOutput && opts.Jobs > 1 && len(hooksToRun) > 1 {
+		r.runParallel(ctx, hooksToRun, st, &result)
+		return result
+	}
 	for _, h := range hooksToRun {
 		select {
 		case <-ctx.Done():
 			return result
 		default:
 		}
-
-		// Check minimum_pre_commit_version.
-		if h.MinimumPreCommitVersion != "" && h.MinimumPreCommitVersion != "0" {
-			if !checkMinVersion(h.MinimumPreCommitVersion) {
-				output.PrintHookHeader(h.Name, output.ResultError)
-				output.Error("hook requires pre-commit >= %s", h.MinimumPreCommitVersion)
-				result.Errors++
-				if shouldFailFast(r.cfg, h) {
-					return result
-				}
-				continue
-			}
+		w := st.output()
+		failedBefore := result.Failed + result.Errors
+		stop := r.runHook(ctx, h, st, w, &result)
+		if l, ok := w.(*hookLog); ok {
+			st.done(l, result.Failed+result.Errors > failedBefore)
+		}
+		if stop {
+			return result
 		}
+	}
 
-		// Check if skipped.
-		if skipSet[h.ID] || (h.Alias != "" && skipSet[h.Alias]) {
-			output.PrintHookHeader(h.Name, output.ResultSkipped)
-			result.Skipped++
+	return result
+}
+
+// runState is what every hook of a run shares. Lazily computed fields are
+// guarded by mu since hooks may run concurrently.
+type runState struct {
+	opts    RunOptions
+	files   []string
+	skipSet map[string]bool
+	types   *fileTypes
+
+	mu      sync.Mutex
+	tracked map[string]bool // only looked up if a tracked_only hook runs
+	branch  *string         // only looked up if a hook restricts branches
+
+	logs []*hookLog // buffered hook output, with DedupOutput
+
+	limits map[string]chan struct{} // from invocationLimits
+}
+
+// invocationLimits returns the semaphores bounding the simultaneous
+// invocations of hooks, one per repo and id that any entry gives a
+// concurrency, sized by the smallest concurrency among those entries.
+func invocationLimits(hooks []*Hook) map[string]chan struct{} {
+	sizes := make(map[string]int)
+	for _, h := range hooks {
+		if h.Concurrency <= 0 {
 			continue
 		}
+		key := h.Repo + "\x00" + h.ID
+		if n, ok := sizes[key]; !ok || h.Concurrency < n {
+			sizes[key] = h.Concurrency
+		}
+	}
+	limits := make(map[string]chan struct{}, len(sizes))
+	for key, n := range sizes {
+		limits[key] = make(chan struct{}, n)
+	}
+	return limits
+}
 
-		// Filter files by hook's patterns and types.
-		matchedFiles := filterFiles(files, h)
+// invocationLimit returns the semaphore bounding the simultaneous
+// invocations of h, shared by every hook with the same repo and id, or nil
+// if none of them sets a concurrency.
+func (st *runState) invocationLimit(h *Hook) chan struct{} {
+	return st.limits[h.Repo+"\x00"+h.ID]
+}
 
-		if len(matchedFiles) == 0 && !h.AlwaysRun {
-			output.PrintHookHeader(h.Name, output.ResultSkipped)
-			result.Skipped++
-			continue
+// invocationLimitKey is the context key under which runHookXargs finds the
+// semaphore from runState.invocationLimit.
+type invocationLimitKey struct{}
+
+// output returns where the next hook's output goes: straight to the
+// terminal, or with DedupOutput or Quiet a new hookLog to pass to done.
+func (st *runState) output() io.Writer {
+	if !st.opts.DedupOutput && !st.opts.Quiet {
+		return st.stderr()
+	}
+	return &hookLog{}
+}
+
+// done takes the output of a finished hook: dropped with Quiet unless the
+// hook failed, otherwise shown now or, with DedupOutput, once the run
+// finishes.
+func (st *runState) done(l *hookLog, failed bool) {
+	switch {
+	case st.opts.Quiet && !failed:
+	case st.opts.DedupOutput:
+		st.logs = append(st.logs, l)
+	default:
+		st.stderr().Write(l.Bytes())
+	}
+}
+
+// stderr returns where hook output is shown.
+func (st *runState) stderr() io.Writer {
+	if st.opts.Output != nil {
+		return st.opts.Output
+	}
+	return os.Stderr
+}
+
+func (st *runState) trackedFiles() (map[string]bool, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.tracked == nil {
+		tracked, err := git.TrackedFiles()
+		if err != nil {
+			return nil, err
 		}
+		st.tracked = tracked
+	}
+	return st.tracked, nil
+}
 
-		// Get the language handler.
-		lang, err := languages.Get(h.Language)
+func (st *runState) currentBranch(root string) (string, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.branch == nil {
+		branch, err := git.CurrentBranch(root)
 		if err != nil {
-			output.PrintHookHeader(h.Name, output.ResultError)
-			output.Error("unsupported language %q: %v", h.Language, err)
-			result.Errors++
-			if shouldFailFast(r.cfg, h) {
-				return result
+			return "", err
+		}
+		st.branch = &branch
+	}
+	return *st.branch, nil
+}
+
+// runParallel runs hooks up to opts.Jobs at a time, starting them in order.
+// Each hook's output is buffered and printed in order once it and the hooks
+// before it have finished. Hooks sharing an installed environment never run
+// at the same time, and a hook matching any file an earlier hook matched
+// waits for that hook to finish, so a formatter and a checker of the same
+// files run in config order. After a fail_fast failure, hooks not yet
+// started are dropped; ones already running finish and are reported.
+func (r *Runner) runParallel(ctx context.Context, hooks []*Hook, st *runState, result *RunResult) {
+	type slot struct {
+		out   hookLog
+		res   RunResult
+		ran   bool
+		after []int // earlier hooks matching the same files
+		done  chan struct{}
+	}
+	slots := make([]*slot, len(hooks))
+	envLocks := make(map[string]*sync.Mutex)
+	matched := make([]map[string]bool, len(hooks))
+	for i, h := range hooks {
+		slots[i] = &slot{done: make(chan struct{})}
+		matched[i] = make(map[string]bool)
+		for _, f := range filterFiles(r.root, st.files, h, st.types) {
+			matched[i][f] = true
+		}
+		for j := range i {
+			if overlaps(matched[j], matched[i]) {
+				slots[i].after = append(slots[i].after, j)
+			}
+		}
+		if lang, err := languages.Get(h.Language); err == nil && lang.EnvironmentDir() != "" {
+			if envLocks[h.InstallKey()] == nil {
+				envLocks[h.InstallKey()] = &sync.Mutex{}
 			}
-			continue
 		}
+	}
 
-		// Handle meta hooks specially.
-		if h.ID == "check-hooks-apply" || h.ID == "check-useless-excludes" {
-			metaExit, metaOut := r.runMetaHook(h, files)
-			if metaExit != 0 {
-				output.PrintHookHeader(h.Name, output.ResultFailed)
-				output.PrintHookOutput(metaOut, h.ID, metaExit, true)
-				result.Failed++
-			} else {
-				output.PrintHookHeader(h.Name, output.ResultPassed)
+	var stopped atomic.Bool
+	sem := make(chan struct{}, st.opts.Jobs)
+	go func() {
+		for i, h := range hooks {
+			sem <- struct{}{}
+			if stopped.Load() || ctx.Err() != nil {
+				<-sem
+				close(slots[i].done)
+				continue
 			}
+			go func() {
+				defer func() {
+					<-sem
+					close(slots[i].done)
+				}()
+				for _, j := range slots[i].after {
+					<-slots[j].done
+				}
+				if stopped.Load() || ctx.Err() != nil {
+					return
+				}
+				if mu := envLocks[h.InstallKey()]; mu != nil {
+					mu.Lock()
+					defer mu.Unlock()
+				}
+				slots[i].ran = true
+				if r.runHook(ctx, h, st, &slots[i].out, &slots[i].res) {
+					stopped.Store(true)
+				}
+			}()
+		}
+	}()
+
+	for _, sl := range slots {
+		<-sl.done
+		if !sl.ran {
 			continue
 		}
+		st.done(&sl.out, sl.res.Failed+sl.res.Errors > 0)
+		result.Passed += sl.res.Passed
+		result.Failed += sl.res.Failed
+		result.Skipped += sl.res.Skipped
+		result.Errors += sl.res.Errors
+		result.Annotations = append(result.Annotations, sl.res.Annotations...)
+		result.Hooks = append(result.Hooks, sl.res.Hooks...)
+	}
+}
 
-		// Determine file args to pass.
-		var fileArgs []string
-		if h.PassFilenames {
-			fileArgs = matchedFiles
+// overlaps reports whether the file sets a and b share a file.
+func overlaps(a, b map[string]bool) bool {
+	if len(b) < len(a) {
+		a, b = b, a
+	}
+	for f := range a {
+		if b[f] {
+			return true
 		}
+	}
+	return false
+}
 
-		// Capture file state before running hook (for modification detection).
-		var fpBefore map[string]fileFingerprint
-		if !opts.AllFiles {
-			fpBefore = fingerprintFiles(fileArgs)
+// runHook runs a single hook, writing its status line and output to w and
+// tallying it in res. It reports whether the run should stop (fail_fast).
+func (r *Runner) runHook(ctx context.Context, h *Hook, st *runState, w io.Writer, res *RunResult) bool {
+	start := time.Now()
+	report := func(status string, exitCode int, files []string, out []byte) {
+		if !st.opts.CollectReports {
+			return
+		}
+		if files == nil {
+			files = []string{}
 		}
+		res.Hooks = append(res.Hooks, HookReport{
+			ID: h.ID, Name: h.Name, Status: status, ExitCode: exitCode,
+			DurationMs: time.Since(start).Milliseconds(),
+			Files:      files,
+			Output:     string(out),
+		})
+	}
 
-		// Run the hook using xargs for batching.
-		var exitCode int
-		var hookOutput []byte
-		exitCode, hookOutput, err = runHookXargs(ctx, lang, h, fileArgs, r.root, opts.Jobs)
+	// Check minimum_pre_commit_version.
+	if h.MinimumPreCommitVersion != "" && h.MinimumPreCommitVersion != "0" {
+		if !checkMinVersion(h.MinimumPreCommitVersion) {
+			output.FprintHookHeader(w, h.Name, output.ResultError)
+			msg := fmt.Sprintf("hook %q requires pre-commit version %s but version %s is installed",
+				h.ID, h.MinimumPreCommitVersion, config.Version)
+			output.Ferror(w, "%s", msg)
+			report("error", -1, nil, []byte(msg))
+			res.Errors++
+			return shouldFailFast(r.cfg, h)
+		}
+	}
+
+	// Check if skipped.
+	if st.skipSet[h.ID] || (h.Alias != "" && st.skipSet[h.Alias]) {
+		output.FprintHookHeader(w, h.Name, output.ResultSkipped)
+		report("skipped", 0, nil, nil)
+		res.Skipped++
+		return false
+	}
+
+	// Check the hook's branches filter.
+	if len(h.Branches) > 0 {
+		branch, err := st.currentBranch(r.root)
 		if err != nil {
-			output.PrintHookHeader(h.Name, output.ResultError)
-			output.Error("hook execution error: %v", err)
-			result.Errors++
-			if shouldFailFast(r.cfg, h) {
-				return result
-			}
-			continue
+			output.FprintHookHeader(w, h.Name, output.ResultError)
+			output.Ferror(w, "branches: failed to determine the current branch: %v", err)
+			report("error", -1, nil, []byte(err.Error()))
+			res.Errors++
+			return shouldFailFast(r.cfg, h)
+		}
+		if reason := branchSkipReason(branch, h.Branches); reason != "" {
+			output.FprintHookHeader(w, h.Name, output.ResultSkipped)
+			fmt.Fprintf(w, "- skipped: %s\n", reason)
+			report("skipped", 0, nil, []byte(reason))
+			res.Skipped++
+			return false
 		}
+	}
 
-		// Detect if files were modified by the hook.
-		filesModified := false
-		if fpBefore != nil && exitCode == 0 {
-			fpAfter := fingerprintFiles(fileArgs)
-			for f, before := range fpBefore {
-				if after, ok := fpAfter[f]; ok && (before.size != after.size || before.modTime != after.modTime) {
-					filesModified = true
-					break
-				}
-			}
+	// Filter files by hook's patterns and types.
+	matchedFiles := filterFiles(r.root, st.files, h, st.types)
+	if h.TrackedOnly {
+		tracked, err := st.trackedFiles()
+		if err != nil {
+			output.FprintHookHeader(w, h.Name, output.ResultError)
+			output.Ferror(w, "tracked_only: failed to list tracked files: %v", err)
+			report("error", -1, nil, []byte(err.Error()))
+			res.Errors++
+			return shouldFailFast(r.cfg, h)
 		}
+		matchedFiles = slices.DeleteFunc(matchedFiles, func(f string) bool { return !tracked[f] })
+	}
 
-		if exitCode != 0 || filesModified {
-			output.PrintHookHeader(h.Name, output.ResultFailed)
-			output.PrintHookOutput(hookOutput, h.ID, exitCode, opts.Verbose || h.Verbose)
-			result.Failed++
+	if len(matchedFiles) == 0 && !h.AlwaysRun {
+		output.FprintHookHeader(w, h.Name, output.ResultSkipped)
+		report("skipped", 0, nil, nil)
+		res.Skipped++
+		return false
+	}
 
-			// Write to log file if configured.
-			if h.LogFile != "" {
-				_ = os.WriteFile(h.LogFile, hookOutput, 0o644)
-			}
+	// Get the language handler.
+	lang, err := languages.Get(h.Language)
+	if err != nil {
+		output.FprintHookHeader(w, h.Name, output.ResultError)
+		output.Ferror(w, "%s", errkind.Prefix(errkind.Config, fmt.Sprintf("unsupported language %q: %v", h.Language, err)))
+		report("error", -1, matchedFiles, []byte(err.Error()))
+		res.Errors++
+		return shouldFailFast(r.cfg, h)
+	}
+
+	// A hook whose language runtime is missing fails on its own with a
+	// clear message; other hooks still run. Languages without environments
+	// are only checked now, as they have nothing to install beforehand.
+	var runtimeErr error
+	if st.opts.MissingRuntimes != nil {
+		runtimeErr = st.opts.MissingRuntimes[h.InstallKey()]
+	}
+	if runtimeErr == nil && lang.EnvironmentDir() == "" {
+		runtimeErr = languages.CheckRuntime(lang, h.LanguageVersion)
+	}
+	if runtimeErr != nil {
+		output.FprintHookHeader(w, h.Name, output.ResultError)
+		output.Ferror(w, "%s", errkind.Prefix(errkind.Env, runtimeErr.Error()))
+		report("error", -1, matchedFiles, []byte(runtimeErr.Error()))
+		res.Errors++
+		return shouldFailFast(r.cfg, h)
+	}
+
+	// Handle meta hooks specially.
+	if h.ID == "check-hooks-apply" || h.ID == "check-useless-excludes" {
+		metaExit, metaOut := r.runMetaHook(h, st.files)
+		if metaExit != 0 {
+			output.FprintHookHeader(w, h.Name, output.ResultFailed)
+			output.FprintHookOutput(w, metaOut, h.ID, metaExit, true)
+			report("failed", metaExit, matchedFiles, metaOut)
+			res.Failed++
+		} else {
+			output.FprintHookHeader(w, h.Name, output.ResultPassed)
+			report("passed", 0, matchedFiles, metaOut)
+		}
+		return false
+	}
+
+	// Determine file args to pass.
+	var fileArgs []string
+	if h.PassFilenames {
+		fileArgs = matchedFiles
+	}
 
-			if shouldFailFast(r.cfg, h) {
-				return result
+	// Capture file state before running hook (for modification detection).
+	var fpBefore map[string]fileFingerprint
+	var contentBefore map[string][]byte
+	if !st.opts.AllFiles {
+		fpBefore = fingerprintFiles(fileArgs)
+		if st.opts.DetectNoopChurn {
+			contentBefore = snapshotFiles(fileArgs)
+		}
+	}
+
+	// Append one-off args from --hook-args to a copy of the hook.
+	runHook := h
+	if len(st.opts.HookArgs) > 0 {
+		hc := *h
+		hc.Args = append(append([]string{}, h.Args...), st.opts.HookArgs...)
+		runHook = &hc
+	}
+	// A local script is a path relative to the repo root, wherever the
+	// hook runs from.
+	if h.RepoDir == "" && lang.Name() == "unsupported_script" {
+		hc := *runHook
+		hc.RepoDir = r.root
+		runHook = &hc
+	}
+
+	// A working_directory hook runs from there and gets filenames
+	// relative to it.
+	hookDir, runArgs := r.root, fileArgs
+	if h.WorkingDirectory != "" {
+		hookDir, err = r.workingDirectory(h)
+		if err == nil {
+			runArgs = relativeTo(r.root, hookDir, fileArgs)
+		}
+	}
+
+	if st.opts.Verbose || h.Verbose {
+		printHookEnvironment(w, lang, h, st.opts.BuiltEnvironments[h.InstallKey()])
+	}
+
+	// Run the hook using xargs for batching.
+	var exitCode int
+	var hookOutput []byte
+	if err == nil {
+		hookCtx, jobs := ctx, st.opts.Jobs
+		if st.opts.StreamOutput {
+			hookCtx, jobs = languages.WithOutputStream(ctx, st.stderr()), 1
+		}
+		if limit := st.invocationLimit(h); limit != nil {
+			hookCtx = context.WithValue(hookCtx, invocationLimitKey{}, limit)
+		}
+		exitCode, hookOutput, err = runHookXargs(hookCtx, lang, runHook, runArgs, hookDir, jobs)
+	}
+	if err != nil {
+		output.FprintHookHeader(w, h.Name, output.ResultError)
+		kind := errkind.Of(err)
+		if kind == "" {
+			kind = errkind.Hook
+		}
+		output.Ferror(w, "%s", errkind.Prefix(kind, fmt.Sprintf("hook execution error: %v", err)))
+		report("error", -1, matchedFiles, []byte(err.Error()))
+		res.Errors++
+		return shouldFailFast(r.cfg, h)
+	}
+
+	// Archive the hook's combined output if configured.
+	if h.LogFile != "" {
+		if err := writeHookLog(r.root, h.LogFile, h.ID, hookOutput, time.Now()); err != nil {
+			output.WarnContext(ctx, "failed to write log_file for %s: %v", h.ID, err)
+		}
+	}
+	// Only the terminal copy is capped; log_file and annotations get
+	// the full output.
+	shownOutput := truncateOutput(hookOutput, st.opts.MaxOutputLines)
+	if st.opts.StreamOutput {
+		// Already shown as it was produced.
+		shownOutput = nil
+	}
+
+	// Detect if files were modified by the hook. A failing hook has already
+	// failed, so its changes are only needed for the churn hint.
+	var modified []string
+	if fpBefore != nil && (exitCode == 0 || contentBefore != nil) {
+		fpAfter := fingerprintFiles(fileArgs)
+		for f, before := range fpBefore {
+			if after, ok := fpAfter[f]; ok && (before.size != after.size || before.modTime != after.modTime) {
+				modified = append(modified, f)
 			}
+		}
+	}
+	filesModified := len(modified) > 0
+
+	verbose := st.opts.Verbose || h.Verbose
+	if exitCode != 0 || filesModified {
+		output.FprintHookHeader(w, h.Name, output.ResultFailed)
+		if verbose {
+			printHookCommand(w, runHook, runArgs, st.opts.ShowFullCommand)
+		}
+		if l, ok := w.(*hookLog); ok && st.opts.DedupOutput && len(shownOutput) > 0 {
+			l.holdFailure(h.ID, exitCode, shownOutput, verbose || st.opts.StreamOutput)
 		} else {
-			output.PrintHookHeader(h.Name, output.ResultPassed)
-			if opts.Verbose || h.Verbose {
-				output.PrintHookOutput(hookOutput, h.ID, exitCode, true)
+			output.FprintHookOutput(w, shownOutput, h.ID, exitCode, verbose || st.opts.StreamOutput)
+		}
+		report("failed", exitCode, matchedFiles, hookOutput)
+		res.Failed++
+
+		if st.opts.CollectAnnotations {
+			annotations, err := ParseAnnotations(h.ID, h.AnnotationRegex, hookOutput)
+			if err != nil {
+				output.WarnContext(ctx, "%v", err)
 			}
-			result.Passed++
+			res.Annotations = append(res.Annotations, annotations...)
+		}
+
+		if contentBefore != nil && filesModified && onlyWhitespaceChurn(contentBefore, modified) {
+			fmt.Fprintf(w, "Hint: %s only changed whitespace or line endings in %d file(s).\n", h.ID, len(modified))
+			fmt.Fprintln(w, "This usually means an .editorconfig, core.autocrlf or .gitattributes setting disagrees with the hook.")
+		}
+
+		if shouldFailFast(r.cfg, h) {
+			return true
+		}
+	} else {
+		output.FprintHookHeader(w, h.Name, output.ResultPassed)
+		if verbose {
+			printHookCommand(w, runHook, runArgs, st.opts.ShowFullCommand)
+			output.FprintHookOutput(w, shownOutput, h.ID, exitCode, true)
 		}
+		report("passed", exitCode, matchedFiles, hookOutput)
+		res.Passed++
 	}
 
-	return result
+	return false
+}
+
+// branchSkipReason returns why a hook limited to the given branch patterns
+// does not run on branch, or "" if one of the patterns matches. An empty
+// branch means HEAD is detached.
+func branchSkipReason(branch string, patterns []string) string {
+	if branch == "" {
+		return "HEAD is detached; hook only runs on branches " + strings.Join(patterns, ", ")
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, branch); ok {
+			return ""
+		}
+	}
+	return fmt.Sprintf("branch %q does not match %s", branch, strings.Join(patterns, ", "))
+}
+
+// workingDirectory returns the absolute directory h runs from, checking that
+// its working_directory is an existing directory inside the repo.
+func (r *Runner) workingDirectory(h *Hook) (string, error) {
+	rel := filepath.FromSlash(h.WorkingDirectory)
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(filepath.Clean(rel), ".."+string(filepath.Separator)) {
+		return "", errkind.Wrap(errkind.Config, fmt.Errorf("working_directory %q of hook %q must be relative to the repo root", h.WorkingDirectory, h.ID))
+	}
+	dir, err := filepath.Abs(filepath.Join(r.root, rel))
+	if err != nil {
+		return "", err
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return "", errkind.Wrap(errkind.Config, fmt.Errorf("working_directory %q of hook %q is not a directory", h.WorkingDirectory, h.ID))
+	}
+	return dir, nil
+}
+
+// relativeTo rewrites files, which are relative to root unless absolute, as
+// paths relative to dir.
+func relativeTo(root, dir string, files []string) []string {
+	if len(files) == 0 {
+		return files
+	}
+	rel := make([]string, len(files))
+	for i, f := range files {
+		abs := f
+		if !filepath.IsAbs(abs) {
+			abs = filepath.Join(root, f)
+		}
+		if p, err := filepath.Abs(abs); err == nil {
+			abs = p
+		}
+		rel[i] = f
+		if r, err := filepath.Rel(dir, abs); err == nil {
+			rel[i] = r
+		}
+	}
+	return rel
+}
+
+// truncateOutput keeps the last limit lines of out, where errors are usually
+// summarized, behind a marker saying how many lines were dropped. It
+// returns out unchanged if limit is not positive or out is short enough.
+func truncateOutput(out []byte, limit int) []byte {
+	if limit <= 0 {
+		return out
+	}
+	lines := bytes.SplitAfter(out, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= limit {
+		return out
+	}
+	dropped := len(lines) - limit
+	marker := fmt.Sprintf("... (truncated, %d more lines)\n", dropped)
+	return append([]byte(marker), bytes.Join(lines[dropped:], nil)...)
+}
+
+// printHookCommand echoes the command line h was run with for --verbose.
+func printHookCommand(w io.Writer, h *Hook, fileArgs []string, full bool) {
+	argv := append(languages.ParseEntry(h.Entry), h.Args...)
+	output.FprintCommand(w, append(argv, fileArgs...), len(fileArgs), full)
+}
+
+// printHookEnvironment prints, on one line, the language and version h
+// resolved to and the environment it runs in, and whether that environment
+// was just built or reused.
+func printHookEnvironment(w io.Writer, lang languages.Language, h *Hook, built bool) {
+	version := languages.ResolveVersion(lang, h.LanguageVersion)
+	if version == "" {
+		version = lang.GetDefaultVersion()
+	}
+	envPath := languages.EnvironmentPath(lang, h.RepoDir, h.LanguageVersion)
+	switch {
+	case envPath == "":
+		fmt.Fprintf(w, "- environment: %s: %s %s, no environment\n", h.ID, h.Language, version)
+	case built:
+		fmt.Fprintf(w, "- environment: %s: %s %s at %s (built)\n", h.ID, h.Language, version, envPath)
+	default:
+		fmt.Fprintf(w, "- environment: %s: %s %s at %s (reused)\n", h.ID, h.Language, version, envPath)
+	}
+}
+
+// runGlobalCommand runs a top-level pre_run/post_run command from the repo
+// root and reports whether it succeeded. Output is shown on w, like hook
+// output, and only on failure.
+func (r *Runner) runGlobalCommand(ctx context.Context, w io.Writer, name, command string) bool {
+	exitCode, out, err := languages.RunHookCommand(ctx, r.root, command, nil, nil, nil)
+	if err != nil {
+		output.FprintHookHeader(w, name, output.ResultError)
+		output.Ferror(w, "%s failed: %v", name, err)
+		return false
+	}
+	if exitCode != 0 {
+		output.FprintHookHeader(w, name, output.ResultFailed)
+		output.FprintHookOutput(w, out, name, exitCode, false)
+		return false
+	}
+	return true
 }
 
 // setEnvVars sets hook-stage-specific environment variables.
@@ -302,13 +944,55 @@ func (r *Runner) unsetEnvVars() {
 	}
 }
 
+// dumpHookEnv prints the sorted environment h would run with.
+func dumpHookEnv(ctx context.Context, h *Hook, root string) error {
+	lang, err := languages.Get(h.Language)
+	if err != nil {
+		return fmt.Errorf("unsupported language %q: %w", h.Language, err)
+	}
+	ctx, err = withSystemPath(ctx, lang, h, root)
+	if err != nil {
+		return err
+	}
+	env, ok := languages.HookEnv(ctx, lang, h.RepoDir, root, expandEntry(h.Entry, languages.EnvironmentPath(lang, h.RepoDir, h.LanguageVersion)), h.LanguageVersion)
+	if !ok {
+		return fmt.Errorf("hook %q (language: %s) runs in-process and has no command environment", h.ID, h.Language)
+	}
+	slices.Sort(env)
+	for _, kv := range env {
+		fmt.Println(kv)
+	}
+	return nil
+}
+
 // shouldFailFast checks whether execution should stop after a failure.
 func shouldFailFast(cfg *config.Config, h *Hook) bool {
 	return cfg.FailFast || h.FailFast
 }
 
+// normalizePath returns f as a clean, forward-slash path, treating
+// backslashes as separators.
+func normalizePath(f string) string {
+	return path.Clean(strings.ReplaceAll(filepath.ToSlash(f), `\`, "/"))
+}
+
+// matchPath returns the name files/exclude patterns are matched against:
+// f relative to root when f is an absolute path inside it, so patterns see
+// repo-relative paths however the files were given, normalized so that
+// Windows-style paths match the same patterns on every platform. Hooks
+// are still given f as it was.
+func matchPath(root, f string) string {
+	if root != "" && filepath.IsAbs(f) {
+		rel, err := filepath.Rel(root, filepath.FromSlash(f))
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			f = rel
+		}
+	}
+	return normalizePath(f)
+}
+
 // filterByIncludeExclude filters filenames using include/exclude regex patterns.
-func filterByIncludeExclude(names []string, include, exclude string) []string {
+func filterByIncludeExclude(root string, names []string, include, exclude string) []string {
 	var result []string
 	var includeRe, excludeRe *regexp2.Regexp
 	if include != "" {
@@ -318,10 +1002,11 @@ func filterByIncludeExclude(names []string, include, exclude string) []string {
 		excludeRe, _ = pcre.Compile(exclude)
 	}
 	for _, name := range names {
-		if includeRe != nil && !pcre.Match(includeRe, name) {
+		rel := matchPath(root, name)
+		if includeRe != nil && !pcre.Match(includeRe, rel) {
 			continue
 		}
-		if excludeRe != nil && pcre.Match(excludeRe, name) {
+		if excludeRe != nil && pcre.Match(excludeRe, rel) {
 			continue
 		}
 		result = append(result, name)
@@ -329,8 +1014,26 @@ func filterByIncludeExclude(names []string, include, exclude string) []string {
 	return result
 }
 
+// SelectHooks returns the hooks a run with opts would execute, filtering by
+// HookID and HookStage.
+func SelectHooks(hooks []*Hook, opts RunOptions) []*Hook {
+	var selected []*Hook
+	for _, h := range hooks {
+		if opts.HookID != "" && h.ID != opts.HookID && h.Alias != opts.HookID {
+			continue
+		}
+		if opts.HookStage != "" && !(opts.AnyStage && opts.HookID != "") && !h.MatchesStage(opts.HookStage) {
+			continue
+		}
+		selected = append(selected, h)
+	}
+	return selected
+}
+
 // filterFiles filters files based on hook include/exclude patterns and type filters.
-func filterFiles(files []string, h *Hook) []string {
+// types classifies the files for the hook's type filters; nil classifies
+// each from its contents.
+func filterFiles(root string, files []string, h *Hook, types *fileTypes) []string {
 	var matched []string
 
 	var includeRe, excludeRe *regexp2.Regexp
@@ -349,16 +1052,17 @@ func filterFiles(files []string, h *Hook) []string {
 		if _, err := os.Lstat(f); err != nil {
 			continue
 		}
+		rel := matchPath(root, f)
 		// Check include pattern.
-		if includeRe != nil && !pcre.Match(includeRe, f) {
+		if includeRe != nil && !pcre.Match(includeRe, rel) {
 			continue
 		}
 		// Check exclude pattern.
-		if excludeRe != nil && pcre.Match(excludeRe, f) {
+		if excludeRe != nil && pcre.Match(excludeRe, rel) {
 			continue
 		}
 		// Check types.
-		tags := identify.TagsForFile(f)
+		tags := types.tags(f)
 		if !identify.MatchesTypes(tags, h.Types, h.TypesOr, h.ExcludeTypes) {
 			continue
 		}
@@ -368,12 +1072,59 @@ func filterFiles(files []string, h *Hook) []string {
 	return matched
 }
 
+// envPlaceholder in a hook's entry is replaced by its environment directory.
+const envPlaceholder = "${PRE_COMMIT_ENV}"
+
+// expandEntry substitutes envPath for envPlaceholder in entry, so a hook can
+// name a tool inside its own environment (e.g. ${PRE_COMMIT_ENV}/bin/tool).
+func expandEntry(entry, envPath string) string {
+	return strings.ReplaceAll(entry, envPlaceholder, envPath)
+}
+
+// withSystemPath returns ctx carrying the PATH directories a system hook
+// names via "path:" additional_dependencies. Other languages install their
+// dependencies instead, so ctx is returned unchanged for them.
+func withSystemPath(ctx context.Context, lang languages.Language, h *Hook, workDir string) (context.Context, error) {
+	if lang.Name() != "unsupported" || len(h.AdditionalDependencies) == 0 {
+		return ctx, nil
+	}
+	dirs, err := languages.SystemPathDirs(h.AdditionalDependencies, workDir)
+	if err != nil {
+		return ctx, errkind.Wrap(errkind.Config, fmt.Errorf("hook %q: %w", h.ID, err))
+	}
+	return languages.WithPathDirs(ctx, dirs), nil
+}
+
 // runHookXargs runs a hook using xargs-style batching and concurrency.
 // All execution goes through lang.Run to ensure language-specific environment
 // setup (e.g. virtualenv PATH for Python hooks).
 func runHookXargs(ctx context.Context, lang languages.Language, h *Hook, fileArgs []string, workDir string, jobs int) (int, []byte, error) {
+	ctx, err := withSystemPath(ctx, lang, h, workDir)
+	if err != nil {
+		return -1, nil, err
+	}
+	entry := expandEntry(h.Entry, languages.EnvironmentPath(lang, h.RepoDir, h.LanguageVersion))
+
+	// A hook with a concurrency waits for a free slot before each
+	// invocation, whichever hook in the run holds the others.
+	limit, _ := ctx.Value(invocationLimitKey{}).(chan struct{})
+	run := func(files []string) (int, []byte, error) {
+		if limit != nil {
+			select {
+			case limit <- struct{}{}:
+				defer func() { <-limit }()
+			case <-ctx.Done():
+				return -1, nil, ctx.Err()
+			}
+		}
+		return lang.Run(ctx, h.RepoDir, workDir, entry, h.Args, files, h.LanguageVersion)
+	}
 	if len(fileArgs) == 0 {
-		return lang.Run(ctx, h.RepoDir, workDir, h.Entry, h.Args, nil, h.LanguageVersion)
+		return run(nil)
+	}
+	// A fail hook's message names all its files, so it must be printed once.
+	if lang.Name() == "fail" {
+		return run(fileArgs)
 	}
 
 	// Determine batch size and concurrency.
@@ -381,9 +1132,12 @@ func runHookXargs(ctx context.Context, lang languages.Language, h *Hook, fileArg
 	if !h.RequireSerial {
 		maxJobs = targetConcurrency(jobs, len(fileArgs))
 	}
+	if h.Concurrency > 0 {
+		maxJobs = min(maxJobs, h.Concurrency)
+	}
 
 	// Batch the file arguments.
-	batches := batchFileArgs(fileArgs, xargs.DefaultMaxBatchSize())
+	batches := batchFileArgs(fileArgs, fileBatchSize())
 
 	type batchResult struct {
 		exitCode int
@@ -396,7 +1150,7 @@ func runHookXargs(ctx context.Context, lang languages.Language, h *Hook, fileArg
 	if maxJobs <= 1 || len(batches) <= 1 {
 		// Sequential execution.
 		for i, batch := range batches {
-			exitCode, out, err := lang.Run(ctx, h.RepoDir, workDir, h.Entry, h.Args, batch, h.LanguageVersion)
+			exitCode, out, err := run(batch)
 			results[i] = batchResult{exitCode: exitCode, output: out, err: err}
 		}
 	} else {
@@ -409,7 +1163,7 @@ func runHookXargs(ctx context.Context, lang languages.Language, h *Hook, fileArg
 				defer wg.Done()
 				sem <- struct{}{}
 				defer func() { <-sem }()
-				exitCode, out, err := lang.Run(ctx, h.RepoDir, workDir, h.Entry, h.Args, files, h.LanguageVersion)
+				exitCode, out, err := run(files)
 				results[idx] = batchResult{exitCode: exitCode, output: out, err: err}
 			}(i, batch)
 		}
@@ -432,6 +1186,10 @@ func runHookXargs(ctx context.Context, lang languages.Language, h *Hook, fileArg
 	return exitCode, allOutput, nil
 }
 
+// fileBatchSize returns how many files one invocation is given; tests
+// replace it to split files into several batches.
+var fileBatchSize = xargs.DefaultMaxBatchSize
+
 // batchFileArgs splits file arguments into batches.
 func batchFileArgs(files []string, maxBatchSize int) [][]string {
 	if maxBatchSize <= 0 || len(files) <= maxBatchSize {
@@ -498,6 +1256,68 @@ func fingerprintFiles(files []string) map[string]fileFingerprint {
 	return fps
 }
 
+// writeHookLog writes a hook's output to its log_file, truncating any
+// previous contents. Relative paths are resolved against the repo root and
+// "{hook_id}" and "{timestamp}" in the path are substituted.
+func writeHookLog(root, logFile, hookID string, data []byte, now time.Time) error {
+	path := strings.NewReplacer(
+		"{hook_id}", hookID,
+		"{timestamp}", now.Format("20060102T150405"),
+	).Replace(logFile)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// snapshotFiles reads the contents of files so changes made by a hook can be
+// inspected afterwards. Unreadable files are omitted.
+func snapshotFiles(files []string) map[string][]byte {
+	contents := make(map[string][]byte, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		contents[f] = data
+	}
+	return contents
+}
+
+// onlyWhitespaceChurn reports whether every modified file differs from its
+// snapshot only in line endings or trailing whitespace.
+func onlyWhitespaceChurn(before map[string][]byte, modified []string) bool {
+	for _, f := range modified {
+		old, ok := before[f]
+		if !ok {
+			return false
+		}
+		cur, err := os.ReadFile(f)
+		if err != nil {
+			return false
+		}
+		if normalizeWhitespace(old) != normalizeWhitespace(cur) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeWhitespace converts CRLF/CR line endings to LF and strips trailing
+// whitespace from every line.
+func normalizeWhitespace(data []byte) string {
+	s := strings.ReplaceAll(string(data), "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
 // checkMinVersion checks if the current version meets the minimum requirement.
 func checkMinVersion(minVersion string) bool {
 	current := parseVersionParts(config.Version)
@@ -536,20 +1356,62 @@ func parseVersionParts(v string) []int {
 	return parts
 }
 
-// installStateFile is the filename used to track installed environment state.
-// This matches Python pre-commit's install_state tracking to avoid unnecessary
-// reinstalls and to detect when dependencies have changed.
-const installStateFile = "install_state_v2"
-
 // installTask represents a single environment install job.
 type installTask struct {
 	hook *Hook
 	lang languages.Language
 }
 
-// InstallEnvironments installs environments for all provided hooks.
-// Installs are run in parallel since each operates on a separate directory.
+// InstallFailure records an environment that failed to install.
+type InstallFailure struct {
+	Hook *Hook
+	Err  error
+}
+
+// InstallSummary reports the outcome of every environment install attempted
+// by InstallEnvironmentsSummary. Environments that were already installed
+// appear in neither list.
+type InstallSummary struct {
+	Installed []*Hook
+	Failed    []InstallFailure
+}
+
+// MissingRuntimes splits the failures caused by a language runtime not
+// being installed, keyed by InstallKey for RunOptions.MissingRuntimes, from
+// the rest. Only those hooks need fail; the others can still run.
+func (s InstallSummary) MissingRuntimes() (map[string]error, []InstallFailure) {
+	var missing map[string]error
+	var rest []InstallFailure
+	for _, f := range s.Failed {
+		var rm *languages.RuntimeMissingError
+		if !errors.As(f.Err, &rm) {
+			rest = append(rest, f)
+			continue
+		}
+		if missing == nil {
+			missing = make(map[string]error)
+		}
+		missing[f.Hook.InstallKey()] = f.Err
+	}
+	return missing, rest
+}
+
+// InstallEnvironments installs environments for all provided hooks and
+// returns the first failure, if any. Every install is attempted regardless.
 func InstallEnvironments(ctx context.Context, hooks []*Hook) error {
+	summary := InstallEnvironmentsSummary(ctx, hooks)
+	if len(summary.Failed) > 0 {
+		return summary.Failed[0].Err
+	}
+	return nil
+}
+
+// InstallEnvironmentsSummary installs environments for all provided hooks,
+// attempting each one even if others fail, and reports what happened.
+// Installs are run in parallel since each operates on a separate directory.
+func InstallEnvironmentsSummary(ctx context.Context, hooks []*Hook) InstallSummary {
+	var summary InstallSummary
+
 	// Deduplicate and filter to only hooks that need installation.
 	seen := make(map[string]bool)
 	var tasks []installTask
@@ -563,35 +1425,57 @@ func InstallEnvironments(ctx context.Context, hooks []*Hook) error {
 
 		lang, err := languages.Get(h.Language)
 		if err != nil {
-			return fmt.Errorf("unsupported language %q for hook %q: %w", h.Language, h.ID, err)
+			summary.Failed = append(summary.Failed, InstallFailure{
+				Hook: h,
+				Err:  errkind.Wrap(errkind.Config, fmt.Errorf("unsupported language %q for hook %q: %w", h.Language, h.ID, err)),
+			})
+			continue
 		}
 
 		if lang.EnvironmentDir() == "" {
 			continue
 		}
 
-		envDir := h.RepoDir
-		if envDir == "" {
+		if h.RepoDir == "" {
 			continue
 		}
 
-		stateFile := filepath.Join(envDir, lang.EnvironmentDir(), installStateFile)
-		expectedState := h.InstallKey()
-
-		if data, err := os.ReadFile(stateFile); err == nil {
-			if string(data) == expectedState {
-				continue // Already installed with same deps.
-			}
-			// State mismatch — deps changed, need reinstall.
-			envPath := filepath.Join(envDir, lang.EnvironmentDir())
-			os.RemoveAll(envPath)
+		envPath := envStatePath(lang, h)
+		state, ok := readInstallState(envPath, h)
+		if output.DebugEnabled() {
+			output.Debug("%s: %s", h.ID, explainInstallState(lang, envPath, h, state, ok))
 		}
+		if ok && state == h.InstallKey() {
+			continue // Already installed with same deps.
+		}
+		if err := languages.CheckRuntime(lang, h.LanguageVersion); err != nil {
+			summary.Failed = append(summary.Failed, InstallFailure{Hook: h, Err: errkind.Wrap(errkind.Env, err)})
+			continue
+		}
+		// Deps changed, or an earlier install never finished: rebuild from
+		// scratch so no dependency dropped from the config lingers.
+		os.RemoveAll(envPath)
 
 		tasks = append(tasks, installTask{hook: h, lang: lang})
 	}
 
 	if len(tasks) == 0 {
-		return nil
+		return summary
+	}
+
+	// Builds that run out of space part way leave corrupt environments
+	// behind. Low space is only a warning unless PRE_COMMIT_MIN_FREE_SPACE
+	// sets a minimum, in which case builds are refused below it.
+	if err := store.CheckFreeSpace(tasks[0].hook.RepoDir, diskFree); err != nil {
+		var low *store.LowSpaceError
+		if errors.As(err, &low) && os.Getenv("PRE_COMMIT_MIN_FREE_SPACE") == "" {
+			output.WarnContext(ctx, "Only %d MiB free in %s; building hook environments may fail part way.", low.Available>>20, low.Dir)
+		} else {
+			for _, t := range tasks {
+				summary.Failed = append(summary.Failed, InstallFailure{Hook: t.hook, Err: errkind.Wrap(errkind.Env, err)})
+			}
+			return summary
+		}
 	}
 
 	// Run installs in parallel with bounded concurrency.
@@ -608,9 +1492,9 @@ func InstallEnvironments(ctx context.Context, hooks []*Hook) error {
 	errs := make([]error, len(tasks))
 
 	for i, task := range tasks {
-		output.Info("Installing environment for %s.", task.hook.Repo)
-		output.Info("Once installed this environment will be reused.")
-		output.Info("This may take a few minutes...")
+		output.InfoContext(ctx, "Installing environment for %s.", task.hook.Repo)
+		output.InfoContext(ctx, "Once installed this environment will be reused.")
+		output.InfoContext(ctx, "This may take a few minutes...")
 
 		wg.Add(1)
 		go func(idx int, t installTask) {
@@ -618,44 +1502,53 @@ func InstallEnvironments(ctx context.Context, hooks []*Hook) error {
 			sem <- struct{}{}
 			defer func() { <-sem }()
 
-			if err := t.lang.InstallEnvironment(t.hook.RepoDir, t.hook.LanguageVersion, t.hook.AdditionalDependencies); err != nil {
-				envPath := filepath.Join(t.hook.RepoDir, t.lang.EnvironmentDir())
-				os.RemoveAll(envPath)
-				errs[idx] = fmt.Errorf("failed to install environment for hook %q: %w", t.hook.ID, err)
+			if err := languages.InstallEnvironmentFile(t.lang, t.hook.RepoDir, t.hook.LanguageVersion, t.hook.CondaEnvFile, t.hook.AdditionalDependencies); err != nil {
+				os.RemoveAll(envStatePath(t.lang, t.hook))
+				errs[idx] = errkind.Wrap(errkind.Env, fmt.Errorf("failed to install environment for hook %q: %w", t.hook.ID, err))
 				return
 			}
 
 			// Write install state file.
-			stateFile := filepath.Join(t.hook.RepoDir, t.lang.EnvironmentDir(), installStateFile)
-			stateDir := filepath.Dir(stateFile)
-			os.MkdirAll(stateDir, 0o755)
-			if err := os.WriteFile(stateFile, []byte(t.hook.InstallKey()), 0o644); err != nil {
-				output.Warn("Failed to write install state: %v", err)
+			if err := writeInstallState(envStatePath(t.lang, t.hook), t.hook.InstallKey()); err != nil {
+				output.WarnContext(ctx, "Failed to write install state: %v", err)
 			}
 		}(i, task)
 	}
 
 	wg.Wait()
 
-	// Return the first error encountered.
-	for _, err := range errs {
+	for i, err := range errs {
 		if err != nil {
-			return err
+			summary.Failed = append(summary.Failed, InstallFailure{Hook: tasks[i].hook, Err: err})
+		} else {
+			summary.Installed = append(summary.Installed, tasks[i].hook)
 		}
 	}
-	return nil
+	return summary
 }
 
-// ShowDiffOnFailure runs git diff to show changes made by hooks.
-func ShowDiffOnFailure(allFiles bool) {
-	useColor := "never"
-	if output.UseColor() {
-		useColor = "always"
+// diskFree measures free space before installs; tests replace it to
+// simulate a full disk.
+var diskFree store.FreeSpaceFunc = store.FreeSpace
+
+// ChangedDiff returns the working tree diff if hooks changed it, given the
+// diff taken before they ran, and "" if they modified nothing.
+func ChangedDiff(before string) (string, error) {
+	after, err := WorkingTreeDiff()
+	if err != nil || after == before {
+		return "", err
 	}
-	cmd := exec.Command("git", "--no-pager", "diff", "--no-ext-diff", "--color="+useColor)
-	cmd.Stdout = os.Stderr
-	cmd.Stderr = os.Stderr
-	_ = cmd.Run()
+	return after, nil
+}
+
+// ShowDiffOnFailure prints diff, the changes made by hooks, to stderr,
+// colorized when color output is enabled. Binary files show up as a
+// "Binary files ... differ" line rather than their contents.
+func ShowDiffOnFailure(diff string, allFiles bool) {
+	if diff == "" {
+		return
+	}
+	fmt.Fprint(os.Stderr, output.ColorizeDiff(diff))
 
 	if allFiles {
 		fmt.Fprintln(os.Stderr, "")
@@ -663,6 +1556,24 @@ func ShowDiffOnFailure(allFiles bool) {
 	}
 }
 
+// WritePatchFile writes diff, the changes made by hooks, to path as a plain
+// unified diff that can be applied with `git apply`. Color is never used.
+func WritePatchFile(path, diff string) error {
+	return os.WriteFile(path, []byte(diff), 0o644)
+}
+
+// WorkingTreeDiff returns the uncolored diff of unstaged changes.
+func WorkingTreeDiff() (string, error) {
+	cmd := exec.Command("git", "--no-pager", "diff", "--no-ext-diff", "--no-color")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git diff failed: %w\nstderr: %s", err, stderr.String())
+	}
+	return stdout.String(), nil
+}
+
 // runMetaHook performs meta hook checks (check-hooks-apply, check-useless-excludes).
 func (r *Runner) runMetaHook(metaHook *Hook, allFiles []string) (int, []byte) {
 	switch metaHook.ID {
@@ -679,6 +1590,7 @@ func (r *Runner) runMetaHook(metaHook *Hook, allFiles []string) (int, []byte) {
 func (r *Runner) checkHooksApply(allFiles []string) (int, []byte) {
 	var msgs []string
 	exitCode := 0
+	binaryAttrs, _ := git.BinaryAttributes(allFiles)
 
 	for _, h := range r.hooks {
 		// Skip meta hooks themselves.
@@ -689,7 +1601,7 @@ func (r *Runner) checkHooksApply(allFiles []string) (int, []byte) {
 		if h.AlwaysRun {
 			continue
 		}
-		matched := filterFiles(allFiles, h)
+		matched := filterFiles(r.root, allFiles, h, &fileTypes{binaryAttrs: binaryAttrs})
 		if len(matched) == 0 {
 			msgs = append(msgs, fmt.Sprintf("%s does not apply to this repository", h.ID))
 			exitCode = 1
@@ -706,14 +1618,15 @@ func (r *Runner) checkHooksApply(allFiles []string) (int, []byte) {
 func (r *Runner) checkUselessExcludes(allFiles []string) (int, []byte) {
 	var msgs []string
 	exitCode := 0
+	binaryAttrs, _ := git.BinaryAttributes(allFiles)
 
 	// Check top-level exclude.
 	if r.cfg.Exclude != "" && r.cfg.Exclude != "^$" {
-		excludeRe, err := pcre.Compile(r.cfg.Exclude)
+		excludeRe, err := pcre.Compile(pcre.CaseInsensitive(r.cfg.Exclude, r.cfg.FilesCaseInsensitive))
 		if err == nil {
 			matched := false
 			for _, f := range allFiles {
-				if pcre.Match(excludeRe, f) {
+				if pcre.Match(excludeRe, matchPath(r.root, f)) {
 					matched = true
 					break
 				}
@@ -737,10 +1650,10 @@ func (r *Runner) checkUselessExcludes(allFiles []string) (int, []byte) {
 		var included []string
 		includeRe, _ := pcre.Compile(h.Files)
 		for _, f := range allFiles {
-			if includeRe != nil && !pcre.Match(includeRe, f) {
+			if includeRe != nil && !pcre.Match(includeRe, matchPath(r.root, f)) {
 				continue
 			}
-			tags := identify.TagsForFile(f)
+			tags := tagsForFile(f, binaryAttrs)
 			if !identify.MatchesTypes(tags, h.Types, h.TypesOr, h.ExcludeTypes) {
 				continue
 			}
@@ -754,7 +1667,7 @@ func (r *Runner) checkUselessExcludes(allFiles []string) (int, []byte) {
 		}
 		matched := false
 		for _, f := range included {
-			if pcre.Match(excludeRe, f) {
+			if pcre.Match(excludeRe, matchPath(r.root, f)) {
 				matched = true
 				break
 			}
@@ -1,12 +1,23 @@
 package hook
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
+	"github.com/blairham/go-pre-commit/v4/internal/output"
 )
 
 // ---------------------------------------------------------------------------
@@ -51,7 +62,7 @@ func TestFilterByIncludeExclude(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := filterByIncludeExclude(files, tt.include, tt.exclude)
+			got := filterByIncludeExclude("", files, tt.include, tt.exclude)
 			if len(got) != len(tt.want) {
 				t.Fatalf("got %v, want %v", got, tt.want)
 			}
@@ -86,7 +97,7 @@ func TestFilterFiles(t *testing.T) {
 
 	t.Run("include pattern filters", func(t *testing.T) {
 		h := &Hook{Files: `\.go$`, Types: []string{"file"}}
-		got := filterFiles(files, h)
+		got := filterFiles("", files, h, nil)
 		if len(got) != 2 {
 			t.Fatalf("expected 2 files, got %d: %v", len(got), got)
 		}
@@ -94,7 +105,7 @@ func TestFilterFiles(t *testing.T) {
 
 	t.Run("exclude pattern filters", func(t *testing.T) {
 		h := &Hook{Files: `\.go$`, Exclude: `_test\.go$`, Types: []string{"file"}}
-		got := filterFiles(files, h)
+		got := filterFiles("", files, h, nil)
 		if len(got) != 1 {
 			t.Fatalf("expected 1 file, got %d: %v", len(got), got)
 		}
@@ -102,7 +113,7 @@ func TestFilterFiles(t *testing.T) {
 
 	t.Run("no patterns matches all", func(t *testing.T) {
 		h := &Hook{Types: []string{"file"}}
-		got := filterFiles(files, h)
+		got := filterFiles("", files, h, nil)
 		if len(got) != 3 {
 			t.Fatalf("expected 3 files, got %d: %v", len(got), got)
 		}
@@ -592,6 +603,311 @@ func TestRunnerRun_FileModificationDetected(t *testing.T) {
 	}
 }
 
+func TestInstallEnvironments_ReorderedDepsReuseEnv(t *testing.T) {
+	repoDir := t.TempDir()
+	installed := &Hook{
+		ID: "lint", Language: "golang", LanguageVersion: "default", RepoDir: repoDir,
+		AdditionalDependencies: []string{"example.com/a@v1", "example.com/b@v1"},
+	}
+	stateDir := filepath.Join(repoDir, "go_env-default")
+	os.MkdirAll(stateDir, 0o755)
+	os.WriteFile(filepath.Join(stateDir, installStateFile), []byte(installed.InstallKey()), 0o644)
+
+	// Same deps in a different order must not trigger a reinstall (which
+	// would fail here since the repo has nothing to build).
+	reordered := &Hook{
+		ID: "lint", Language: "golang", LanguageVersion: "default", RepoDir: repoDir,
+		AdditionalDependencies: []string{"example.com/b@v1", "example.com/a@v1"},
+	}
+	if err := InstallEnvironments(context.Background(), []*Hook{reordered}); err != nil {
+		t.Fatalf("expected existing environment to be reused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(stateDir, installStateFile)); err != nil {
+		t.Errorf("expected install state to be kept: %v", err)
+	}
+}
+
+func TestRunnerRun_PreAndPostRun(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "order.log")
+	record := func(word string) string {
+		return "sh -c 'echo " + word + " >> " + logPath + "'"
+	}
+
+	cfg := &config.Config{
+		PreRun:  record("pre"),
+		PostRun: record("post"),
+	}
+	hooks := []*Hook{
+		{ID: "a", Name: "A", Language: "system", Entry: record("a"), AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+		{ID: "b", Name: "B", Language: "system", Entry: "sh -c 'echo b >> " + logPath + "; exit 1'", AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+	}
+
+	runner := NewRunner(cfg, hooks, dir)
+	result := runner.Run(context.Background(), RunOptions{HookStage: config.HookTypePreCommit})
+	if result.Passed != 1 || result.Failed != 1 || result.Errors != 0 {
+		t.Fatalf("result = %+v, want 1 passed and 1 failed", result)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Fields(string(data)), []string{"pre", "a", "b", "post"}; !slices.Equal(got, want) {
+		t.Errorf("run order = %v, want %v", got, want)
+	}
+}
+
+func TestRunnerRun_PreRunFailureStopsHooks(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "order.log")
+
+	cfg := &config.Config{
+		PreRun:  "sh -c 'echo setup broke; exit 1'",
+		PostRun: "sh -c 'echo post >> " + logPath + "'",
+	}
+	hooks := []*Hook{{
+		ID: "a", Name: "A", Language: "system",
+		Entry: "sh -c 'echo a >> " + logPath + "'", AlwaysRun: true,
+		Stages: []config.Stage{config.HookTypePreCommit},
+	}}
+
+	var out strings.Builder
+	var result RunResult
+	stderr := captureStderr(t, func() {
+		runner := NewRunner(cfg, hooks, dir)
+		result = runner.Run(context.Background(), RunOptions{HookStage: config.HookTypePreCommit, Output: &out})
+	})
+	if result.Errors != 1 || result.Passed != 0 {
+		t.Fatalf("result = %+v, want 1 error and no hooks run", result)
+	}
+	if !strings.Contains(out.String(), "pre_run") || !strings.Contains(out.String(), "setup broke") {
+		t.Errorf("expected the pre_run failure on Output, got:\n%s", out.String())
+	}
+	if stderr != "" {
+		t.Errorf("pre_run output bypassed Output:\n%s", stderr)
+	}
+
+	data, _ := os.ReadFile(logPath)
+	if got := strings.Fields(string(data)); !slices.Equal(got, []string{"post"}) {
+		t.Errorf("run order = %v, want only post_run", got)
+	}
+}
+
+// captureStderr redirects os.Stderr while fn runs and returns what was written.
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stderr
+	os.Stderr = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	os.Stderr = old
+	return <-done
+}
+
+func TestRunnerRun_DetectNoopChurn(t *testing.T) {
+	// Fixers usually exit non-zero when they rewrite a file.
+	for _, exit := range []string{"0", "1"} {
+		t.Run("exit "+exit, func(t *testing.T) {
+			dir := t.TempDir()
+			f := filepath.Join(dir, "crlf.txt")
+			os.WriteFile(f, []byte("line one\nline two\n"), 0o644)
+
+			cfg := &config.Config{}
+			hooks := []*Hook{{
+				ID: "crlf", Name: "CRLF", Language: "system",
+				Entry:         `sh -c 'printf "line one\r\nline two\r\n" > "$1"; exit ` + exit + `' --`,
+				Types:         []string{"file"},
+				PassFilenames: true,
+				Stages:        []config.Stage{config.HookTypePreCommit},
+			}}
+
+			var result RunResult
+			stderr := captureStderr(t, func() {
+				runner := NewRunner(cfg, hooks, dir)
+				result = runner.Run(context.Background(), RunOptions{
+					Files:           []string{f},
+					HookStage:       config.HookTypePreCommit,
+					DetectNoopChurn: true,
+				})
+			})
+
+			if result.Failed != 1 {
+				t.Errorf("Failed = %d, want 1 (file was modified)", result.Failed)
+			}
+			if !strings.Contains(stderr, "only changed whitespace or line endings") {
+				t.Errorf("expected churn hint, got stderr:\n%s", stderr)
+			}
+		})
+	}
+}
+
+func TestRunnerRun_PerHookVerbose(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	hooks := []*Hook{
+		{
+			ID: "loud", Name: "Loud", Language: "system",
+			Entry: "echo loud-summary", AlwaysRun: true, Verbose: true,
+			Stages: []config.Stage{config.HookTypePreCommit},
+		},
+		{
+			ID: "quiet", Name: "Quiet", Language: "system",
+			Entry: "echo quiet-summary", AlwaysRun: true,
+			Stages: []config.Stage{config.HookTypePreCommit},
+		},
+	}
+
+	var result RunResult
+	stderr := captureStderr(t, func() {
+		runner := NewRunner(cfg, hooks, dir)
+		result = runner.Run(context.Background(), RunOptions{
+			HookStage: config.HookTypePreCommit,
+		})
+	})
+
+	if result.Passed != 2 {
+		t.Fatalf("Passed = %d, want 2", result.Passed)
+	}
+	if !strings.Contains(stderr, "loud-summary") {
+		t.Errorf("expected verbose hook output on success, got:\n%s", stderr)
+	}
+	if strings.Contains(stderr, "quiet-summary") {
+		t.Errorf("expected non-verbose hook to stay quiet, got:\n%s", stderr)
+	}
+}
+
+func TestRunnerRun_LogFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	hooks := []*Hook{{
+		ID: "report", Name: "Report", Language: "system",
+		Entry:     "sh -c 'echo report-out; echo report-err >&2'",
+		AlwaysRun: true,
+		LogFile:   "logs/{hook_id}/{timestamp}.log",
+		Stages:    []config.Stage{config.HookTypePreCommit},
+	}}
+
+	runner := NewRunner(cfg, hooks, dir)
+	result := runner.Run(context.Background(), RunOptions{HookStage: config.HookTypePreCommit})
+	if result.Passed != 1 {
+		t.Fatalf("Passed = %d, want 1", result.Passed)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "logs", "report", "*.log"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one log file, got %v", matches)
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "report-out") || !strings.Contains(string(data), "report-err") {
+		t.Errorf("log file missing hook output, got %q", data)
+	}
+}
+
+func TestWriteHookLogTruncates(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := writeHookLog(dir, "out/{hook_id}-{timestamp}.log", "lint", []byte("first run output\n"), now); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeHookLog(dir, "out/{hook_id}-{timestamp}.log", "lint", []byte("second\n"), now); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "out", "lint-20240102T030405.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "second\n" {
+		t.Errorf("log file = %q, want %q", data, "second\n")
+	}
+}
+
+func TestOnlyWhitespaceChurn(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.txt")
+	before := map[string][]byte{f: []byte("a\nb\n")}
+
+	os.WriteFile(f, []byte("a  \r\nb\r\n"), 0o644)
+	if !onlyWhitespaceChurn(before, []string{f}) {
+		t.Error("expected whitespace-only change to be detected")
+	}
+
+	os.WriteFile(f, []byte("a\nc\n"), 0o644)
+	if onlyWhitespaceChurn(before, []string{f}) {
+		t.Error("expected content change not to be reported as churn")
+	}
+}
+
+func TestRunnerRun_HookArgsPosition(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "test.txt")
+	os.WriteFile(f, []byte("hello"), 0o644)
+	logPath := filepath.Join(t.TempDir(), "args.log")
+
+	cfg := &config.Config{}
+	hooks := []*Hook{{
+		ID: "record", Name: "Record", Language: "system",
+		Entry:         "sh -c 'echo \"$@\" > " + logPath + "' --",
+		Args:          []string{"--configured"},
+		Types:         []string{"file"},
+		PassFilenames: true,
+		Stages:        []config.Stage{config.HookTypePreCommit},
+	}}
+
+	runner := NewRunner(cfg, hooks, dir)
+	result := runner.Run(context.Background(), RunOptions{
+		Files:     []string{f},
+		HookID:    "record",
+		HookStage: config.HookTypePreCommit,
+		HookArgs:  []string{"-k", "test_foo"},
+	})
+	if result.Passed != 1 {
+		t.Fatalf("Passed = %d, want 1", result.Passed)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--configured -k test_foo " + f
+	if got := strings.TrimSpace(string(data)); got != want {
+		t.Errorf("hook args = %q, want %q", got, want)
+	}
+	if len(hooks[0].Args) != 1 {
+		t.Errorf("configured args were modified: %v", hooks[0].Args)
+	}
+}
+
+func TestRunnerRun_HookArgsRequiresSingleHook(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	hooks := []*Hook{
+		{ID: "a", Name: "A", Language: "system", Entry: "true", AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+		{ID: "b", Name: "B", Language: "system", Entry: "true", AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+	}
+
+	runner := NewRunner(cfg, hooks, dir)
+	result := runner.Run(context.Background(), RunOptions{
+		HookStage: config.HookTypePreCommit,
+		HookArgs:  []string{"--extra"},
+	})
+	if result.Errors != 1 || result.Passed != 0 {
+		t.Errorf("result = %+v, want a single error and no hooks run", result)
+	}
+}
+
 func TestRunnerRun_HookNotFound(t *testing.T) {
 	dir := t.TempDir()
 	cfg := &config.Config{}
@@ -606,3 +922,1128 @@ func TestRunnerRun_HookNotFound(t *testing.T) {
 		t.Errorf("Errors = %d, want 1", result.Errors)
 	}
 }
+
+func TestShowDiffOnFailureColorAndPatchFile(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("old\n"), 0o644)
+	git("add", "a.txt")
+	git("commit", "-qm", "init")
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("new\n"), 0o644)
+	t.Chdir(dir)
+
+	output.SetColorMode(output.ColorAlways)
+	defer output.SetColorMode(output.ColorAuto)
+
+	diff, err := ChangedDiff("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := captureStderr(t, func() { ShowDiffOnFailure(diff, false) })
+	if !strings.Contains(stderr, "\x1b[32m+new") || !strings.Contains(stderr, "\x1b[31m-old") {
+		t.Errorf("expected colorized diff, got:\n%q", stderr)
+	}
+
+	patch := filepath.Join(t.TempDir(), "fix.patch")
+	if err := WritePatchFile(patch, diff); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(patch)
+	if strings.Contains(string(data), "\x1b[") {
+		t.Errorf("patch file contains color codes:\n%q", data)
+	}
+	git("apply", "--check", "-R", patch)
+}
+
+func TestRunnerRun_GitattributesTextHint(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	// A generated, extensionless file with a NUL byte would be sniffed as binary.
+	os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte("generated text\nasset binary\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "generated"), []byte("data\x00more\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "asset"), []byte("looks like text\n"), 0o644)
+	t.Chdir(dir)
+
+	logPath := filepath.Join(t.TempDir(), "files.log")
+	hooks := []*Hook{{
+		ID: "text-only", Name: "text only", Language: "system",
+		Entry:         "sh -c 'printf \"%s\\n\" \"$@\" >> " + logPath + "' --",
+		Types:         []string{"text"},
+		PassFilenames: true,
+		Stages:        []config.Stage{config.HookTypePreCommit},
+	}}
+	runner := NewRunner(&config.Config{}, hooks, dir)
+	captureStderr(t, func() {
+		runner.Run(context.Background(), RunOptions{
+			Files:     []string{"generated", "asset"},
+			HookStage: config.HookTypePreCommit,
+		})
+	})
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("text hook did not run: %v", err)
+	}
+	if got := strings.Fields(string(data)); !slices.Equal(got, []string{"generated"}) {
+		t.Errorf("text hook ran on %v, want [generated]", got)
+	}
+}
+
+func TestRunnerRun_TrackedOnly(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(dir, "tracked.txt"), []byte("a\n"), 0o644)
+	git("add", "tracked.txt")
+	git("commit", "-qm", "init")
+	os.WriteFile(filepath.Join(dir, "untracked.txt"), []byte("generated\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "intent.txt"), []byte("generated\n"), 0o644)
+	git("add", "-N", "intent.txt")
+	t.Chdir(dir)
+
+	logDir := t.TempDir()
+	newHook := func(id string, trackedOnly bool) *Hook {
+		return &Hook{
+			ID: id, Name: id, Language: "system",
+			Entry:         "sh -c 'printf \"%s\\n\" \"$@\" >> " + filepath.Join(logDir, id) + "' --",
+			PassFilenames: true,
+			TrackedOnly:   trackedOnly,
+			Stages:        []config.Stage{config.HookTypePreCommit},
+		}
+	}
+	hooks := []*Hook{newHook("all", false), newHook("tracked", true)}
+	runner := NewRunner(&config.Config{}, hooks, dir)
+	captureStderr(t, func() {
+		runner.Run(context.Background(), RunOptions{
+			Files:     []string{"tracked.txt", "untracked.txt", "intent.txt"},
+			HookStage: config.HookTypePreCommit,
+		})
+	})
+
+	for id, want := range map[string][]string{
+		"all":     {"tracked.txt", "untracked.txt", "intent.txt"},
+		"tracked": {"tracked.txt"},
+	} {
+		data, err := os.ReadFile(filepath.Join(logDir, id))
+		if err != nil {
+			t.Fatalf("hook %s did not run: %v", id, err)
+		}
+		if got := strings.Fields(string(data)); !slices.Equal(got, want) {
+			t.Errorf("hook %s ran on %v, want %v", id, got, want)
+		}
+	}
+}
+
+// fakeLanguage stands in for a real language. Installing creates the
+// environment with an "ok" marker, which HealthCheck requires, and appends
+// the additional_dependencies to a "deps" file, so anything left over from
+// an earlier install shows up. Installs fail for repos holding a "broken"
+// file and skip the marker for repos holding an "unfixable" one. Run
+// reports the entry it was given as its output.
+type fakeLanguage struct {
+	name     string
+	installs *int // counts installs when set
+}
+
+func (l fakeLanguage) Name() string { return l.name }
+
+func (l fakeLanguage) EnvironmentDir() string { return l.name + "_env" }
+
+func (l fakeLanguage) GetDefaultVersion() string { return "default" }
+
+func (l fakeLanguage) HealthCheck(prefix, version string) error {
+	if _, err := os.Stat(filepath.Join(prefix, l.EnvironmentDir()+"-"+version, "ok")); err != nil {
+		return errors.New("ok marker missing")
+	}
+	return nil
+}
+
+func (l fakeLanguage) InstallEnvironment(prefix, version string, deps []string) error {
+	if _, err := os.Stat(filepath.Join(prefix, "broken")); err == nil {
+		return errors.New("toolchain not found")
+	}
+	if l.installs != nil {
+		*l.installs++
+	}
+	envDir := filepath.Join(prefix, l.EnvironmentDir()+"-"+version)
+	if err := os.MkdirAll(envDir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(envDir, "deps"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, dep := range deps {
+		fmt.Fprintln(f, dep)
+	}
+	if _, err := os.Stat(filepath.Join(prefix, "unfixable")); err == nil {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(envDir, "ok"), nil, 0o644)
+}
+
+func (l fakeLanguage) Run(_ context.Context, _, _, entry string, _, _ []string, _ string) (int, []byte, error) {
+	return 0, []byte(entry), nil
+}
+
+// registerLanguage registers lang for the duration of the test, restoring
+// whatever was registered under its name before.
+func registerLanguage(t *testing.T, lang languages.Language) {
+	t.Helper()
+	name := lang.Name()
+	orig, err := languages.Get(name)
+	languages.Register(name, lang)
+	t.Cleanup(func() {
+		if err != nil {
+			languages.Unregister(name)
+			return
+		}
+		languages.Register(name, orig)
+	})
+}
+
+func TestInstallEnvironmentsSummary_KeepsGoing(t *testing.T) {
+	registerLanguage(t, fakeLanguage{name: "installtest"})
+
+	var hooks []*Hook
+	for _, id := range []string{"good-1", "bad", "good-2", "good-3"} {
+		repoDir := t.TempDir()
+		if id == "bad" {
+			os.WriteFile(filepath.Join(repoDir, "broken"), nil, 0o644)
+		}
+		hooks = append(hooks, &Hook{ID: id, Repo: "https://example.com/" + id, Language: "installtest", LanguageVersion: "default", RepoDir: repoDir})
+	}
+
+	summary := InstallEnvironmentsSummary(context.Background(), hooks)
+
+	if len(summary.Installed) != 3 {
+		t.Errorf("Installed = %d hooks, want 3", len(summary.Installed))
+	}
+	for _, h := range summary.Installed {
+		if _, err := os.Stat(filepath.Join(h.RepoDir, "installtest_env-default", installStateFile)); err != nil {
+			t.Errorf("expected %s to be installed: %v", h.ID, err)
+		}
+	}
+	if len(summary.Failed) != 1 || summary.Failed[0].Hook.ID != "bad" {
+		t.Fatalf("Failed = %+v, want only the bad hook", summary.Failed)
+	}
+	if !strings.Contains(summary.Failed[0].Err.Error(), "toolchain not found") {
+		t.Errorf("unexpected failure error: %v", summary.Failed[0].Err)
+	}
+	if kind := errkind.Of(summary.Failed[0].Err); kind != errkind.Env {
+		t.Errorf("failure kind = %q, want %q", kind, errkind.Env)
+	}
+}
+
+func TestInstallEnvironmentsSummary_DefaultLanguageVersionSharesEnv(t *testing.T) {
+	registerLanguage(t, fakeLanguage{name: "installtest"})
+	repoDir := t.TempDir()
+	repoCfg := &config.RepoConfig{Repo: "https://example.com/hooks", Rev: "v1"}
+	globalCfg := &config.Config{DefaultLanguageVersion: map[string]string{"installtest": "3.12"}}
+	manifest := &config.ManifestHook{ID: "a", Name: "a", Entry: "a", Language: "installtest"}
+
+	inherited := MergeManifest(manifest, &config.HookConfig{ID: "a"}, repoCfg, globalCfg)
+	pinned := MergeManifest(manifest, &config.HookConfig{ID: "a", LanguageVersion: "3.12"}, repoCfg, globalCfg)
+	inherited.RepoDir, pinned.RepoDir = repoDir, repoDir
+
+	if inherited.InstallKey() != pinned.InstallKey() {
+		t.Fatalf("InstallKey differs: %q vs %q", inherited.InstallKey(), pinned.InstallKey())
+	}
+	summary := InstallEnvironmentsSummary(context.Background(), []*Hook{inherited, pinned})
+	if len(summary.Installed) != 1 || len(summary.Failed) != 0 {
+		t.Fatalf("summary = %+v, want exactly one environment installed", summary)
+	}
+	if _, err := os.Stat(filepath.Join(repoDir, "installtest_env-3.12", installStateFile)); err != nil {
+		t.Errorf("expected the shared environment in installtest_env-3.12: %v", err)
+	}
+	if missing := MissingEnvironments([]*Hook{inherited, pinned}); len(missing) != 0 {
+		t.Errorf("MissingEnvironments() = %v, want none", missing)
+	}
+}
+
+func TestRunHookXargs_ExpandsEnvPlaceholder(t *testing.T) {
+	repoDir := t.TempDir()
+	h := &Hook{
+		ID: "tool", Language: "echotest", LanguageVersion: "1.2",
+		Entry:   "${PRE_COMMIT_ENV}/bin/mytool --config ${PRE_COMMIT_ENV}/etc/tool.cfg",
+		RepoDir: repoDir,
+	}
+	_, out, err := runHookXargs(context.Background(), fakeLanguage{name: "echotest"}, h, nil, t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := filepath.Join(repoDir, "echotest_env-1.2")
+	want := env + "/bin/mytool --config " + env + "/etc/tool.cfg"
+	if string(out) != want {
+		t.Errorf("entry = %q, want %q", out, want)
+	}
+	if h.Entry != "${PRE_COMMIT_ENV}/bin/mytool --config ${PRE_COMMIT_ENV}/etc/tool.cfg" {
+		t.Error("runHookXargs must not modify the hook's entry")
+	}
+}
+
+func TestRunHookXargs_SystemPathDependency(t *testing.T) {
+	toolDir := t.TempDir()
+	tool := filepath.Join(toolDir, "only-in-tooldir")
+	if err := os.WriteFile(tool, []byte("#!/bin/sh\necho found\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	lang, err := languages.Get("system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Hook{ID: "tool", Language: "system", Entry: "only-in-tooldir"}
+
+	if code, _, err := runHookXargs(context.Background(), lang, h, nil, t.TempDir(), 1); err == nil && code == 0 {
+		t.Fatal("tool ran without its path: dependency")
+	}
+
+	h.AdditionalDependencies = []string{"path:" + toolDir}
+	code, out, err := runHookXargs(context.Background(), lang, h, nil, t.TempDir(), 1)
+	if err != nil || code != 0 {
+		t.Fatalf("code = %d, err = %v, output:\n%s", code, err, out)
+	}
+	if strings.TrimSpace(string(out)) != "found" {
+		t.Errorf("output = %q, want %q", out, "found")
+	}
+
+	h.AdditionalDependencies = []string{"path:" + filepath.Join(toolDir, "missing")}
+	if _, _, err := runHookXargs(context.Background(), lang, h, nil, t.TempDir(), 1); err == nil {
+		t.Error("expected an error for a path: dependency that is not a directory")
+	}
+}
+
+func TestRunnerRun_HookMinimumPreCommitVersion(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+	hooks := []*Hook{
+		{
+			ID: "too-new", Name: "Too new", Language: "system",
+			Entry: "touch " + marker, AlwaysRun: true,
+			MinimumPreCommitVersion: "999.0.0",
+			Stages:                  []config.Stage{config.HookTypePreCommit},
+		},
+		{
+			ID: "fine", Name: "Fine", Language: "system",
+			Entry: "true", AlwaysRun: true,
+			MinimumPreCommitVersion: "0.1.0",
+			Stages:                  []config.Stage{config.HookTypePreCommit},
+		},
+	}
+
+	var result RunResult
+	stderr := captureStderr(t, func() {
+		result = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			HookStage: config.HookTypePreCommit,
+		})
+	})
+
+	if result.Errors != 1 || result.Passed != 1 {
+		t.Errorf("result = %+v, want 1 error and 1 passed", result)
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("hook requiring a newer pre-commit must not run")
+	}
+	want := `hook "too-new" requires pre-commit version 999.0.0 but version ` + config.Version + " is installed"
+	if !strings.Contains(stderr, want) {
+		t.Errorf("expected %q in stderr:\n%s", want, stderr)
+	}
+}
+
+func TestRunnerRun_VerboseCommandEcho(t *testing.T) {
+	t.Setenv("COLUMNS", "80")
+	dir := t.TempDir()
+	var files []string
+	for i := range 1000 {
+		f := filepath.Join(dir, fmt.Sprintf("f%04d.txt", i))
+		if err := os.WriteFile(f, []byte("x\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	hooks := []*Hook{{
+		ID: "quiet", Name: "Quiet", Language: "system",
+		Entry: "true", Args: []string{"--check"},
+		Types: []string{"file"}, PassFilenames: true,
+		Stages: []config.Stage{config.HookTypePreCommit},
+	}}
+
+	run := func(full bool) string {
+		return captureStderr(t, func() {
+			NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+				Files:           files,
+				HookStage:       config.HookTypePreCommit,
+				Verbose:         true,
+				ShowFullCommand: full,
+			})
+		})
+	}
+
+	stderr := run(false)
+	if !strings.Contains(stderr, "- command: true --check <1000 files>\n") {
+		t.Errorf("expected summarized command, got:\n%s", stderr)
+	}
+	if strings.Contains(stderr, "f0000.txt") {
+		t.Errorf("summarized command must not list files:\n%s", stderr)
+	}
+
+	stderr = run(true)
+	if strings.Contains(stderr, "<1000 files>") {
+		t.Errorf("--show-full-command must not summarize:\n%s", stderr)
+	}
+	for _, f := range []string{files[0], files[999]} {
+		if !strings.Contains(stderr, f) {
+			t.Errorf("expected %s in full command:\n%.500s", f, stderr)
+		}
+	}
+	for line := range strings.SplitSeq(stderr, "\n") {
+		if strings.HasPrefix(line, "- command: ") || strings.HasPrefix(line, "           ") {
+			if len(line) > 80 {
+				t.Errorf("command line exceeds terminal width: %q", line)
+			}
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	for _, tt := range []struct{ in, want string }{
+		{`src\pkg\main.py`, "src/pkg/main.py"},
+		{"./docs/index.md", "docs/index.md"},
+		{`a\.\b\..\c.txt`, "a/c.txt"},
+	} {
+		if got := normalizePath(tt.in); got != tt.want {
+			t.Errorf("normalizePath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	root := t.TempDir()
+	inside := filepath.ToSlash(filepath.Join(root, "lib", "util.py"))
+	outside := filepath.ToSlash(filepath.Join(filepath.Dir(root), "other", "x.py"))
+	for _, tt := range []struct{ in, want string }{
+		{"lib/util.py", "lib/util.py"},
+		{`lib\util.py`, "lib/util.py"},
+		{inside, "lib/util.py"},
+		{outside, outside},
+	} {
+		if got := matchPath(root, tt.in); got != tt.want {
+			t.Errorf("matchPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRunnerRun_BackslashPathsMatchForwardSlashPatterns(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "src", "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Outside Windows these are plain file names containing backslashes;
+	// they are matched as paths but passed to the hook unchanged.
+	for _, name := range []string{`src\pkg\main.py`, `src\pkg\gen_pb2.py`} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x = 1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	logPath := filepath.Join(t.TempDir(), "files.log")
+
+	hooks := []*Hook{{
+		ID: "record", Name: "Record", Language: "system",
+		Entry:         `sh -c 'printf "%s\n" "$@" >> ` + logPath + `' --`,
+		Files:         `^src/pkg/.*\.py$`,
+		Exclude:       `^src/pkg/gen_`,
+		Types:         []string{"file"},
+		PassFilenames: true,
+		Stages:        []config.Stage{config.HookTypePreCommit},
+	}}
+	result := NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+		Files:     []string{`src\pkg\main.py`, `src\pkg\gen_pb2.py`},
+		HookStage: config.HookTypePreCommit,
+	})
+	if result.Passed != 1 {
+		t.Fatalf("result = %+v, want 1 passed", result)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(data)); !slices.Equal(got, []string{`src\pkg\main.py`}) {
+		t.Errorf("hook ran on %q, want [src\\pkg\\main.py]", got)
+	}
+}
+
+func TestRunnerRun_WorkingDirectory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "pkg", "a", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pkg/a/main.go", "pkg/a/sub/util.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("package a\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(dir)
+	logPath := filepath.Join(t.TempDir(), "run.log")
+
+	hooks := []*Hook{{
+		ID: "record", Name: "Record", Language: "system",
+		Entry:            `sh -c 'pwd -P >> ` + logPath + `; printf "%s\n" "$@" >> ` + logPath + `' --`,
+		Files:            `^pkg/a/`,
+		Types:            []string{"file"},
+		PassFilenames:    true,
+		WorkingDirectory: "pkg/a",
+		Stages:           []config.Stage{config.HookTypePreCommit},
+	}}
+	result := NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+		Files:     []string{"pkg/a/main.go", "pkg/a/sub/util.go"},
+		HookStage: config.HookTypePreCommit,
+	})
+	if result.Passed != 1 {
+		t.Fatalf("result = %+v, want 1 passed", result)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantDir, err := filepath.EvalSymlinks(filepath.Join(dir, "pkg", "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Fields(string(data)), []string{wantDir, "main.go", "sub/util.go"}; !slices.Equal(got, want) {
+		t.Errorf("hook log = %q, want %q", got, want)
+	}
+
+	hooks[0].WorkingDirectory = "pkg/missing"
+	var res RunResult
+	stderr := captureStderr(t, func() {
+		res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			Files:     []string{"pkg/a/main.go"},
+			HookStage: config.HookTypePreCommit,
+		})
+	})
+	if res.Errors != 1 || !strings.Contains(stderr, `working_directory "pkg/missing" of hook "record" is not a directory`) {
+		t.Errorf("result = %+v, stderr:\n%s", res, stderr)
+	}
+}
+
+func TestRunnerRun_Branches(t *testing.T) {
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0o644)
+	git("add", "a.txt")
+	git("commit", "-qm", "init")
+	t.Chdir(dir)
+
+	hooks := []*Hook{{
+		ID: "release-only", Name: "Release only", Language: "system",
+		Entry:         "true",
+		AlwaysRun:     true,
+		Branches:      []string{"main", "release/*"},
+		Stages:        []config.Stage{config.HookTypePreCommit},
+		PassFilenames: true,
+	}}
+	run := func() (RunResult, string) {
+		var res RunResult
+		stderr := captureStderr(t, func() {
+			res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+				Files:     []string{"a.txt"},
+				HookStage: config.HookTypePreCommit,
+			})
+		})
+		return res, stderr
+	}
+
+	tests := []struct {
+		name     string
+		checkout []string
+		skipped  bool
+		reason   string
+	}{
+		{"exact match", nil, false, ""},
+		{"glob match", []string{"checkout", "-qb", "release/1.0"}, false, ""},
+		{"no match", []string{"checkout", "-qb", "feature/x"}, true, `- skipped: branch "feature/x" does not match main, release/*`},
+		{"detached", []string{"checkout", "-q", "--detach"}, true, "- skipped: HEAD is detached; hook only runs on branches main, release/*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.checkout != nil {
+				git(tt.checkout...)
+			}
+			res, stderr := run()
+			if tt.skipped {
+				if res.Skipped != 1 || res.Passed != 0 {
+					t.Errorf("result = %+v, want 1 skipped\n%s", res, stderr)
+				}
+				if !strings.Contains(stderr, tt.reason) {
+					t.Errorf("stderr missing %q:\n%s", tt.reason, stderr)
+				}
+			} else if res.Passed != 1 {
+				t.Errorf("result = %+v, want 1 passed\n%s", res, stderr)
+			}
+		})
+	}
+}
+
+func TestRunnerRun_Parallel(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	syncDir := t.TempDir()
+
+	// Each hook waits for the other to have started, so they can only
+	// pass when run at the same time.
+	waitFor := func(mine, other string) string {
+		return `sh -c 'touch ` + filepath.Join(syncDir, mine) + `; i=0; while [ ! -f ` + filepath.Join(syncDir, other) +
+			` ]; do i=$((i+1)); [ $i -gt 100 ] && exit 1; sleep 0.05; done; echo ` + mine + ` done'`
+	}
+	hooks := []*Hook{
+		{ID: "first", Name: "First", Language: "system", Entry: waitFor("first", "second"), AlwaysRun: true, Verbose: true, Stages: []config.Stage{config.HookTypePreCommit}},
+		{ID: "second", Name: "Second", Language: "system", Entry: waitFor("second", "first"), AlwaysRun: true, Verbose: true, Stages: []config.Stage{config.HookTypePreCommit}},
+	}
+	var res RunResult
+	stderr := captureStderr(t, func() {
+		res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			HookStage: config.HookTypePreCommit,
+			Parallel:  true,
+			Jobs:      2,
+		})
+	})
+	if res.Passed != 2 {
+		t.Fatalf("result = %+v, want 2 passed\n%s", res, stderr)
+	}
+	// Output stays grouped per hook and in config order.
+	first, second := strings.Index(stderr, "First."), strings.Index(stderr, "Second.")
+	if first < 0 || second < first || !strings.Contains(stderr[first:second], "first done") || !strings.Contains(stderr[second:], "second done") {
+		t.Errorf("output not grouped in config order:\n%s", stderr)
+	}
+}
+
+func TestRunnerRun_ParallelSerializesSharedFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.WriteFile("a.txt", []byte("unformatted\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The checker only reads a.txt, but would see the formatter rewrite it
+	// mid-run if the two were started together.
+	hooks := []*Hook{
+		{
+			ID: "format", Name: "format", Language: "system", Entry: `sh -c 'sleep 0.2; echo formatted > "$1"' --`,
+			PassFilenames: true, Stages: []config.Stage{config.HookTypePreCommit},
+		},
+		{
+			ID: "check", Name: "check", Language: "system", Entry: `sh -c 'grep -q formatted "$1" && sleep 0.4' --`,
+			PassFilenames: true, Stages: []config.Stage{config.HookTypePreCommit},
+		},
+	}
+	var res RunResult
+	stderr := captureStderr(t, func() {
+		res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			Files:     []string{"a.txt"},
+			HookStage: config.HookTypePreCommit,
+			Parallel:  true,
+			Jobs:      2,
+		})
+	})
+	if res.Failed != 1 || res.Passed != 1 {
+		t.Fatalf("result = %+v, want the formatter failed and the checker passed\n%s", res, stderr)
+	}
+	if check := stderr[strings.Index(stderr, "check."):]; strings.Contains(check, "files were modified") {
+		t.Errorf("checker reported the formatter's changes:\n%s", stderr)
+	}
+}
+
+// overlapEntry returns an entry that holds a lock while it runs and records
+// in logPath if it finds the lock taken, i.e. two invocations overlapped.
+func overlapEntry(t *testing.T) (entry, logPath string) {
+	t.Helper()
+	lock := filepath.Join(t.TempDir(), "lock")
+	logPath = filepath.Join(t.TempDir(), "overlap.log")
+	return `sh -c 'mkdir ` + lock + ` 2>/dev/null || { echo overlap >> ` + logPath + `; exit 1; }; sleep 0.2; rmdir ` + lock + `' --`, logPath
+}
+
+func TestRunnerRun_Concurrency(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	entry, logPath := overlapEntry(t)
+
+	// Entries of one hook share a single limit, the smallest any of them
+	// sets.
+	var hooks []*Hook
+	for i, args := range [][]string{{"a"}, {"b"}, {"c"}} {
+		concurrency := 2
+		if i == 1 {
+			concurrency = 1
+		}
+		hooks = append(hooks, &Hook{
+			ID: "api", Name: "api " + args[0], Language: "system", Entry: entry, Args: args,
+			AlwaysRun: true, PassFilenames: false, Concurrency: concurrency, Stages: []config.Stage{config.HookTypePreCommit},
+		})
+	}
+	var res RunResult
+	stderr := captureStderr(t, func() {
+		res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			HookStage: config.HookTypePreCommit,
+			Parallel:  true,
+			Jobs:      3,
+		})
+	})
+	if res.Passed != 3 {
+		t.Errorf("result = %+v, want 3 passed\n%s", res, stderr)
+	}
+	if data, err := os.ReadFile(logPath); err == nil {
+		t.Errorf("invocations of a concurrency: 1 hook overlapped:\n%s", data)
+	}
+}
+
+func TestRunnerRun_ConcurrencyBatches(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	entry, logPath := overlapEntry(t)
+	old := fileBatchSize
+	fileBatchSize = func() int { return 2 }
+	t.Cleanup(func() { fileBatchSize = old })
+
+	var files []string
+	for i := range 8 {
+		name := fmt.Sprintf("f%d.txt", i)
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	hooks := []*Hook{{
+		ID: "api", Name: "api", Language: "system", Entry: entry,
+		PassFilenames: true, Concurrency: 1, Stages: []config.Stage{config.HookTypePreCommit},
+	}}
+	var res RunResult
+	stderr := captureStderr(t, func() {
+		res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			Files:     files,
+			HookStage: config.HookTypePreCommit,
+			Jobs:      4,
+		})
+	})
+	if res.Passed != 1 {
+		t.Errorf("result = %+v, want 1 passed\n%s", res, stderr)
+	}
+	if data, err := os.ReadFile(logPath); err == nil {
+		t.Errorf("batches of a concurrency: 1 hook overlapped:\n%s", data)
+	}
+}
+
+func TestRunnerRun_FailLanguageNotBatched(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	old := fileBatchSize
+	fileBatchSize = func() int { return 2 }
+	t.Cleanup(func() { fileBatchSize = old })
+
+	var files []string
+	for i := range 5 {
+		name := fmt.Sprintf("f%d.txt", i)
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, name)
+	}
+	hooks := []*Hook{{
+		ID: "no-txt", Name: "no-txt", Language: "fail", Entry: "no .txt files: {files}",
+		PassFilenames: true, Stages: []config.Stage{config.HookTypePreCommit},
+	}}
+	var res RunResult
+	captureStderr(t, func() {
+		res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			Files:          files,
+			HookStage:      config.HookTypePreCommit,
+			Jobs:           4,
+			CollectReports: true,
+		})
+	})
+	if len(res.Hooks) != 1 {
+		t.Fatalf("reports = %+v, want 1", res.Hooks)
+	}
+	want := "no .txt files: " + strings.Join(files, " ") + "\n"
+	if got := res.Hooks[0].Output; got != want {
+		t.Errorf("output = %q, want the message once: %q", got, want)
+	}
+}
+
+func TestRunnerRun_ParallelFailFast(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	logPath := filepath.Join(t.TempDir(), "ran.log")
+
+	record := func(id, extra string) string {
+		return `sh -c 'echo ` + id + ` >> ` + logPath + extra + `'`
+	}
+	run := func(failFast bool) (RunResult, []string) {
+		os.Remove(logPath)
+		hooks := []*Hook{
+			{ID: "fails", Name: "fails", Language: "system", Entry: record("fails", "; exit 1"), AlwaysRun: true, FailFast: failFast, Stages: []config.Stage{config.HookTypePreCommit}},
+			{ID: "slow", Name: "slow", Language: "system", Entry: record("slow", "; sleep 0.3"), AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+			{ID: "queued", Name: "queued", Language: "system", Entry: record("queued", ""), AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+		}
+		var res RunResult
+		captureStderr(t, func() {
+			res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+				HookStage: config.HookTypePreCommit,
+				Parallel:  true,
+				Jobs:      2,
+			})
+		})
+		data, _ := os.ReadFile(logPath)
+		ran := strings.Fields(string(data))
+		slices.Sort(ran)
+		return res, ran
+	}
+
+	res, ran := run(true)
+	if res.Failed != 1 || res.Passed != 1 || !slices.Equal(ran, []string{"fails", "slow"}) {
+		t.Errorf("with fail_fast: result = %+v, ran %v; want the queued hook dropped", res, ran)
+	}
+	res, ran = run(false)
+	if res.Failed != 1 || res.Passed != 2 || !slices.Equal(ran, []string{"fails", "queued", "slow"}) {
+		t.Errorf("without fail_fast: result = %+v, ran %v; want every hook run", res, ran)
+	}
+}
+
+func TestRunnerRun_StreamOutput(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	release := filepath.Join(t.TempDir(), "release")
+
+	// The hook only prints its second line once the test has seen the first,
+	// so it can only pass if output reaches the terminal while it runs.
+	hooks := []*Hook{{
+		ID: "live", Name: "Live", Language: "system",
+		Entry:     `sh -c 'echo first; i=0; while [ ! -f ` + release + ` ]; do i=$((i+1)); [ $i -gt 100 ] && exit 1; sleep 0.05; done; echo second'`,
+		AlwaysRun: true,
+		Stages:    []config.Stage{config.HookTypePreCommit},
+	}}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stderr
+	os.Stderr = w
+	done := make(chan []string)
+	go func() {
+		var lines []string
+		sc := bufio.NewScanner(r)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+			if sc.Text() == "first" {
+				os.WriteFile(release, nil, 0o644)
+			}
+		}
+		done <- lines
+	}()
+	result := NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+		HookStage:    config.HookTypePreCommit,
+		StreamOutput: true,
+	})
+	w.Close()
+	os.Stderr = old
+	lines := <-done
+
+	if result.Passed != 1 {
+		t.Fatalf("result = %+v, want the hook to pass; stderr: %q", result, lines)
+	}
+	// The status line follows the streamed output, which isn't repeated.
+	if len(lines) != 3 || !slices.Equal(lines[:2], []string{"first", "second"}) || !strings.HasPrefix(lines[2], "Live") {
+		t.Errorf("stderr = %q, want the streamed output once, then the status line", lines)
+	}
+}
+
+func TestRunnerRun_VerboseEnvironmentLine(t *testing.T) {
+	dir := t.TempDir()
+	hooks := []*Hook{{
+		ID: "noop", Name: "Noop", Language: "system", Entry: "true",
+		AlwaysRun: true,
+		Stages:    []config.Stage{config.HookTypePreCommit},
+	}}
+	run := func(verbose bool) string {
+		return captureStderr(t, func() {
+			NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+				HookStage: config.HookTypePreCommit,
+				Verbose:   verbose,
+			})
+		})
+	}
+	if got := run(true); !strings.Contains(got, "- environment: noop: system default, no environment\n") {
+		t.Errorf("expected the environment line under --verbose, got:\n%s", got)
+	}
+	if got := run(false); strings.Contains(got, "- environment:") {
+		t.Errorf("expected no environment line without --verbose, got:\n%s", got)
+	}
+
+	python, err := languages.Get("python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := &Hook{ID: "lint", Language: "python", LanguageVersion: "3.12", RepoDir: dir}
+	envPath := filepath.Join(dir, "py_env-3.12")
+	if got := captureStderr(t, func() { printHookEnvironment(os.Stderr, python, h, true) }); got != "- environment: lint: python 3.12 at "+envPath+" (built)\n" {
+		t.Errorf("built env line = %q", got)
+	}
+	if got := captureStderr(t, func() { printHookEnvironment(os.Stderr, python, h, false) }); !strings.HasSuffix(got, "(reused)\n") {
+		t.Errorf("reused env line = %q", got)
+	}
+}
+
+func TestRunnerRun_AlwaysRunWithoutFilenamesRunsOnce(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for i := range 500 {
+		f := filepath.Join(dir, fmt.Sprintf("f%03d.py", i))
+		if err := os.WriteFile(f, []byte("x = 1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	logPath := filepath.Join(t.TempDir(), "calls.log")
+	hooks := []*Hook{{
+		ID: "typecheck", Name: "Typecheck", Language: "system",
+		Entry:     `sh -c 'echo "call $#" >> ` + logPath + `' --`,
+		Types:     []string{"python"},
+		AlwaysRun: true,
+		Stages:    []config.Stage{config.HookTypePreCommit},
+	}}
+
+	for _, tc := range []struct {
+		name  string
+		files []string
+	}{
+		{"many changed files", files},
+		{"no changed files", nil},
+	} {
+		os.Remove(logPath)
+		result := NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			Files:     tc.files,
+			HookStage: config.HookTypePreCommit,
+			Jobs:      8,
+		})
+		if result.Passed != 1 {
+			t.Fatalf("%s: result = %+v, want 1 passed", tc.name, result)
+		}
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(data); got != "call 0\n" {
+			t.Errorf("%s: invocations = %q, want a single call with no filenames", tc.name, got)
+		}
+	}
+}
+
+func TestTruncateOutput(t *testing.T) {
+	out := []byte("1\n2\n3\n4\n")
+	for _, limit := range []int{0, -1, 4, 10} {
+		if got := truncateOutput(out, limit); string(got) != string(out) {
+			t.Errorf("truncateOutput(limit=%d) = %q, want unchanged", limit, got)
+		}
+	}
+	if got, want := string(truncateOutput(out, 2)), "... (truncated, 2 more lines)\n3\n4\n"; got != want {
+		t.Errorf("truncateOutput = %q, want %q", got, want)
+	}
+	if got, want := string(truncateOutput([]byte("a\nb\nc"), 1)), "... (truncated, 2 more lines)\nc"; got != want {
+		t.Errorf("without trailing newline: got %q, want %q", got, want)
+	}
+}
+
+func TestRunnerRun_MaxOutputLines(t *testing.T) {
+	dir := t.TempDir()
+	hooks := []*Hook{{
+		ID: "noisy", Name: "Noisy", Language: "system",
+		Entry:     `sh -c 'for i in $(seq 1 20); do echo "line $i"; done; exit 1'`,
+		AlwaysRun: true,
+		LogFile:   "noisy.log",
+		Stages:    []config.Stage{config.HookTypePreCommit},
+	}}
+
+	var result RunResult
+	stderr := captureStderr(t, func() {
+		result = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			HookStage:      config.HookTypePreCommit,
+			MaxOutputLines: 5,
+		})
+	})
+	if result.Failed != 1 {
+		t.Fatalf("result = %+v, want 1 failed", result)
+	}
+	if !strings.Contains(stderr, "... (truncated, 15 more lines)\nline 16\nline 17\nline 18\nline 19\nline 20\n") {
+		t.Errorf("expected the last 5 lines behind a marker, got:\n%s", stderr)
+	}
+	if strings.Contains(stderr, "line 15\n") {
+		t.Errorf("truncated lines must not be shown:\n%s", stderr)
+	}
+
+	log, err := os.ReadFile(filepath.Join(dir, "noisy.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(log), "line 1\n") || !strings.Contains(string(log), "line 20\n") {
+		t.Errorf("log_file should keep the full output, got:\n%s", log)
+	}
+}
+
+func TestRunnerRun_DedupOutput(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	fail := "sh -c 'echo same problem; exit 1'"
+	hooks := []*Hook{
+		{ID: "first", Name: "First", Language: "system", Entry: fail, AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+		{ID: "second", Name: "Second", Language: "system", Entry: fail + " --", AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+		{ID: "third", Name: "Third", Language: "system", Entry: "sh -c 'echo other problem; exit 1'", AlwaysRun: true, Stages: []config.Stage{config.HookTypePreCommit}},
+	}
+	var res RunResult
+	stderr := captureStderr(t, func() {
+		res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			HookStage:   config.HookTypePreCommit,
+			DedupOutput: true,
+		})
+	})
+	if res.Failed != 3 {
+		t.Fatalf("result = %+v, want 3 failed\n%s", res, stderr)
+	}
+	if n := strings.Count(stderr, "same problem"); n != 1 {
+		t.Errorf("identical output printed %d times, want 1:\n%s", n, stderr)
+	}
+	if !strings.Contains(stderr, "(also failed: second)") {
+		t.Errorf("missing also-failed note:\n%s", stderr)
+	}
+	if !strings.Contains(stderr, "(same output as first, shown above)") {
+		t.Errorf("missing back-reference for second hook:\n%s", stderr)
+	}
+	if !strings.Contains(stderr, "other problem") {
+		t.Errorf("distinct output dropped:\n%s", stderr)
+	}
+}
+
+func TestRunnerRun_LocalScriptRelativeToRepoRoot(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"scripts", "pkg"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logPath := filepath.Join(t.TempDir(), "run.log")
+	script := filepath.Join(dir, "scripts", "check.sh")
+	// Committed without its executable bit.
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" >> "+logPath+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pkg", "main.go"), []byte("package pkg\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(dir)
+
+	hooks := []*Hook{{
+		ID: "check", Name: "Check", Language: "script", Entry: "scripts/check.sh --strict",
+		// The hook's process runs from pkg/, not the repo root.
+		Args: []string{"--fast"}, PassFilenames: true, AlwaysRun: true, WorkingDirectory: "pkg",
+		Stages: []config.Stage{config.HookTypePreCommit},
+	}}
+	var res RunResult
+	stderr := captureStderr(t, func() {
+		res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+			Files:     []string{"pkg/main.go"},
+			HookStage: config.HookTypePreCommit,
+		})
+	})
+	if res.Passed != 1 {
+		t.Fatalf("result = %+v, want 1 passed\n%s", res, stderr)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Fields(string(data)), []string{"--strict", "--fast", "main.go"}; !slices.Equal(got, want) {
+		t.Errorf("script args = %q, want %q", got, want)
+	}
+	if info, err := os.Stat(script); err != nil || info.Mode().Perm()&0o111 == 0 {
+		t.Errorf("script not made executable: %v", err)
+	}
+}
+
+func TestRunnerRun_CacheTypes(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	t.Chdir(dir)
+	for name, content := range map[string]string{"a.py": "print(1)\n", "b.txt": "b\n"} {
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hooks := []*Hook{
+		{ID: "py", Name: "py", Language: "system", Entry: "true", Types: []string{"python"}, Stages: []config.Stage{config.HookTypePreCommit}},
+	}
+	run := func(cache bool) int64 {
+		t.Helper()
+		before := classifications.Load()
+		var res RunResult
+		stderr := captureStderr(t, func() {
+			res = NewRunner(&config.Config{}, hooks, dir).Run(context.Background(), RunOptions{
+				HookStage:  config.HookTypePreCommit,
+				Files:      []string{"a.py", "b.txt"},
+				CacheTypes: cache,
+			})
+		})
+		if res.Passed != 1 {
+			t.Fatalf("result = %+v, want 1 passed\n%s", res, stderr)
+		}
+		return classifications.Load() - before
+	}
+
+	if n := run(true); n != 2 {
+		t.Errorf("first run classified %d files, want 2", n)
+	}
+	if n := run(true); n != 0 {
+		t.Errorf("second run classified %d files, want the cache reused", n)
+	}
+	if err := os.WriteFile("a.py", []byte("print(1)\nprint(2)\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if n := run(true); n != 1 {
+		t.Errorf("run after a change classified %d files, want only the changed one", n)
+	}
+	if n := run(false); n != 2 {
+		t.Errorf("run without --cache-types classified %d files, want 2", n)
+	}
+}
@@ -0,0 +1,187 @@
+package hook
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/identify"
+	"github.com/blairham/go-pre-commit/v4/internal/store"
+)
+
+// classifications counts the files whose type tags were computed rather
+// than taken from a typeCache, so tests can tell the cache was used.
+var classifications atomic.Int64
+
+// fileTypes classifies files for the type filters of a run. A
+// .gitattributes text/binary hint in binaryAttrs overrides content
+// sniffing; with a cache, classifications are reused across runs. A nil
+// *fileTypes classifies every file from scratch.
+type fileTypes struct {
+	binaryAttrs map[string]bool
+	cache       *typeCache
+}
+
+// tags returns the identify tags for f.
+func (ft *fileTypes) tags(f string) map[string]bool {
+	if ft == nil {
+		return tagsForFile(f, nil)
+	}
+	if ft.cache == nil {
+		return tagsForFile(f, ft.binaryAttrs)
+	}
+	return ft.cache.tags(f, ft.binaryAttrs)
+}
+
+// typeCacheEntry is a file's tags along with what they were computed from.
+// Attr is the .gitattributes hint in effect: "", "binary" or "text".
+type typeCacheEntry struct {
+	ModTime int64    `json:"mtime"`
+	Size    int64    `json:"size"`
+	Attr    string   `json:"attr,omitempty"`
+	Tags    []string `json:"tags"`
+}
+
+// typeCacheFile is the on-disk form of a typeCache. Entries written by
+// another version are discarded, since its tag tables may differ.
+type typeCacheFile struct {
+	Version string                    `json:"version"`
+	Files   map[string]typeCacheEntry `json:"files"`
+}
+
+// typeCache holds the type tags of a repository's files, keyed by absolute
+// path and invalidated when a file's mtime or size changes. It lives under
+// the store directory, one file per repository root.
+type typeCache struct {
+	path string
+
+	mu      sync.Mutex
+	entries map[string]typeCacheEntry
+	dirty   bool
+}
+
+// typeCachePath returns where the type cache for the repository at root is
+// kept.
+func typeCachePath(root string) string {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	hash := sha256.Sum256([]byte(root))
+	return filepath.Join(store.DefaultDir(), "types", fmt.Sprintf("%x.json", hash[:8]))
+}
+
+// loadTypeCache reads the cache at path. A missing, unreadable or outdated
+// cache yields an empty one.
+func loadTypeCache(path string) *typeCache {
+	c := &typeCache{path: path, entries: map[string]typeCacheEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return c
+	}
+	var f typeCacheFile
+	if json.Unmarshal(data, &f) == nil && f.Version == config.Version && f.Files != nil {
+		c.entries = f.Files
+	}
+	return c
+}
+
+// tags returns f's tags from the cache if it is unchanged since they were
+// recorded, and otherwise classifies it and records the result.
+func (c *typeCache) tags(f string, binaryAttrs map[string]bool) map[string]bool {
+	abs, err := filepath.Abs(f)
+	if err != nil {
+		return tagsForFile(f, binaryAttrs)
+	}
+	info, err := os.Stat(f)
+	if err != nil {
+		return tagsForFile(f, binaryAttrs)
+	}
+	attr := ""
+	if binary, ok := binaryAttrs[f]; ok {
+		attr = "text"
+		if binary {
+			attr = "binary"
+		}
+	}
+
+	c.mu.Lock()
+	e, ok := c.entries[abs]
+	c.mu.Unlock()
+	if ok && e.ModTime == info.ModTime().UnixNano() && e.Size == info.Size() && e.Attr == attr {
+		tags := make(map[string]bool, len(e.Tags))
+		for _, t := range e.Tags {
+			tags[t] = true
+		}
+		return tags
+	}
+
+	tags := tagsForFile(f, binaryAttrs)
+	e = typeCacheEntry{ModTime: info.ModTime().UnixNano(), Size: info.Size(), Attr: attr}
+	for t := range tags {
+		e.Tags = append(e.Tags, t)
+	}
+	slices.Sort(e.Tags)
+	c.mu.Lock()
+	c.entries[abs] = e
+	c.dirty = true
+	c.mu.Unlock()
+	return tags
+}
+
+// save writes the cache back if anything was added, dropping entries for
+// files that no longer exist. The file is replaced atomically so concurrent
+// runs never read a partial cache.
+func (c *typeCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	for path := range c.entries {
+		if _, err := os.Lstat(path); err != nil {
+			delete(c.entries, path)
+		}
+	}
+	data, err := json.Marshal(typeCacheFile{Version: config.Version, Files: c.entries})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".types-*.json")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// tagsForFile returns the identify tags for f, letting a .gitattributes
+// text/binary hint in binaryAttrs override content sniffing.
+func tagsForFile(f string, binaryAttrs map[string]bool) map[string]bool {
+	classifications.Add(1)
+	if binary, ok := binaryAttrs[f]; ok {
+		return identify.TagsForFileWithBinary(f, binary)
+	}
+	return identify.TagsForFile(f)
+}
@@ -0,0 +1,74 @@
+package hook
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
+)
+
+// verifyArgs are passed to each hook's entry by VerifyHooks in place of its
+// configured args, so tools print their version rather than doing any work.
+var verifyArgs = []string{"--version"}
+
+// VerifyResult is the outcome of launching one hook's entry.
+type VerifyResult struct {
+	Hook *Hook
+	Err  error // nil if the entry launched.
+}
+
+// VerifyHooks launches each hook's entry with --version and no files from
+// an empty directory, to catch broken installs before the first real run.
+// Only failing to launch counts: the tool's exit status is otherwise
+// ignored, since not every tool knows --version. Hooks that never start a
+// process (fail, pygrep and meta hooks) are skipped.
+func VerifyHooks(ctx context.Context, hooks []*Hook) []VerifyResult {
+	workDir, err := os.MkdirTemp("", "pre-commit-verify-*")
+	if err != nil {
+		results := make([]VerifyResult, len(hooks))
+		for i, h := range hooks {
+			results[i] = VerifyResult{Hook: h, Err: err}
+		}
+		return results
+	}
+	defer os.RemoveAll(workDir)
+
+	var results []VerifyResult
+	for _, h := range hooks {
+		if h.Repo == "meta" {
+			continue
+		}
+		lang, err := languages.Get(h.Language)
+		if err != nil {
+			results = append(results, VerifyResult{Hook: h, Err: errkind.Wrap(errkind.Config, err)})
+			continue
+		}
+		if lang.Name() == "fail" || lang.Name() == "pygrep" {
+			continue
+		}
+
+		hc := *h
+		hc.Args = verifyArgs
+		exitCode, out, err := runHookXargs(ctx, lang, &hc, nil, workDir, 1)
+		switch {
+		case err != nil:
+			results = append(results, VerifyResult{Hook: h, Err: errkind.Wrap(errkind.Env, err)})
+		case exitCode == 126 || exitCode == 127:
+			// Shells and wrapper scripts report an unrunnable or missing
+			// command with these codes.
+			results = append(results, VerifyResult{Hook: h, Err: errkind.Wrap(errkind.Env, fmt.Errorf("entry exited %d: %s", exitCode, firstLine(out)))})
+		default:
+			results = append(results, VerifyResult{Hook: h})
+		}
+	}
+	return results
+}
+
+// firstLine returns the first non-blank line of out, for short error messages.
+func firstLine(out []byte) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+	return line
+}
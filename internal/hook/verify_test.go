@@ -0,0 +1,38 @@
+package hook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
+)
+
+func TestVerifyHooks(t *testing.T) {
+	hooks := []*Hook{
+		{ID: "works", Language: "system", Entry: "sh -c 'exit 3'"},
+		{ID: "missing", Language: "system", Entry: "pre-commit-no-such-binary"},
+		{ID: "not-found-in-shell", Language: "system", Entry: "sh -c 'pre-commit-no-such-binary'"},
+		{ID: "in-process", Language: "pygrep", Entry: "TODO"},
+		{ID: "check-hooks-apply", Repo: "meta", Language: "system", Entry: "pre-commit-meta-check-hooks-apply"},
+		{ID: "check-useless-excludes", Repo: "local", Language: "system", Entry: "pre-commit-no-such-binary"},
+	}
+
+	results := VerifyHooks(context.Background(), hooks)
+	if len(results) != 4 {
+		t.Fatalf("got %d results, want 4 (pygrep and meta skipped): %+v", len(results), results)
+	}
+	byID := make(map[string]error)
+	for _, r := range results {
+		byID[r.Hook.ID] = r.Err
+	}
+	if err := byID["works"]; err != nil {
+		t.Errorf("works: a non-zero exit status from a launched tool should pass, got %v", err)
+	}
+	for _, id := range []string{"missing", "not-found-in-shell", "check-useless-excludes"} {
+		if err := byID[id]; err == nil {
+			t.Errorf("%s: expected verification to fail", id)
+		} else if errkind.Of(err) != errkind.Env {
+			t.Errorf("%s: error kind = %q, want %q", id, errkind.Of(err), errkind.Env)
+		}
+	}
+}
@@ -9,13 +9,19 @@ import (
 
 // TagsForFile returns the set of type tags for a file path.
 func TagsForFile(path string) map[string]bool {
+	return TagsForFileWithBinary(path, isBinaryFile(path))
+}
+
+// TagsForFileWithBinary is like TagsForFile but takes the text/binary
+// decision from the caller instead of sniffing the file's contents, for when
+// it is already known (e.g. from .gitattributes).
+func TagsForFileWithBinary(path string, binary bool) map[string]bool {
 	tags := make(map[string]bool)
 
 	// Always add "file".
 	tags["file"] = true
 
-	// Check if binary.
-	if isBinaryFile(path) {
+	if binary {
 		tags["binary"] = true
 		return tags
 	}
@@ -311,3 +311,26 @@ func TestMatchesTypesEmptyTags(t *testing.T) {
 		t.Error("empty tags should not satisfy 'file' type requirement")
 	}
 }
+
+// ---------------------------------------------------------------------------
+// TagsForFileWithBinary – caller-supplied text/binary decision
+// ---------------------------------------------------------------------------
+
+func TestTagsForFileWithBinaryOverridesSniffing(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "generated.py")
+	if err := os.WriteFile(path, []byte("x = 1\x00\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if tags := TagsForFile(path); !tags["binary"] {
+		t.Fatalf("expected content sniffing to report binary, got %v", tags)
+	}
+	tags := TagsForFileWithBinary(path, false)
+	if !tags["text"] || tags["binary"] || !tags["python"] {
+		t.Errorf("expected text python tags, got %v", tags)
+	}
+	if tags := TagsForFileWithBinary(path, true); !tags["binary"] || tags["text"] {
+		t.Errorf("expected binary tags, got %v", tags)
+	}
+}
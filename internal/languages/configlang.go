@@ -24,6 +24,10 @@ type SimpleLanguage struct {
 	// HealthCheckFn is a full override; when set, HealthCmd is ignored.
 	HealthCmd     []string
 	HealthCheckFn func(prefix, version string) error
+	// InstallURL says where to get the runtime HealthCmd runs. When set, a
+	// runtime missing from PATH is reported by CheckRuntime before any
+	// install is attempted.
+	InstallURL string
 
 	// --- Install ---
 	// InstallCmd returns the command name and args for the primary install step.
@@ -42,10 +46,47 @@ type SimpleLanguage struct {
 	RunEnvFn func(envDir string) []string
 	// RunFn is a full override for Run. When set, RunEnvFn and RunBinSubdir are ignored.
 	RunFn func(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version, envDirName string) (int, []byte, error)
+
+	// --- Environment file ---
+	// EnvFile is the file in prefix the environment is built from by
+	// default (conda's environment.yml); a hook may name another one.
+	// EnvFileInstallFn installs from the resolved file, relative to prefix,
+	// and replaces every other install step.
+	EnvFile          string
+	EnvFileInstallFn func(prefix, version, envDirName, envFile string, additionalDeps []string) error
+
+	// --- Reuse ---
+	// FingerprintFn digests files in prefix the environment is built from
+	// (see EnvironmentFingerprint); envFile is the resolved EnvFile. nil
+	// means only the version and additional_dependencies matter.
+	FingerprintFn func(prefix, envFile string) string
 }
 
 func (s *SimpleLanguage) Name() string { return s.LangName }
 
+func (s *SimpleLanguage) environmentFingerprint(prefix, envFile string) string {
+	if s.FingerprintFn == nil {
+		return ""
+	}
+	return s.FingerprintFn(prefix, s.envFile(envFile))
+}
+
+// envFile returns the environment file a hook builds from: the one it
+// names, or the language's default.
+func (s *SimpleLanguage) envFile(name string) string {
+	if name != "" && s.EnvFile != "" {
+		return name
+	}
+	return s.EnvFile
+}
+
+func (s *SimpleLanguage) installEnvironmentFile(prefix, version, envFile string, additionalDeps []string) error {
+	if s.EnvFileInstallFn == nil {
+		return s.InstallEnvironment(prefix, version, additionalDeps)
+	}
+	return s.EnvFileInstallFn(prefix, version, s.EnvDirName, s.envFile(envFile), additionalDeps)
+}
+
 func (s *SimpleLanguage) EnvironmentDir() string { return s.EnvDirName }
 
 func (s *SimpleLanguage) GetDefaultVersion() string {
@@ -70,8 +111,11 @@ func (s *SimpleLanguage) HealthCheck(prefix, version string) error {
 }
 
 func (s *SimpleLanguage) InstallEnvironment(prefix, version string, additionalDeps []string) error {
-	envDir := filepath.Join(prefix, s.EnvDirName+"-"+version)
+	envDir := envDirPath(prefix, s.EnvDirName, version)
 
+	if s.EnvFileInstallFn != nil {
+		return s.installEnvironmentFile(prefix, version, "", additionalDeps)
+	}
 	if s.InstallFn != nil {
 		return s.InstallFn(prefix, version, s.EnvDirName, additionalDeps)
 	}
@@ -99,7 +143,7 @@ func (s *SimpleLanguage) Run(ctx context.Context, prefix, workDir, entry string,
 		return s.RunFn(ctx, prefix, workDir, entry, args, fileArgs, version, s.EnvDirName)
 	}
 
-	envDir := filepath.Join(prefix, s.EnvDirName+"-"+version)
+	envDir := envDirPath(prefix, s.EnvDirName, version)
 
 	var env []string
 	if s.RunEnvFn != nil {
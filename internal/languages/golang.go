@@ -2,10 +2,12 @@ package languages
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"time"
 )
 
 // Golang implements the Language interface for Go hooks.
@@ -15,16 +17,63 @@ func (g *Golang) Name() string              { return "golang" }
 func (g *Golang) EnvironmentDir() string    { return "go_env" }
 func (g *Golang) GetDefaultVersion() string { return "default" }
 
+// goHealthTimeout bounds each command HealthCheck runs; tests shorten it.
+var goHealthTimeout = 10 * time.Second
+
+// HealthCheck confirms the env's bin dir holds installed binaries that can
+// still be executed, and that the go toolchain, which installs and rebuilds
+// them, still runs. Each binary is started with --help; any exit status is
+// accepted since only a failure to exec (missing file, wrong architecture,
+// broken interpreter) or a hang means the environment needs rebuilding.
 func (g *Golang) HealthCheck(prefix, version string) error {
-	envDir := filepath.Join(prefix, g.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, g.EnvironmentDir(), version)
 	binDir := filepath.Join(envDir, "bin")
 	entries, err := os.ReadDir(binDir)
 	if err != nil || len(entries) == 0 {
 		return fmt.Errorf("golang environment unhealthy: no binaries in %s", binDir)
 	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		bin := filepath.Join(binDir, e.Name())
+		info, err := os.Stat(bin)
+		if err != nil {
+			return fmt.Errorf("golang environment unhealthy: %w", err)
+		}
+		if info.Mode().Perm()&0o111 == 0 {
+			return fmt.Errorf("golang environment unhealthy: %s is not executable", bin)
+		}
+		var exitErr *exec.ExitError
+		if err := runGoHealthCommand(prefix, nil, bin, "--help"); err != nil && !errors.As(err, &exitErr) {
+			return fmt.Errorf("golang environment unhealthy: cannot run %s: %w", bin, err)
+		}
+	}
+
+	// GOTOOLCHAIN=local: the hook repo's go.mod must not make this
+	// download a toolchain.
+	if err := runGoHealthCommand(prefix, []string{"GOTOOLCHAIN=local"}, "go", "version"); err != nil {
+		return fmt.Errorf("golang environment unhealthy: go version: %w", err)
+	}
 	return nil
 }
 
+// runGoHealthCommand runs name with args in dir, adding env to the
+// environment, and kills it after goHealthTimeout.
+func runGoHealthCommand(dir string, env []string, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), goHealthTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Env = append(cmd.Environ(), env...)
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", goHealthTimeout)
+	}
+	return err
+}
+
 // goInstallEnv builds the env overrides for installing a golang hook env.
 // GOTOOLCHAIN defaults to "local" so a hook repo's go.mod can't pull in a
 // different toolchain — unless the caller set GOTOOLCHAIN explicitly. CI pins
@@ -43,7 +92,7 @@ func goInstallEnv(envDir string) []string {
 }
 
 func (g *Golang) InstallEnvironment(prefix, version string, additionalDeps []string) error {
-	envDir := filepath.Join(prefix, g.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, g.EnvironmentDir(), version)
 
 	env := goInstallEnv(envDir)
 
@@ -70,7 +119,7 @@ func (g *Golang) InstallEnvironment(prefix, version string, additionalDeps []str
 }
 
 func (g *Golang) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	envDir := filepath.Join(prefix, g.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, g.EnvironmentDir(), version)
 	binDir := filepath.Join(envDir, "bin")
 	env := []string{
 		PrependPath(binDir),
@@ -1,8 +1,12 @@
 package languages
 
 import (
+	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 // ---------------------------------------------------------------------------
@@ -28,3 +32,101 @@ func TestGoInstallEnvRespectsCallerToolchain(t *testing.T) {
 		t.Errorf("env %v must not force GOTOOLCHAIN=local over the caller's pin", env)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// Golang — health check
+// ---------------------------------------------------------------------------
+
+// writeGoEnvBin writes an executable named name with the given content into
+// the golang env's bin dir under prefix.
+func writeGoEnvBin(t *testing.T, prefix, name, content string, mode os.FileMode) {
+	t.Helper()
+	binDir := filepath.Join(prefix, "go_env-default", "bin")
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(binDir, name), []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeGoToolchain puts a go that runs script first on PATH.
+func fakeGoToolchain(t *testing.T, script string) {
+	t.Helper()
+	bin := t.TempDir()
+	writeFakeBin(t, bin, "go", script)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/bin"+string(os.PathListSeparator)+"/usr/bin")
+}
+
+// hang is a shell script that never exits, without needing any command.
+const hang = "while :; do :; done\n"
+
+func TestGolangHealthCheckHealthy(t *testing.T) {
+	fakeGoToolchain(t, "echo go version go1.22.0\n")
+	prefix := t.TempDir()
+	ran := filepath.Join(t.TempDir(), "ran")
+	writeGoEnvBin(t, prefix, "mytool", "#!/bin/sh\necho \"$@\" > "+ran+"\n", 0o755)
+
+	if err := (&Golang{}).HealthCheck(prefix, "default"); err != nil {
+		t.Errorf("expected healthy env, got %v", err)
+	}
+	if data, err := os.ReadFile(ran); err != nil || string(data) != "--help\n" {
+		t.Errorf("expected the tool to be run with --help, got %q, %v", data, err)
+	}
+}
+
+func TestGolangHealthCheckNonZeroHelpIsHealthy(t *testing.T) {
+	// Tools that exit non-zero for --help still ran, so the env is fine.
+	fakeGoToolchain(t, "exit 0\n")
+	prefix := t.TempDir()
+	writeGoEnvBin(t, prefix, "mytool", "#!/bin/sh\nexit 2\n", 0o755)
+
+	if err := (&Golang{}).HealthCheck(prefix, "default"); err != nil {
+		t.Errorf("expected healthy env, got %v", err)
+	}
+}
+
+func TestGolangHealthCheckUnhealthy(t *testing.T) {
+	tests := []struct {
+		name    string
+		goSh    string
+		setup   func(t *testing.T, prefix string)
+		wantErr string
+	}{
+		{"missing bin dir", "exit 0\n", func(t *testing.T, prefix string) {}, "no binaries"},
+		{"empty bin dir", "exit 0\n", func(t *testing.T, prefix string) {
+			if err := os.MkdirAll(filepath.Join(prefix, "go_env-default", "bin"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}, "no binaries"},
+		{"not executable", "exit 0\n", func(t *testing.T, prefix string) {
+			writeGoEnvBin(t, prefix, "mytool", "#!/bin/sh\n", 0o644)
+		}, "not executable"},
+		{"cannot exec", "exit 0\n", func(t *testing.T, prefix string) {
+			writeGoEnvBin(t, prefix, "mytool", "#!/nonexistent/interpreter\n", 0o755)
+		}, "cannot run"},
+		{"hung tool", "exit 0\n", func(t *testing.T, prefix string) {
+			writeGoEnvBin(t, prefix, "mytool", "#!/bin/sh\n"+hang, 0o755)
+		}, "timed out"},
+		{"broken toolchain", "exit 1\n", func(t *testing.T, prefix string) {
+			writeGoEnvBin(t, prefix, "mytool", "#!/bin/sh\n", 0o755)
+		}, "go version"},
+		{"hung toolchain", hang, func(t *testing.T, prefix string) {
+			writeGoEnvBin(t, prefix, "mytool", "#!/bin/sh\n", 0o755)
+		}, "timed out"},
+	}
+	old := goHealthTimeout
+	goHealthTimeout = 100 * time.Millisecond
+	t.Cleanup(func() { goHealthTimeout = old })
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeGoToolchain(t, tc.goSh)
+			prefix := t.TempDir()
+			tc.setup(t, prefix)
+			err := (&Golang{}).HealthCheck(prefix, "default")
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("HealthCheck = %v, want an error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -4,19 +4,102 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 )
 
+// envCaptureKey is the context key under which HookEnv asks RunCommand and
+// RunHookCommand to record the environment instead of running anything.
+type envCaptureKey struct{}
+
+type envCapture struct {
+	env      []string
+	captured bool
+}
+
+// captureEnv records env in ctx's envCapture, if any, and reports whether
+// the caller should skip running its command.
+func captureEnv(ctx context.Context, env []string) bool {
+	c, ok := ctx.Value(envCaptureKey{}).(*envCapture)
+	if !ok {
+		return false
+	}
+	c.env = mergeEnv(env)
+	c.captured = true
+	return true
+}
+
+// HookEnv returns the full environment lang would run entry with, without
+// running it. It returns false for languages that run hooks in-process
+// (e.g. pygrep) and so never build a command environment.
+func HookEnv(ctx context.Context, lang Language, prefix, workDir, entry, version string) ([]string, bool) {
+	c := &envCapture{}
+	lang.Run(context.WithValue(ctx, envCaptureKey{}, c), prefix, workDir, entry, nil, nil, version)
+	return c.env, c.captured
+}
+
+// outputStreamKey is the context key under which WithOutputStream stores the
+// writer hook output is copied to as it is produced.
+type outputStreamKey struct{}
+
+// WithOutputStream returns a copy of ctx under which commands also copy
+// their output to w as it is produced, and read stdin from the terminal, so
+// interactive or long-running hooks give live feedback. Output is still
+// captured and returned as usual.
+func WithOutputStream(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, outputStreamKey{}, w)
+}
+
+// outputStream returns the stream writer in ctx, or nil if output is only
+// captured.
+func outputStream(ctx context.Context) io.Writer {
+	w, _ := ctx.Value(outputStreamKey{}).(io.Writer)
+	return w
+}
+
+// attachOutput points cmd's stdout and stderr at buf, and at the stream
+// writer in ctx if there is one.
+func attachOutput(ctx context.Context, cmd *exec.Cmd, buf *bytes.Buffer) {
+	var out io.Writer = buf
+	if w := outputStream(ctx); w != nil {
+		out = io.MultiWriter(buf, w)
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+}
+
+// mergeEnv returns the process environment with the KEY=VALUE overrides in
+// env applied. Overridden keys are dropped from the inherited environment,
+// since exec keeps the last of duplicate keys.
+func mergeEnv(env []string) []string {
+	overridden := make(map[string]bool, len(env))
+	for _, kv := range env {
+		k, _, _ := strings.Cut(kv, "=")
+		overridden[k] = true
+	}
+	merged := make([]string, 0, len(os.Environ())+len(env))
+	for _, kv := range os.Environ() {
+		if k, _, _ := strings.Cut(kv, "="); !overridden[k] {
+			merged = append(merged, kv)
+		}
+	}
+	return append(merged, env...)
+}
+
 // RunCommand is a helper to run a command and capture output.
 func RunCommand(ctx context.Context, dir, name string, args ...string) (int, []byte, error) {
+	if captureEnv(ctx, nil) {
+		return 0, nil, nil
+	}
 	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Dir = dir
+	setProcessGroup(ctx, cmd)
 	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
+	attachOutput(ctx, cmd, &buf)
 	err := cmd.Run()
 	exitCode := 0
 	if err != nil {
@@ -36,12 +119,20 @@ func RunHookCommand(ctx context.Context, dir, entry string, args, fileArgs []str
 	if len(parts) == 0 {
 		return -1, nil, fmt.Errorf("empty entry")
 	}
+	return runHookParts(ctx, dir, parts, args, fileArgs, env)
+}
 
+// runHookParts runs an already split entry, as RunHookCommand does.
+func runHookParts(ctx context.Context, dir string, parts, args, fileArgs []string, env []string) (int, []byte, error) {
 	cmdArgs := make([]string, 0, len(parts)-1+len(args)+len(fileArgs))
 	cmdArgs = append(cmdArgs, parts[1:]...)
 	cmdArgs = append(cmdArgs, args...)
 	cmdArgs = append(cmdArgs, fileArgs...)
 
+	if captureEnv(ctx, env) {
+		return 0, nil, nil
+	}
+
 	// exec.Command resolves the binary at call-time using the CURRENT process
 	// PATH, not the custom env we pass in.  Since the binary may only exist
 	// inside a virtualenv bin dir (e.g. a pip-installed console script), we
@@ -54,12 +145,12 @@ func RunHookCommand(ctx context.Context, dir, entry string, args, fileArgs []str
 
 	cmd := exec.CommandContext(ctx, resolvedBin, cmdArgs...)
 	cmd.Dir = dir
-	// Put custom env vars first so our PATH takes precedence (mirrors Python's
-	// envcontext behavior of replacing os.environ entries).
-	cmd.Env = append(append([]string{}, env...), os.Environ()...)
+	setProcessGroup(ctx, cmd)
+	// Custom env vars replace inherited ones so our PATH takes precedence
+	// (mirrors Python's envcontext behavior of replacing os.environ entries).
+	cmd.Env = mergeEnv(env)
 	var buf bytes.Buffer
-	cmd.Stdout = &buf
-	cmd.Stderr = &buf
+	attachOutput(ctx, cmd, &buf)
 	err = cmd.Run()
 	exitCode := 0
 	if err != nil {
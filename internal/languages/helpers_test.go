@@ -273,3 +273,52 @@ func assertSliceEqual(t *testing.T, got, want []string) {
 		}
 	}
 }
+
+func TestRunHookCommandEnvOverridesInherited(t *testing.T) {
+	t.Setenv("PRE_COMMIT_TEST_VAR", "inherited")
+	_, out, err := RunHookCommand(context.Background(), t.TempDir(), `sh -c 'echo "$PRE_COMMIT_TEST_VAR"'`, nil, nil,
+		[]string{"PRE_COMMIT_TEST_VAR=override"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(out)); got != "override" {
+		t.Errorf("hook saw PRE_COMMIT_TEST_VAR=%q, want %q", got, "override")
+	}
+}
+
+func TestHookEnvPython(t *testing.T) {
+	t.Setenv("VIRTUAL_ENV", "/outer/venv")
+	prefix := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+
+	env, ok := HookEnv(context.Background(), &Python{}, prefix, t.TempDir(), "touch "+marker, "default")
+	if !ok {
+		t.Fatal("HookEnv() for python reported no command environment")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("HookEnv ran the hook entry")
+	}
+
+	envDir := filepath.Join(prefix, "py_env-default")
+	var paths, venvs []string
+	for _, kv := range env {
+		switch k, v, _ := strings.Cut(kv, "="); k {
+		case "PATH":
+			paths = append(paths, v)
+		case "VIRTUAL_ENV":
+			venvs = append(venvs, v)
+		}
+	}
+	if len(venvs) != 1 || venvs[0] != envDir {
+		t.Errorf("VIRTUAL_ENV = %v, want [%s]", venvs, envDir)
+	}
+	if len(paths) != 1 || !strings.HasPrefix(paths[0], filepath.Join(envDir, "bin")+string(os.PathListSeparator)) {
+		t.Errorf("PATH = %v, want a single PATH starting with the env's bin dir", paths)
+	}
+}
+
+func TestHookEnvInProcessLanguage(t *testing.T) {
+	if _, ok := HookEnv(context.Background(), &Pygrep{}, t.TempDir(), t.TempDir(), "pattern", "default"); ok {
+		t.Error("HookEnv() for pygrep = true, want false")
+	}
+}
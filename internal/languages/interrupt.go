@@ -0,0 +1,31 @@
+package languages
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// Interrupted is the cancellation cause of a run stopped by a signal. Hook
+// processes are sent Signal, then killed if still running after
+// InterruptGrace.
+type Interrupted struct {
+	Signal os.Signal
+}
+
+func (e *Interrupted) Error() string { return "interrupted by " + e.Signal.String() }
+
+// InterruptGrace is how long an interrupted hook and its children get to
+// exit before they are killed.
+var InterruptGrace = 3 * time.Second
+
+// interruptSignal returns the signal ctx was interrupted by, or os.Kill if
+// it was cancelled for another reason.
+func interruptSignal(ctx context.Context) os.Signal {
+	var in *Interrupted
+	if errors.As(context.Cause(ctx), &in) {
+		return in.Signal
+	}
+	return os.Kill
+}
@@ -3,7 +3,10 @@ package languages
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -44,6 +47,13 @@ func Register(name string, lang Language) {
 	registry[strings.ToLower(name)] = lang
 }
 
+// Unregister removes the language handler registered under name.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, strings.ToLower(name))
+}
+
 // Get returns the language handler for the given name.
 func Get(name string) (Language, error) {
 	registryMu.RLock()
@@ -69,6 +79,84 @@ func Get(name string) (Language, error) {
 	return lang, nil
 }
 
+// EnvironmentDirs returns the environment directory names used by all
+// registered languages, for recognising environments inside cached repos.
+func EnvironmentDirs() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, lang := range registry {
+		if d := lang.EnvironmentDir(); d != "" && !seen[d] {
+			seen[d] = true
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// EnvironmentFingerprint returns a digest of the files in prefix that lang
+// builds its environment from, such as conda's environment.yml, or "" if
+// the environment depends only on the version and additional_dependencies.
+// envFile is the hook's own choice of such a file, or "" for the default.
+// It is recorded in the install state, so editing those files forces a
+// rebuild.
+func EnvironmentFingerprint(lang Language, prefix, envFile string) string {
+	if f, ok := lang.(interface {
+		environmentFingerprint(prefix, envFile string) string
+	}); ok && prefix != "" {
+		return f.environmentFingerprint(prefix, envFile)
+	}
+	return ""
+}
+
+// InstallEnvironmentFile is lang.InstallEnvironment for a hook that names
+// the file its environment is built from, relative to prefix (conda's
+// conda_env_file). envFile "" uses the language's default, and languages
+// that build from no such file ignore it.
+func InstallEnvironmentFile(lang Language, prefix, version, envFile string, additionalDeps []string) error {
+	if i, ok := lang.(interface {
+		installEnvironmentFile(prefix, version, envFile string, additionalDeps []string) error
+	}); ok {
+		return i.installEnvironmentFile(prefix, version, envFile, additionalDeps)
+	}
+	return lang.InstallEnvironment(prefix, version, additionalDeps)
+}
+
+// EnvironmentPath returns the environment directory lang uses for a hook
+// cloned at prefix with the given language_version, or "" if lang needs no
+// environment.
+func EnvironmentPath(lang Language, prefix, version string) string {
+	if prefix == "" || lang.EnvironmentDir() == "" {
+		return ""
+	}
+	return envDirPath(prefix, lang.EnvironmentDir(), ResolveVersion(lang, version))
+}
+
+// envDirPath returns the directory under prefix for the environment named
+// name (e.g. py_env) with the given language_version. A version that is
+// itself a path, such as /usr/bin/python3.12 or C:\Python312\python.exe,
+// can't be part of a directory name, so a digest of it is used instead; the
+// result is the same whatever OS it is computed on.
+func envDirPath(prefix, name, version string) string {
+	if strings.ContainsAny(version, `/\:*?"<>|`) || version == "." || version == ".." {
+		sum := sha256.Sum256([]byte(version))
+		version = fmt.Sprintf("sha256_%x", sum[:8])
+	}
+	return filepath.Join(prefix, name+"-"+version)
+}
+
+// ResolveVersion returns the concrete language_version lang uses for
+// version, e.g. an installed interpreter for python's "latest".
+func ResolveVersion(lang Language, version string) string {
+	if r, ok := lang.(interface{ resolveVersion(string) string }); ok {
+		return r.resolveVersion(version)
+	}
+	return version
+}
+
 func init() {
 	Register("python", &Python{})
 	Register("node", &Node{})
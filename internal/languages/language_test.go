@@ -2,6 +2,11 @@ package languages
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -179,3 +184,217 @@ func (tl *testLanguage) InstallEnvironment(_, _ string, _ []string) error { retu
 func (tl *testLanguage) Run(_ context.Context, _, _, _ string, _, _ []string, _ string) (int, []byte, error) {
 	return 0, nil, nil
 }
+
+func TestEnvironmentPath(t *testing.T) {
+	if got, want := EnvironmentPath(&Node{}, "/repo", "18"), filepath.Join("/repo", "node_env-18"); got != want {
+		t.Errorf("EnvironmentPath(node) = %q, want %q", got, want)
+	}
+	if got := EnvironmentPath(&Node{}, "", "18"); got != "" {
+		t.Errorf("EnvironmentPath without a repo = %q, want empty", got)
+	}
+	system, err := Get("system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := EnvironmentPath(system, "/repo", "default"); got != "" {
+		t.Errorf("EnvironmentPath(system) = %q, want empty", got)
+	}
+}
+
+func TestEnvironmentFingerprint(t *testing.T) {
+	prefix := t.TempDir()
+	conda, _ := Get("conda")
+	if got := EnvironmentFingerprint(conda, prefix, ""); got != "" {
+		t.Errorf("fingerprint without environment.yml = %q, want empty", got)
+	}
+	os.WriteFile(filepath.Join(prefix, "environment.yml"), []byte("dependencies: [python]\n"), 0o644)
+	first := EnvironmentFingerprint(conda, prefix, "")
+	if !strings.HasPrefix(first, "environment.yml=") {
+		t.Fatalf("fingerprint = %q", first)
+	}
+	os.WriteFile(filepath.Join(prefix, "environment.yml"), []byte("dependencies: [python, numpy]\n"), 0o644)
+	if EnvironmentFingerprint(conda, prefix, "") == first {
+		t.Error("expected the fingerprint to change with environment.yml")
+	}
+
+	python, _ := Get("python")
+	if got := EnvironmentFingerprint(python, prefix, ""); got != "" {
+		t.Errorf("python fingerprint = %q, want empty", got)
+	}
+}
+
+func TestCondaEnvFile(t *testing.T) {
+	conda, _ := Get("conda")
+	prefix := t.TempDir()
+	os.MkdirAll(filepath.Join(prefix, "conda"), 0o755)
+	os.WriteFile(filepath.Join(prefix, "conda", "environment.yml"), []byte("dependencies: [python]\n"), 0o644)
+
+	if got := EnvironmentFingerprint(conda, prefix, ""); got != "" {
+		t.Errorf("fingerprint without a root environment.yml = %q, want empty", got)
+	}
+	if got := EnvironmentFingerprint(conda, prefix, "conda/environment.yml"); !strings.HasPrefix(got, "conda/environment.yml=") {
+		t.Errorf("fingerprint of nested file = %q", got)
+	}
+
+	// The named file is what conda is asked to build from.
+	bin := t.TempDir()
+	argsLog := filepath.Join(t.TempDir(), "args")
+	os.WriteFile(filepath.Join(bin, "conda"), []byte("#!/bin/sh\necho \"$@\" > "+argsLog+"\n"), 0o755)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	if err := InstallEnvironmentFile(conda, prefix, "default", "conda/environment.yml", nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(argsLog); !strings.Contains(string(data), "--file conda/environment.yml") {
+		t.Errorf("conda called with %q", data)
+	}
+
+	err := InstallEnvironmentFile(conda, prefix, "default", "envs/missing.yml", nil)
+	want := filepath.Join(prefix, "envs", "missing.yml")
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("missing env file error = %v, want it to name %s", err, want)
+	}
+	if err := conda.InstallEnvironment(prefix, "default", nil); err == nil || !strings.Contains(err.Error(), filepath.Join(prefix, "environment.yml")) {
+		t.Errorf("default env file error = %v", err)
+	}
+}
+
+// TestEnvironmentPathConformance checks every language that installs an
+// environment puts it in a single directory directly under the prefix, named
+// the same on every OS, with distinct versions never sharing a directory, and
+// that its InstallEnvironment, HealthCheck and Run all use the directory
+// EnvironmentPath reports.
+func TestEnvironmentPathConformance(t *testing.T) {
+	versions := []string{
+		"default", "latest", "3.12", "python3.12", "lts", "1.22.0",
+		"/nonexistent/bin/python3.12", "/nonexistent/bin/python3_12", `C:\Python312\python.exe`, `C:/Python312/python.exe`,
+		"lts/hydrogen", "..",
+	}
+	registryMu.RLock()
+	langs := make(map[string]Language, len(registry))
+	for name, lang := range registry {
+		langs[name] = lang
+	}
+	registryMu.RUnlock()
+
+	// Every tool a language may call is a stub that logs its arguments and
+	// prints its environment, so the env directories each step used show up
+	// in the log or in the step's output.
+	bin := t.TempDir()
+	logPath := filepath.Join(t.TempDir(), "calls.log")
+	stub := []byte("#!/bin/sh\necho \"$0 $*\" >> \"" + logPath + "\"\necho \"$0 $*\"\nexport -p\nexit 1\n")
+	for _, tool := range []string{
+		"cabal", "cargo", "conda", "coursier", "cpan", "cs", "dart", "docker", "dotnet", "gem",
+		"go", "julia", "luarocks", "node", "nodeenv", "npm", "perl", "pip", "pyenv", "python",
+		"python3", "rbenv", "Rscript", "ruby", "swift", "probe",
+	} {
+		if err := os.WriteFile(filepath.Join(bin, tool), stub, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+
+	for name, lang := range langs {
+		if lang.EnvironmentDir() == "" {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			seen := make(map[string]string)
+			for _, v := range versions {
+				prefix := filepath.Join(t.TempDir(), "repo")
+				if err := os.Mkdir(prefix, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				want := EnvironmentPath(lang, prefix, v)
+				if want != envDirPath(prefix, lang.EnvironmentDir(), ResolveVersion(lang, v)) {
+					t.Errorf("version %q: EnvironmentPath = %s, not the envDirPath of the resolved version", v, want)
+				}
+				if filepath.Dir(want) != prefix {
+					t.Errorf("version %q: %s is not directly under the prefix", v, want)
+				}
+				base := filepath.Base(want)
+				if strings.ContainsAny(base, `/\:`) || !strings.HasPrefix(base, lang.EnvironmentDir()+"-") {
+					t.Errorf("version %q: bad directory name %q", v, base)
+				}
+				if other, ok := seen[base]; ok {
+					t.Errorf("versions %q and %q share directory %q", other, v, base)
+				}
+				seen[base] = v
+
+				os.Remove(logPath)
+				var used strings.Builder
+				if err := lang.InstallEnvironment(prefix, v, nil); err != nil {
+					used.WriteString(err.Error())
+				}
+				if err := lang.HealthCheck(prefix, v); err != nil {
+					used.WriteString(err.Error())
+				}
+				_, out, err := lang.Run(context.Background(), prefix, prefix, "probe", nil, nil, v)
+				if err != nil {
+					t.Fatalf("version %q: Run: %v", v, err)
+				}
+				// Docker hooks run in an image rather than from the env dir.
+				if name != "docker" && !strings.Contains(string(out), want) {
+					t.Errorf("version %q: Run did not use %s:\n%s", v, want, out)
+				}
+				used.Write(out)
+				calls, _ := os.ReadFile(logPath)
+				used.Write(calls)
+				entries, _ := os.ReadDir(prefix)
+				for _, e := range entries {
+					used.WriteString("\n" + filepath.Join(prefix, e.Name()))
+				}
+				envDirRe := regexp.MustCompile(regexp.QuoteMeta(filepath.Join(prefix, lang.EnvironmentDir())+"-") + `[^/\\\s:"']*`)
+				for _, got := range envDirRe.FindAllString(used.String(), -1) {
+					if got != want {
+						t.Errorf("version %q: used %s, want %s", v, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEnvDirPathIsOSIndependent(t *testing.T) {
+	// Fixed names: computing them on Windows or Unix gives the same result.
+	for v, want := range map[string]string{
+		"3.12":                    "py_env-3.12",
+		"/usr/bin/python3.12":     "py_env-sha256_f0d7a494a3f77623",
+		`C:\Python312\python.exe`: "py_env-sha256_15e88d126372a2a8",
+	} {
+		if got := filepath.Base(envDirPath("prefix", "py_env", v)); got != want {
+			t.Errorf("envDirPath(%q) = %q, want %q", v, got, want)
+		}
+	}
+}
+
+func TestCheckRuntime(t *testing.T) {
+	bin := t.TempDir()
+	writeFakeBin(t, bin, "python3.12", "exit 0\n")
+	writeFakeBin(t, bin, "npm", "exit 0\n")
+	t.Setenv("PATH", bin)
+
+	for _, tc := range []struct {
+		lang, version, missing string
+	}{
+		{"python", "python3.12", ""},
+		{"python", "default", "python3"},
+		{"python", ">=3.10", ""},
+		{"node", "default", ""},
+		{"node", "20.11.0", "nodeenv"},
+		{"ruby", "default", "ruby"},
+		{"system", "default", ""},
+	} {
+		lang, err := Get(tc.lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = CheckRuntime(lang, tc.version)
+		var rm *RuntimeMissingError
+		switch {
+		case tc.missing == "" && err != nil:
+			t.Errorf("CheckRuntime(%s, %s) = %v, want nil", tc.lang, tc.version, err)
+		case tc.missing != "" && (!errors.As(err, &rm) || rm.Tool != tc.missing || rm.InstallURL == ""):
+			t.Errorf("CheckRuntime(%s, %s) = %v, want %s reported missing with an install URL", tc.lang, tc.version, err, tc.missing)
+		}
+	}
+}
@@ -2,11 +2,18 @@ package languages
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/blairham/go-pre-commit/v4/internal/store"
 )
 
 // Node implements the Language interface for Node.js hooks.
@@ -17,7 +24,7 @@ func (n *Node) EnvironmentDir() string    { return "node_env" }
 func (n *Node) GetDefaultVersion() string { return "default" }
 
 func (n *Node) HealthCheck(prefix, version string) error {
-	envDir := filepath.Join(prefix, n.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, n.EnvironmentDir(), version)
 	nodePath := filepath.Join(envDir, "bin", "node")
 	cmd := exec.Command(nodePath, "--version")
 	if err := cmd.Run(); err != nil {
@@ -28,30 +35,115 @@ func (n *Node) HealthCheck(prefix, version string) error {
 
 // nodeEnvVars mirrors Python pre-commit's get_env_patch: npm's prefix is
 // pointed at the env so `npm install -g` lands the hook's executables in
-// envDir/bin, which Run then puts on PATH.
+// envDir/bin, which Run then puts on PATH. npm's download cache is shared
+// across envs (see npmCacheDir) while node_modules stays per-env.
 func nodeEnvVars(envDir string) []string {
-	return []string{
+	env := []string{
 		"NODE_VIRTUAL_ENV=" + envDir,
 		"NPM_CONFIG_PREFIX=" + envDir,
 		"npm_config_prefix=" + envDir,
 		"NODE_PATH=" + filepath.Join(envDir, "lib", "node_modules"),
 		PrependPath(filepath.Join(envDir, "bin")),
 	}
+	if os.Getenv("npm_config_cache") == "" && os.Getenv("NPM_CONFIG_CACHE") == "" {
+		env = append(env, "npm_config_cache="+npmCacheDir())
+	}
+	return env
+}
+
+// npmCacheDir is the npm cache shared by every node env in the store, so
+// tarballs downloaded for one hook are reused by the next. npm's cache is
+// content-addressed and safe for concurrent installs. A cache the caller
+// configured explicitly is left alone.
+func npmCacheDir() string {
+	return filepath.Join(store.DefaultDir(), store.NpmCacheDir)
+}
+
+// ResolveNodeDependencies makes relative file: specs in deps absolute
+// against dir, so a tarball or package directory named in the config is found
+// regardless of where npm runs. Registry, git and URL specs are unchanged.
+func ResolveNodeDependencies(deps []string, dir string) []string {
+	if dir == "" {
+		return deps
+	}
+	resolved := make([]string, len(deps))
+	for i, dep := range deps {
+		resolved[i] = dep
+		path, ok := strings.CutPrefix(dep, "file:")
+		if ok && path != "" && !filepath.IsAbs(path) {
+			resolved[i] = "file:" + filepath.Join(dir, path)
+		}
+	}
+	return resolved
+}
+
+// tarballDigests caches the digests NodeDependencyKey computes, with the
+// size and mtime each was computed for, so a tarball is read once however
+// many times its hooks' install keys are needed, while a replaced one is
+// still noticed.
+var tarballDigests struct {
+	sync.Mutex
+	byPath map[string]tarballDigest
+}
+
+type tarballDigest struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// NodeDependencyKey returns the install-state key for an npm dependency
+// spec. For a file: spec naming a regular file (a tarball), the content hash
+// is appended so replacing the tarball forces the env to be rebuilt.
+func NodeDependencyKey(dep string) string {
+	path, ok := strings.CutPrefix(dep, "file:")
+	if !ok {
+		return dep
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return dep
+	}
+
+	tarballDigests.Lock()
+	defer tarballDigests.Unlock()
+	if d, ok := tarballDigests.byPath[path]; ok && d.size == info.Size() && d.modTime.Equal(info.ModTime()) {
+		return dep + "@sha256:" + d.sum
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return dep
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return dep
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if tarballDigests.byPath == nil {
+		tarballDigests.byPath = make(map[string]tarballDigest)
+	}
+	tarballDigests.byPath[path] = tarballDigest{size: info.Size(), modTime: info.ModTime(), sum: sum}
+	return dep + "@sha256:" + sum
 }
 
 func (n *Node) InstallEnvironment(prefix, version string, additionalDeps []string) error {
-	envDir := filepath.Join(prefix, n.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, n.EnvironmentDir(), version)
 
 	nodeVersion := version
 	if nodeVersion == "default" {
 		nodeVersion = "system"
 	}
 
-	// Create the nodeenv ("system" symlinks the host node into the env).
-	cmd := exec.Command("nodeenv", "--prebuilt", "--clean-src", envDir, "-n", nodeVersion)
-	cmd.Dir = prefix
-	if out, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("nodeenv failed: %s: %w", string(out), err)
+	// For the system node, a bin/node link plus npm's per-env prefix is all
+	// nodeenv would set up, so skip it when the host already has a node.
+	if nodeVersion != "system" || linkSystemNode(envDir) != nil {
+		// Create the nodeenv ("system" symlinks the host node into the env).
+		cmd := exec.Command("nodeenv", "--prebuilt", "--clean-src", envDir, "-n", nodeVersion)
+		cmd.Dir = prefix
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("nodeenv failed: %s: %w", string(out), err)
+		}
 	}
 
 	env := nodeEnvVars(envDir)
@@ -60,7 +152,7 @@ func (n *Node) InstallEnvironment(prefix, version string, additionalDeps []strin
 	// install the package globally into the env alongside additional deps —
 	// the same local-install → pack → global-install dance as Python
 	// pre-commit, which is what creates the bin entry points in envDir/bin.
-	cmd = exec.Command("npm", "install")
+	cmd := exec.Command("npm", "install")
 	cmd.Dir = prefix
 	cmd.Env = append(cmd.Environ(), env...)
 	if out, err := cmd.CombinedOutput(); err != nil {
@@ -89,8 +181,40 @@ func (n *Node) InstallEnvironment(prefix, version string, additionalDeps []strin
 	return nil
 }
 
+// linkSystemNode creates a minimal env in envDir around the node on PATH:
+// bin/node links to it, and lib/node_modules is where `npm install -g`
+// puts packages under the env's npm prefix. It fails if there is no
+// working system node.
+func linkSystemNode(envDir string) error {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		return err
+	}
+	node, err = filepath.Abs(node)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command(node, "--version").Output()
+	if err != nil || !strings.HasPrefix(strings.TrimSpace(string(out)), "v") {
+		return fmt.Errorf("%s does not look like node: %q", node, out)
+	}
+	binDir := filepath.Join(envDir, "bin")
+	for _, dir := range []string{binDir, filepath.Join(envDir, "lib", "node_modules")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	link := filepath.Join(binDir, "node")
+	os.Remove(link)
+	if err := os.Symlink(node, link); err != nil {
+		os.RemoveAll(envDir)
+		return err
+	}
+	return nil
+}
+
 func (n *Node) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	envDir := filepath.Join(prefix, n.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, n.EnvironmentDir(), version)
 	env := nodeEnvVars(envDir)
 	return RunHookCommand(ctx, workDir, entry, args, fileArgs, env)
 }
@@ -0,0 +1,225 @@
+package languages
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Node — shared npm cache
+// ---------------------------------------------------------------------------
+
+func TestNodeEnvVarsSharedNpmCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", home)
+	t.Setenv("npm_config_cache", "")
+	t.Setenv("NPM_CONFIG_CACHE", "")
+
+	want := "npm_config_cache=" + filepath.Join(home, "npm-cache")
+	for _, envDir := range []string{"/repo1/node_env-default", "/repo2/node_env-default"} {
+		env := nodeEnvVars(envDir)
+		if !slices.Contains(env, want) {
+			t.Errorf("env for %s = %v, want it to contain %q", envDir, env, want)
+		}
+		if !slices.Contains(env, "NODE_PATH="+filepath.Join(envDir, "lib", "node_modules")) {
+			t.Errorf("env for %s should keep node_modules per-environment", envDir)
+		}
+	}
+}
+
+func TestNodeEnvVarsRespectsCallerCache(t *testing.T) {
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	t.Setenv("npm_config_cache", "/custom/cache")
+
+	for _, e := range nodeEnvVars("/repo/node_env-default") {
+		if e == "npm_config_cache="+npmCacheDir() {
+			t.Errorf("env must not override the caller's npm cache, got %q", e)
+		}
+	}
+}
+
+func TestNodeSharedNpmCacheParallel(t *testing.T) {
+	if _, err := exec.LookPath("npm"); err != nil {
+		t.Skip("npm not available")
+	}
+	home := t.TempDir()
+	t.Setenv("PRE_COMMIT_HOME", home)
+	t.Setenv("npm_config_cache", "")
+	t.Setenv("NPM_CONFIG_CACHE", "")
+
+	// Pack a local package so the cache can be populated offline.
+	pkgDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(pkgDir, "package.json"), []byte(`{"name":"demo","version":"1.0.0"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pack := exec.Command("npm", "pack")
+	pack.Dir = pkgDir
+	if out, err := pack.CombinedOutput(); err != nil {
+		t.Fatalf("npm pack failed: %v\n%s", err, out)
+	}
+	tarball := filepath.Join(pkgDir, "demo-1.0.0.tgz")
+
+	// Several envs add to the shared cache concurrently.
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	outs := make([][]byte, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			envDir := filepath.Join(t.TempDir(), "node_env-default")
+			cmd := exec.Command("npm", "cache", "add", tarball)
+			cmd.Env = append(cmd.Environ(), nodeEnvVars(envDir)...)
+			outs[i], errs[i] = cmd.CombinedOutput()
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("parallel npm cache add %d failed: %v\n%s", i, err, outs[i])
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(home, "npm-cache", "_cacache")); err != nil {
+		t.Errorf("expected shared npm cache under PRE_COMMIT_HOME: %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Node — additional_dependencies spec forms
+// ---------------------------------------------------------------------------
+
+func TestResolveNodeDependencies(t *testing.T) {
+	deps := []string{"file:./tools/foo-1.0.tgz", "file:../pkg", "file:/abs/bar.tgz", "git+https://github.com/org/tool#v2", "lodash@4"}
+	got := ResolveNodeDependencies(deps, "/cfg")
+	want := []string{"file:/cfg/tools/foo-1.0.tgz", "file:/pkg", "file:/abs/bar.tgz", "git+https://github.com/org/tool#v2", "lodash@4"}
+	if !slices.Equal(got, want) {
+		t.Errorf("ResolveNodeDependencies() = %v, want %v", got, want)
+	}
+	if deps[0] != "file:./tools/foo-1.0.tgz" {
+		t.Error("ResolveNodeDependencies must not modify its input")
+	}
+}
+
+func TestNodeDependencyKey(t *testing.T) {
+	dir := t.TempDir()
+	tgz := filepath.Join(dir, "foo-1.0.tgz")
+	os.WriteFile(tgz, []byte("tarball"), 0o644)
+
+	key := NodeDependencyKey("file:" + tgz)
+	if key == "file:"+tgz || !strings.HasPrefix(key, "file:"+tgz+"@sha256:") {
+		t.Errorf("expected content hash in key, got %q", key)
+	}
+	for _, dep := range []string{"file:" + dir, "file:" + filepath.Join(dir, "missing.tgz"), "git+https://github.com/org/tool#v2", "lodash@4"} {
+		if got := NodeDependencyKey(dep); got != dep {
+			t.Errorf("NodeDependencyKey(%q) = %q, want it unchanged", dep, got)
+		}
+	}
+}
+
+func TestNodeDependencyKeyCached(t *testing.T) {
+	tgz := filepath.Join(t.TempDir(), "foo-1.0.tgz")
+	os.WriteFile(tgz, []byte("tarball"), 0o644)
+	key := NodeDependencyKey("file:" + tgz)
+
+	// Same size and mtime: the cached digest is used without reading it.
+	info, _ := os.Stat(tgz)
+	os.WriteFile(tgz, []byte("TARBALL"), 0o644)
+	os.Chtimes(tgz, info.ModTime(), info.ModTime())
+	if got := NodeDependencyKey("file:" + tgz); got != key {
+		t.Errorf("expected the cached key %q, got %q", key, got)
+	}
+
+	// A replaced tarball is hashed again.
+	later := info.ModTime().Add(time.Second)
+	os.Chtimes(tgz, later, later)
+	if got := NodeDependencyKey("file:" + tgz); got == key {
+		t.Errorf("expected a new key after the tarball changed, got %q", got)
+	}
+}
+
+func TestNodeInstallEnvironmentPassesDependencySpecs(t *testing.T) {
+	bin := t.TempDir()
+	log := filepath.Join(t.TempDir(), "npm.log")
+	writeFakeBin(t, bin, "nodeenv", "exit 0\n")
+	writeFakeBin(t, bin, "npm", `echo "$@" >> `+log+`
+if [ "$1" = pack ]; then echo hook-1.0.0.tgz; fi
+`)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/bin"+string(os.PathListSeparator)+"/usr/bin")
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+
+	prefix := t.TempDir()
+	deps := []string{"file:/cfg/tools/foo-1.0.tgz", "git+https://github.com/org/tool#v2", "lodash@4"}
+	if err := (&Node{}).InstallEnvironment(prefix, "default", deps); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "install -g " + filepath.Join(prefix, "hook-1.0.0.tgz") + " " + strings.Join(deps, " ")
+	if !strings.Contains(string(data), want) {
+		t.Errorf("expected npm to be called with %q, got:\n%s", want, data)
+	}
+}
+
+func TestNodeInstallEnvironmentUsesSystemNode(t *testing.T) {
+	bin := t.TempDir()
+	log := filepath.Join(t.TempDir(), "calls.log")
+	writeFakeBin(t, bin, "node", "echo v20.11.0\n")
+	writeFakeBin(t, bin, "nodeenv", `echo "nodeenv $@" >> `+log+"\n")
+	writeFakeBin(t, bin, "npm", `echo "npm $npm_config_prefix $@" >> `+log+`
+if [ "$1" = pack ]; then echo hook-1.0.0.tgz; fi
+`)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/bin")
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+
+	prefix := t.TempDir()
+	if err := (&Node{}).InstallEnvironment(prefix, "default", []string{"prettier@3"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "nodeenv") {
+		t.Errorf("nodeenv must not run when a system node exists:\n%s", data)
+	}
+	envDir := filepath.Join(prefix, "node_env-default")
+	want := "npm " + envDir + " install -g " + filepath.Join(prefix, "hook-1.0.0.tgz") + " prettier@3"
+	if !strings.Contains(string(data), want) {
+		t.Errorf("expected deps installed under the env prefix (%q), got:\n%s", want, data)
+	}
+	if target, err := os.Readlink(filepath.Join(envDir, "bin", "node")); err != nil || target != filepath.Join(bin, "node") {
+		t.Errorf("expected bin/node to link to the system node, got %q, %v", target, err)
+	}
+	if err := (&Node{}).HealthCheck(prefix, "default"); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+}
+
+func TestNodeInstallEnvironmentFallsBackToNodeenv(t *testing.T) {
+	bin := t.TempDir()
+	log := filepath.Join(t.TempDir(), "calls.log")
+	writeFakeBin(t, bin, "nodeenv", `echo "nodeenv $@" >> `+log+"\n")
+	writeFakeBin(t, bin, "npm", `if [ "$1" = pack ]; then echo hook-1.0.0.tgz; fi
+`)
+	t.Setenv("PATH", bin) // no node on PATH
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+
+	if err := (&Node{}).InstallEnvironment(t.TempDir(), "default", nil); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(log)
+	if !strings.Contains(string(data), "nodeenv --prebuilt") {
+		t.Errorf("expected nodeenv without a system node, got:\n%s", data)
+	}
+}
@@ -2,6 +2,7 @@ package languages
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"os"
 	"os/exec"
@@ -18,13 +19,21 @@ var condaLang = &SimpleLanguage{
 		if err := cmd.Run(); err != nil {
 			return fmt.Errorf("%s not available: %w", condaExe, err)
 		}
+		// Every conda env records its installed packages in conda-meta.
+		if !dirExists(filepath.Join(envDirPath(prefix, "conda_env", version), "conda-meta")) {
+			return fmt.Errorf("conda environment unhealthy: conda-meta missing")
+		}
 		return nil
 	},
-	InstallFn: func(prefix, version, envDirName string, additionalDeps []string) error {
-		envDir := filepath.Join(prefix, envDirName+"-"+version)
+	EnvFile: "environment.yml",
+	EnvFileInstallFn: func(prefix, version, envDirName, envFile string, additionalDeps []string) error {
+		envDir := envDirPath(prefix, envDirName, version)
 		condaExe := condaExecutable()
 
-		cmd := exec.Command(condaExe, "env", "create", "--file", "environment.yml", "--prefix", envDir)
+		if path := filepath.Join(prefix, envFile); !regularFile(path) {
+			return fmt.Errorf("conda environment file not found: %s", path)
+		}
+		cmd := exec.Command(condaExe, "env", "create", "--file", envFile, "--prefix", envDir)
 		cmd.Dir = prefix
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("%s env create failed: %s: %w", condaExe, string(out), err)
@@ -47,6 +56,27 @@ var condaLang = &SimpleLanguage{
 			fmt.Sprintf("CONDA_PREFIX=%s", envDir),
 		}
 	},
+	// environment.yml lists the packages and channels the env is created
+	// from, so any edit to it must rebuild the env.
+	FingerprintFn: func(prefix, envFile string) string {
+		data, err := os.ReadFile(filepath.Join(prefix, envFile))
+		if err != nil {
+			return ""
+		}
+		return fmt.Sprintf("%s=%x", envFile, sha256.Sum256(data))
+	},
+}
+
+// regularFile reports whether path exists and is not a directory.
+func regularFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// dirExists reports whether path exists and is a directory.
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
 }
 
 // condaExecutable returns the conda-like executable to use, respecting
@@ -88,9 +118,10 @@ var dartLang = &SimpleLanguage{
 	LangName:     "dart",
 	EnvDirName:   "dart_env",
 	HealthCmd:    []string{"dart", "--version"},
+	InstallURL:   "https://dart.dev/get-dart",
 	RunBinSubdir: "bin",
 	InstallFn: func(prefix, version, envDirName string, _ []string) error {
-		envDir := filepath.Join(prefix, envDirName+"-"+version)
+		envDir := envDirPath(prefix, envDirName, version)
 		binDir := filepath.Join(envDir, "bin")
 
 		matches, _ := filepath.Glob(filepath.Join(prefix, "bin", "*.dart"))
@@ -114,6 +145,7 @@ var dotnetLang = &SimpleLanguage{
 	LangName:   "dotnet",
 	EnvDirName: "dotnet_env",
 	HealthCmd:  []string{"dotnet", "--version"},
+	InstallURL: "https://dotnet.microsoft.com/download",
 	InstallCmd: func(envDir, prefix string) (string, []string) {
 		return "dotnet", []string{"tool", "install", "--tool-path", envDir, "--add-source", "."}
 	},
@@ -124,6 +156,7 @@ var haskellLang = &SimpleLanguage{
 	LangName:   "haskell",
 	EnvDirName: "hs_env",
 	HealthCmd:  []string{"cabal", "--version"},
+	InstallURL: "https://www.haskell.org/ghcup/",
 	InstallCmd: func(envDir, prefix string) (string, []string) {
 		return "cabal", []string{"install", "--install-method=copy", "--installdir=" + envDir}
 	},
@@ -134,6 +167,7 @@ var luaLang = &SimpleLanguage{
 	LangName:     "lua",
 	EnvDirName:   "lua_env",
 	HealthCmd:    []string{"luarocks", "--version"},
+	InstallURL:   "https://luarocks.org/#quick-start",
 	RunBinSubdir: "bin",
 	InstallCmd: func(envDir, prefix string) (string, []string) {
 		return "luarocks", []string{"install", "--tree", envDir, prefix}
@@ -145,6 +179,7 @@ var perlLang = &SimpleLanguage{
 	LangName:     "perl",
 	EnvDirName:   "perl_env",
 	HealthCmd:    []string{"perl", "--version"},
+	InstallURL:   "https://www.perl.org/get.html",
 	RunBinSubdir: "bin",
 	InstallCmd: func(envDir, prefix string) (string, []string) {
 		return "cpan", []string{"-T", "-l", envDir, "."}
@@ -166,6 +201,7 @@ var rLang = &SimpleLanguage{
 	LangName:   "r",
 	EnvDirName: "r_env",
 	HealthCmd:  []string{"Rscript", "--version"},
+	InstallURL: "https://cloud.r-project.org/",
 	InstallCmd: func(envDir, prefix string) (string, []string) {
 		script := fmt.Sprintf(
 			"renv::activate(project = '%s')\nrenv::restore(project = '%s')\n",
@@ -196,7 +232,7 @@ func (j *Julia) HealthCheck(prefix, version string) error {
 }
 
 func (j *Julia) InstallEnvironment(prefix, version string, additionalDeps []string) error {
-	envDir := filepath.Join(prefix, j.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, j.EnvironmentDir(), version)
 
 	installScript := `
 using Pkg
@@ -218,7 +254,7 @@ Pkg.instantiate()
 }
 
 func (j *Julia) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	envDir := filepath.Join(prefix, j.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, j.EnvironmentDir(), version)
 
 	parts := ParseEntry(entry)
 	if len(parts) == 0 {
@@ -251,7 +287,7 @@ func (s *Swift) HealthCheck(prefix, version string) error {
 }
 
 func (s *Swift) InstallEnvironment(prefix, version string, additionalDeps []string) error {
-	envDir := filepath.Join(prefix, s.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, s.EnvironmentDir(), version)
 	binDir := filepath.Join(envDir, "bin")
 
 	cmd := exec.Command("swift", "build", "-c", "release", "--build-path", envDir)
@@ -272,7 +308,7 @@ func (s *Swift) InstallEnvironment(prefix, version string, additionalDeps []stri
 }
 
 func (s *Swift) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	envDir := filepath.Join(prefix, s.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, s.EnvironmentDir(), version)
 	binDir := filepath.Join(envDir, "bin")
 	env := []string{PrependPath(binDir)}
 	return RunHookCommand(ctx, workDir, entry, args, fileArgs, env)
@@ -0,0 +1,180 @@
+package languages
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// isVersionSpecifier reports whether a language_version is a PEP 440
+// version specifier such as ">=3.10,<3.13" rather than an interpreter name.
+func isVersionSpecifier(version string) bool {
+	return strings.ContainsAny(version, "<>=!~")
+}
+
+// versionClause is one comma-separated clause of a version specifier.
+type versionClause struct {
+	op       string
+	release  []int
+	wildcard bool   // "==3.11.*" or "!=3.11.*"
+	raw      string // the version as written, for "==="
+}
+
+// versionSpecifier is a parsed PEP 440 version specifier. A version
+// satisfies it when it satisfies every clause. Only the release segment of
+// a version is compared; pre-, post- and dev-release suffixes are not
+// supported.
+type versionSpecifier []versionClause
+
+// parseVersionSpecifier parses a specifier such as ">=3.10,<3.13",
+// "~=3.11" or "==3.12.*".
+func parseVersionSpecifier(s string) (versionSpecifier, error) {
+	var spec versionSpecifier
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		op := ""
+		for _, candidate := range []string{"===", "~=", "==", "!=", "<=", ">=", "<", ">"} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return nil, fmt.Errorf("invalid version specifier %q: %q has no operator", s, part)
+		}
+		v := strings.TrimSpace(part[len(op):])
+		c := versionClause{op: op, raw: v}
+		if op == "===" {
+			spec = append(spec, c)
+			continue
+		}
+		if rest, ok := strings.CutSuffix(v, ".*"); ok {
+			if op != "==" && op != "!=" {
+				return nil, fmt.Errorf("invalid version specifier %q: %s does not allow a wildcard", s, op)
+			}
+			c.wildcard = true
+			v = rest
+		}
+		release, err := parseRelease(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version specifier %q: %w", s, err)
+		}
+		if op == "~=" && len(release) < 2 {
+			return nil, fmt.Errorf("invalid version specifier %q: ~= needs at least two version components", s)
+		}
+		c.release = release
+		spec = append(spec, c)
+	}
+	return spec, nil
+}
+
+// parseRelease parses a release version such as "3.11.4".
+func parseRelease(v string) ([]int, error) {
+	var release []int
+	for field := range strings.SplitSeq(v, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version %q", v)
+		}
+		release = append(release, n)
+	}
+	return release, nil
+}
+
+// compareRelease compares two release versions, padding the shorter one
+// with zeros so that 3.11 == 3.11.0.
+func compareRelease(a, b []int) int {
+	for i := range max(len(a), len(b)) {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// hasReleasePrefix reports whether v starts with prefix, padding v with
+// zeros, so 3.11 matches 3.11.*.
+func hasReleasePrefix(v, prefix []int) bool {
+	for i, p := range prefix {
+		n := 0
+		if i < len(v) {
+			n = v[i]
+		}
+		if n != p {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether version v satisfies every clause of spec.
+func (spec versionSpecifier) matches(v []int) bool {
+	for _, c := range spec {
+		var ok bool
+		switch c.op {
+		case "===":
+			ok = c.raw == formatRelease(v)
+		case "==":
+			ok = c.wildcard && hasReleasePrefix(v, c.release) || !c.wildcard && compareRelease(v, c.release) == 0
+		case "!=":
+			ok = c.wildcard && !hasReleasePrefix(v, c.release) || !c.wildcard && compareRelease(v, c.release) != 0
+		case "<=":
+			ok = compareRelease(v, c.release) <= 0
+		case ">=":
+			ok = compareRelease(v, c.release) >= 0
+		case "<":
+			ok = compareRelease(v, c.release) < 0
+		case ">":
+			ok = compareRelease(v, c.release) > 0
+		case "~=":
+			// ~=3.11.2 means >=3.11.2 and ==3.11.*.
+			ok = compareRelease(v, c.release) >= 0 && hasReleasePrefix(v, c.release[:len(c.release)-1])
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// newestMatching returns the newest of versions that satisfies spec.
+func (spec versionSpecifier) newestMatching(versions [][]int) ([]int, bool) {
+	var best []int
+	for _, v := range versions {
+		if spec.matches(v) && (best == nil || compareRelease(v, best) > 0) {
+			best = v
+		}
+	}
+	return best, best != nil
+}
+
+// formatRelease formats a release version as "3.11.4".
+func formatRelease(v []int) string {
+	fields := make([]string, len(v))
+	for i, n := range v {
+		fields[i] = strconv.Itoa(n)
+	}
+	return strings.Join(fields, ".")
+}
+
+// sortedReleases returns versions formatted and sorted oldest first, for
+// error messages.
+func sortedReleases(versions [][]int) []string {
+	sorted := slices.Clone(versions)
+	slices.SortFunc(sorted, compareRelease)
+	var out []string
+	for _, v := range sorted {
+		if s := formatRelease(v); !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,60 @@
+//go:build !windows
+
+package languages
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// setProcessGroup starts cmd in its own process group so that, when ctx is
+// cancelled, the signal that interrupted the run reaches the hook and any
+// children it spawned. Whatever is left of the group after InterruptGrace
+// is killed.
+//
+// A hook whose output is streamed reads the terminal, which a background
+// process group may not do without being stopped by SIGTTIN, so it stays in
+// the foreground group and only the hook itself is signalled.
+func setProcessGroup(ctx context.Context, cmd *exec.Cmd) {
+	if outputStream(ctx) != nil {
+		cmd.Cancel = func() error {
+			sig, ok := interruptSignal(ctx).(syscall.Signal)
+			if !ok {
+				sig = syscall.SIGKILL
+			}
+			if err := cmd.Process.Signal(sig); err != nil {
+				return err
+			}
+			if sig != syscall.SIGKILL {
+				time.AfterFunc(InterruptGrace, func() { cmd.Process.Kill() })
+			}
+			return nil
+		}
+		cmd.WaitDelay = 2 * InterruptGrace
+		return
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		pgid := cmd.Process.Pid
+		sig, ok := interruptSignal(ctx).(syscall.Signal)
+		if !ok {
+			sig = syscall.SIGKILL
+		}
+		if err := syscall.Kill(-pgid, sig); err != nil {
+			if err == syscall.ESRCH {
+				return os.ErrProcessDone
+			}
+			return err
+		}
+		if sig != syscall.SIGKILL {
+			time.AfterFunc(InterruptGrace, func() { syscall.Kill(-pgid, syscall.SIGKILL) })
+		}
+		return nil
+	}
+	// Children that outlive the hook keep its output pipe open; stop
+	// waiting for them once the group has been killed.
+	cmd.WaitDelay = 2 * InterruptGrace
+}
@@ -0,0 +1,39 @@
+//go:build !windows
+
+package languages
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestSetProcessGroup_StreamedHookStaysInForeground(t *testing.T) {
+	if _, err := exec.LookPath("ps"); err != nil {
+		t.Skip("ps not available")
+	}
+	pgid := func(ctx context.Context) int {
+		t.Helper()
+		code, out, err := RunCommand(ctx, t.TempDir(), "sh", "-c", "ps -o pgid= -p $$")
+		if err != nil || code != 0 {
+			t.Fatalf("RunCommand = %d, %v: %s", code, err, out)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+		if err != nil {
+			t.Fatalf("parse pgid %q: %v", out, err)
+		}
+		return n
+	}
+
+	own := syscall.Getpgrp()
+	if got := pgid(context.Background()); got == own {
+		t.Errorf("captured hook ran in this process group %d", own)
+	}
+	if got := pgid(WithOutputStream(context.Background(), &bytes.Buffer{})); got != own {
+		t.Errorf("streamed hook ran in process group %d, want %d", got, own)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package languages
+
+import (
+	"context"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on Windows, where exec.CommandContext kills
+// the hook process itself when ctx is cancelled.
+func setProcessGroup(ctx context.Context, cmd *exec.Cmd) {}
@@ -3,8 +3,12 @@ package languages
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 )
 
 // Python implements the Language interface for Python hooks.
@@ -14,19 +18,257 @@ func (p *Python) Name() string              { return "python" }
 func (p *Python) EnvironmentDir() string    { return "py_env" }
 func (p *Python) GetDefaultVersion() string { return "python3" }
 
+// resolvedPython caches the resolution of language_version "latest" and of
+// version specifiers for the PATH they were resolved under, so the hooks of
+// one run share a single pyenv lookup while a changed PATH (say, a newly
+// activated pyenv) is noticed.
+var resolvedPython struct {
+	sync.Mutex
+	path     string
+	versions map[string]string
+}
+
+func cachedPythonResolution(version string, resolve func() string) string {
+	resolvedPython.Lock()
+	defer resolvedPython.Unlock()
+	path := os.Getenv("PATH")
+	if resolvedPython.versions == nil || resolvedPython.path != path {
+		resolvedPython.versions = map[string]string{}
+		resolvedPython.path = path
+	}
+	if v, ok := resolvedPython.versions[version]; ok {
+		return v
+	}
+	v := resolve()
+	resolvedPython.versions[version] = v
+	return v
+}
+
+// forgetPythonResolutions drops cached resolutions, after an interpreter
+// was installed.
+func forgetPythonResolutions() {
+	resolvedPython.Lock()
+	defer resolvedPython.Unlock()
+	resolvedPython.versions = nil
+}
+
+// resolveVersion maps language_version "latest" to a concrete interpreter
+// name so the env is named after the version it was built with instead of a
+// literal py_env-latest that silently drifts. A version specifier such as
+// ">=3.10,<3.13" maps to the newest installed interpreter satisfying it,
+// and stays as is when there is none.
+func (p *Python) resolveVersion(version string) string {
+	switch {
+	case version == "latest":
+		return cachedPythonResolution(version, findLatestPython)
+	case isVersionSpecifier(version):
+		return cachedPythonResolution(version, func() string {
+			if python, err := findPythonForSpecifier(version); err == nil {
+				return python
+			}
+			return version
+		})
+	}
+	return version
+}
+
+var (
+	pyenvVersionRe  = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?$`)
+	pythonBinNameRe = regexp.MustCompile(`^python(\d+)\.(\d+)$`)
+)
+
+// findLatestPython returns the interpreter of the newest CPython installed
+// via pyenv, or failing that the newest pythonX.Y executable on PATH. It
+// falls back to the default version when neither is found.
+func findLatestPython() string {
+	for _, candidates := range [][]pythonCandidate{pyenvPythons(), pathPythons()} {
+		if best := newestPython(candidates, nil); best != nil {
+			return best.python
+		}
+	}
+	return (&Python{}).GetDefaultVersion()
+}
+
+// pythonCandidate is an installed interpreter that "latest" or a version
+// specifier may select.
+type pythonCandidate struct {
+	version []int
+	python  string // what to create the venv with
+}
+
+// pyenvPythons returns the CPython versions installed via pyenv, each with
+// the path of its interpreter.
+func pyenvPythons() []pythonCandidate {
+	out, err := exec.Command("pyenv", "root").Output()
+	if err != nil {
+		return nil
+	}
+	root := strings.TrimSpace(string(out))
+	if out, err = exec.Command("pyenv", "versions", "--bare").Output(); err != nil {
+		return nil
+	}
+	var found []pythonCandidate
+	for _, line := range strings.Split(string(out), "\n") {
+		name := strings.TrimSpace(line)
+		if !pyenvVersionRe.MatchString(name) {
+			continue
+		}
+		v, _ := parseRelease(name)
+		found = append(found, pythonCandidate{version: v, python: filepath.Join(root, "versions", name, "bin", "python")})
+	}
+	return found
+}
+
+// pathPythons returns the pythonX.Y executables on PATH, with the version
+// each reports, or X.Y if it can't be asked.
+func pathPythons() []pythonCandidate {
+	var found []pythonCandidate
+	seen := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !pythonBinNameRe.MatchString(name) || seen[name] {
+				continue
+			}
+			seen[name] = true
+			v, _ := parseRelease(strings.TrimPrefix(name, "python"))
+			if out, err := exec.Command(filepath.Join(dir, name), "--version").Output(); err == nil {
+				if full, err := parseRelease(strings.TrimPrefix(strings.TrimSpace(string(out)), "Python ")); err == nil {
+					v = full
+				}
+			}
+			found = append(found, pythonCandidate{version: v, python: name})
+		}
+	}
+	return found
+}
+
+// newestPython returns the newest of candidates that match accepts, or any
+// of them if match is nil, or nil if there is none.
+func newestPython(candidates []pythonCandidate, match func([]int) bool) *pythonCandidate {
+	var best *pythonCandidate
+	for i, c := range candidates {
+		if (match == nil || match(c.version)) && (best == nil || compareRelease(c.version, best.version) > 0) {
+			best = &candidates[i]
+		}
+	}
+	return best
+}
+
+// findPythonForSpecifier returns the interpreter for the newest installed
+// Python satisfying the version specifier spec, preferring pyenv's
+// versions over those on PATH. The error lists the versions available.
+func findPythonForSpecifier(spec string) (string, error) {
+	parsed, err := parseVersionSpecifier(spec)
+	if err != nil {
+		return "", err
+	}
+	var available [][]int
+	for _, candidates := range [][]pythonCandidate{pyenvPythons(), pathPythons()} {
+		for _, c := range candidates {
+			available = append(available, c.version)
+		}
+		if best := newestPython(candidates, parsed.matches); best != nil {
+			return best.python, nil
+		}
+	}
+	if len(available) == 0 {
+		return "", fmt.Errorf("no installed Python satisfies %s (none found)", spec)
+	}
+	return "", fmt.Errorf("no installed Python satisfies %s (available: %s)", spec, strings.Join(sortedReleases(available), ", "))
+}
+
+// installPythonForSpecifier installs the newest CPython release satisfying
+// spec with pyenv, for when none installed does. notFound is the error to
+// report if pyenv is unavailable or knows no such release.
+func installPythonForSpecifier(spec string, notFound error) error {
+	parsed, err := parseVersionSpecifier(spec)
+	if err != nil {
+		return err
+	}
+	out, err := exec.Command("pyenv", "install", "--list").Output()
+	if err != nil {
+		return notFound
+	}
+	var releases [][]int
+	for _, line := range strings.Split(string(out), "\n") {
+		name := strings.TrimSpace(line)
+		if pyenvVersionRe.MatchString(name) {
+			v, _ := parseRelease(name)
+			releases = append(releases, v)
+		}
+	}
+	newest, ok := parsed.newestMatching(releases)
+	if !ok {
+		return notFound
+	}
+	if out, err := exec.Command("pyenv", "install", "-s", formatRelease(newest)).CombinedOutput(); err != nil {
+		return fmt.Errorf("pyenv install %s failed: %s: %w", formatRelease(newest), out, err)
+	}
+	forgetPythonResolutions()
+	return nil
+}
+
+// HealthCheck verifies both the env's interpreter and its pip run, and that
+// its pyvenv.cfg still points at an existing base installation. A venv
+// whose interpreter works but whose pip is missing or broken is reported
+// separately, since it only surfaces later as a confusing dependency install
+// failure.
 func (p *Python) HealthCheck(prefix, version string) error {
-	envDir := filepath.Join(prefix, p.EnvironmentDir()+"-"+version)
+	version = p.resolveVersion(version)
+	envDir := envDirPath(prefix, p.EnvironmentDir(), version)
 	binDir := filepath.Join(envDir, "bin")
 	pythonPath := filepath.Join(binDir, "python")
-	cmd := exec.Command(pythonPath, "--version")
-	if err := cmd.Run(); err != nil {
+	if err := exec.Command(pythonPath, "--version").Run(); err != nil {
+		return fmt.Errorf("python environment unhealthy: interpreter missing or broken: %w", err)
+	}
+	if err := checkPyvenvCfg(envDir); err != nil {
 		return fmt.Errorf("python environment unhealthy: %w", err)
 	}
+	if err := exec.Command(filepath.Join(binDir, "pip"), "--version").Run(); err != nil {
+		return fmt.Errorf("python environment unhealthy: pip missing or broken: %w", err)
+	}
 	return nil
 }
 
+// checkPyvenvCfg verifies the venv's pyvenv.cfg names a base installation
+// ("home") that still exists.
+func checkPyvenvCfg(envDir string) error {
+	data, err := os.ReadFile(filepath.Join(envDir, "pyvenv.cfg"))
+	if err != nil {
+		return fmt.Errorf("pyvenv.cfg missing: %w", err)
+	}
+	for line := range strings.Lines(string(data)) {
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "home" {
+			continue
+		}
+		home := strings.TrimSpace(value)
+		if info, err := os.Stat(home); err != nil || !info.IsDir() {
+			return fmt.Errorf("pyvenv.cfg home %s does not exist", home)
+		}
+		return nil
+	}
+	return fmt.Errorf("pyvenv.cfg has no home")
+}
+
 func (p *Python) InstallEnvironment(prefix, version string, additionalDeps []string) error {
-	envDir := filepath.Join(prefix, p.EnvironmentDir()+"-"+version)
+	if spec := version; isVersionSpecifier(spec) && isVersionSpecifier(p.resolveVersion(spec)) {
+		// No installed interpreter satisfies spec; try to install one.
+		_, notFound := findPythonForSpecifier(spec)
+		if err := installPythonForSpecifier(spec, notFound); err != nil {
+			return err
+		}
+		if isVersionSpecifier(p.resolveVersion(spec)) {
+			return notFound
+		}
+	}
+	version = p.resolveVersion(version)
+	envDir := envDirPath(prefix, p.EnvironmentDir(), version)
 
 	python := version
 	if python == "default" {
@@ -59,7 +301,8 @@ func (p *Python) InstallEnvironment(prefix, version string, additionalDeps []str
 }
 
 func (p *Python) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	envDir := filepath.Join(prefix, p.EnvironmentDir()+"-"+version)
+	version = p.resolveVersion(version)
+	envDir := envDirPath(prefix, p.EnvironmentDir(), version)
 	binDir := filepath.Join(envDir, "bin")
 	env := []string{
 		PrependPath(binDir),
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -201,6 +202,63 @@ func TestPythonHealthCheckMissingExecutable(t *testing.T) {
 	}
 }
 
+// makeFakePythonEnv creates a py_env-default under a temp prefix whose bin
+// dir holds the given fake executables, with a pyvenv.cfg pointing at an
+// existing home.
+func makeFakePythonEnv(t *testing.T, bins map[string]string) string {
+	t.Helper()
+	prefix := t.TempDir()
+	envDir := filepath.Join(prefix, (&Python{}).EnvironmentDir()+"-default")
+	binDir := filepath.Join(envDir, "bin")
+	if err := os.MkdirAll(binDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, script := range bins {
+		writeFakeBin(t, binDir, name, script)
+	}
+	if err := os.WriteFile(filepath.Join(envDir, "pyvenv.cfg"), []byte("home = "+t.TempDir()+"\nversion = 3.12.0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return prefix
+}
+
+func TestPythonHealthCheckFakeEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		bins    map[string]string
+		wantErr string
+	}{
+		{"healthy", map[string]string{"python": "exit 0\n", "pip": "exit 0\n"}, ""},
+		{"interpreter missing", map[string]string{"pip": "exit 0\n"}, "interpreter missing or broken"},
+		{"pip missing", map[string]string{"python": "exit 0\n"}, "pip missing or broken"},
+		{"pip broken", map[string]string{"python": "exit 0\n", "pip": "echo 'No module named pip' >&2; exit 1\n"}, "pip missing or broken"},
+		{"pyvenv.cfg missing", map[string]string{"python": "exit 0\n", "pip": "exit 0\n"}, "pyvenv.cfg missing"},
+		{"pyvenv.cfg home gone", map[string]string{"python": "exit 0\n", "pip": "exit 0\n"}, "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix := makeFakePythonEnv(t, tt.bins)
+			cfg := filepath.Join(prefix, "py_env-default", "pyvenv.cfg")
+			switch tt.name {
+			case "pyvenv.cfg missing":
+				os.Remove(cfg)
+			case "pyvenv.cfg home gone":
+				os.WriteFile(cfg, []byte("home = "+filepath.Join(prefix, "gone")+"\n"), 0o644)
+			}
+			err := (&Python{}).HealthCheck(prefix, "default")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("HealthCheck() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("HealthCheck() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 // TestPythonRunSimpleHook mirrors test_simple_python_hook: install a project
 // with a console-script entry and verify stdout contains the expected output.
 func TestPythonRunSimpleHook(t *testing.T) {
@@ -263,3 +321,247 @@ func TestPythonRunExitCode(t *testing.T) {
 		t.Errorf("exit code = %d, want 42", code)
 	}
 }
+
+// ---------------------------------------------------------------------------
+// language_version: latest
+// ---------------------------------------------------------------------------
+
+// writeFakeBin writes an executable shell script named name into dir.
+func writeFakeBin(t *testing.T, dir, name, script string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindLatestPythonFromPyenv(t *testing.T) {
+	// The pyenv version need not be active, so its interpreter is named by
+	// path rather than looked up on PATH.
+	root := fakePyenvWithVersions(t, []string{"system", "3.9.18", "3.12.1", "3.11.7", "3.13-dev", "pypy3.10-7.3.15", "3.10.13"}, nil)
+
+	if got, want := findLatestPython(), filepath.Join(root, "versions", "3.12.1", "bin", "python"); got != want {
+		t.Errorf("findLatestPython() = %q, want %q", got, want)
+	}
+}
+
+func TestFindLatestPythonFromPath(t *testing.T) {
+	bin := t.TempDir()
+	for _, name := range []string{"python3.9", "python3.11", "python3.10", "python3.11-config"} {
+		writeFakeBin(t, bin, name, "exit 0\n")
+	}
+	t.Setenv("PATH", bin)
+
+	if got := findLatestPython(); got != "python3.11" {
+		t.Errorf("findLatestPython() = %q, want %q", got, "python3.11")
+	}
+}
+
+func TestFindLatestPythonFallback(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	if got := findLatestPython(); got != "python3" {
+		t.Errorf("findLatestPython() = %q, want %q", got, "python3")
+	}
+}
+
+// fakePyenvCounting puts a pyenv on PATH that reports 3.12.1 and appends a
+// line to the returned log every time its versions are listed.
+func fakePyenvCounting(t testing.TB) (bin, log string) {
+	bin = t.TempDir()
+	log = filepath.Join(t.TempDir(), "calls")
+	script := "#!/bin/sh\ncase \"$1\" in\nroot) echo /pyenv ;;\nversions) echo call >> " + log + "; echo 3.12.1 ;;\nesac\n"
+	if err := os.WriteFile(filepath.Join(bin, "pyenv"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return bin, log
+}
+
+func countLines(path string) int {
+	data, _ := os.ReadFile(path)
+	return strings.Count(string(data), "\n")
+}
+
+func TestPythonResolveVersionLatestCachedPerPath(t *testing.T) {
+	bin, log := fakePyenvCounting(t)
+	t.Setenv("PATH", bin)
+
+	p := &Python{}
+	for range 5 {
+		if got := p.resolveVersion("latest"); got != "/pyenv/versions/3.12.1/bin/python" {
+			t.Fatalf("resolveVersion(latest) = %q, want /pyenv/versions/3.12.1/bin/python", got)
+		}
+	}
+	if n := countLines(log); n != 1 {
+		t.Errorf("pyenv ran %d times for 5 resolutions, want 1", n)
+	}
+
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+t.TempDir())
+	p.resolveVersion("latest")
+	if n := countLines(log); n != 2 {
+		t.Errorf("pyenv ran %d times after PATH changed, want 2", n)
+	}
+}
+
+// BenchmarkPythonResolveVersionLatest resolves language_version "latest"
+// for a config with five Python hooks and reports the pyenv invocations per
+// run, with and without the per-PATH cache.
+func BenchmarkPythonResolveVersionLatest(b *testing.B) {
+	bin, log := fakePyenvCounting(b)
+	b.Setenv("PATH", bin)
+	p := &Python{}
+
+	for _, bm := range []struct {
+		name    string
+		resolve func() string
+	}{
+		{"uncached", findLatestPython},
+		{"cached", func() string { return p.resolveVersion("latest") }},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			os.Remove(log)
+			// A fresh PATH entry forces one real lookup per run.
+			for i := 0; b.Loop(); i++ {
+				os.Setenv("PATH", bin+string(os.PathListSeparator)+strconv.Itoa(i))
+				for range 5 {
+					bm.resolve()
+				}
+			}
+			b.ReportMetric(float64(countLines(log))/float64(b.N), "pyenv-calls/op")
+		})
+	}
+}
+
+func TestPythonResolveVersionPassesThroughConcrete(t *testing.T) {
+	p := &Python{}
+	for _, v := range []string{"default", "python3.11", "python3"} {
+		if got := p.resolveVersion(v); got != v {
+			t.Errorf("resolveVersion(%q) = %q, want unchanged", v, got)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// language_version: version specifiers
+// ---------------------------------------------------------------------------
+
+func TestVersionSpecifierMatches(t *testing.T) {
+	tests := []struct {
+		spec    string
+		version string
+		want    bool
+	}{
+		{">=3.10,<3.13", "3.12.4", true},
+		{">=3.10,<3.13", "3.13.0", false},
+		{">=3.10,<3.13", "3.9.18", false},
+		{">= 3.10 , < 3.13", "3.10", true},
+		{"==3.11.*", "3.11.9", true},
+		{"==3.11.*", "3.12.0", false},
+		{"!=3.11.*", "3.11.2", false},
+		{"==3.11", "3.11.0", true},
+		{"!=3.11.4", "3.11.5", true},
+		{"~=3.11", "3.12.1", true},
+		{"~=3.11", "4.0.0", false},
+		{"~=3.11.2", "3.11.8", true},
+		{"~=3.11.2", "3.12.0", false},
+		{">3.11,<=3.12", "3.12.0", true},
+		{"===3.12.1", "3.12.1", true},
+		{"===3.12", "3.12.0", false},
+	}
+	for _, tt := range tests {
+		spec, err := parseVersionSpecifier(tt.spec)
+		if err != nil {
+			t.Fatalf("parseVersionSpecifier(%q): %v", tt.spec, err)
+		}
+		v, _ := parseRelease(tt.version)
+		if got := spec.matches(v); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.spec, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestParseVersionSpecifierInvalid(t *testing.T) {
+	for _, spec := range []string{">=3.x", "3.11,<4", "~=3", ">=3.11.*"} {
+		if _, err := parseVersionSpecifier(spec); err == nil {
+			t.Errorf("parseVersionSpecifier(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+// fakePyenvWithVersions puts a pyenv on PATH with the given versions
+// installed under its root and the given releases available to install.
+// Installing one adds it to the installed versions.
+func fakePyenvWithVersions(t *testing.T, installed, releases []string) (root string) {
+	t.Helper()
+	bin := t.TempDir()
+	root = t.TempDir()
+	list := filepath.Join(t.TempDir(), "installed")
+	if err := os.WriteFile(list, []byte(strings.Join(installed, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	writeFakeBin(t, bin, "pyenv", `case "$1" in
+root) echo `+root+` ;;
+versions) cat `+list+` ;;
+install)
+	if [ "$2" = --list ]; then
+		printf '%s\n' `+strings.Join(releases, " ")+`
+		exit 0
+	fi
+	echo "$3" >> `+list+`
+	mkdir -p `+root+`/versions/$3/bin
+	printf '#!/bin/sh\nmkdir -p "$2/bin" && printf "#!/bin/sh\\n" > "$2/bin/pip" && chmod +x "$2/bin/pip"\n' > `+root+`/versions/$3/bin/python
+	chmod +x `+root+`/versions/$3/bin/python
+	;;
+esac
+`)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/bin"+string(os.PathListSeparator)+"/usr/bin")
+	return root
+}
+
+func TestPythonResolveVersionSpecifier(t *testing.T) {
+	root := fakePyenvWithVersions(t, []string{"system", "3.9.18", "3.11.7", "3.12.1", "3.13.0", "3.13-dev"}, nil)
+	p := &Python{}
+
+	if got, want := p.resolveVersion(">=3.10,<3.13"), filepath.Join(root, "versions", "3.12.1", "bin", "python"); got != want {
+		t.Errorf("resolveVersion(>=3.10,<3.13) = %q, want %q", got, want)
+	}
+	if got, want := p.resolveVersion("~=3.11.0"), filepath.Join(root, "versions", "3.11.7", "bin", "python"); got != want {
+		t.Errorf("resolveVersion(~=3.11.0) = %q, want %q", got, want)
+	}
+	if got := p.resolveVersion(">=4"); got != ">=4" {
+		t.Errorf("resolveVersion(>=4) = %q, want it unchanged", got)
+	}
+	if got := p.resolveVersion("python3.11"); got != "python3.11" {
+		t.Errorf("resolveVersion(python3.11) = %q, want it unchanged", got)
+	}
+}
+
+func TestPythonInstallEnvironmentSpecifierUnsatisfied(t *testing.T) {
+	fakePyenvWithVersions(t, []string{"3.9.18", "3.11.7"}, []string{"3.10.14", "3.11.9"})
+	forgetPythonResolutions()
+
+	err := (&Python{}).InstallEnvironment(t.TempDir(), ">=4", nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if !strings.Contains(err.Error(), "no installed Python satisfies >=4") || !strings.Contains(err.Error(), "3.9.18, ") || !strings.Contains(err.Error(), "3.11.7") {
+		t.Errorf("error = %v, want it to list the available versions", err)
+	}
+}
+
+func TestPythonInstallEnvironmentSpecifierInstallsNewest(t *testing.T) {
+	root := fakePyenvWithVersions(t, []string{"3.9.18"}, []string{"3.10.14", "3.12.3", "3.12.4", "3.13.1", "3.12-dev"})
+	forgetPythonResolutions()
+	prefix := t.TempDir()
+
+	p := &Python{}
+	if err := p.InstallEnvironment(prefix, ">=3.12,<3.13", nil); err != nil {
+		t.Fatal(err)
+	}
+	python := filepath.Join(root, "versions", "3.12.4", "bin", "python")
+	if got := p.resolveVersion(">=3.12,<3.13"); got != python {
+		t.Errorf("resolveVersion after install = %q, want %q", got, python)
+	}
+	if _, err := os.Stat(filepath.Join(envDirPath(prefix, p.EnvironmentDir(), python), "bin", "pip")); err != nil {
+		t.Errorf("env not built with the installed interpreter: %v", err)
+	}
+}
@@ -3,54 +3,97 @@ package languages
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 )
 
-// Ruby implements the Language interface for Ruby hooks.
+// Ruby implements the Language interface for Ruby hooks. Gems are installed
+// into a GEM_HOME inside the env. A language_version other than "default"
+// or "system" is built with rbenv (and its ruby-build plugin) into the env
+// itself, which serves as RBENV_ROOT, so each version is isolated from any
+// rubies the user manages.
 type Ruby struct{}
 
 func (r *Ruby) Name() string              { return "ruby" }
 func (r *Ruby) EnvironmentDir() string    { return "rbenv" }
 func (r *Ruby) GetDefaultVersion() string { return "default" }
 
+// usesSystemRuby reports whether version means the ruby already on PATH.
+func usesSystemRuby(version string) bool {
+	return version == "" || version == "default" || version == "system"
+}
+
+// rubyBinDirs returns the directories to put on PATH for the env at envDir:
+// the gems' executables and, for an rbenv-built version, its ruby.
+func rubyBinDirs(envDir, version string) []string {
+	dirs := []string{filepath.Join(envDir, "gems", "bin")}
+	if !usesSystemRuby(version) {
+		dirs = append(dirs, filepath.Join(envDir, "versions", version, "bin"))
+	}
+	return dirs
+}
+
+// rubyEnv returns the env overrides for running ruby and gem in the env at
+// envDir.
+func rubyEnv(envDir, version string) []string {
+	env := []string{
+		PrependPath(strings.Join(rubyBinDirs(envDir, version), string(os.PathListSeparator))),
+		fmt.Sprintf("GEM_HOME=%s", filepath.Join(envDir, "gems")),
+	}
+	if !usesSystemRuby(version) {
+		env = append(env, fmt.Sprintf("RBENV_ROOT=%s", envDir), fmt.Sprintf("RBENV_VERSION=%s", version))
+	}
+	return env
+}
+
+// rubyCommand returns a command running the ruby tool name (ruby or gem) of
+// the env at envDir: the rbenv-built one for a specific version, otherwise
+// the one on PATH.
+func rubyCommand(envDir, version, name string, args ...string) *exec.Cmd {
+	if !usesSystemRuby(version) {
+		name = filepath.Join(envDir, "versions", version, "bin", name)
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Env = mergeEnv(rubyEnv(envDir, version))
+	return cmd
+}
+
+// HealthCheck verifies that the env's ruby and gem both run.
 func (r *Ruby) HealthCheck(prefix, version string) error {
-	envDir := filepath.Join(prefix, r.EnvironmentDir()+"-"+version)
-	gemHome := filepath.Join(envDir, "gems")
-	cmd := exec.Command("ruby", "--version")
-	cmd.Env = append(cmd.Environ(), fmt.Sprintf("GEM_HOME=%s", gemHome))
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("ruby environment unhealthy: %w", err)
+	envDir := envDirPath(prefix, r.EnvironmentDir(), version)
+	for _, name := range []string{"ruby", "gem"} {
+		if err := rubyCommand(envDir, version, name, "--version").Run(); err != nil {
+			return fmt.Errorf("ruby environment unhealthy: %s missing or broken: %w", name, err)
+		}
 	}
 	return nil
 }
 
 func (r *Ruby) InstallEnvironment(prefix, version string, additionalDeps []string) error {
-	envDir := filepath.Join(prefix, r.EnvironmentDir()+"-"+version)
-	gemHome := filepath.Join(envDir, "gems")
-	binDir := filepath.Join(gemHome, "bin")
+	envDir := envDirPath(prefix, r.EnvironmentDir(), version)
 
-	env := []string{
-		fmt.Sprintf("GEM_HOME=%s", gemHome),
-		PrependPath(binDir),
+	if !usesSystemRuby(version) {
+		if err := installRbenvRuby(envDir, version); err != nil {
+			return err
+		}
 	}
 
 	// Build and install the gem.
 	// Find gemspec.
 	matches, _ := filepath.Glob(filepath.Join(prefix, "*.gemspec"))
 	if len(matches) > 0 {
-		cmd := exec.Command("gem", "build", filepath.Base(matches[0]))
+		cmd := rubyCommand(envDir, version, "gem", "build", filepath.Base(matches[0]))
 		cmd.Dir = prefix
-		cmd.Env = append(cmd.Environ(), env...)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("gem build failed: %s: %w", string(out), err)
 		}
 
 		gemFiles, _ := filepath.Glob(filepath.Join(prefix, "*.gem"))
 		if len(gemFiles) > 0 {
-			cmd = exec.Command("gem", "install", "--no-document", filepath.Base(gemFiles[0]))
+			cmd = rubyCommand(envDir, version, "gem", "install", "--no-document", filepath.Base(gemFiles[0]))
 			cmd.Dir = prefix
-			cmd.Env = append(cmd.Environ(), env...)
 			if out, err := cmd.CombinedOutput(); err != nil {
 				return fmt.Errorf("gem install failed: %s: %w", string(out), err)
 			}
@@ -59,9 +102,8 @@ func (r *Ruby) InstallEnvironment(prefix, version string, additionalDeps []strin
 
 	// Install additional dependencies.
 	for _, dep := range additionalDeps {
-		cmd := exec.Command("gem", "install", "--no-document", dep)
+		cmd := rubyCommand(envDir, version, "gem", "install", "--no-document", dep)
 		cmd.Dir = prefix
-		cmd.Env = append(cmd.Environ(), env...)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return fmt.Errorf("gem install %s failed: %s: %w", dep, string(out), err)
 		}
@@ -70,13 +112,24 @@ func (r *Ruby) InstallEnvironment(prefix, version string, additionalDeps []strin
 	return nil
 }
 
-func (r *Ruby) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	envDir := filepath.Join(prefix, r.EnvironmentDir()+"-"+version)
-	gemHome := filepath.Join(envDir, "gems")
-	binDir := filepath.Join(gemHome, "bin")
-	env := []string{
-		PrependPath(binDir),
-		fmt.Sprintf("GEM_HOME=%s", gemHome),
+// installRbenvRuby builds ruby version into envDir with rbenv, using envDir
+// as RBENV_ROOT.
+func installRbenvRuby(envDir, version string) error {
+	if _, err := exec.LookPath("rbenv"); err != nil {
+		return fmt.Errorf("ruby language_version %s needs rbenv with ruby-build to install it: %w", version, err)
 	}
-	return RunHookCommand(ctx, workDir, entry, args, fileArgs, env)
+	if err := os.MkdirAll(envDir, 0o755); err != nil {
+		return err
+	}
+	cmd := exec.Command("rbenv", "install", "--skip-existing", version)
+	cmd.Env = mergeEnv([]string{fmt.Sprintf("RBENV_ROOT=%s", envDir)})
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("rbenv install %s failed: %s: %w", version, string(out), err)
+	}
+	return nil
+}
+
+func (r *Ruby) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
+	envDir := envDirPath(prefix, r.EnvironmentDir(), version)
+	return RunHookCommand(ctx, workDir, entry, args, fileArgs, rubyEnv(envDir, version))
 }
@@ -0,0 +1,122 @@
+package languages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRubyTools puts a ruby, a gem that logs each call with its GEM_HOME,
+// and an rbenv that "builds" a version by writing the same fakes into
+// RBENV_ROOT/versions/<version>/bin onto PATH. It returns the gem log.
+func fakeRubyTools(t *testing.T) string {
+	t.Helper()
+	bin := t.TempDir()
+	log := filepath.Join(t.TempDir(), "gem.log")
+	gem := `echo "$0 GEM_HOME=$GEM_HOME $*" >> ` + log + "\n"
+	writeFakeBin(t, bin, "ruby", "echo ruby 3.2.0\n")
+	writeFakeBin(t, bin, "gem", gem)
+	writeFakeBin(t, bin, "rbenv", `[ "$1" = install ] || exit 1
+dir="$RBENV_ROOT/versions/$3/bin"
+mkdir -p "$dir"
+printf '#!/bin/sh\necho ruby %s\n' "$3" > "$dir/ruby"
+printf '#!/bin/sh\n%s' '`+gem+`' > "$dir/gem"
+chmod +x "$dir/ruby" "$dir/gem"
+`)
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+"/bin"+string(os.PathListSeparator)+"/usr/bin")
+	return log
+}
+
+func TestRubyInstallEnvironmentDefault(t *testing.T) {
+	log := fakeRubyTools(t)
+	prefix := t.TempDir()
+	r := &Ruby{}
+
+	if err := r.InstallEnvironment(prefix, "default", []string{"rubocop:1.60.0", "rake"}); err != nil {
+		t.Fatal(err)
+	}
+	gemHome := filepath.Join(envDirPath(prefix, r.EnvironmentDir(), "default"), "gems")
+	lines := strings.Split(strings.TrimSpace(readFile(t, log)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("gem ran %d times, want once per dependency:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	for i, dep := range []string{"rubocop:1.60.0", "rake"} {
+		if !strings.HasSuffix(lines[i], "GEM_HOME="+gemHome+" install --no-document "+dep) {
+			t.Errorf("gem call %d = %q, want %s installed into %s", i, lines[i], dep, gemHome)
+		}
+		if strings.Contains(lines[i], "/versions/") {
+			t.Errorf("gem call %d used an rbenv ruby for language_version default: %q", i, lines[i])
+		}
+	}
+	if err := r.HealthCheck(prefix, "default"); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+}
+
+func TestRubyInstallEnvironmentVersion(t *testing.T) {
+	log := fakeRubyTools(t)
+	prefix := t.TempDir()
+	r := &Ruby{}
+
+	if err := r.InstallEnvironment(prefix, "3.3.0", []string{"rake"}); err != nil {
+		t.Fatal(err)
+	}
+	envDir := envDirPath(prefix, r.EnvironmentDir(), "3.3.0")
+	if filepath.Base(envDir) != "rbenv-3.3.0" {
+		t.Errorf("env dir = %s, want rbenv-3.3.0", envDir)
+	}
+	want := filepath.Join(envDir, "versions", "3.3.0", "bin", "gem") + " GEM_HOME=" + filepath.Join(envDir, "gems") + " install --no-document rake"
+	if got := strings.TrimSpace(readFile(t, log)); got != want {
+		t.Errorf("gem call = %q, want %q", got, want)
+	}
+	if err := r.HealthCheck(prefix, "3.3.0"); err != nil {
+		t.Errorf("HealthCheck: %v", err)
+	}
+
+	code, out, err := r.Run(context.Background(), prefix, t.TempDir(), "ruby", nil, nil, "3.3.0")
+	if err != nil || code != 0 {
+		t.Fatalf("Run() = %d, %v: %s", code, err, out)
+	}
+	if got := strings.TrimSpace(string(out)); got != "ruby 3.3.0" {
+		t.Errorf("hook ran %q, want the rbenv-built ruby", got)
+	}
+}
+
+func TestRubyInstallEnvironmentVersionWithoutRbenv(t *testing.T) {
+	fakeRubyTools(t)
+	os.Remove(filepath.Join(strings.Split(os.Getenv("PATH"), string(os.PathListSeparator))[0], "rbenv"))
+
+	err := (&Ruby{}).InstallEnvironment(t.TempDir(), "3.3.0", nil)
+	if err == nil || !strings.Contains(err.Error(), "needs rbenv") {
+		t.Errorf("err = %v, want one saying rbenv is needed", err)
+	}
+}
+
+func TestRubyHealthCheckMissingVersion(t *testing.T) {
+	fakeRubyTools(t)
+	err := (&Ruby{}).HealthCheck(t.TempDir(), "3.3.0")
+	if err == nil || !strings.Contains(err.Error(), "ruby missing or broken") {
+		t.Errorf("err = %v, want the uninstalled ruby reported", err)
+	}
+}
+
+func TestRubyHealthCheckBrokenGem(t *testing.T) {
+	fakeRubyTools(t)
+	bin := strings.Split(os.Getenv("PATH"), string(os.PathListSeparator))[0]
+	writeFakeBin(t, bin, "gem", "exit 1\n")
+	err := (&Ruby{}).HealthCheck(t.TempDir(), "default")
+	if err == nil || !strings.Contains(err.Error(), "gem missing or broken") {
+		t.Errorf("err = %v, want the broken gem reported", err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,76 @@
+package languages
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// RuntimeMissingError reports that the tool a language builds or runs its
+// hooks with is not installed.
+type RuntimeMissingError struct {
+	Language   string
+	Tool       string
+	InstallURL string
+}
+
+func (e *RuntimeMissingError) Error() string {
+	return fmt.Sprintf("%s hooks need %s, which was not found on PATH; install it (see %s) and try again",
+		e.Language, e.Tool, e.InstallURL)
+}
+
+// runtimeTool returns the executable lang needs on PATH to set up or run
+// hooks with language_version version and where to get it, or "" if it
+// needs none or can't tell in advance. Only the built-in languages are
+// known.
+func runtimeTool(lang Language, version string) (tool, installURL string) {
+	switch l := lang.(type) {
+	case *Python:
+		version = ResolveVersion(lang, version)
+		if version == "default" {
+			version = l.GetDefaultVersion()
+		}
+		if isVersionSpecifier(version) {
+			return "", "" // may still be installed with pyenv
+		}
+		return version, "https://www.python.org/downloads/"
+	case *Node:
+		if version == "default" || version == "system" {
+			return "npm", "https://nodejs.org/en/download"
+		}
+		return "nodeenv", "https://github.com/ekalinin/nodeenv#install"
+	case *Ruby:
+		if usesSystemRuby(version) {
+			return "ruby", "https://www.ruby-lang.org/en/documentation/installation/"
+		}
+		return "rbenv", "https://github.com/rbenv/rbenv#installation"
+	case *Golang:
+		return "go", "https://go.dev/doc/install"
+	case *Rust:
+		return "cargo", "https://rustup.rs/"
+	case *Docker, *DockerImage:
+		return "docker", "https://docs.docker.com/get-docker/"
+	case *Julia:
+		return "julia", "https://julialang.org/downloads/"
+	case *Swift:
+		return "swift", "https://www.swift.org/install/"
+	case *SimpleLanguage:
+		if l.InstallURL != "" && len(l.HealthCmd) > 0 {
+			return l.HealthCmd[0], l.InstallURL
+		}
+	}
+	return "", ""
+}
+
+// CheckRuntime returns a *RuntimeMissingError if the runtime lang needs for
+// language_version version is not on PATH, so a hook can fail with a clear
+// message rather than a low-level exec error part way through an install.
+func CheckRuntime(lang Language, version string) error {
+	tool, installURL := runtimeTool(lang, version)
+	if tool == "" {
+		return nil
+	}
+	if _, err := exec.LookPath(tool); err != nil {
+		return &RuntimeMissingError{Language: lang.Name(), Tool: tool, InstallURL: installURL}
+	}
+	return nil
+}
@@ -15,7 +15,7 @@ func (r *Rust) EnvironmentDir() string    { return "rustenv" }
 func (r *Rust) GetDefaultVersion() string { return "default" }
 
 func (r *Rust) HealthCheck(prefix, version string) error {
-	envDir := filepath.Join(prefix, r.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, r.EnvironmentDir(), version)
 	binDir := filepath.Join(envDir, "bin")
 	cmd := exec.Command(filepath.Join(binDir, "cargo"), "--version")
 	if err := cmd.Run(); err != nil {
@@ -29,7 +29,7 @@ func (r *Rust) HealthCheck(prefix, version string) error {
 }
 
 func (r *Rust) InstallEnvironment(prefix, version string, additionalDeps []string) error {
-	envDir := filepath.Join(prefix, r.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, r.EnvironmentDir(), version)
 
 	env := []string{
 		fmt.Sprintf("CARGO_HOME=%s", envDir),
@@ -67,7 +67,7 @@ func (r *Rust) InstallEnvironment(prefix, version string, additionalDeps []strin
 }
 
 func (r *Rust) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	envDir := filepath.Join(prefix, r.EnvironmentDir()+"-"+version)
+	envDir := envDirPath(prefix, r.EnvironmentDir(), version)
 	binDir := filepath.Join(envDir, "bin")
 	env := []string{
 		PrependPath(binDir),
@@ -5,8 +5,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 
+	"github.com/blairham/go-pre-commit/v4/internal/output"
 	"github.com/blairham/go-pre-commit/v4/internal/pcre"
 )
 
@@ -24,8 +27,11 @@ func (f *Fail) InstallEnvironment(prefix, version string, additionalDeps []strin
 	return nil
 }
 
+// Run prints the entry as the failure message, with {files} replaced by the
+// space-separated files the hook matched.
 func (f *Fail) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	return 1, []byte(entry + "\n"), nil
+	msg := strings.ReplaceAll(entry, "{files}", strings.Join(fileArgs, " "))
+	return 1, []byte(msg + "\n"), nil
 }
 
 // Pygrep implements the Language interface for pygrep hooks.
@@ -124,7 +130,50 @@ func (u *Unsupported) InstallEnvironment(prefix, version string, additionalDeps
 }
 
 func (u *Unsupported) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	return RunHookCommand(ctx, workDir, entry, args, fileArgs, nil)
+	var env []string
+	if dirs, ok := ctx.Value(pathDirsKey{}).([]string); ok && len(dirs) > 0 {
+		env = []string{PrependPath(strings.Join(dirs, string(os.PathListSeparator)))}
+	}
+	return RunHookCommand(ctx, workDir, entry, args, fileArgs, env)
+}
+
+// systemPathPrefix marks a system hook's additional_dependencies entry as a
+// directory to prepend to PATH rather than something to install.
+const systemPathPrefix = "path:"
+
+// pathDirsKey is the context key under which WithPathDirs stores the
+// directories a system hook prepends to PATH.
+type pathDirsKey struct{}
+
+// SystemPathDirs returns the directories named by "path:" entries in deps,
+// resolving relative ones against workDir. It errors if any of them is not
+// an existing directory.
+func SystemPathDirs(deps []string, workDir string) ([]string, error) {
+	var dirs []string
+	for _, dep := range deps {
+		dir, ok := strings.CutPrefix(dep, systemPathPrefix)
+		if !ok {
+			continue
+		}
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(workDir, dir)
+		}
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("additional_dependencies %q: %w", dep, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("additional_dependencies %q: not a directory", dep)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// WithPathDirs returns a copy of ctx under which system hooks run with dirs
+// prepended to PATH.
+func WithPathDirs(ctx context.Context, dirs []string) context.Context {
+	return context.WithValue(ctx, pathDirsKey{}, dirs)
 }
 
 // UnsupportedScript implements the Language interface for script hooks.
@@ -139,11 +188,34 @@ func (u *UnsupportedScript) InstallEnvironment(prefix, version string, additiona
 	return nil
 }
 
+// Run runs the script the entry names, relative to prefix (the hook repo,
+// or the repo root for a local hook) rather than workDir, so it is found
+// whatever directory the hook runs from.
 func (u *UnsupportedScript) Run(ctx context.Context, prefix, workDir, entry string, args, fileArgs []string, version string) (int, []byte, error) {
-	// For script hooks, entry is relative to the hook repo.
-	fullEntry := entry
-	if prefix != "" {
-		fullEntry = prefix + "/" + entry
+	parts := ParseEntry(entry)
+	if len(parts) == 0 {
+		return -1, nil, fmt.Errorf("empty entry")
+	}
+	if !filepath.IsAbs(parts[0]) && prefix != "" {
+		parts[0] = filepath.Join(prefix, parts[0])
+	}
+	ensureExecutable(ctx, parts[0])
+	return runHookParts(ctx, workDir, parts, args, fileArgs, nil)
+}
+
+// ensureExecutable makes a script that was committed without its executable
+// bit runnable, with a warning so the bit can be fixed in the repo.
+func ensureExecutable(ctx context.Context, script string) {
+	if runtime.GOOS == "windows" {
+		return
+	}
+	info, err := os.Stat(script)
+	if err != nil || !info.Mode().IsRegular() || info.Mode().Perm()&0o111 != 0 {
+		return
+	}
+	if err := os.Chmod(script, info.Mode().Perm()|0o111); err != nil {
+		output.WarnContext(ctx, "Script %s is not executable and could not be marked so: %v", script, err)
+		return
 	}
-	return RunHookCommand(ctx, workDir, fullEntry, args, fileArgs, nil)
+	output.WarnContext(ctx, "Script %s was not executable; marked it executable", script)
 }
@@ -217,3 +217,32 @@ func TestFailAlwaysReturns1(t *testing.T) {
 		t.Errorf("output %q should contain the entry message", out)
 	}
 }
+
+func TestFailExpandsFilesTemplate(t *testing.T) {
+	f := &Fail{}
+	_, out, err := f.Run(context.Background(), "", t.TempDir(), "Do not commit {files}", nil, []string{"a.env", "dir/b.env"}, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), "Do not commit a.env dir/b.env\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestUnsupportedScriptRunResolvesAgainstPrefix(t *testing.T) {
+	prefix := filepath.Join(t.TempDir(), "hook repo")
+	if err := os.MkdirAll(filepath.Join(prefix, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(prefix, "bin", "lint"), []byte("#!/bin/sh\necho \"$@\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out, err := (&UnsupportedScript{}).Run(context.Background(), prefix, t.TempDir(), "bin/lint -v", []string{"--x"}, []string{"a.txt"}, "default")
+	if err != nil || code != 0 {
+		t.Fatalf("Run() = %d, %v: %s", code, err, out)
+	}
+	if got := strings.TrimSpace(string(out)); got != "-v --x a.txt" {
+		t.Errorf("output = %q, want %q", got, "-v --x a.txt")
+	}
+}
@@ -2,7 +2,9 @@
 package output
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -33,12 +35,18 @@ func SetColorMode(mode ColorMode) {
 	currentColorMode = mode
 }
 
-// SetColorModeFromString parses a color mode string.
+// SetColorModeFromString parses a color mode string. An empty string means
+// the --color flag was not given, in which case PRE_COMMIT_COLOR is used
+// (matching Python pre-commit, where the env var is the flag's default).
+// 1/true and 0/false are accepted for always and never.
 func SetColorModeFromString(s string) {
+	if s == "" {
+		s = os.Getenv("PRE_COMMIT_COLOR")
+	}
 	switch strings.ToLower(s) {
-	case "always":
+	case "always", "1", "true":
 		currentColorMode = ColorAlways
-	case "never":
+	case "never", "0", "false":
 		currentColorMode = ColorNever
 	default:
 		currentColorMode = ColorAuto
@@ -57,9 +65,6 @@ func UseColor() bool {
 		if os.Getenv("TERM") == "dumb" {
 			return false
 		}
-		if os.Getenv("PRE_COMMIT_COLOR") != "" {
-			return SetColorFromEnv()
-		}
 		// Check if stdout is a terminal.
 		fi, err := os.Stdout.Stat()
 		if err != nil {
@@ -69,19 +74,6 @@ func UseColor() bool {
 	}
 }
 
-// SetColorFromEnv reads PRE_COMMIT_COLOR env var.
-func SetColorFromEnv() bool {
-	v := os.Getenv("PRE_COMMIT_COLOR")
-	switch strings.ToLower(v) {
-	case "always", "1", "true":
-		return true
-	case "never", "0", "false":
-		return false
-	default:
-		return true
-	}
-}
-
 func render(style lipgloss.Style, text string) string {
 	if !UseColor() {
 		return text
@@ -89,6 +81,50 @@ func render(style lipgloss.Style, text string) string {
 	return style.Render(text)
 }
 
+// ANSI sequences used for diff highlighting. These are written directly,
+// like git does, so that --color=always works even when stderr is piped.
+const (
+	ansiReset = "\x1b[m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+	ansiCyan  = "\x1b[36m"
+)
+
+// ColorizeDiff highlights a unified diff: file headers bold, hunk headers
+// cyan, added lines green and removed lines red. It returns diff unchanged
+// when color output is disabled.
+func ColorizeDiff(diff string) string {
+	if !UseColor() || diff == "" {
+		return diff
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		text := strings.TrimSuffix(line, "\n")
+		color := ""
+		switch {
+		case strings.HasPrefix(text, "diff --git "), strings.HasPrefix(text, "index "),
+			strings.HasPrefix(text, "--- "), strings.HasPrefix(text, "+++ "):
+			color = ansiBold
+		case strings.HasPrefix(text, "@@"):
+			color = ansiCyan
+		case strings.HasPrefix(text, "+"):
+			color = ansiGreen
+		case strings.HasPrefix(text, "-"):
+			color = ansiRed
+		}
+		if color == "" || text == "" {
+			b.WriteString(line)
+			continue
+		}
+		b.WriteString(color + text + ansiReset + line[len(text):])
+	}
+	return b.String()
+}
+
 // HookResult represents the outcome of running a hook.
 type HookResult int
 
@@ -133,6 +169,11 @@ func coloredResult(result HookResult) string {
 // PrintHookHeader prints a hook execution header line.
 // Format: "Hook Name...................................................Result"
 func PrintHookHeader(name string, result HookResult) {
+	FprintHookHeader(os.Stderr, name, result)
+}
+
+// FprintHookHeader is PrintHookHeader writing to w.
+func FprintHookHeader(w io.Writer, name string, result HookResult) {
 	totalWidth := TerminalWidth()
 	nameLen := len(name)
 	resultStr := result.String()
@@ -142,19 +183,24 @@ func PrintHookHeader(name string, result HookResult) {
 		dotsLen = 1
 	}
 	dots := strings.Repeat(".", dotsLen)
-	fmt.Fprintf(os.Stderr, "%s%s%s\n", name, dots, coloredResult(result))
+	fmt.Fprintf(w, "%s%s%s\n", name, dots, coloredResult(result))
 }
 
 // PrintHookOutput prints hook output with optional indentation.
 func PrintHookOutput(output []byte, hookID string, exitCode int, verbose bool) {
+	FprintHookOutput(os.Stderr, output, hookID, exitCode, verbose)
+}
+
+// FprintHookOutput is PrintHookOutput writing to w.
+func FprintHookOutput(w io.Writer, output []byte, hookID string, exitCode int, verbose bool) {
 	if len(output) == 0 && !verbose {
 		return
 	}
 
 	if exitCode != 0 || verbose {
-		fmt.Fprintf(os.Stderr, "- hook id: %s\n", hookID)
+		fmt.Fprintf(w, "- hook id: %s\n", hookID)
 		if exitCode != 0 {
-			fmt.Fprintf(os.Stderr, "- exit code: %d\n", exitCode)
+			fmt.Fprintf(w, "- exit code: %d\n", exitCode)
 		}
 	}
 
@@ -162,15 +208,74 @@ func PrintHookOutput(output []byte, hookID string, exitCode int, verbose bool) {
 		outStr := string(output)
 		// Check if files were modified.
 		if strings.Contains(outStr, "Files were modified by this hook") {
-			fmt.Fprintln(os.Stderr)
-			fmt.Fprintln(os.Stderr, render(yellowStyle, "Files were modified by this hook. Additional output:"))
-			fmt.Fprintln(os.Stderr)
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, render(yellowStyle, "Files were modified by this hook. Additional output:"))
+			fmt.Fprintln(w)
 		}
-		fmt.Fprint(os.Stderr, outStr)
+		fmt.Fprint(w, outStr)
 		if !strings.HasSuffix(outStr, "\n") {
-			fmt.Fprintln(os.Stderr)
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+// commandPrefix starts the verbose command echo; continuation lines are
+// indented to line up with it.
+const commandPrefix = "- command: "
+
+// FormatCommand renders argv, whose last nFiles elements are filenames, as a
+// shell-quoted command line wrapped at argument boundaries to fit width.
+// Unless full is set, a file list that would not fit on a single line is
+// shown as "<N files>" so long runs stay scannable.
+func FormatCommand(argv []string, nFiles int, full bool, width int) string {
+	words := make([]string, len(argv))
+	for i, a := range argv {
+		words[i] = shellQuote(a)
+	}
+	if !full && nFiles > 0 && len(commandPrefix+strings.Join(words, " ")) > width {
+		words = append(words[:len(words)-nFiles], fmt.Sprintf("<%d files>", nFiles))
+	}
+
+	indent := strings.Repeat(" ", len(commandPrefix))
+	var lines []string
+	line := ""
+	for _, w := range words {
+		switch {
+		case line == "":
+			line = w
+		// Leave room for the trailing " \" continuation marker.
+		case len(commandPrefix)+len(line)+1+len(w)+2 > width:
+			lines = append(lines, line)
+			line = w
+		default:
+			line += " " + w
 		}
 	}
+	lines = append(lines, line)
+	return strings.Join(lines, " \\\n"+indent)
+}
+
+// PrintCommand prints the command line a hook was run with. See
+// FormatCommand for how long commands are summarized and wrapped.
+func PrintCommand(argv []string, nFiles int, full bool) {
+	FprintCommand(os.Stderr, argv, nFiles, full)
+}
+
+// FprintCommand is PrintCommand writing to w.
+func FprintCommand(w io.Writer, argv []string, nFiles int, full bool) {
+	fmt.Fprintf(w, "%s%s\n", commandPrefix, render(cyanStyle, FormatCommand(argv, nFiles, full, TerminalWidth())))
+}
+
+// shellQuote single-quotes s if it contains characters a POSIX shell would
+// interpret.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if !strings.ContainsAny(s, " \t\n'\"\\$`*?[]{}()<>|&;#~!") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
 // TerminalWidth returns the terminal width, defaulting to 80.
@@ -191,22 +296,95 @@ func TerminalWidth() int {
 	return 80
 }
 
-// Info prints an informational message.
+// DebugEnabled reports whether debug messages are shown, which is when
+// PRE_COMMIT_LOG_LEVEL is "debug".
+func DebugEnabled() bool {
+	return strings.EqualFold(os.Getenv("PRE_COMMIT_LOG_LEVEL"), "debug")
+}
+
+// Debug prints a diagnostic message to stderr if DebugEnabled.
+func Debug(format string, args ...any) {
+	if !DebugEnabled() {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	fmt.Fprintf(os.Stderr, "[%s] %s\n", render(cyanStyle, "DEBUG"), msg)
+}
+
+var quiet bool
+
+// SetQuiet turns Info messages off (true) or back on.
+func SetQuiet(q bool) {
+	quiet = q
+}
+
+// Info prints an informational message, unless SetQuiet(true).
 func Info(format string, args ...any) {
+	Finfo(os.Stdout, format, args...)
+}
+
+// Finfo is Info writing to w.
+func Finfo(w io.Writer, format string, args ...any) {
+	if quiet {
+		return
+	}
 	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("[%s] %s\n", render(cyanStyle, "INFO"), msg)
+	fmt.Fprintf(w, "[%s] %s\n", render(cyanStyle, "INFO"), msg)
 }
 
 // Warn prints a warning message.
 func Warn(format string, args ...any) {
+	Fwarn(os.Stdout, format, args...)
+}
+
+// Fwarn is Warn writing to w.
+func Fwarn(w io.Writer, format string, args ...any) {
 	msg := fmt.Sprintf(format, args...)
-	fmt.Printf("[%s] %s\n", render(yellowStyle, "WARNING"), msg)
+	fmt.Fprintf(w, "[%s] %s\n", render(yellowStyle, "WARNING"), msg)
 }
 
 // Error prints an error message.
 func Error(format string, args ...any) {
+	Ferror(os.Stderr, format, args...)
+}
+
+// Ferror is Error writing to w.
+func Ferror(w io.Writer, format string, args ...any) {
 	msg := fmt.Sprintf(format, args...)
-	fmt.Fprintf(os.Stderr, "[%s] %s\n", render(redStyle, "ERROR"), msg)
+	fmt.Fprintf(w, "[%s] %s\n", render(redStyle, "ERROR"), msg)
+}
+
+type messagesKey struct{}
+
+// WithMessages returns a copy of ctx under which InfoContext, WarnContext
+// and ErrorContext write to w rather than stdout and stderr, for callers
+// that embed pre-commit and must keep its messages off their own stdio.
+func WithMessages(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, messagesKey{}, w)
+}
+
+// messages returns ctx's WithMessages writer, or def if it has none.
+func messages(ctx context.Context, def io.Writer) io.Writer {
+	if w, ok := ctx.Value(messagesKey{}).(io.Writer); ok && w != nil {
+		return w
+	}
+	return def
+}
+
+// InfoContext is Info, writing to ctx's WithMessages writer if it has one.
+func InfoContext(ctx context.Context, format string, args ...any) {
+	Finfo(messages(ctx, os.Stdout), format, args...)
+}
+
+// WarnContext is Warn, writing to ctx's WithMessages writer if it has one.
+func WarnContext(ctx context.Context, format string, args ...any) {
+	Fwarn(messages(ctx, os.Stdout), format, args...)
+}
+
+// ErrorContext is Error, writing to ctx's WithMessages writer if it has
+// one.
+func ErrorContext(ctx context.Context, format string, args ...any) {
+	Ferror(messages(ctx, os.Stderr), format, args...)
 }
 
 // PrintSeparator prints a separator line.
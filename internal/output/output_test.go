@@ -1,6 +1,7 @@
 package output
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -44,6 +45,59 @@ func TestSetColorModeFromStringUnknownDefaultsToAuto(t *testing.T) {
 	}
 }
 
+func TestSetColorModeFromStringEmptyUsesEnv(t *testing.T) {
+	t.Setenv("PRE_COMMIT_COLOR", "never")
+	SetColorModeFromString("")
+	if currentColorMode != ColorNever {
+		t.Fatalf("expected ColorNever from PRE_COMMIT_COLOR, got %d", currentColorMode)
+	}
+
+	t.Setenv("PRE_COMMIT_COLOR", "always")
+	SetColorModeFromString("")
+	if currentColorMode != ColorAlways {
+		t.Fatalf("expected ColorAlways from PRE_COMMIT_COLOR, got %d", currentColorMode)
+	}
+}
+
+func TestSetColorModeFromStringBooleanEnv(t *testing.T) {
+	for _, tt := range []struct {
+		value string
+		want  ColorMode
+	}{
+		{"1", ColorAlways},
+		{"true", ColorAlways},
+		{"TRUE", ColorAlways},
+		{"0", ColorNever},
+		{"false", ColorNever},
+	} {
+		t.Setenv("PRE_COMMIT_COLOR", tt.value)
+		SetColorModeFromString("")
+		if currentColorMode != tt.want {
+			t.Errorf("PRE_COMMIT_COLOR=%s: got %d, want %d", tt.value, currentColorMode, tt.want)
+		}
+	}
+}
+
+func TestSetColorModeFromStringFlagOverridesEnv(t *testing.T) {
+	t.Setenv("PRE_COMMIT_COLOR", "always")
+	SetColorModeFromString("never")
+	if currentColorMode != ColorNever {
+		t.Fatalf("expected flag to take precedence over PRE_COMMIT_COLOR, got %d", currentColorMode)
+	}
+}
+
+func TestUseColorAutoEnvDoesNotForceColor(t *testing.T) {
+	t.Setenv("PRE_COMMIT_COLOR", "auto")
+	SetColorModeFromString("")
+	if currentColorMode != ColorAuto {
+		t.Fatalf("expected ColorAuto, got %d", currentColorMode)
+	}
+	// Test output is not a terminal, so auto resolves to no color.
+	if UseColor() {
+		t.Fatal("expected PRE_COMMIT_COLOR=auto to detect no terminal")
+	}
+}
+
 func TestHookResultStringPassed(t *testing.T) {
 	if ResultPassed.String() != "Passed" {
 		t.Fatalf("expected Passed, got %s", ResultPassed.String())
@@ -98,3 +152,43 @@ func TestTerminalWidthInvalidEnvFallsBack(t *testing.T) {
 		t.Fatalf("expected fallback width 80 for invalid COLUMNS, got %d", w)
 	}
 }
+
+func TestColorizeDiff(t *testing.T) {
+	diff := "diff --git a/f b/f\n--- a/f\n+++ b/f\n@@ -1 +1 @@\n-old\n+new\n context\n"
+
+	SetColorMode(ColorNever)
+	if got := ColorizeDiff(diff); got != diff {
+		t.Errorf("expected diff unchanged with color disabled, got %q", got)
+	}
+
+	SetColorMode(ColorAlways)
+	defer SetColorMode(ColorAuto)
+	got := ColorizeDiff(diff)
+	for _, want := range []string{
+		ansiBold + "--- a/f" + ansiReset + "\n",
+		ansiCyan + "@@ -1 +1 @@" + ansiReset + "\n",
+		ansiRed + "-old" + ansiReset + "\n",
+		ansiGreen + "+new" + ansiReset + "\n",
+		"\n context\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q in %q", want, got)
+		}
+	}
+}
+
+func TestFormatCommand(t *testing.T) {
+	argv := []string{"ruff", "--fix", "it's", "a.py", "b c.py"}
+	if got, want := FormatCommand(argv, 2, false, 80), `ruff --fix 'it'\''s' a.py 'b c.py'`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := FormatCommand(argv, 2, false, 24), `ruff --fix \
+           'it'\''s' \
+           <2 files>`; got != want {
+		t.Errorf("summarized: got %q, want %q", got, want)
+	}
+	if got, want := FormatCommand(argv, 2, true, 34), `ruff --fix 'it'\''s' \
+           a.py 'b c.py'`; got != want {
+		t.Errorf("full: got %q, want %q", got, want)
+	}
+}
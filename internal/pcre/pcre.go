@@ -28,6 +28,16 @@ func Compile(pattern string) (*regexp2.Regexp, error) {
 	return re, nil
 }
 
+// CaseInsensitive returns pattern with the (?i) flag prepended when ci is
+// set, so it matches regardless of case. Empty patterns and the "^$" match
+// nothing placeholder are returned as is.
+func CaseInsensitive(pattern string, ci bool) string {
+	if !ci || pattern == "" || pattern == "^$" {
+		return pattern
+	}
+	return "(?i)" + pattern
+}
+
 // MustCompile compiles a PCRE-compatible regex pattern and panics on error.
 func MustCompile(pattern string) *regexp2.Regexp {
 	re, err := Compile(pattern)
@@ -266,3 +266,25 @@ func TestCompileSetsTimeout(t *testing.T) {
 		t.Errorf("MatchTimeout = %v, want %v", re.MatchTimeout, DefaultTimeout)
 	}
 }
+
+func TestCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		pattern string
+		ci      bool
+		want    string
+	}{
+		{`\.py$`, false, `\.py$`},
+		{`\.py$`, true, `(?i)\.py$`},
+		{"", true, ""},
+		{"^$", true, "^$"},
+	}
+	for _, tt := range tests {
+		if got := CaseInsensitive(tt.pattern, tt.ci); got != tt.want {
+			t.Errorf("CaseInsensitive(%q, %v) = %q, want %q", tt.pattern, tt.ci, got, tt.want)
+		}
+	}
+	matched, err := MatchString(CaseInsensitive(`\.py$`, true), "SETUP.PY")
+	if err != nil || !matched {
+		t.Errorf("case-insensitive pattern did not match SETUP.PY: %v", err)
+	}
+}
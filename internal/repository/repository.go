@@ -9,7 +9,9 @@ import (
 	"sync"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
 	"github.com/blairham/go-pre-commit/v4/internal/hook"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
 	"github.com/blairham/go-pre-commit/v4/internal/store"
 )
 
@@ -85,6 +87,15 @@ func (r *Resolver) resolveLocalRepo(repo *config.RepoConfig) ([]*hook.Hook, erro
 	var hooks []*hook.Hook
 	for i := range repo.Hooks {
 		h := hook.FromLocalConfig(&repo.Hooks[i], r.Cfg)
+		// Languages that install an environment get one shared by every
+		// local hook with the same additional_dependencies.
+		if lang, err := languages.Get(h.Language); err == nil && lang.EnvironmentDir() != "" {
+			dir, err := r.Store.MakeLocal(h.AdditionalDependencies)
+			if err != nil {
+				return nil, errkind.Wrap(errkind.Env, fmt.Errorf("creating environment directory for local hook %q: %w", h.ID, err))
+			}
+			h.RepoDir = dir
+		}
 		hooks = append(hooks, h)
 	}
 	return hooks, nil
@@ -97,6 +108,7 @@ func (r *Resolver) resolveMetaRepo(repo *config.RepoConfig) ([]*hook.Hook, error
 		if err != nil {
 			return nil, err
 		}
+		h.Repo = repo.Repo
 		hooks = append(hooks, h)
 	}
 	return hooks, nil
@@ -143,7 +155,7 @@ func makeMetaHook(hc *config.HookConfig) (*hook.Hook, error) {
 			Types:         []string{"file"},
 		}, nil
 	default:
-		return nil, fmt.Errorf("unknown meta hook: %s", hc.ID)
+		return nil, errkind.Wrap(errkind.Config, fmt.Errorf("unknown meta hook: %s", hc.ID))
 	}
 }
 
@@ -172,7 +184,7 @@ func (r *Resolver) resolveRemoteRepo(ctx context.Context, repo *config.RepoConfi
 		hc := &repo.Hooks[i]
 		mh, ok := manifestByID[hc.ID]
 		if !ok {
-			return nil, fmt.Errorf("hook %q not found in manifest for %s@%s", hc.ID, repo.Repo, repo.Rev)
+			return nil, errkind.Wrap(errkind.Config, fmt.Errorf("hook %q not found in manifest for %s@%s", hc.ID, repo.Repo, repo.Rev))
 		}
 
 		h := hook.MergeManifest(mh, hc, repo, r.Cfg)
@@ -190,7 +202,7 @@ func loadManifest(repoDir string) ([]config.ManifestHook, error) {
 		// Fall back to hooks.yaml.
 		manifestPath = filepath.Join(repoDir, "hooks.yaml")
 		if _, err := os.Stat(manifestPath); err != nil {
-			return nil, fmt.Errorf("no manifest file found in %s", repoDir)
+			return nil, errkind.Wrap(errkind.Config, fmt.Errorf("no manifest file found in %s", repoDir))
 		}
 	}
 	return config.LoadManifest(manifestPath)
@@ -1,11 +1,17 @@
 package repository
 
 import (
+	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/hook"
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
 	"github.com/blairham/go-pre-commit/v4/internal/store"
 )
 
@@ -244,3 +250,142 @@ func TestLoadManifest_InvalidYAML(t *testing.T) {
 		t.Fatal("expected error for invalid YAML manifest")
 	}
 }
+
+// recordingLanguage records the version and dependencies each environment
+// is installed with.
+type recordingLanguage struct {
+	mu       sync.Mutex
+	versions []string
+	deps     [][]string
+}
+
+func (l *recordingLanguage) Name() string                  { return "recordlang" }
+func (l *recordingLanguage) EnvironmentDir() string        { return "record_env" }
+func (l *recordingLanguage) GetDefaultVersion() string     { return "default" }
+func (l *recordingLanguage) HealthCheck(_, _ string) error { return nil }
+func (l *recordingLanguage) InstallEnvironment(_, version string, deps []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.versions = append(l.versions, version)
+	l.deps = append(l.deps, deps)
+	return nil
+}
+
+func (l *recordingLanguage) Run(context.Context, string, string, string, []string, []string, string) (int, []byte, error) {
+	return 0, nil, nil
+}
+
+func TestResolveRemoteRepo_ManifestLanguageDefaults(t *testing.T) {
+	lang := &recordingLanguage{}
+	languages.Register("recordlang", lang)
+	t.Cleanup(func() { languages.Unregister("recordlang") })
+
+	hookRepo := t.TempDir()
+	manifest := `-   id: lint
+    name: lint
+    entry: lint
+    language: recordlang
+    language_version: "3.11"
+    additional_dependencies: [plugin==1.0]
+-   id: fmt
+    name: fmt
+    entry: fmt
+    language: recordlang
+    language_version: "3.11"
+`
+	if err := os.WriteFile(filepath.Join(hookRepo, ".pre-commit-hooks.yaml"), []byte(manifest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=t", "-c", "user.email=t@t", "commit", "-qm", "hooks"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = hookRepo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	rev, err := exec.Command("git", "-C", hookRepo, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{
+		Repos: []config.RepoConfig{{
+			Repo: hookRepo,
+			Rev:  strings.TrimSpace(string(rev)),
+			Hooks: []config.HookConfig{
+				{ID: "lint"},
+				{ID: "fmt", LanguageVersion: "3.12"},
+			},
+		}},
+		// The manifest's language_version wins over the config-wide default.
+		DefaultLanguageVersion: map[string]string{"recordlang": "3.9"},
+	}
+	r := NewResolver(store.New(t.TempDir()), cfg)
+	hooks, err := r.ResolveAll(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hooks[0].LanguageVersion != "3.11" {
+		t.Errorf("lint LanguageVersion = %q, want manifest default 3.11", hooks[0].LanguageVersion)
+	}
+	if hooks[1].LanguageVersion != "3.12" {
+		t.Errorf("fmt LanguageVersion = %q, want config override 3.12", hooks[1].LanguageVersion)
+	}
+
+	if err := hook.InstallEnvironments(context.Background(), hooks[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(lang.versions) != 1 || lang.versions[0] != "3.11" {
+		t.Errorf("environment built with versions %v, want [3.11]", lang.versions)
+	}
+	if len(lang.deps) != 1 || strings.Join(lang.deps[0], ",") != "plugin==1.0" {
+		t.Errorf("environment built with deps %v, want [plugin==1.0]", lang.deps)
+	}
+}
+
+func TestResolveLocalRepo_SharedEnvironment(t *testing.T) {
+	python, err := languages.Get("python")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lang := &recordingLanguage{}
+	languages.Register("python", lang)
+	t.Cleanup(func() { languages.Register("python", python) })
+
+	cfg := &config.Config{Repos: []config.RepoConfig{{
+		Repo: "local",
+		Hooks: []config.HookConfig{
+			{ID: "lint", Name: "lint", Entry: "flake8", Language: "python", AdditionalDependencies: []string{"flake8==7.0", "pep8-naming"}},
+			{ID: "lint-tests", Name: "lint tests", Entry: "flake8", Language: "python", AdditionalDependencies: []string{"pep8-naming", "flake8==7.0"}},
+			{ID: "types", Name: "types", Entry: "mypy", Language: "python", AdditionalDependencies: []string{"mypy"}},
+		},
+	}}}
+	r := NewResolver(store.New(t.TempDir()), cfg)
+	hooks, err := r.ResolveAll(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hooks[0].RepoDir == "" || hooks[0].RepoDir != hooks[1].RepoDir {
+		t.Errorf("hooks with identical deps should share a directory, got %q and %q", hooks[0].RepoDir, hooks[1].RepoDir)
+	}
+	if hooks[2].RepoDir == hooks[0].RepoDir {
+		t.Errorf("hooks with different deps must not share a directory: %q", hooks[2].RepoDir)
+	}
+
+	if err := hook.InstallEnvironments(context.Background(), hooks); err != nil {
+		t.Fatal(err)
+	}
+	if len(lang.deps) != 2 {
+		t.Fatalf("expected 2 environments to be installed, got %d: %v", len(lang.deps), lang.deps)
+	}
+	for _, dir := range []string{hooks[0].RepoDir, hooks[2].RepoDir} {
+		if _, err := os.Stat(filepath.Join(dir, "setup.py")); err != nil {
+			t.Errorf("expected placeholder package in %s: %v", dir, err)
+		}
+	}
+}
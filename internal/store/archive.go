@@ -0,0 +1,400 @@
+package store
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// exportMetaFile records where an exported store originally lived so that
+// path-sensitive environments can be detected on import.
+const exportMetaFile = "export.json"
+
+// installStateFile marks an installed hook environment (see hook.installStateFile).
+const installStateFile = "install_state_v2"
+
+type exportMeta struct {
+	StoreDir string `json:"store_dir"`
+}
+
+// Export writes the store's cloned repos, their environments and the database
+// to a gzipped tarball at archive. Repo paths in the exported database are
+// stored relative to the store directory so the archive can be imported
+// elsewhere.
+func (s *Store) Export(archive string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.acquireLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	db, err := s.loadDB()
+	if err != nil {
+		return err
+	}
+
+	absDir, err := filepath.Abs(s.dir)
+	if err != nil {
+		return err
+	}
+
+	var repos []RepoEntry
+	for _, entry := range db.Repos {
+		rel, err := filepath.Rel(s.dir, entry.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if _, err := os.Stat(entry.Path); err != nil {
+			continue
+		}
+		entry.Path = filepath.ToSlash(rel)
+		repos = append(repos, entry)
+	}
+
+	f, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	dbData, err := json.MarshalIndent(&storeDB{Repos: repos}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, "db.json", dbData); err != nil {
+		return err
+	}
+	metaData, err := json.MarshalIndent(exportMeta{StoreDir: absDir}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, exportMetaFile, metaData); err != nil {
+		return err
+	}
+
+	for _, entry := range repos {
+		if err := addTree(tw, s.dir, entry.Path); err != nil {
+			return fmt.Errorf("failed to export %s: %w", entry.Repo, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Import unpacks an archive created by Export into the store and merges its
+// repos into the database. When the archive came from a different store
+// directory, installed environments that embed the old absolute path are
+// removed so they are rebuilt on next use.
+func (s *Store) Import(archive string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.acquireLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("invalid store archive: %w", err)
+	}
+	defer gr.Close()
+
+	root, err := resolvedDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	var imported storeDB
+	var meta exportMeta
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid store archive: %w", err)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || !within(root, filepath.Join(root, name)) {
+			return fmt.Errorf("invalid path in store archive: %s", hdr.Name)
+		}
+
+		switch hdr.Name {
+		case "db.json":
+			if err := json.NewDecoder(tr).Decode(&imported); err != nil {
+				return fmt.Errorf("invalid store database in archive: %w", err)
+			}
+			continue
+		case exportMetaFile:
+			if err := json.NewDecoder(tr).Decode(&meta); err != nil {
+				return fmt.Errorf("invalid export metadata in archive: %w", err)
+			}
+			continue
+		}
+
+		if err := extractEntry(tr, hdr, root, filepath.Join(root, name)); err != nil {
+			return err
+		}
+	}
+
+	absDir, err := filepath.Abs(s.dir)
+	if err != nil {
+		return err
+	}
+
+	db, err := s.loadDB()
+	if err != nil {
+		return err
+	}
+	for _, entry := range imported.Repos {
+		rel := filepath.Clean(filepath.FromSlash(entry.Path))
+		if filepath.IsAbs(rel) || !within(root, filepath.Join(root, rel)) {
+			return fmt.Errorf("invalid repo path in store archive: %s", entry.Path)
+		}
+		entry.Path = filepath.Join(s.dir, rel)
+		if meta.StoreDir != "" && meta.StoreDir != absDir {
+			if err := removeStaleEnvironments(entry.Path, meta.StoreDir); err != nil {
+				return err
+			}
+		}
+
+		found := false
+		for _, existing := range db.Repos {
+			if existing.Repo == entry.Repo && existing.Rev == entry.Rev {
+				found = true
+				break
+			}
+		}
+		if !found {
+			db.Repos = append(db.Repos, entry)
+		}
+	}
+	s.cache = nil
+	return s.saveDB(db)
+}
+
+// removeStaleEnvironments deletes installed environments under repoDir that
+// reference oldDir, such as Python virtualenvs with absolute shebangs.
+func removeStaleEnvironments(repoDir, oldDir string) error {
+	entries, err := os.ReadDir(repoDir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		envPath := filepath.Join(repoDir, e.Name())
+		if _, err := os.Stat(filepath.Join(envPath, installStateFile)); err != nil {
+			continue
+		}
+		if treeReferences(envPath, oldDir) {
+			if err := os.RemoveAll(envPath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// treeReferences reports whether any regular file under root contains needle.
+func treeReferences(root, needle string) bool {
+	found := false
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || found {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 {
+			if target, err := os.Readlink(path); err == nil && strings.Contains(target, needle) {
+				found = true
+				return filepath.SkipAll
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err == nil && bytes.Contains(data, []byte(needle)) {
+			found = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return found
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// addTree adds base/rel and everything below it to the tarball, using paths
+// relative to base.
+func addTree(tw *tar.Writer, base, rel string) error {
+	return filepath.WalkDir(filepath.Join(base, rel), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(name)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+}
+
+// resolvedDir returns dir as an absolute path with symlinks resolved,
+// creating it if needed, so paths extracted below it can be checked
+// against it.
+func resolvedDir(dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(abs)
+}
+
+// within reports whether path is root or below it, lexically.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// extractEntry writes hdr to dest, which is below root. Symlinks may point
+// anywhere (a venv's bin/python links to the system interpreter), but
+// nothing is created or written through a symlink leading out of root, so a
+// crafted archive can't touch files outside the store.
+func extractEntry(tr *tar.Reader, hdr *tar.Header, root, dest string) error {
+	switch hdr.Typeflag {
+	case tar.TypeDir, tar.TypeSymlink, tar.TypeReg:
+	default:
+		return nil
+	}
+	parent := filepath.Dir(dest)
+	// Check the deepest existing ancestor before creating the rest, so no
+	// directory is made through a link leading out of the store.
+	existing := parent
+	for {
+		if _, err := os.Lstat(existing); err == nil || filepath.Dir(existing) == existing {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	realExisting, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	if !within(root, realExisting) {
+		return fmt.Errorf("invalid path in store archive: %s leads outside the store", hdr.Name)
+	}
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return err
+	}
+	realParent, err := filepath.EvalSymlinks(parent)
+	if err != nil {
+		return err
+	}
+	if !within(root, realParent) {
+		return fmt.Errorf("invalid path in store archive: %s leads outside the store", hdr.Name)
+	}
+	dest = filepath.Join(realParent, filepath.Base(dest))
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		if info, err := os.Lstat(dest); err == nil && !info.IsDir() {
+			return fmt.Errorf("invalid path in store archive: %s is not a directory", hdr.Name)
+		}
+		return os.MkdirAll(dest, 0o755)
+	case tar.TypeSymlink:
+		os.Remove(dest)
+		return os.Symlink(hdr.Linkname, dest)
+	default:
+		// Replace rather than open an existing file, which may be a link.
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		out, err := os.OpenFile(dest, os.O_CREATE|os.O_EXCL|os.O_WRONLY, os.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	}
+}
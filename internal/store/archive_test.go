@@ -0,0 +1,177 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	srcDir := t.TempDir()
+	src := New(srcDir)
+
+	repoDir := filepath.Join(srcDir, "repo1")
+	os.MkdirAll(filepath.Join(repoDir, "node_env"), 0o755)
+	os.MkdirAll(filepath.Join(repoDir, "py_env", "bin"), 0o755)
+	os.WriteFile(filepath.Join(repoDir, ".pre-commit-hooks.yaml"), []byte("- id: example\n"), 0o644)
+	os.WriteFile(filepath.Join(repoDir, "node_env", installStateFile), []byte("{}"), 0o644)
+	os.WriteFile(filepath.Join(repoDir, "node_env", "lib.js"), []byte("relocatable\n"), 0o644)
+	os.WriteFile(filepath.Join(repoDir, "py_env", installStateFile), []byte("{}"), 0o644)
+	os.WriteFile(filepath.Join(repoDir, "py_env", "bin", "hook"),
+		[]byte("#!"+filepath.Join(repoDir, "py_env", "bin", "python")+"\n"), 0o755)
+	if err := src.save("https://example.com/repo", "v1", repoDir); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "cache.tar.gz")
+	if err := src.Export(archive); err != nil {
+		t.Fatal(err)
+	}
+
+	dstDir := t.TempDir()
+	dst := New(dstDir)
+	if err := dst.Import(archive); err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(dstDir, "repo1")
+	if got := dst.GetPath("https://example.com/repo", "v1"); got != want {
+		t.Fatalf("GetPath = %q, want %q", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(want, ".pre-commit-hooks.yaml")); err != nil {
+		t.Errorf("expected repo contents to be imported: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(want, "node_env", "lib.js")); err != nil {
+		t.Errorf("expected relocatable environment to be kept: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(want, "py_env")); !os.IsNotExist(err) {
+		t.Error("expected environment referencing the old store path to be removed")
+	}
+
+	// Importing again does not duplicate database entries.
+	if err := dst.Import(archive); err != nil {
+		t.Fatal(err)
+	}
+	repos, err := dst.ListRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 {
+		t.Errorf("expected 1 repo after re-import, got %d", len(repos))
+	}
+}
+
+func TestExportImportKeepsSymlinksOutOfStore(t *testing.T) {
+	// A venv's bin/python3 links to the system interpreter.
+	interp := filepath.Join(t.TempDir(), "python3")
+	os.WriteFile(interp, []byte("#!/bin/sh\n"), 0o755)
+
+	srcDir := t.TempDir()
+	src := New(srcDir)
+	repoDir := filepath.Join(srcDir, "repo1")
+	binDir := filepath.Join(repoDir, "py_env-default", "bin")
+	os.MkdirAll(binDir, 0o755)
+	if err := os.Symlink(interp, filepath.Join(binDir, "python3")); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(repoDir, "zzz.txt"), []byte("after the link\n"), 0o644)
+	if err := src.save("https://example.com/repo", "v1", repoDir); err != nil {
+		t.Fatal(err)
+	}
+	archive := filepath.Join(t.TempDir(), "cache.tar.gz")
+	if err := src.Export(archive); err != nil {
+		t.Fatal(err)
+	}
+
+	dstDir := t.TempDir()
+	dst := New(dstDir)
+	if err := dst.Import(archive); err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join(dstDir, "repo1")
+	if got, err := os.Readlink(filepath.Join(want, "py_env-default", "bin", "python3")); err != nil || got != interp {
+		t.Errorf("Readlink = %q, %v, want %q", got, err, interp)
+	}
+	if _, err := os.Stat(filepath.Join(want, "zzz.txt")); err != nil {
+		t.Errorf("expected the rest of the repo to be imported: %v", err)
+	}
+	if got := dst.GetPath("https://example.com/repo", "v1"); got != want {
+		t.Errorf("GetPath = %q, want %q", got, want)
+	}
+}
+
+// writeArchive writes a gzipped tarball of hdrs, with content for regular
+// files, to a temp file.
+func writeArchive(t *testing.T, hdrs []*tar.Header, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cache.tar.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = int64(len(content))
+			hdr.Mode = 0o644
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			tw.Write([]byte(content))
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportRejectsEscapingEntries(t *testing.T) {
+	outside := t.TempDir()
+	tests := []struct {
+		name string
+		hdrs []*tar.Header
+	}{
+		{"dot-dot inside name", []*tar.Header{
+			{Name: "repo/../../../" + filepath.Base(outside) + "/pwned", Typeflag: tar.TypeReg},
+		}},
+		{"absolute symlink", []*tar.Header{
+			{Name: "repo/link", Typeflag: tar.TypeSymlink, Linkname: outside},
+			{Name: "repo/link/pwned", Typeflag: tar.TypeReg},
+		}},
+		{"relative symlink", []*tar.Header{
+			{Name: "repo/", Typeflag: tar.TypeDir},
+			{Name: "repo/link", Typeflag: tar.TypeSymlink, Linkname: "../../../../../../../../../../.." + outside},
+			{Name: "repo/link/pwned", Typeflag: tar.TypeReg},
+		}},
+		{"directory through symlink", []*tar.Header{
+			{Name: "repo/link", Typeflag: tar.TypeSymlink, Linkname: outside},
+			{Name: "repo/link/pwned/file", Typeflag: tar.TypeReg},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Nest the store so a "../.." escape lands next to outside.
+			storeDir := filepath.Join(filepath.Dir(outside), "store-"+strings.ReplaceAll(tt.name, " ", "-"))
+			t.Cleanup(func() { os.RemoveAll(storeDir) })
+			archive := writeArchive(t, tt.hdrs, "pwned\n")
+			err := New(filepath.Join(storeDir, "cache")).Import(archive)
+			if err == nil || !strings.Contains(err.Error(), "store archive") {
+				t.Errorf("Import = %v, want an invalid archive error", err)
+			}
+			if _, err := os.Stat(filepath.Join(outside, "pwned")); !os.IsNotExist(err) {
+				t.Errorf("archive wrote outside the store (stat err = %v)", err)
+			}
+		})
+	}
+}
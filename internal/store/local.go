@@ -0,0 +1,75 @@
+package store
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LocalRepo is the repo name under which environments for `repo: local`
+// hooks are recorded.
+const LocalRepo = "local"
+
+// localTemplate is a placeholder package for each language installer that
+// installs the hook repo itself, so a local hook's environment can be built
+// from its additional_dependencies alone.
+var localTemplate = map[string]string{
+	"setup.py":     "from setuptools import setup\n\nsetup(name='pre-commit-placeholder-package', version='0.0.0', py_modules=[])\n",
+	"package.json": `{"name": "pre-commit-placeholder-package", "version": "0.0.0"}` + "\n",
+	"go.mod":       "module pre-commit-placeholder-empty-module\n",
+	"main.go":      "package main\n\nfunc main() {}\n",
+}
+
+// LocalRev returns the rev local hooks with deps are recorded under. It
+// depends only on the set of deps, so hooks listing the same
+// additional_dependencies in any order share one directory.
+func LocalRev(deps []string) string {
+	sorted := slices.Clone(deps)
+	slices.Sort(sorted)
+	hash := sha256.Sum256([]byte(strings.Join(sorted, "\n")))
+	return fmt.Sprintf("deps-%x", hash[:8])
+}
+
+// MakeLocal returns the directory holding environments for local hooks with
+// the given additional_dependencies, creating it from a placeholder package
+// on first use. Hooks whose language, language_version and deps all match
+// therefore share a single environment.
+func (s *Store) MakeLocal(deps []string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rev := LocalRev(deps)
+	if path, err := s.lookup(LocalRepo, rev); err == nil {
+		return path, nil
+	}
+
+	unlock, err := s.acquireLock()
+	if err != nil {
+		return "", fmt.Errorf("failed to acquire store lock: %w", err)
+	}
+	defer unlock()
+
+	if path, err := s.lookup(LocalRepo, rev); err == nil {
+		return path, nil
+	}
+
+	hash := sha256.Sum256([]byte(LocalRepo + rev))
+	dest := filepath.Join(s.dir, fmt.Sprintf("repo%x", hash[:8]))
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return "", err
+	}
+	for name, content := range localTemplate {
+		if err := os.WriteFile(filepath.Join(dest, name), []byte(content), 0o644); err != nil {
+			os.RemoveAll(dest)
+			return "", err
+		}
+	}
+
+	if err := s.save(LocalRepo, rev, dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
@@ -0,0 +1,179 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/blairham/go-pre-commit/v4/internal/output"
+)
+
+// StaleLockAge is how long a process may hold the store lock before others
+// treat it as hung and reclaim the lock.
+var StaleLockAge = time.Hour
+
+// lockPollInterval is how often a process waiting on the store lock checks
+// whether the holder has gone stale.
+var lockPollInterval = 100 * time.Millisecond
+
+// LockHolder records which process holds the store lock.
+type LockHolder struct {
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+}
+
+// Stale reports whether the holder's process has exited or has held the lock
+// for longer than StaleLockAge.
+func (h *LockHolder) Stale() bool {
+	return !processAlive(h.PID) || h.hung()
+}
+
+// hung reports whether the holder is still running but has held the lock for
+// longer than StaleLockAge.
+func (h *LockHolder) hung() bool {
+	return processAlive(h.PID) && time.Since(h.Started) > StaleLockAge
+}
+
+func (s *Store) lockHolderPath() string {
+	return filepath.Join(s.dir, ".lock.holder")
+}
+
+// readLockHolder returns the recorded lock holder, or nil if none is.
+func (s *Store) readLockHolder() (*LockHolder, error) {
+	data, err := os.ReadFile(s.lockHolderPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var h LockHolder
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("corrupt lock holder file: %w", err)
+	}
+	return &h, nil
+}
+
+// acquireLock acquires file-level locking for concurrent process safety.
+// A lock whose holder is still running but has held it longer than
+// StaleLockAge is reclaimed. Returns an unlock function.
+func (s *Store) acquireLock() (func(), error) {
+	if err := s.Init(); err != nil {
+		return nil, err
+	}
+
+	for {
+		lf, err := os.OpenFile(s.lockPath(), os.O_CREATE|os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open lock file: %w", err)
+		}
+
+		// Use platform-specific file locking.
+		locked, err := tryLockFile(lf)
+		if err != nil {
+			lf.Close()
+			return nil, fmt.Errorf("failed to acquire lock: %w", err)
+		}
+		if locked {
+			// A reclaim may have replaced the lock file between opening and
+			// locking it, in which case this lock excludes nobody.
+			if cur, err := os.Stat(s.lockPath()); err == nil {
+				if fi, err := lf.Stat(); err == nil && os.SameFile(cur, fi) {
+					return s.holdLock(lf)
+				}
+			}
+			_ = unlockFile(lf)
+			lf.Close()
+			continue
+		}
+		lf.Close()
+
+		// The OS releases the lock of a process that exits, so a record naming
+		// a dead process was left by an earlier holder and the live one has
+		// yet to replace it. Only a live holder that hung is reclaimed.
+		holder, err := s.readLockHolder()
+		if err != nil {
+			return nil, err
+		}
+		if holder != nil && holder.hung() {
+			output.Warn("Reclaiming stale store lock held by pid %d since %s", holder.PID, holder.Started.Format(time.RFC3339))
+			if err := s.removeLock(); err != nil {
+				return nil, fmt.Errorf("failed to reclaim stale lock: %w", err)
+			}
+			continue
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// holdLock records this process as the holder of the locked file lf and
+// returns the function that releases it.
+func (s *Store) holdLock(lf *os.File) (func(), error) {
+	holder := LockHolder{PID: os.Getpid(), Started: time.Now()}
+	data, err := json.Marshal(holder)
+	if err == nil {
+		err = os.WriteFile(s.lockHolderPath(), data, 0o644)
+	}
+	if err != nil {
+		_ = unlockFile(lf)
+		lf.Close()
+		return nil, fmt.Errorf("failed to record lock holder: %w", err)
+	}
+
+	return func() {
+		// Leave the record alone if another process reclaimed the lock.
+		if cur, err := s.readLockHolder(); err == nil && cur != nil && cur.PID == holder.PID && cur.Started.Equal(holder.Started) {
+			_ = os.Remove(s.lockHolderPath())
+		}
+		_ = unlockFile(lf)
+		lf.Close()
+	}, nil
+}
+
+// removeLock deletes the lock file and its holder record.
+func (s *Store) removeLock() error {
+	for _, path := range []string{s.lockPath(), s.lockHolderPath()} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// LockStatus reports whether another process holds the store lock and, if it
+// recorded itself, which one.
+func (s *Store) LockStatus() (bool, *LockHolder, error) {
+	lf, err := os.OpenFile(s.lockPath(), os.O_RDWR, 0o644)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+	defer lf.Close()
+
+	locked, err := tryLockFile(lf)
+	if err != nil {
+		return false, nil, err
+	}
+	if locked {
+		_ = unlockFile(lf)
+		return false, nil, nil
+	}
+	holder, err := s.readLockHolder()
+	return true, holder, err
+}
+
+// ForceUnlock removes the store lock regardless of who holds it, returning
+// the recorded holder if there was one. A process still running under the
+// removed lock is no longer excluded from the store.
+func (s *Store) ForceUnlock() (*LockHolder, error) {
+	holder, _ := s.readLockHolder()
+	if err := s.removeLock(); err != nil {
+		return nil, err
+	}
+	return holder, nil
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// holdStoreLock locks the store's lock file through a separate descriptor,
+// as another process would, recording holder as its owner.
+func holdStoreLock(t *testing.T, s *Store, holder LockHolder) *os.File {
+	t.Helper()
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	lf, err := os.OpenFile(s.lockPath(), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { lf.Close() })
+	if ok, err := tryLockFile(lf); !ok || err != nil {
+		t.Fatalf("tryLockFile = %v, %v", ok, err)
+	}
+	data, _ := json.Marshal(holder)
+	if err := os.WriteFile(s.lockHolderPath(), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return lf
+}
+
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("go", "version")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot start a process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestAcquireLock_ReclaimsStaleLock(t *testing.T) {
+	s := New(t.TempDir())
+	holdStoreLock(t, s, LockHolder{PID: os.Getpid(), Started: time.Now().Add(-2 * StaleLockAge)})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		unlock, err := s.acquireLock()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		holder, _ := s.readLockHolder()
+		if holder == nil || holder.PID != os.Getpid() {
+			t.Errorf("holder = %+v, want this process", holder)
+		}
+		unlock()
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stale lock was not reclaimed")
+	}
+	if _, err := os.Stat(s.lockHolderPath()); !os.IsNotExist(err) {
+		t.Errorf("holder record not removed on unlock: %v", err)
+	}
+}
+
+// A holder that locked the file but has not yet replaced a dead process's
+// record must not have its lock taken away.
+func TestAcquireLock_KeepsLockWithDeadHolderRecord(t *testing.T) {
+	s := New(t.TempDir())
+	lf := holdStoreLock(t, s, LockHolder{PID: deadPID(t), Started: time.Now()})
+
+	acquired := make(chan func())
+	go func() {
+		unlock, err := s.acquireLock()
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- unlock
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("reclaimed a lock that is still held")
+	case <-time.After(500 * time.Millisecond):
+	}
+	if _, err := os.Stat(s.lockPath()); err != nil {
+		t.Fatalf("lock file removed while held: %v", err)
+	}
+
+	if err := unlockFile(lf); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case unlock := <-acquired:
+		if unlock != nil {
+			unlock()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock not acquired after the holder released it")
+	}
+}
+
+// A holder that died leaves its record behind, but the OS has released its
+// lock, so the next process acquires it straight away.
+func TestAcquireLock_AfterHolderDied(t *testing.T) {
+	s := New(t.TempDir())
+	lf := holdStoreLock(t, s, LockHolder{PID: deadPID(t), Started: time.Now()})
+	if err := unlockFile(lf); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := s.acquireLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	holder, _ := s.readLockHolder()
+	if holder == nil || holder.PID != os.Getpid() {
+		t.Errorf("holder = %+v, want this process", holder)
+	}
+	unlock()
+}
+
+func TestAcquireLock_RespectsActiveLock(t *testing.T) {
+	s := New(t.TempDir())
+	lf := holdStoreLock(t, s, LockHolder{PID: os.Getpid(), Started: time.Now()})
+
+	held, holder, err := s.LockStatus()
+	if err != nil || !held || holder == nil || holder.PID != os.Getpid() {
+		t.Fatalf("LockStatus = %v, %+v, %v", held, holder, err)
+	}
+
+	acquired := make(chan func())
+	go func() {
+		unlock, err := s.acquireLock()
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- unlock
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquired a lock held by a live process")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	if err := unlockFile(lf); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case unlock := <-acquired:
+		if unlock != nil {
+			unlock()
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("lock not acquired after the holder released it")
+	}
+	if held, _, _ := s.LockStatus(); held {
+		t.Error("LockStatus reports held after unlock")
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	s := New(t.TempDir())
+	holdStoreLock(t, s, LockHolder{PID: os.Getpid(), Started: time.Now()})
+
+	holder, err := s.ForceUnlock()
+	if err != nil || holder == nil || holder.PID != os.Getpid() {
+		t.Fatalf("ForceUnlock = %+v, %v", holder, err)
+	}
+	unlock, err := s.acquireLock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+}
@@ -15,24 +15,33 @@ var (
 )
 
 const (
-	lockfileExclusiveLock = 0x00000002
+	lockfileFailImmediately = 0x00000001
+	lockfileExclusiveLock   = 0x00000002
+
+	errorLockViolation = syscall.Errno(33)
+	stillActive        = 259
 )
 
-func lockFile(f *os.File) error {
+// tryLockFile takes an exclusive lock on f without blocking, reporting
+// whether it was acquired.
+func tryLockFile(f *os.File) (bool, error) {
 	h := syscall.Handle(f.Fd())
 	ol := new(syscall.Overlapped)
 	r1, _, err := procLockFileEx.Call(
 		uintptr(h),
-		uintptr(lockfileExclusiveLock),
+		uintptr(lockfileExclusiveLock|lockfileFailImmediately),
 		0,
 		1,
 		0,
 		uintptr(unsafe.Pointer(ol)),
 	)
 	if r1 == 0 {
-		return err
+		if err == errorLockViolation {
+			return false, nil
+		}
+		return false, err
 	}
-	return nil
+	return true, nil
 }
 
 func unlockFile(f *os.File) error {
@@ -50,3 +59,20 @@ func unlockFile(f *os.File) error {
 	}
 	return nil
 }
+
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Access denied still means the process exists.
+		return err == syscall.ERROR_ACCESS_DENIED
+	}
+	defer syscall.CloseHandle(h)
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
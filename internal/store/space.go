@@ -0,0 +1,94 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultMinFreeSpace is the free space environment builds should have
+// unless PRE_COMMIT_MIN_FREE_SPACE says otherwise.
+const DefaultMinFreeSpace = 1 << 30
+
+// FreeSpaceFunc reports the bytes available on the filesystem holding dir.
+type FreeSpaceFunc func(dir string) (uint64, error)
+
+// FreeSpace returns the bytes available to unprivileged users on the
+// filesystem holding dir, or its nearest existing ancestor if dir does not
+// exist yet.
+func FreeSpace(dir string) (uint64, error) {
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return freeSpace(dir)
+}
+
+// MinFreeSpace returns the free space environment builds require, from
+// PRE_COMMIT_MIN_FREE_SPACE if set. It takes a byte count with an optional
+// K, M or G suffix (e.g. 500M); 0 disables the check.
+func MinFreeSpace() (uint64, error) {
+	v := strings.TrimSpace(os.Getenv("PRE_COMMIT_MIN_FREE_SPACE"))
+	if v == "" {
+		return DefaultMinFreeSpace, nil
+	}
+	mult := uint64(1)
+	switch strings.ToUpper(v[len(v)-1:]) {
+	case "K":
+		mult = 1 << 10
+	case "M":
+		mult = 1 << 20
+	case "G":
+		mult = 1 << 30
+	}
+	if mult != 1 {
+		v = v[:len(v)-1]
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid PRE_COMMIT_MIN_FREE_SPACE %q (e.g. 500M, 2G)", os.Getenv("PRE_COMMIT_MIN_FREE_SPACE"))
+	}
+	return n * mult, nil
+}
+
+// LowSpaceError reports that the filesystem holding Dir has less free space
+// than environment builds require.
+type LowSpaceError struct {
+	Dir       string
+	Available uint64
+	Required  uint64
+}
+
+func (e *LowSpaceError) Error() string {
+	return fmt.Sprintf("only %d MiB free in %s, but building hook environments requires %d MiB "+
+		"(set PRE_COMMIT_MIN_FREE_SPACE to change this)", e.Available>>20, e.Dir, e.Required>>20)
+}
+
+// CheckFreeSpace returns a *LowSpaceError if the filesystem holding dir, as
+// measured by free, has less space than MinFreeSpace. A filesystem that
+// can't be measured passes.
+func CheckFreeSpace(dir string, free FreeSpaceFunc) error {
+	required, err := MinFreeSpace()
+	if err != nil {
+		return err
+	}
+	if required == 0 {
+		return nil
+	}
+	available, err := free(dir)
+	if err != nil {
+		return nil
+	}
+	if available < required {
+		return &LowSpaceError{Dir: dir, Available: available, Required: required}
+	}
+	return nil
+}
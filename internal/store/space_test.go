@@ -0,0 +1,58 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMinFreeSpace(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint64
+	}{
+		{"", DefaultMinFreeSpace},
+		{"0", 0},
+		{"4096", 4096},
+		{"500M", 500 << 20},
+		{"2g", 2 << 30},
+	}
+	for _, tt := range tests {
+		t.Setenv("PRE_COMMIT_MIN_FREE_SPACE", tt.value)
+		if got, err := MinFreeSpace(); err != nil || got != tt.want {
+			t.Errorf("MinFreeSpace() with %q = %d, %v; want %d", tt.value, got, err, tt.want)
+		}
+	}
+	t.Setenv("PRE_COMMIT_MIN_FREE_SPACE", "lots")
+	if _, err := MinFreeSpace(); err == nil {
+		t.Error("expected an error for an invalid size")
+	}
+}
+
+func TestCheckFreeSpace(t *testing.T) {
+	t.Setenv("PRE_COMMIT_MIN_FREE_SPACE", "1G")
+	low := func(string) (uint64, error) { return 10 << 20, nil }
+	err := CheckFreeSpace("/cache", low)
+	var lowErr *LowSpaceError
+	if !errors.As(err, &lowErr) {
+		t.Fatalf("expected a LowSpaceError, got %v", err)
+	}
+	if lowErr.Available != 10<<20 || lowErr.Required != 1<<30 {
+		t.Errorf("LowSpaceError = %+v", lowErr)
+	}
+
+	plenty := func(string) (uint64, error) { return 2 << 30, nil }
+	if err := CheckFreeSpace("/cache", plenty); err != nil {
+		t.Errorf("expected enough space, got %v", err)
+	}
+	failing := func(string) (uint64, error) { return 0, errors.New("statfs failed") }
+	if err := CheckFreeSpace("/cache", failing); err != nil {
+		t.Errorf("expected an unmeasurable disk to pass, got %v", err)
+	}
+}
+
+func TestFreeSpaceMissingDir(t *testing.T) {
+	if _, err := FreeSpace(filepath.Join(t.TempDir(), "not", "yet")); err != nil {
+		t.Errorf("FreeSpace() on a missing dir = %v, want its ancestor measured", err)
+	}
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package store
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = modkernel32.NewProc("GetDiskFreeSpaceExW")
+
+func freeSpace(dir string) (uint64, error) {
+	path, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	r1, _, err := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(path)),
+		uintptr(unsafe.Pointer(&available)),
+		0,
+		0,
+	)
+	if r1 == 0 {
+		return 0, err
+	}
+	return available, nil
+}
@@ -2,14 +2,19 @@
 package store
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 
+	"github.com/blairham/go-pre-commit/v4/internal/errkind"
 	gitutil "github.com/blairham/go-pre-commit/v4/internal/git"
+	"github.com/blairham/go-pre-commit/v4/internal/output"
 )
 
 // Store manages the cache of cloned hook repositories.
@@ -36,7 +41,7 @@ type storeDB struct {
 func DefaultDir() string {
 	// Check PRE_COMMIT_HOME first.
 	if home := os.Getenv("PRE_COMMIT_HOME"); home != "" {
-		return home
+		return expandPath(home)
 	}
 	// Check XDG_CACHE_HOME.
 	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
@@ -47,10 +52,27 @@ func DefaultDir() string {
 	return filepath.Join(home, ".cache", "pre-commit")
 }
 
+// expandPath expands a leading ~ and environment variables in path and
+// makes it absolute.
+func expandPath(path string) string {
+	path = os.ExpandEnv(path)
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[1:])
+		}
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return path
+}
+
 // New creates a new Store at the given directory.
 func New(dir string) *Store {
 	if dir == "" {
 		dir = DefaultDir()
+	} else {
+		dir = expandPath(dir)
 	}
 	return &Store{dir: dir}
 }
@@ -67,7 +89,36 @@ func (s *Store) Init() error {
 
 // Clean removes the entire store directory.
 func (s *Store) Clean() error {
-	return os.RemoveAll(s.dir)
+	_, err := s.CleanContext(context.Background(), nil)
+	return err
+}
+
+// ProgressFunc is called after each cached repo is removed with the number
+// removed so far and the total to remove.
+type ProgressFunc func(done, total int)
+
+// CleanContext removes the store directory one cached repo at a time so it
+// can be interrupted. Each repo is unregistered from the database before its
+// directory is deleted, so a cancelled clean never leaves entries pointing
+// at half-removed clones. It returns the number of repos removed; on
+// cancellation it stops after the current deletion and returns ctx.Err().
+// A database that can't be read is no reason to keep a broken cache, so the
+// whole directory is then removed at once.
+func (s *Store) CleanContext(ctx context.Context, progress ProgressFunc) (int, error) {
+	if _, err := os.Stat(s.dir); os.IsNotExist(err) {
+		return 0, nil
+	}
+	repos, err := s.ListRepos()
+	if err != nil {
+		output.Warn("Could not read the store database (%v); removing the whole cache.", err)
+		return 0, os.RemoveAll(s.dir)
+	}
+
+	removed, err := s.removeRepos(ctx, repos, progress)
+	if err != nil {
+		return removed, err
+	}
+	return removed, os.RemoveAll(s.dir)
 }
 
 // Clone clones a hook repository and returns the local path.
@@ -108,11 +159,12 @@ func (s *Store) Clone(repo, rev string) (string, error) {
 		os.RemoveAll(dest)
 		err = gitutil.Clone(repo, dest)
 		if err != nil {
-			return "", fmt.Errorf("failed to clone %s: %w", repo, err)
+			return "", errkind.Wrap(errkind.Network, fmt.Errorf("failed to clone %s: %w", repo, err))
 		}
 		if err := gitutil.Checkout(dest, rev); err != nil {
 			os.RemoveAll(dest)
-			return "", fmt.Errorf("failed to checkout %s at %s: %w", repo, rev, err)
+			// The clone worked, so the rev named in the config is missing.
+			return "", errkind.Wrap(errkind.Config, fmt.Errorf("failed to checkout %s at %s: %w", repo, rev, err))
 		}
 	}
 
@@ -168,6 +220,58 @@ func (s *Store) MarkConfigUsed(configPath string) error {
 
 // GC garbage-collects unused repos.
 func (s *Store) GC(usedRepos map[string]bool) error {
+	_, err := s.GCContext(context.Background(), usedRepos, nil)
+	return err
+}
+
+// GCContext garbage-collects unused repos like GC, but can be interrupted
+// between deletions. It returns the number of repos removed.
+func (s *Store) GCContext(ctx context.Context, usedRepos map[string]bool, progress ProgressFunc) (int, error) {
+	unused, err := s.unusedRepos(usedRepos)
+	if err != nil {
+		return 0, err
+	}
+	return s.removeRepos(ctx, unused, progress)
+}
+
+// unusedRepos returns the cached repos whose repo@rev is not in usedRepos,
+// which GC removes.
+func (s *Store) unusedRepos(usedRepos map[string]bool) ([]RepoEntry, error) {
+	repos, err := s.ListRepos()
+	if err != nil {
+		return nil, err
+	}
+	var unused []RepoEntry
+	for _, entry := range repos {
+		if !usedRepos[entry.Repo+"@"+entry.Rev] {
+			unused = append(unused, entry)
+		}
+	}
+	return unused, nil
+}
+
+// removeRepos unregisters and deletes each repo in turn, checking ctx
+// between deletions.
+func (s *Store) removeRepos(ctx context.Context, repos []RepoEntry, progress ProgressFunc) (int, error) {
+	for i, entry := range repos {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := s.unregister(entry); err != nil {
+			return i, err
+		}
+		if err := os.RemoveAll(entry.Path); err != nil {
+			return i, err
+		}
+		if progress != nil {
+			progress(i+1, len(repos))
+		}
+	}
+	return len(repos), nil
+}
+
+// unregister removes entry from the database.
+func (s *Store) unregister(entry RepoEntry) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -181,21 +285,81 @@ func (s *Store) GC(usedRepos map[string]bool) error {
 	if err != nil {
 		return err
 	}
-
 	var kept []RepoEntry
-	for _, entry := range db.Repos {
-		key := entry.Repo + "@" + entry.Rev
-		if usedRepos[key] {
-			kept = append(kept, entry)
-		} else {
-			// Remove the directory.
-			os.RemoveAll(entry.Path)
+	for _, e := range db.Repos {
+		if e != entry {
+			kept = append(kept, e)
 		}
 	}
 	db.Repos = kept
+	delete(s.cache, s.cacheKey(entry.Repo, entry.Rev))
 	return s.saveDB(db)
 }
 
+// Vacuum rewrites the database in canonical form: duplicate repo@rev
+// entries and entries whose clone no longer exists are dropped, tracked
+// configs are deduplicated and pruned of missing files, and both lists are
+// sorted so the file diffs stably. Clones and environments on disk are left
+// alone. It returns the number of entries removed.
+func (s *Store) Vacuum() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	unlock, err := s.acquireLock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	db, err := s.loadDB()
+	if err != nil {
+		return 0, err
+	}
+	before := len(db.Repos) + len(db.ConfigsUsed)
+
+	seen := make(map[string]bool)
+	var repos []RepoEntry
+	for _, e := range db.Repos {
+		key := s.cacheKey(e.Repo, e.Rev)
+		if seen[key] {
+			continue
+		}
+		if _, err := os.Stat(e.Path); err != nil {
+			continue
+		}
+		seen[key] = true
+		repos = append(repos, e)
+	}
+	sort.Slice(repos, func(i, j int) bool {
+		if repos[i].Repo != repos[j].Repo {
+			return repos[i].Repo < repos[j].Repo
+		}
+		return repos[i].Rev < repos[j].Rev
+	})
+
+	seenConfigs := make(map[string]bool)
+	var configs []string
+	for _, c := range db.ConfigsUsed {
+		if seenConfigs[c] {
+			continue
+		}
+		if _, err := os.Stat(c); err != nil {
+			continue
+		}
+		seenConfigs[c] = true
+		configs = append(configs, c)
+	}
+	sort.Strings(configs)
+
+	db.Repos = repos
+	db.ConfigsUsed = configs
+	s.cache = nil
+	if err := s.saveDB(db); err != nil {
+		return 0, err
+	}
+	return before - len(repos) - len(configs), nil
+}
+
 // ListRepos returns all cached repos.
 func (s *Store) ListRepos() ([]RepoEntry, error) {
 	db, err := s.loadDB()
@@ -302,27 +466,3 @@ func (s *Store) save(repo, rev, path string) error {
 	s.cache[s.cacheKey(repo, rev)] = path
 	return nil
 }
-
-// acquireLock acquires file-level locking for concurrent process safety.
-// Returns an unlock function.
-func (s *Store) acquireLock() (func(), error) {
-	if err := s.Init(); err != nil {
-		return nil, err
-	}
-
-	lf, err := os.OpenFile(s.lockPath(), os.O_CREATE|os.O_RDWR, 0o644)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open lock file: %w", err)
-	}
-
-	// Use platform-specific file locking.
-	if err := lockFile(lf); err != nil {
-		lf.Close()
-		return nil, fmt.Errorf("failed to acquire lock: %w", err)
-	}
-
-	return func() {
-		_ = unlockFile(lf)
-		lf.Close()
-	}, nil
-}
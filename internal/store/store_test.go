@@ -1,7 +1,9 @@
 package store
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -31,6 +33,35 @@ func TestDefaultDirPreCommitHome(t *testing.T) {
 	}
 }
 
+func TestDefaultDirPreCommitHomeExpandsTilde(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("PRE_COMMIT_HOME", "~/foo")
+	want := filepath.Join(home, "foo")
+	if got := DefaultDir(); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestDefaultDirPreCommitHomeExpandsEnv(t *testing.T) {
+	base := t.TempDir()
+	t.Setenv("MY_CACHE_ROOT", base)
+	t.Setenv("PRE_COMMIT_HOME", "$MY_CACHE_ROOT/pre-commit")
+	want := filepath.Join(base, "pre-commit")
+	if got := DefaultDir(); got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestNewExpandsDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s := New("~/store")
+	if want := filepath.Join(home, "store"); s.Dir() != want {
+		t.Fatalf("expected %s, got %s", want, s.Dir())
+	}
+}
+
 func TestDefaultDirXDGCacheHome(t *testing.T) {
 	t.Setenv("PRE_COMMIT_HOME", "")
 	t.Setenv("XDG_CACHE_HOME", "/xdg/cache")
@@ -86,6 +117,23 @@ func TestCleanRemovesDirectory(t *testing.T) {
 	}
 }
 
+func TestCleanRemovesDirectoryWithCorruptDB(t *testing.T) {
+	storeDir := filepath.Join(t.TempDir(), "store")
+	s := New(storeDir)
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(storeDir, "db.json"), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Clean(); err != nil {
+		t.Fatalf("Clean() with a corrupt db.json: %v", err)
+	}
+	if _, err := os.Stat(storeDir); !os.IsNotExist(err) {
+		t.Fatal("expected store dir to be removed")
+	}
+}
+
 func TestGetPathUnknownRepo(t *testing.T) {
 	dir := t.TempDir()
 	s := New(dir)
@@ -265,3 +313,134 @@ func TestDBPersistence(t *testing.T) {
 		t.Fatalf("unexpected configs: %v", configs)
 	}
 }
+
+func TestCleanContextCancelledMidClean(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+
+	var entries []RepoEntry
+	for _, name := range []string{"repo1", "repo2", "repo3"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(path, 0o755)
+		os.WriteFile(filepath.Join(path, "file.txt"), []byte("x"), 0o644)
+		entry := RepoEntry{Repo: "https://example.com/" + name, Rev: "v1", Path: path}
+		if err := s.save(entry.Repo, entry.Rev, entry.Path); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, entry)
+	}
+
+	// Cancel as soon as the first repo has been removed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var calls int
+	removed, err := s.CleanContext(ctx, func(done, total int) {
+		calls++
+		if total != 3 {
+			t.Errorf("total = %d, want 3", total)
+		}
+		cancel()
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if removed != 1 || calls != 1 {
+		t.Fatalf("removed = %d, progress calls = %d, want 1 and 1", removed, calls)
+	}
+
+	// The store is still consistent: every registered repo exists on disk and
+	// the removed repo is no longer registered.
+	repos, err := New(dir).ListRepos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 2 {
+		t.Fatalf("expected 2 repos left registered, got %d", len(repos))
+	}
+	for _, r := range repos {
+		if r == entries[0] {
+			t.Errorf("removed repo %s is still registered", r.Repo)
+		}
+		if _, err := os.Stat(r.Path); err != nil {
+			t.Errorf("registered repo %s missing on disk: %v", r.Repo, err)
+		}
+	}
+	if _, err := os.Stat(entries[0].Path); !os.IsNotExist(err) {
+		t.Error("expected first repo directory to be removed")
+	}
+
+	// A later uninterrupted clean finishes the job.
+	if _, err := s.CleanContext(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Error("expected store directory to be removed")
+	}
+}
+
+func TestVacuum(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+	if err := s.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	repoA := filepath.Join(dir, "repoa")
+	repoB := filepath.Join(dir, "repob")
+	os.MkdirAll(repoA, 0o755)
+	os.MkdirAll(repoB, 0o755)
+	cfg := filepath.Join(t.TempDir(), ".pre-commit-config.yaml")
+	os.WriteFile(cfg, []byte("repos: []\n"), 0o644)
+
+	db := storeDB{
+		Repos: []RepoEntry{
+			{Repo: "https://example.com/zeta", Rev: "v2", Path: repoB},
+			{Repo: "https://example.com/alpha", Rev: "v1", Path: repoA},
+			{Repo: "https://example.com/zeta", Rev: "v2", Path: repoB},
+			{Repo: "https://example.com/gone", Rev: "v1", Path: filepath.Join(dir, "missing")},
+		},
+		ConfigsUsed: []string{cfg, "/does/not/exist.yaml", cfg},
+	}
+	data, _ := json.MarshalIndent(db, "", "  ")
+	if err := os.WriteFile(s.dbPath(), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sizeBefore := len(data)
+
+	removed, err := s.Vacuum()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 4 {
+		t.Errorf("removed = %d, want 4", removed)
+	}
+
+	got, err := os.ReadFile(s.dbPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) >= sizeBefore {
+		t.Errorf("vacuumed db is %d bytes, expected smaller than %d", len(got), sizeBefore)
+	}
+	want, _ := json.MarshalIndent(storeDB{
+		Repos: []RepoEntry{
+			{Repo: "https://example.com/alpha", Rev: "v1", Path: repoA},
+			{Repo: "https://example.com/zeta", Rev: "v2", Path: repoB},
+		},
+		ConfigsUsed: []string{cfg},
+	}, "", "  ")
+	if string(got) != string(want) {
+		t.Errorf("vacuumed db:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Clones on disk are untouched, and vacuuming again is a no-op.
+	for _, p := range []string{repoA, repoB} {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("clone %s removed: %v", p, err)
+		}
+	}
+	if removed, err := s.Vacuum(); err != nil || removed != 0 {
+		t.Errorf("second Vacuum() = %d, %v; want 0, nil", removed, err)
+	}
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NpmCacheDir is the store subdirectory holding npm's download cache, shared
+// by all node environments.
+const NpmCacheDir = "npm-cache"
+
+// RepoInfo describes a cached repo and its size on disk, including any
+// environments installed inside it.
+type RepoInfo struct {
+	RepoEntry
+	Size int64 `json:"size"`
+}
+
+// Usage is a breakdown of the store's disk usage in bytes.
+type Usage struct {
+	Repos        int64 `json:"repos"`
+	Environments int64 `json:"environments"`
+	Caches       int64 `json:"caches"`
+	Other        int64 `json:"other"`
+	Total        int64 `json:"total"`
+}
+
+// ListRepoInfo returns the cached repos along with their sizes.
+func (s *Store) ListRepoInfo() ([]RepoInfo, error) {
+	repos, err := s.ListRepos()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]RepoInfo, 0, len(repos))
+	for _, r := range repos {
+		infos = append(infos, RepoInfo{RepoEntry: r, Size: dirSize(r.Path)})
+	}
+	return infos, nil
+}
+
+// UnusedRepoInfo returns the repos GC would remove for usedRepos, with
+// their sizes, without removing anything. Each size includes the
+// environments installed in the repo, which go with it.
+func (s *Store) UnusedRepoInfo(usedRepos map[string]bool) ([]RepoInfo, error) {
+	unused, err := s.unusedRepos(usedRepos)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]RepoInfo, 0, len(unused))
+	for _, r := range unused {
+		infos = append(infos, RepoInfo{RepoEntry: r, Size: dirSize(r.Path)})
+	}
+	return infos, nil
+}
+
+// DiskUsage reports how the store's disk usage splits between repo clones,
+// the environments installed in them, shared caches and everything else.
+// envDirs are the environment directory names used by the languages; a
+// repo subdirectory named after one (optionally with a "-<version>" suffix)
+// counts as an environment.
+func (s *Store) DiskUsage(envDirs []string) (Usage, error) {
+	var u Usage
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return u, nil
+		}
+		return u, err
+	}
+
+	repos, err := s.ListRepos()
+	if err != nil {
+		return u, err
+	}
+	repoPaths := make(map[string]bool, len(repos))
+	for _, r := range repos {
+		repoPaths[filepath.Clean(r.Path)] = true
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(s.dir, e.Name())
+		switch {
+		case e.Name() == NpmCacheDir:
+			u.Caches += dirSize(path)
+		case repoPaths[path]:
+			children, _ := os.ReadDir(path)
+			for _, c := range children {
+				size := dirSize(filepath.Join(path, c.Name()))
+				if c.IsDir() && isEnvDir(c.Name(), envDirs) {
+					u.Environments += size
+				} else {
+					u.Repos += size
+				}
+			}
+		default:
+			u.Other += dirSize(path)
+		}
+	}
+	u.Total = u.Repos + u.Environments + u.Caches + u.Other
+	return u, nil
+}
+
+func isEnvDir(name string, envDirs []string) bool {
+	for _, d := range envDirs {
+		if name == d || strings.HasPrefix(name, d+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// dirSize returns the total size of the regular files under path.
+// Symlinks are neither followed nor counted, so links out of path or back
+// into it can't inflate the total or loop.
+func dirSize(path string) int64 {
+	var size int64
+	_ = filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskUsageCategories(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+
+	repoDir := filepath.Join(dir, "repo1")
+	os.MkdirAll(filepath.Join(repoDir, "py_env-python3"), 0o755)
+	os.WriteFile(filepath.Join(repoDir, "setup.py"), make([]byte, 100), 0o644)
+	os.WriteFile(filepath.Join(repoDir, "py_env-python3", "lib.py"), make([]byte, 1000), 0o644)
+	if err := s.save("https://example.com/repo", "v1", repoDir); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Join(dir, NpmCacheDir), 0o755)
+	os.WriteFile(filepath.Join(dir, NpmCacheDir, "blob"), make([]byte, 10), 0o644)
+
+	u, err := s.DiskUsage([]string{"py_env", "node_env"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Repos != 100 {
+		t.Errorf("Repos = %d, want 100", u.Repos)
+	}
+	if u.Environments != 1000 {
+		t.Errorf("Environments = %d, want 1000", u.Environments)
+	}
+	if u.Caches != 10 {
+		t.Errorf("Caches = %d, want 10", u.Caches)
+	}
+	// db.json is the only other file.
+	if u.Other == 0 || u.Total != u.Repos+u.Environments+u.Caches+u.Other {
+		t.Errorf("unexpected totals: %+v", u)
+	}
+}
+
+func TestDiskUsageMissingStore(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "missing"))
+	u, err := s.DiskUsage(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Total != 0 {
+		t.Errorf("expected zero usage, got %+v", u)
+	}
+}
+
+func TestUnusedRepoInfo(t *testing.T) {
+	dir := t.TempDir()
+	s := New(dir)
+
+	used, unused := filepath.Join(dir, "used"), filepath.Join(dir, "unused")
+	os.MkdirAll(used, 0o755)
+	os.MkdirAll(filepath.Join(unused, "py_env-python3"), 0o755)
+	os.WriteFile(filepath.Join(used, "a"), make([]byte, 50), 0o644)
+	os.WriteFile(filepath.Join(unused, "setup.py"), make([]byte, 100), 0o644)
+	os.WriteFile(filepath.Join(unused, "py_env-python3", "lib.py"), make([]byte, 1000), 0o644)
+	// Links back into the repo and out to another must neither loop nor
+	// count.
+	if err := os.Symlink(unused, filepath.Join(unused, "py_env-python3", "loop")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(used, filepath.Join(unused, "other")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save("https://example.com/used", "v1", used); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.save("https://example.com/unused", "v1", unused); err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := s.UnusedRepoInfo(map[string]bool{"https://example.com/used@v1": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Path != unused || infos[0].Size != 1100 {
+		t.Fatalf("UnusedRepoInfo = %+v, want only %s with 1100 bytes", infos, unused)
+	}
+	if _, err := os.Stat(unused); err != nil {
+		t.Errorf("expected nothing to be removed: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package runner runs pre-commit hooks from Go, for tools that embed
+// pre-commit rather than shelling out to the binary. It loads the config,
+// resolves and installs hook environments, and runs hooks exactly as the
+// run command does.
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/blairham/go-pre-commit/v4/internal/config"
+	"github.com/blairham/go-pre-commit/v4/internal/git"
+	"github.com/blairham/go-pre-commit/v4/internal/hook"
+	"github.com/blairham/go-pre-commit/v4/internal/output"
+	"github.com/blairham/go-pre-commit/v4/internal/repository"
+	"github.com/blairham/go-pre-commit/v4/internal/store"
+)
+
+// Options selects what Run runs. It operates on the git repository
+// containing the current working directory.
+type Options struct {
+	// ConfigPath is the config file; "" means .pre-commit-config.yaml.
+	ConfigPath string
+
+	// Stages are the hook stages to run, e.g. "pre-commit" or "pre-push",
+	// one after the other; none means pre-commit. Legacy names such as
+	// "commit" are accepted; an unknown stage is an error.
+	Stages []string
+
+	// Files are the files to run hooks on, relative to the repository
+	// root. With none, hooks run on the staged files. Unstaged changes are
+	// not stashed, so hooks see the working tree.
+	Files []string
+
+	// AllFiles runs hooks on every tracked file instead of Files.
+	AllFiles bool
+
+	// Output receives the status lines, messages and hook output the run
+	// command would print; nil discards them. Nothing is written to the
+	// process's stdout or stderr.
+	Output io.Writer
+}
+
+// Results is the outcome of a Run.
+type Results struct {
+	Passed  int
+	Failed  int
+	Skipped int
+	Errors  int
+
+	// Hooks holds one result per hook selected for each stage, in run
+	// order.
+	Hooks []HookResult
+}
+
+// OK reports whether no hook failed or errored.
+func (r *Results) OK() bool {
+	return r.Failed == 0 && r.Errors == 0
+}
+
+// HookResult is the outcome of a single hook.
+type HookResult struct {
+	ID    string
+	Name  string
+	Stage string
+
+	// Status is "passed", "failed", "skipped" or "error".
+	Status string
+
+	// ExitCode is the hook's exit code; 0 if it was skipped and -1 if it
+	// could not be run.
+	ExitCode int
+
+	Duration time.Duration
+	Files    []string
+
+	// Output is the hook's combined stdout and stderr, or the error that
+	// kept it from running.
+	Output string
+}
+
+// Run runs the configured hooks. Hook failures are reported in Results,
+// not as an error; the error is for a run that could not be carried out,
+// such as an invalid config or an environment that failed to install.
+// Cancelling ctx stops running hooks and returns the results so far with
+// ctx's cause.
+func Run(ctx context.Context, opts Options) (*Results, error) {
+	out := opts.Output
+	if out == nil {
+		out = io.Discard
+	}
+	ctx = output.WithMessages(ctx, out)
+
+	stages := []config.Stage{config.Stage(config.HookTypePreCommit)}
+	if len(opts.Stages) > 0 {
+		stages = make([]config.Stage, len(opts.Stages))
+		for i, s := range opts.Stages {
+			stage, err := config.ParseStage(s)
+			if err != nil {
+				return nil, err
+			}
+			stages[i] = stage
+		}
+	}
+
+	configPath := opts.ConfigPath
+	if configPath == "" {
+		configPath = config.ConfigFile
+	}
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	root, err := git.GetRoot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get git root: %w", err)
+	}
+
+	hooks, err := repository.NewResolver(store.New(""), cfg).ResolveAll(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve hooks: %w", err)
+	}
+	// A hook whose language runtime is missing is reported as an error in
+	// Results; any other install failure stops the run.
+	missingRuntimes, failed := hook.InstallEnvironmentsSummary(ctx, hooks).MissingRuntimes()
+	if len(failed) > 0 {
+		return nil, failed[0].Err
+	}
+
+	files := opts.Files
+	switch {
+	case opts.AllFiles:
+		files, err = git.GetAllFiles()
+	case len(files) == 0:
+		files, err = git.GetStagedFiles()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %w", err)
+	}
+
+	results := &Results{}
+	runner := hook.NewRunner(cfg, hooks, root)
+	for _, stage := range stages {
+		res := runner.Run(ctx, hook.RunOptions{
+			HookStage:       stage,
+			Files:           files,
+			AllFiles:        opts.AllFiles,
+			Output:          out,
+			CollectReports:  true,
+			MissingRuntimes: missingRuntimes,
+		})
+		results.Passed += res.Passed
+		results.Failed += res.Failed
+		results.Skipped += res.Skipped
+		results.Errors += res.Errors
+		for _, r := range res.Hooks {
+			results.Hooks = append(results.Hooks, HookResult{
+				ID:       r.ID,
+				Name:     r.Name,
+				Stage:    string(stage),
+				Status:   r.Status,
+				ExitCode: r.ExitCode,
+				Duration: time.Duration(r.DurationMs) * time.Millisecond,
+				Files:    r.Files,
+				Output:   r.Output,
+			})
+		}
+		if ctx.Err() != nil {
+			return results, context.Cause(ctx)
+		}
+	}
+	return results, nil
+}
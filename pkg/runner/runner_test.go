@@ -0,0 +1,172 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blairham/go-pre-commit/v4/internal/languages"
+)
+
+func setupRepo(t *testing.T, cfg string) string {
+	t.Helper()
+	t.Setenv("PRE_COMMIT_HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".pre-commit-config.yaml"), []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-b", "main"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	t.Chdir(dir)
+	return dir
+}
+
+func TestRun(t *testing.T) {
+	setupRepo(t, "repos:\n-   repo: local\n    hooks:\n"+
+		"    -   id: ok\n        name: ok\n        entry: sh -c 'echo fine' --\n        language: system\n        stages: [pre-commit]\n"+
+		"    -   id: bad\n        name: bad\n        entry: sh -c 'echo broken; exit 3' --\n        language: system\n        stages: [pre-commit]\n"+
+		"    -   id: push\n        name: push\n        entry: 'true'\n        language: system\n        stages: [pre-push]\n")
+
+	var out strings.Builder
+	res, err := Run(context.Background(), Options{
+		Stages: []string{"pre-commit", "pre-push"},
+		Files:  []string{"a.txt"},
+		Output: &out,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK() || res.Passed != 2 || res.Failed != 1 {
+		t.Errorf("results = %+v, want 2 passed and 1 failed", res)
+	}
+	var got []string
+	for _, h := range res.Hooks {
+		got = append(got, h.Stage+":"+h.ID+":"+h.Status)
+	}
+	if want := []string{"pre-commit:ok:passed", "pre-commit:bad:failed", "pre-push:push:passed"}; !slices.Equal(got, want) {
+		t.Errorf("hooks = %v, want %v", got, want)
+	}
+	if bad := res.Hooks[1]; bad.ExitCode != 3 || bad.Output != "broken\n" || !slices.Equal(bad.Files, []string{"a.txt"}) {
+		t.Errorf("bad hook = %+v", bad)
+	}
+	if !strings.Contains(out.String(), "broken") {
+		t.Errorf("expected hook output on Output, got:\n%s", out.String())
+	}
+}
+
+func TestRun_Cancel(t *testing.T) {
+	setupRepo(t, "repos:\n-   repo: local\n    hooks:\n"+
+		"    -   id: slow\n        name: slow\n        entry: sleep 30\n        language: system\n        pass_filenames: false\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := Run(ctx, Options{AllFiles: true})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Run took %s after the context expired", elapsed)
+	}
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	setupRepo(t, "repos: [")
+	if _, err := Run(context.Background(), Options{}); err == nil || !strings.Contains(err.Error(), "failed to load config") {
+		t.Errorf("err = %v, want a config error", err)
+	}
+}
+
+func TestRun_Stages(t *testing.T) {
+	setupRepo(t, "repos:\n-   repo: local\n    hooks:\n"+
+		"    -   id: ok\n        name: ok\n        entry: 'true'\n        language: system\n        stages: [pre-commit]\n")
+
+	res, err := Run(context.Background(), Options{Stages: []string{"commit"}, Files: []string{"a.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Hooks) != 1 || res.Hooks[0].Stage != "pre-commit" {
+		t.Errorf("legacy stage \"commit\" ran %+v, want the pre-commit hook", res.Hooks)
+	}
+
+	if _, err := Run(context.Background(), Options{Stages: []string{"pre-comit"}}); err == nil || !strings.Contains(err.Error(), `unknown stage "pre-comit"`) {
+		t.Errorf("err = %v, want an unknown stage error", err)
+	}
+}
+
+// quietLanguage installs an empty environment, so installs run offline.
+type quietLanguage struct{}
+
+func (quietLanguage) Name() string                  { return "runnertest" }
+func (quietLanguage) EnvironmentDir() string        { return "runnertest_env" }
+func (quietLanguage) GetDefaultVersion() string     { return "default" }
+func (quietLanguage) HealthCheck(_, _ string) error { return nil }
+func (quietLanguage) InstallEnvironment(prefix, version string, _ []string) error {
+	return os.MkdirAll(filepath.Join(prefix, "runnertest_env-"+version), 0o755)
+}
+
+func (quietLanguage) Run(context.Context, string, string, string, []string, []string, string) (int, []byte, error) {
+	return 0, nil, nil
+}
+
+// captureStdio redirects os.Stdout and os.Stderr while fn runs and returns
+// what was written to either.
+func captureStdio(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldOut, oldErr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = w, w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	os.Stdout, os.Stderr = oldOut, oldErr
+	return <-done
+}
+
+func TestRun_WritesNothingToStdio(t *testing.T) {
+	languages.Register("runnertest", quietLanguage{})
+	t.Cleanup(func() { languages.Unregister("runnertest") })
+	setupRepo(t, "repos:\n-   repo: local\n    hooks:\n"+
+		"    -   id: env\n        name: env\n        entry: env\n        language: runnertest\n")
+
+	var out strings.Builder
+	var err error
+	stdio := captureStdio(t, func() {
+		_, err = Run(context.Background(), Options{Files: []string{"a.txt"}, Output: &out})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdio != "" {
+		t.Errorf("Run wrote to stdout or stderr:\n%s", stdio)
+	}
+	if !strings.Contains(out.String(), "Installing environment for") {
+		t.Errorf("expected install messages on Output, got:\n%s", out.String())
+	}
+}